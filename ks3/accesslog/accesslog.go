@@ -0,0 +1,183 @@
+// Package accesslog parses the access logs of a bucket, which are delivered to the target bucket and prefix
+// of Client.SetBucketLogging, and aggregates them.
+//
+// A log line has the fields separated by spaces, the time is in brackets, and the fields with spaces are quoted.
+// The missing fields are -.
+//
+//	owner bucket [02/Jan/2024:15:04:05 +0800] 10.0.0.1 requester request-id REST.GET.OBJECT key
+//	"GET /key HTTP/1.1" 200 - 1024 1024 12 10 "referer" "user agent"
+//
+// Read the logs of a time range and aggregate them:
+//
+//	scanner := accesslog.NewScanner(targetBucket, "logs/")
+//	scanner.Start, scanner.End = start, end
+//	stats := accesslog.NewStats()
+//	err := scanner.Each(func(r accesslog.Record) error {
+//		stats.Add(r)
+//		return nil
+//	})
+//	top := stats.TopKeys(10)
+//	// scanner.Malformed lists the lines which couldn't be parsed
+package accesslog
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeLayout is the layout of the time of the log lines
+const TimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// fieldCount is the number of the fields of a log line, the fields after them are ignored
+const fieldCount = 17
+
+// Record is a parsed log line
+type Record struct {
+	BucketOwner    string
+	Bucket         string
+	Time           time.Time
+	RemoteIP       string
+	Requester      string
+	RequestID      string
+	Operation      string // Such as REST.GET.OBJECT
+	Key            string // The decoded object key, empty for the bucket operations
+	RequestURI     string // Such as GET /key HTTP/1.1
+	Status         int
+	ErrorCode      string
+	BytesSent      int64
+	ObjectSize     int64
+	TotalTime      time.Duration
+	TurnAroundTime time.Duration
+	Referer        string
+	UserAgent      string
+}
+
+// IsError reports whether the request failed, whose status is 4xx or 5xx
+func (r Record) IsError() bool {
+	return r.Status >= 400
+}
+
+// ParseError is a malformed log line
+type ParseError struct {
+	Object string // The log object, or the name passed to Parse
+	Line   int    // The line number from 1
+	Text   string // The line
+	Err    error
+}
+
+// Error implements interface error
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Object, e.Line, e.Err.Error())
+}
+
+// ParseLine parses the log line
+func ParseLine(line string) (Record, error) {
+	var record Record
+	fields, err := splitFields(line)
+	if err != nil {
+		return record, err
+	}
+	if len(fields) < fieldCount {
+		return record, fmt.Errorf("the line has %d fields, at least %d are expected", len(fields), fieldCount)
+	}
+
+	record.BucketOwner = fields[0]
+	record.Bucket = fields[1]
+	if record.Time, err = time.Parse(TimeLayout, fields[2]); err != nil {
+		return record, fmt.Errorf("invalid time %q", fields[2])
+	}
+	record.RemoteIP = fields[3]
+	record.Requester = fields[4]
+	record.RequestID = fields[5]
+	record.Operation = fields[6]
+	record.Key = fields[7]
+	if decoded, err := url.PathUnescape(fields[7]); err == nil {
+		record.Key = decoded
+	}
+	record.RequestURI = fields[8]
+	if record.Status, err = strconv.Atoi(fields[9]); err != nil {
+		return record, fmt.Errorf("invalid status %q", fields[9])
+	}
+	record.ErrorCode = fields[10]
+
+	numbers := []*int64{&record.BytesSent, &record.ObjectSize}
+	for i, n := range numbers {
+		if *n, err = parseNumber(fields[11+i]); err != nil {
+			return record, err
+		}
+	}
+	durations := []*time.Duration{&record.TotalTime, &record.TurnAroundTime}
+	for i, d := range durations {
+		ms, err := parseNumber(fields[13+i])
+		if err != nil {
+			return record, err
+		}
+		*d = time.Duration(ms) * time.Millisecond
+	}
+	record.Referer = fields[15]
+	record.UserAgent = fields[16]
+	return record, nil
+}
+
+// parseNumber parses the number field, - is 0
+func parseNumber(field string) (int64, error) {
+	if field == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(field, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", field)
+	}
+	return n, nil
+}
+
+// splitFields splits the line into the fields, the brackets and the quotes are removed, and - is empty
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+			continue
+		case '[':
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket at %d", i)
+			}
+			fields = append(fields, line[i+1:i+end])
+			i += end + 1
+		case '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(line) && line[j] != '"'; j++ {
+				if line[j] == '\\' && j+1 < len(line) {
+					j++
+				}
+				b.WriteByte(line[j])
+			}
+			if j >= len(line) {
+				return nil, fmt.Errorf("unclosed quote at %d", i)
+			}
+			fields = append(fields, emptyIfDash(b.String()))
+			i = j + 1
+		default:
+			end := strings.IndexAny(line[i:], " \t\r\n")
+			if end < 0 {
+				end = len(line) - i
+			}
+			fields = append(fields, emptyIfDash(line[i:i+end]))
+			i += end
+		}
+	}
+	return fields, nil
+}
+
+func emptyIfDash(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
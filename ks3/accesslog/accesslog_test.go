@@ -0,0 +1,224 @@
+package accesslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+	. "gopkg.in/check.v1"
+)
+
+// Test hooks up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type Ks3AccessLogSuite struct{}
+
+var _ = Suite(&Ks3AccessLogSuite{})
+
+func logLine(t time.Time, requester, operation, key string, status int, code string, bytesSent int) string {
+	return fmt.Sprintf(`owner bucket [%s] 10.0.0.1 %s req-%d %s %s "GET /%s HTTP/1.1" %d %s %d 2048 35 30 "-" "ks3-go-sdk/1.0 (linux)"`,
+		t.Format(TimeLayout), requester, t.Unix(), operation, key, key, status, code, bytesSent)
+}
+
+func (s *Ks3AccessLogSuite) TestParseLine(c *C) {
+	line := `owner bucket [02/Jan/2024:15:04:05 +0800] 10.0.0.1 - req-1 REST.GET.OBJECT dir/a%20b.txt ` +
+		`"GET /dir/a%20b.txt?x=1 HTTP/1.1" 404 NoSuchKey - - 12 10 "https://www.example.com/" "Mozilla/5.0 (\"quoted\")" extra`
+	record, err := ParseLine(line)
+	c.Assert(err, IsNil)
+	c.Assert(record, DeepEquals, Record{
+		BucketOwner:    "owner",
+		Bucket:         "bucket",
+		Time:           record.Time,
+		RemoteIP:       "10.0.0.1",
+		RequestID:      "req-1",
+		Operation:      "REST.GET.OBJECT",
+		Key:            "dir/a b.txt",
+		RequestURI:     "GET /dir/a%20b.txt?x=1 HTTP/1.1",
+		Status:         404,
+		ErrorCode:      "NoSuchKey",
+		TotalTime:      12 * time.Millisecond,
+		TurnAroundTime: 10 * time.Millisecond,
+		Referer:        "https://www.example.com/",
+		UserAgent:      `Mozilla/5.0 ("quoted")`,
+	})
+	c.Assert(record.IsError(), Equals, true)
+
+	for _, t := range []struct {
+		line  string
+		error string
+	}{
+		{"owner bucket", "the line has 2 fields, at least 17 are expected"},
+		{`owner bucket [02/Jan/2024:15:04:05 +0800`, "unclosed bracket at 13"},
+		{`owner "bucket`, "unclosed quote at 6"},
+		{strings.Replace(line, "[02/Jan/2024:15:04:05 +0800]", "[2024-01-02]", 1), `invalid time "2024-01-02"`},
+		{strings.Replace(line, " 404 ", " OK ", 1), `invalid status "OK"`},
+		{strings.Replace(line, " 12 10 ", " 12ms 10 ", 1), `invalid number "12ms"`},
+	} {
+		_, err = ParseLine(t.line)
+		c.Assert(err, ErrorMatches, t.error)
+	}
+}
+
+func (s *Ks3AccessLogSuite) TestParse(c *C) {
+	base := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	lines := []string{
+		logLine(base, "alice", "REST.GET.OBJECT", "a.txt", 200, "-", 100),
+		"garbage",
+		"",
+		logLine(base.Add(time.Minute), "bob", "REST.PUT.OBJECT", "b.txt", 403, "AccessDenied", 0),
+		logLine(base.Add(2*time.Minute), "alice", "REST.GET.OBJECT", "a.txt", 200, "-", 100),
+	}
+	data := strings.Join(lines, "\n") + "\n"
+
+	var records []Record
+	collect := func(r Record) error {
+		records = append(records, r)
+		return nil
+	}
+	malformed, err := Parse(strings.NewReader(data), "log", time.Time{}, time.Time{}, collect)
+	c.Assert(err, IsNil)
+	c.Assert(len(records), Equals, 3)
+	c.Assert(len(malformed), Equals, 1)
+	c.Assert(malformed[0].Line, Equals, 2)
+	c.Assert(malformed[0].Text, Equals, "garbage")
+	c.Assert(malformed[0].Error(), Equals, "log:2: the line has 1 fields, at least 17 are expected")
+
+	// The gzip log and the time range
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(data))
+	gz.Close()
+	records = nil
+	_, err = Parse(&buf, "log.gz", base.Add(time.Minute), base.Add(2*time.Minute), collect)
+	c.Assert(err, IsNil)
+	c.Assert(len(records), Equals, 1)
+	c.Assert(records[0].Requester, Equals, "bob")
+
+	// The error of fn stops parsing
+	malformed, err = Parse(strings.NewReader(data), "log", time.Time{}, time.Time{}, func(Record) error { return fmt.Errorf("stop") })
+	c.Assert(err, ErrorMatches, "stop")
+	c.Assert(len(malformed), Equals, 0)
+}
+
+func (s *Ks3AccessLogSuite) TestStats(c *C) {
+	base := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	stats := NewStats()
+	for _, line := range []string{
+		logLine(base, "alice", "REST.GET.OBJECT", "a.txt", 200, "-", 100),
+		logLine(base, "alice", "REST.GET.OBJECT", "a.txt", 200, "-", 100),
+		logLine(base, "bob", "REST.GET.OBJECT", "b.txt", 200, "-", 500),
+		logLine(base, "bob", "REST.GET.OBJECT", "c.txt", 404, "NoSuchKey", 0),
+		logLine(base, "bob", "REST.PUT.OBJECT", "d.txt", 403, "AccessDenied", 0),
+		logLine(base, "-", "REST.PUT.OBJECT", "d.txt", 500, "-", 0),
+		logLine(base, "-", "REST.GET.BUCKET", "-", 200, "-", 300),
+	} {
+		record, err := ParseLine(line)
+		c.Assert(err, IsNil)
+		stats.Add(record)
+	}
+
+	c.Assert(stats.Requests, Equals, int64(7))
+	c.Assert(stats.Errors, Equals, int64(3))
+	c.Assert(stats.BytesSent, Equals, int64(1000))
+	c.Assert(stats.TopKeys(3), DeepEquals, []KeyStats{
+		{Key: "a.txt", Requests: 2, BytesSent: 200},
+		{Key: "d.txt", Requests: 2, BytesSent: 0},
+		{Key: "b.txt", Requests: 1, BytesSent: 500},
+	})
+	c.Assert(len(stats.TopKeys(0)), Equals, 4)
+
+	operations := stats.Operations()
+	c.Assert(len(operations), Equals, 3)
+	c.Assert(operations[0].Operation, Equals, "REST.PUT.OBJECT")
+	c.Assert(operations[0].ErrorRate(), Equals, 1.0)
+	c.Assert(operations[0].ByCode, DeepEquals, map[string]int64{"AccessDenied": 1, "HTTP 500": 1})
+	c.Assert(operations[1].Operation, Equals, "REST.GET.OBJECT")
+	c.Assert(operations[1].ErrorRate(), Equals, 0.25)
+	c.Assert(operations[2].ErrorRate(), Equals, 0.0)
+
+	c.Assert(stats.Requesters(), DeepEquals, []RequesterStats{
+		{Requester: "bob", Requests: 3, BytesSent: 500},
+		{Requester: "", Requests: 2, BytesSent: 300},
+		{Requester: "alice", Requests: 2, BytesSent: 200},
+	})
+}
+
+func (s *Ks3AccessLogSuite) TestScanner(c *C) {
+	base := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	objects := map[string]string{
+		"logs/2024-01-02-14-00-00-AAAA": logLine(base.Add(-90*time.Minute), "old", "REST.GET.OBJECT", "a", 200, "-", 1),
+		"logs/2024-01-02-15-00-00-BBBB": logLine(base.Add(-30*time.Minute), "early", "REST.GET.OBJECT", "a", 200, "-", 1) + "\n" +
+			logLine(base.Add(-time.Minute), "first", "REST.GET.OBJECT", "a", 200, "-", 1),
+		"logs/2024-01-02-16-00-00-CCCC":  "bad line\n" + logLine(base.Add(30*time.Minute), "second", "REST.GET.OBJECT", "a", 200, "-", 1),
+		"logs/2024-01-02-18-00-00-DDDD":  logLine(base.Add(150*time.Minute), "late", "REST.GET.OBJECT", "a", 200, "-", 1),
+		"other/2024-01-02-15-00-00-EEEE": logLine(base, "other", "REST.GET.OBJECT", "a", 200, "-", 1),
+	}
+	var gets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if key != "" {
+			gets = append(gets, key)
+			w.Write([]byte(objects[key]))
+			return
+		}
+		prefix, marker := r.URL.Query().Get("prefix"), r.URL.Query().Get("marker")
+		var keys []string
+		for k := range objects {
+			if strings.HasPrefix(k, prefix) && k > marker {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		result := ks3.ListObjectsResult{Prefix: prefix}
+		for _, k := range keys {
+			result.Objects = append(result.Objects, ks3.ObjectProperties{Key: k})
+		}
+		data, _ := xml.Marshal(result)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+	client, err := ks3.New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", ks3.HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("log-bucket")
+	c.Assert(err, IsNil)
+
+	scanner := NewScanner(bucket, "logs/")
+	scanner.Start, scanner.End = base.Add(-10*time.Minute), base.Add(time.Hour)
+	var requesters []string
+	err = scanner.Each(func(r Record) error {
+		requesters = append(requesters, r.Requester)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(requesters, DeepEquals, []string{"first", "second"})
+	c.Assert(gets, DeepEquals, []string{"logs/2024-01-02-15-00-00-BBBB", "logs/2024-01-02-16-00-00-CCCC"})
+	c.Assert(len(scanner.Malformed), Equals, 1)
+	c.Assert(scanner.Malformed[0].Error(), Equals, "logs/2024-01-02-16-00-00-CCCC:1: the line has 2 fields, at least 17 are expected")
+
+	// All the logs under the prefix
+	scanner = NewScanner(bucket, "logs/")
+	requesters = nil
+	c.Assert(scanner.Each(func(r Record) error {
+		requesters = append(requesters, r.Requester)
+		return nil
+	}), IsNil)
+	c.Assert(requesters, DeepEquals, []string{"old", "early", "first", "second", "late"})
+}
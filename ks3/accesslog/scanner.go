@@ -0,0 +1,129 @@
+package accesslog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// ObjectTimeLayout is the layout of the time in the keys of the log objects, which follows the target prefix,
+// such as logs/2024-01-02-15-00-00-ABCDEF
+const ObjectTimeLayout = "2006-01-02-15-04-05"
+
+// DeliveryDelay is how long after the last record a log object may be delivered, the objects delivered later
+// than End plus the delay are skipped
+const DeliveryDelay = time.Hour
+
+// maxLineSize is the max length of a log line
+const maxLineSize = 1024 * 1024
+
+// Scanner reads the records of the log objects under the target prefix
+type Scanner struct {
+	Start     time.Time    // The records before it are skipped, zero for no limit
+	End       time.Time    // The records at or after it are skipped, zero for no limit
+	Malformed []ParseError // The malformed lines of the last Each
+
+	bucket *ks3.Bucket
+	prefix string
+}
+
+// NewScanner creates the scanner of the log objects in the target bucket with the target prefix
+func NewScanner(bucket *ks3.Bucket, targetPrefix string) *Scanner {
+	return &Scanner{bucket: bucket, prefix: targetPrefix}
+}
+
+// Each calls fn for each record in the time range, in the order of the log objects. The objects are chosen by
+// the times in their keys: the listing starts from the key of Start, and stops at the key of End plus
+// DeliveryDelay. The gzip objects are decompressed.
+//
+// The malformed lines are collected in Malformed and skipped. It stops at the first error of fn,
+// of listing or of reading the objects.
+func (s *Scanner) Each(fn func(Record) error) error {
+	s.Malformed = nil
+	options := []ks3.Option{ks3.Prefix(s.prefix)}
+	if !s.Start.IsZero() {
+		// The objects delivered before the start have no record in the range
+		options = append(options, ks3.Marker(s.prefix+s.Start.UTC().Format(ObjectTimeLayout)))
+	}
+
+	it := ks3.NewListObjectsIterator(s.bucket, options...)
+	for it.HasNext() {
+		result, err := it.NextPage()
+		if err != nil {
+			return err
+		}
+		for _, object := range result.Objects {
+			if t, ok := objectTime(s.prefix, object.Key); ok && !s.End.IsZero() && t.After(s.End.Add(DeliveryDelay)) {
+				return nil
+			}
+			if err = s.readObject(object.Key, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Scanner) readObject(key string, fn func(Record) error) error {
+	body, err := s.bucket.GetObject(key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	malformed, err := Parse(body, key, s.Start, s.End, fn)
+	s.Malformed = append(s.Malformed, malformed...)
+	return err
+}
+
+// objectTime returns the time in the key of the log object
+func objectTime(prefix, key string) (time.Time, bool) {
+	name := strings.TrimPrefix(key, prefix)
+	if len(name) < len(ObjectTimeLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(ObjectTimeLayout, name[:len(ObjectTimeLayout)])
+	return t, err == nil
+}
+
+// Parse calls fn for each record of the log in the time range, start and end could be zero for no limit.
+// The gzip log is decompressed. The name is the name of the log in the ParseErrors.
+//
+// It returns the malformed lines, which are skipped, and the error of fn or of reading.
+func Parse(r io.Reader, name string, start, end time.Time, fn func(Record) error) ([]ParseError, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	var malformed []ParseError
+	lines := bufio.NewScanner(br)
+	lines.Buffer(make([]byte, 64*1024), maxLineSize)
+	for n := 1; lines.Scan(); n++ {
+		line := lines.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		record, err := ParseLine(line)
+		if err != nil {
+			malformed = append(malformed, ParseError{Object: name, Line: n, Text: line, Err: err})
+			continue
+		}
+		if !start.IsZero() && record.Time.Before(start) || !end.IsZero() && !record.Time.Before(end) {
+			continue
+		}
+		if err = fn(record); err != nil {
+			return malformed, err
+		}
+	}
+	return malformed, lines.Err()
+}
@@ -0,0 +1,154 @@
+package accesslog
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Stats aggregates the records as they're added, it isn't safe for concurrent use
+type Stats struct {
+	Requests  int64
+	Errors    int64
+	BytesSent int64
+
+	keys       map[string]*KeyStats
+	operations map[string]*OperationStats
+	requesters map[string]*RequesterStats
+}
+
+// KeyStats is the requests of an object
+type KeyStats struct {
+	Key       string
+	Requests  int64
+	BytesSent int64
+}
+
+// OperationStats is the requests of an operation
+type OperationStats struct {
+	Operation string
+	Requests  int64
+	Errors    int64            // The requests whose status is 4xx or 5xx
+	ByCode    map[string]int64 // The errors by the error code, the ones without the code are by the status
+}
+
+// ErrorRate returns the ratio of the errors
+func (o OperationStats) ErrorRate() float64 {
+	if o.Requests == 0 {
+		return 0
+	}
+	return float64(o.Errors) / float64(o.Requests)
+}
+
+// RequesterStats is the requests of a requester, the anonymous requester is empty
+type RequesterStats struct {
+	Requester string
+	Requests  int64
+	BytesSent int64
+}
+
+// NewStats creates the empty stats
+func NewStats() *Stats {
+	return &Stats{
+		keys:       map[string]*KeyStats{},
+		operations: map[string]*OperationStats{},
+		requesters: map[string]*RequesterStats{},
+	}
+}
+
+// Add adds the record
+func (s *Stats) Add(r Record) {
+	s.Requests++
+	s.BytesSent += r.BytesSent
+
+	if r.Key != "" {
+		k := s.keys[r.Key]
+		if k == nil {
+			k = &KeyStats{Key: r.Key}
+			s.keys[r.Key] = k
+		}
+		k.Requests++
+		k.BytesSent += r.BytesSent
+	}
+
+	o := s.operations[r.Operation]
+	if o == nil {
+		o = &OperationStats{Operation: r.Operation, ByCode: map[string]int64{}}
+		s.operations[r.Operation] = o
+	}
+	o.Requests++
+	if r.IsError() {
+		s.Errors++
+		o.Errors++
+		code := r.ErrorCode
+		if code == "" {
+			code = statusText(r.Status)
+		}
+		o.ByCode[code]++
+	}
+
+	q := s.requesters[r.Requester]
+	if q == nil {
+		q = &RequesterStats{Requester: r.Requester}
+		s.requesters[r.Requester] = q
+	}
+	q.Requests++
+	q.BytesSent += r.BytesSent
+}
+
+// TopKeys returns the n most requested keys, by the requests and then the bytes sent. All the keys are returned
+// if n <= 0.
+func (s *Stats) TopKeys(n int) []KeyStats {
+	keys := make([]KeyStats, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, *k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Requests != keys[j].Requests {
+			return keys[i].Requests > keys[j].Requests
+		}
+		if keys[i].BytesSent != keys[j].BytesSent {
+			return keys[i].BytesSent > keys[j].BytesSent
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if n > 0 && n < len(keys) {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// Operations returns the stats of the operations, by the error rates in descending order
+func (s *Stats) Operations() []OperationStats {
+	operations := make([]OperationStats, 0, len(s.operations))
+	for _, o := range s.operations {
+		operations = append(operations, *o)
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		ri, rj := operations[i].ErrorRate(), operations[j].ErrorRate()
+		if ri != rj {
+			return ri > rj
+		}
+		return operations[i].Operation < operations[j].Operation
+	})
+	return operations
+}
+
+// Requesters returns the stats of the requesters, by the bytes sent in descending order
+func (s *Stats) Requesters() []RequesterStats {
+	requesters := make([]RequesterStats, 0, len(s.requesters))
+	for _, q := range s.requesters {
+		requesters = append(requesters, *q)
+	}
+	sort.Slice(requesters, func(i, j int) bool {
+		if requesters[i].BytesSent != requesters[j].BytesSent {
+			return requesters[i].BytesSent > requesters[j].BytesSent
+		}
+		return requesters[i].Requester < requesters[j].Requester
+	})
+	return requesters
+}
+
+// statusText returns the status as the error code
+func statusText(status int) string {
+	return "HTTP " + strconv.Itoa(status)
+}
@@ -46,10 +46,20 @@ func (conn Conn) getAdditionalHeaderKeys(req *http.Request) ([]string, map[strin
 
 // signHeader signs the header and sets it as the authorization header.
 func (conn Conn) signHeader(req *http.Request, canonicalizedResource string) {
-	akIf := conn.config.GetCredentials()
+	conn.signHeaderWithCredentials(req, canonicalizedResource, conn.config.GetCredentials())
+}
+
+// signHeaderWithCredentials signs the header with the credentials got by the caller,
+// so the security token header and the signature come from the same credentials.
+func (conn Conn) signHeaderWithCredentials(req *http.Request, canonicalizedResource string, akIf Credentials) {
 	if akIf.GetAccessKeyID() == "" && akIf.GetAccessKeySecret() == "" && akIf.GetSecurityToken() == "" {
 		return
 	}
+	if conn.config.AuthVersion == AuthV4 {
+		conn.signHeaderV4(req, akIf)
+		return
+	}
+
 	authorizationStr := ""
 	if conn.config.AuthVersion == AuthV2 {
 		additionalList, _ := conn.getAdditionalHeaderKeys(req)
@@ -71,6 +81,27 @@ func (conn Conn) signHeader(req *http.Request, canonicalizedResource string) {
 }
 
 func (conn Conn) getSignedStr(req *http.Request, canonicalizedResource string, keySecret string) string {
+	signStr := conn.getStringToSignPrefix(req) + canonicalizedResource
+
+	// convert sign to log for easy to view
+	if conn.config.LogLevel >= Debug {
+		var signBuf bytes.Buffer
+		for i := 0; i < len(signStr); i++ {
+			if signStr[i] != '\n' {
+				signBuf.WriteByte(signStr[i])
+			} else {
+				signBuf.WriteString("\\n")
+			}
+		}
+		conn.config.WriteLog(Debug, "[Req:%p]signStr:%s\n", req, signBuf.String())
+	}
+
+	return conn.signString(signStr, keySecret)
+}
+
+// getStringToSignPrefix gets the string to sign before the canonicalized resource, it's shared by the requests
+// with the same method and headers
+func (conn Conn) getStringToSignPrefix(req *http.Request) string {
 	// Find out the "x-ks3-"'s address in header of the request
 	ks3HeadersMap := make(map[string]string)
 	additionalList, additionalMap := conn.getAdditionalHeaderKeys(req)
@@ -100,33 +131,22 @@ func (conn Conn) getSignedStr(req *http.Request, canonicalizedResource string, k
 	contentType := req.Header.Get(HTTPHeaderContentType)
 	contentMd5 := req.Header.Get(HTTPHeaderContentMD5)
 
-	// default is v1 signature
-	signStr := req.Method + "\n" + contentMd5 + "\n" + contentType + "\n" + date + "\n" + canonicalizedKS3Headers + canonicalizedResource
-	h := hmac.New(func() hash.Hash { return sha1.New() }, []byte(keySecret))
-
 	// v2 signature
 	if conn.config.AuthVersion == AuthV2 {
-		signStr = req.Method + "\n" + contentMd5 + "\n" + contentType + "\n" + date + "\n" + canonicalizedKS3Headers + strings.Join(additionalList, ";") + "\n" + canonicalizedResource
-		h = hmac.New(func() hash.Hash { return sha256.New() }, []byte(keySecret))
+		return req.Method + "\n" + contentMd5 + "\n" + contentType + "\n" + date + "\n" + canonicalizedKS3Headers + strings.Join(additionalList, ";") + "\n"
 	}
+	// default is v1 signature
+	return req.Method + "\n" + contentMd5 + "\n" + contentType + "\n" + date + "\n" + canonicalizedKS3Headers
+}
 
-	// convert sign to log for easy to view
-	if conn.config.LogLevel >= Debug {
-		var signBuf bytes.Buffer
-		for i := 0; i < len(signStr); i++ {
-			if signStr[i] != '\n' {
-				signBuf.WriteByte(signStr[i])
-			} else {
-				signBuf.WriteString("\\n")
-			}
-		}
-		conn.config.WriteLog(Debug, "[Req:%p]signStr:%s\n", req, signBuf.String())
+// signString signs the string by HMAC-SHA1 for v1 signature and HMAC-SHA256 for v2 signature
+func (conn Conn) signString(signStr string, keySecret string) string {
+	h := hmac.New(func() hash.Hash { return sha1.New() }, []byte(keySecret))
+	if conn.config.AuthVersion == AuthV2 {
+		h = hmac.New(func() hash.Hash { return sha256.New() }, []byte(keySecret))
 	}
-
 	io.WriteString(h, signStr)
-	signedStr := base64.StdEncoding.EncodeToString(h.Sum(nil))
-
-	return signedStr
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
 func (conn Conn) getPolicySignedStr(canonicalizedResource string, date string, keySecret string) string {
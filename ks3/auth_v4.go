@@ -0,0 +1,92 @@
+package ks3
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
+)
+
+// regionFromEndpoint parses the region from the endpoint such as ks3-cn-beijing.ksyuncs.com or ks3-cn-beijing-internal.ksyuncs.com
+func regionFromEndpoint(netLoc string) string {
+	host := netLoc
+	if h, _, err := net.SplitHostPort(netLoc); err == nil {
+		host = h
+	}
+	label := strings.SplitN(host, ".", 2)[0]
+	if !strings.HasPrefix(label, "ks3-") || len(strings.Split(host, ".")) < 3 {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(label, "ks3-"), "-internal")
+}
+
+// v4Signer creates the v4 signer of the credentials
+func (conn Conn) v4Signer(akIf Credentials) *sign.V4Signer {
+	return &sign.V4Signer{
+		AccessKeyID:     akIf.GetAccessKeyID(),
+		SecretAccessKey: akIf.GetAccessKeySecret(),
+		SecurityToken:   akIf.GetSecurityToken(),
+		Region:          conn.config.Region,
+	}
+}
+
+// setV4PayloadHash sets X-Kss-Content-Sha256 by the payload signing type
+func (conn Conn) setV4PayloadHash(req *http.Request, data io.Reader) {
+	payloadHash := sign.UnsignedPayload
+	switch {
+	case data == nil:
+		payloadHash = sign.EmptyPayloadHash
+	case conn.config.PayloadSigning == PayloadUnsigned:
+	case conn.config.PayloadSigning == PayloadStreaming:
+		payloadHash = sign.StreamingPayload
+	default:
+		if sum, ok := hashPayload(data); ok {
+			payloadHash = sum
+		}
+	}
+	req.Header.Set(HTTPHeaderKs3ContentSha256, payloadHash)
+}
+
+// hashPayload calculates the SHA256 of the body without consuming it, only the buffer and the seekable body could be hashed
+func hashPayload(data io.Reader) (string, bool) {
+	h := sha256.New()
+	switch v := data.(type) {
+	case *bytes.Buffer:
+		h.Write(v.Bytes())
+	case io.ReadSeeker:
+		pos, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return "", false
+		}
+		_, err = io.Copy(h, v)
+		if _, seekErr := v.Seek(pos, io.SeekStart); err != nil || seekErr != nil {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// signHeaderV4 signs the request by v4 signature, the body is signed in chunks if it's streaming
+func (conn Conn) signHeaderV4(req *http.Request, akIf Credentials) {
+	signer := conn.v4Signer(akIf)
+	now := time.Now()
+	payloadHash := req.Header.Get(HTTPHeaderKs3ContentSha256)
+	if payloadHash == sign.StreamingPayload {
+		if req.ContentLength >= 0 {
+			if _, err := signer.SignStreaming(req, sign.DefaultChunkSize, now); err == nil {
+				return
+			}
+		}
+		payloadHash = sign.UnsignedPayload
+	}
+	result := signer.SignRequest(req, payloadHash, now)
+	conn.config.WriteLog(Debug, "[Req:%p]canonicalRequest:%s\n", req, strings.Replace(result.CanonicalRequest, "\n", "\\n", -1))
+}
@@ -0,0 +1,194 @@
+package ks3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
+	. "gopkg.in/check.v1"
+)
+
+type Ks3AuthV4Suite struct {
+	server *httptest.Server
+	req    *http.Request
+	body   []byte
+}
+
+var _ = Suite(&Ks3AuthV4Suite{})
+
+func (s *Ks3AuthV4Suite) SetUpSuite(c *C) {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.req = r
+		s.body, _ = ioutil.ReadAll(r.Body)
+	}))
+}
+
+func (s *Ks3AuthV4Suite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3AuthV4Suite) newBucket(c *C, options ...ClientOption) *Bucket {
+	addr := s.server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	options = append([]ClientOption{AuthVersion(AuthV4), HTTPClient(&http.Client{Transport: transport})}, options...)
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", options...)
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("v4-bucket")
+	c.Assert(err, IsNil)
+	return bucket
+}
+
+// verifyV4 recomputes the signature of the received request with its signed headers
+func verifyV4(c *C, r *http.Request) {
+	auth := r.Header.Get(HTTPHeaderAuthorization)
+	c.Assert(strings.HasPrefix(auth, sign.V4Algorithm+" Credential=ak/"), Equals, true)
+	var signedHeaders, signature string
+	for _, part := range strings.Split(strings.TrimPrefix(auth, sign.V4Algorithm+" "), ", ") {
+		if strings.HasPrefix(part, "SignedHeaders=") {
+			signedHeaders = strings.TrimPrefix(part, "SignedHeaders=")
+		} else if strings.HasPrefix(part, "Signature=") {
+			signature = strings.TrimPrefix(part, "Signature=")
+		}
+	}
+
+	t, err := time.Parse("20060102T150405Z", r.Header.Get("X-Kss-Date"))
+	c.Assert(err, IsNil)
+	req := &http.Request{
+		Method: r.Method,
+		URL:    &url.URL{Path: r.URL.Path, RawQuery: r.URL.RawQuery},
+		Host:   r.Host,
+		Header: http.Header{},
+	}
+	for _, name := range strings.Split(signedHeaders, ";") {
+		if name != "host" {
+			req.Header[http.CanonicalHeaderKey(name)] = r.Header[http.CanonicalHeaderKey(name)]
+		}
+	}
+	signer := &sign.V4Signer{AccessKeyID: "ak", SecretAccessKey: "sk", Region: "cn-beijing",
+		SecurityToken: r.Header.Get(HTTPHeaderKs3SecurityToken)}
+	result := signer.SignRequest(req, r.Header.Get(HTTPHeaderKs3ContentSha256), t)
+	c.Assert(result.SignedHeaders, Equals, signedHeaders)
+	c.Assert(result.Signature, Equals, signature)
+}
+
+func (s *Ks3AuthV4Suite) TestRegionFromEndpoint(c *C) {
+	c.Assert(regionFromEndpoint("ks3-cn-beijing.ksyuncs.com"), Equals, "cn-beijing")
+	c.Assert(regionFromEndpoint("ks3-cn-shanghai-internal.ksyuncs.com"), Equals, "cn-shanghai")
+	c.Assert(regionFromEndpoint("ks3-cn-beijing.ksyuncs.com:8080"), Equals, "cn-beijing")
+	c.Assert(regionFromEndpoint("127.0.0.1:8080"), Equals, "")
+	c.Assert(regionFromEndpoint("cname.example.com"), Equals, "")
+
+	_, err := New("http://127.0.0.1:8080", "ak", "sk", AuthVersion(AuthV4))
+	c.Assert(err, NotNil)
+	client, err := New("http://127.0.0.1:8080", "ak", "sk", AuthVersion(AuthV4), Region("cn-guangzhou"))
+	c.Assert(err, IsNil)
+	c.Assert(client.Config.Region, Equals, "cn-guangzhou")
+}
+
+func (s *Ks3AuthV4Suite) TestSignedPayload(c *C) {
+	bucket := s.newBucket(c)
+	err := bucket.PutObject("dir/object name", strings.NewReader("hello v4"), Meta("name", "value"))
+	c.Assert(err, IsNil)
+
+	c.Assert(s.req.Header.Get(HTTPHeaderKs3ContentSha256), Equals, sign.PayloadHash([]byte("hello v4")))
+	c.Assert(string(s.body), Equals, "hello v4")
+	verifyV4(c, s.req)
+
+	_, err = bucket.GetObjectDetailedMeta("object", VersionId("v1"))
+	c.Assert(err, IsNil)
+	c.Assert(s.req.Header.Get(HTTPHeaderKs3ContentSha256), Equals, sign.EmptyPayloadHash)
+	verifyV4(c, s.req)
+}
+
+func (s *Ks3AuthV4Suite) TestUnsignedPayload(c *C) {
+	bucket := s.newBucket(c, PayloadSigning(PayloadUnsigned))
+	err := bucket.PutObject("object", strings.NewReader("hello v4"))
+	c.Assert(err, IsNil)
+	c.Assert(s.req.Header.Get(HTTPHeaderKs3ContentSha256), Equals, sign.UnsignedPayload)
+	verifyV4(c, s.req)
+
+	// The body which can't be hashed is unsigned
+	bucket = s.newBucket(c)
+	err = bucket.PutObject("object", ioutil.NopCloser(strings.NewReader("hello v4")))
+	c.Assert(err, IsNil)
+	c.Assert(s.req.Header.Get(HTTPHeaderKs3ContentSha256), Equals, sign.UnsignedPayload)
+	c.Assert(string(s.body), Equals, "hello v4")
+	verifyV4(c, s.req)
+}
+
+func (s *Ks3AuthV4Suite) TestStreamingPayload(c *C) {
+	bucket := s.newBucket(c, PayloadSigning(PayloadStreaming))
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	err := bucket.PutObject("object", bytes.NewReader(data))
+	c.Assert(err, IsNil)
+
+	c.Assert(s.req.Header.Get(HTTPHeaderKs3ContentSha256), Equals, sign.StreamingPayload)
+	c.Assert(s.req.Header.Get("Content-Encoding"), Equals, "aws-chunked")
+	c.Assert(s.req.Header.Get("X-Kss-Decoded-Content-Length"), Equals, strconv.Itoa(len(data)))
+	c.Assert(s.req.ContentLength, Equals, sign.StreamingContentLength(int64(len(data)), sign.DefaultChunkSize))
+	c.Assert(strings.Contains(s.req.Header.Get(HTTPHeaderAuthorization), "content-length"), Equals, true)
+
+	// Decode the chunks
+	var decoded []byte
+	reader := bufio.NewReader(bytes.NewReader(s.body))
+	for {
+		line, err := reader.ReadString('\n')
+		c.Assert(err, IsNil)
+		size, err := strconv.ParseInt(strings.SplitN(line, ";", 2)[0], 16, 64)
+		c.Assert(err, IsNil)
+		c.Assert(strings.Contains(line, ";chunk-signature="), Equals, true)
+		chunk := make([]byte, size+2)
+		_, err = io.ReadFull(reader, chunk)
+		c.Assert(err, IsNil)
+		decoded = append(decoded, chunk[:size]...)
+		if size == 0 {
+			break
+		}
+	}
+	c.Assert(decoded, DeepEquals, data)
+}
+
+func (s *Ks3AuthV4Suite) TestSecurityToken(c *C) {
+	bucket := s.newBucket(c, SetCredentialsProvider(NewStaticCredentialsProvider("ak", "sk", "token")))
+	err := bucket.PutObject("object", strings.NewReader("data"))
+	c.Assert(err, IsNil)
+	c.Assert(s.req.Header.Get(HTTPHeaderKs3SecurityToken), Equals, "token")
+	c.Assert(strings.Contains(s.req.Header.Get(HTTPHeaderAuthorization), "x-kss-security-token"), Equals, true)
+	verifyV4(c, s.req)
+}
+
+func (s *Ks3AuthV4Suite) TestSignURL(c *C) {
+	bucket := s.newBucket(c)
+	signedURL, err := bucket.SignURL("dir/object", HTTPGet, 600, ResponseContentType("text/plain"))
+	c.Assert(err, IsNil)
+
+	u, err := url.Parse(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(u.Host, Equals, "v4-bucket.ks3-cn-beijing.ksyuncs.com")
+	c.Assert(u.Path, Equals, "/dir/object")
+	query := u.Query()
+	c.Assert(query.Get("X-Kss-Algorithm"), Equals, sign.V4Algorithm)
+	c.Assert(query.Get("X-Kss-Expires"), Equals, "600")
+	c.Assert(query.Get("X-Kss-SignedHeaders"), Equals, "host")
+	c.Assert(query.Get("response-content-type"), Equals, "text/plain")
+	c.Assert(strings.HasPrefix(query.Get("X-Kss-Credential"), "ak/"), Equals, true)
+	c.Assert(strings.HasSuffix(query.Get("X-Kss-Credential"), "/cn-beijing/s3/aws4_request"), Equals, true)
+	c.Assert(len(query.Get("X-Kss-Signature")), Equals, 64)
+
+	_, err = bucket.SignURL("object", HTTPGet, 8*24*3600)
+	c.Assert(err, NotNil)
+}
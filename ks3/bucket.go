@@ -20,8 +20,9 @@ import (
 
 // Bucket implements the operations of object.
 type Bucket struct {
-	Client     Client
-	BucketName string
+	Client         Client
+	BucketName     string
+	sseCustomerKey *SSECustomerKey // The SSE-C key of the bucket view, check out WithSSECustomerKey
 }
 
 // PutObject creates a new object, and it will overwrite the original one if it exists already.
@@ -296,6 +297,11 @@ func (bucket Bucket) CopyObjectFrom(srcBucketName, srcObjectKey, destObjectKey s
 		return out, err
 	}
 
+	// The SSE-C key of the bucket view is the key of the target object
+	if set, _, _ := IsOptionSet(options, HTTPHeaderSSECKey); !set && bucket.sseCustomerKey != nil {
+		options = append(options, SSECustomer(bucket.sseCustomerKey))
+	}
+
 	return srcBucket.copy(srcObjectKey, destBucketName, destObjectKey, options...)
 }
 
@@ -312,17 +318,28 @@ func (bucket Bucket) copy(srcObjectKey, destBucketName, destObjectKey string, op
 		options = append(options, CopySourceVersion(bucket.BucketName, srcObjectKey, versionId.(string)))
 	}
 
+	// The SSE-C key of the bucket view is the key of the source object
+	if set, _, _ := IsOptionSet(options, HTTPHeaderCopySourceSSECKey); !set && bucket.sseCustomerKey != nil {
+		options = append(options, CopySourceSSECustomer(bucket.sseCustomerKey))
+	}
+
 	headers := make(map[string]string)
 	err := handleOptions(headers, options)
 	if err != nil {
 		return out, err
 	}
+	if err = checkSSECustomerHeaders(destBucketName, destObjectKey, headers); err != nil {
+		return out, err
+	}
 	params := map[string]interface{}{}
 
 	ctxArg, _ := FindOption(options, contextArg, nil)
 	ctx, _ := ctxArg.(context.Context)
 
 	resp, err := bucket.Client.Conn.DoWithContext(ctx, "PUT", destBucketName, destObjectKey, params, headers, nil, 0, nil)
+	if err != nil {
+		err = sseCustomerError("PUT", destBucketName, destObjectKey, params, headers, err)
+	}
 
 	// get response header
 	respHeader, _ := FindOption(options, responseHeader, nil)
@@ -1051,18 +1068,11 @@ func (bucket Bucket) SignURL(objectKey string, method HTTPMethod, expiredInSec i
 	A := time.Now().Unix()
 	expiration := A + expiredInSec
 
-	params, err := GetRawParams(options)
+	p, err := bucket.newURLPresigner(method, expiration, nil, options)
 	if err != nil {
 		return "", err
 	}
-
-	headers := make(map[string]string)
-	err = handleOptions(headers, options)
-	if err != nil {
-		return "", err
-	}
-
-	return bucket.Client.Conn.signURL(method, bucket.BucketName, objectKey, expiration, params, headers), nil
+	return p.sign(objectKey), nil
 }
 
 func (bucket Bucket) SignPolicyURL(policy string, expiration int64, options ...Option) (string, error) {
@@ -1072,7 +1082,7 @@ func (bucket Bucket) SignPolicyURL(policy string, expiration int64, options ...O
 	}
 	params["X-Kss-Policy"] = base64.StdEncoding.EncodeToString([]byte(policy))
 
-	return bucket.Client.Conn.signPolicyURL(bucket.BucketName, expiration, params), nil
+	return bucket.Client.Conn.signPolicyURL(bucket.BucketName, expiration, params)
 }
 
 // PutObjectWithURL uploads an object with the URL. If the object exists, it will be overwritten.
@@ -1433,11 +1443,15 @@ func (bucket Bucket) Do(method, objectName string, params map[string]interface{}
 // Private
 func (bucket Bucket) do(method, objectName string, params map[string]interface{}, options []Option,
 	data io.Reader, listener ProgressListener) (*Response, error) {
+	options = bucket.sseCustomerOptions(method, objectName, params, options)
 	headers := make(map[string]string)
 	err := handleOptions(headers, options)
 	if err != nil {
 		return nil, err
 	}
+	if err = checkSSECustomerHeaders(bucket.BucketName, objectName, headers); err != nil {
+		return nil, err
+	}
 
 	err = CheckBucketName(bucket.BucketName)
 	if len(bucket.BucketName) > 0 && err != nil {
@@ -1449,6 +1463,9 @@ func (bucket Bucket) do(method, objectName string, params map[string]interface{}
 
 	resp, err := bucket.Client.Conn.DoWithContext(ctx, method, bucket.BucketName, objectName,
 		params, headers, data, 0, listener)
+	if err != nil {
+		err = sseCustomerError(method, bucket.BucketName, objectName, params, headers, err)
+	}
 
 	// get response header
 	respHeader, _ := FindOption(options, responseHeader, nil)
@@ -0,0 +1,704 @@
+package ks3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/policy"
+)
+
+// BucketConfig is the configuration of a bucket as one document, a section is nil or empty if it's not
+// configured. It's serialized by encoding/json, and to YAML by the converters which follow the JSON tags,
+// such as sigs.k8s.io/yaml: convert the YAML to JSON by yaml.YAMLToJSON and parse it by ParseBucketConfig,
+// or convert the output of JSON by yaml.JSONToYAML.
+type BucketConfig struct {
+	Bucket               string                    `json:"bucket,omitempty"` // Informational, the bucket is given by the caller
+	ACL                  ACLType                   `json:"acl,omitempty"`
+	Policy               *policy.Document          `json:"policy,omitempty"`
+	Versioning           VersioningStatus          `json:"versioning,omitempty"`
+	Encryption           *ServerSideEncryptionRule `json:"encryption,omitempty"`
+	CORS                 []CORSRule                `json:"cors,omitempty"`
+	Referer              *RefererXML               `json:"referer,omitempty"`
+	Website              *WebsiteXML               `json:"website,omitempty"`
+	Logging              *LoggingEnabled           `json:"logging,omitempty"`
+	Lifecycle            []LifecycleRule           `json:"lifecycle,omitempty"`
+	Tagging              []Tag                     `json:"tagging,omitempty"`
+	Replication          *Replication              `json:"replication,omitempty"`
+	Mirror               *BucketMirror             `json:"mirror,omitempty"`
+	Retention            *RetentionRule            `json:"retention,omitempty"`
+	QoS                  *BucketQoSConfiguration   `json:"qos,omitempty"`
+	Inventory            []InventoryConfiguration  `json:"inventory,omitempty"`
+	TransferAcceleration bool                      `json:"transferAcceleration,omitempty"`
+}
+
+// ParseBucketConfig parses the JSON document, the unknown fields are errors.
+func ParseBucketConfig(data []byte) (*BucketConfig, error) {
+	config := &BucketConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("invalid bucket config: %s", err.Error())
+	}
+	return config, nil
+}
+
+// JSON returns the indented JSON document.
+func (config *BucketConfig) JSON() ([]byte, error) {
+	return json.MarshalIndent(config, "", "  ")
+}
+
+// BucketConfigAction is how a section is changed
+type BucketConfigAction string
+
+const (
+	BucketConfigCreate BucketConfigAction = "create"
+	BucketConfigUpdate BucketConfigAction = "update"
+	BucketConfigDelete BucketConfigAction = "delete"
+)
+
+// BucketConfigChange is the change of a section
+type BucketConfigChange struct {
+	Section string // The JSON name of the section, such as cors
+	Action  BucketConfigAction
+	Current interface{} // The live section, nil for create
+	Desired interface{} // The section of the document, nil for delete
+	Applied bool        // Whether ApplyBucketConfig has applied it
+}
+
+// BucketConfigPlan is the difference between a document and the live configuration of the bucket
+type BucketConfigPlan struct {
+	Bucket  string
+	Changes []BucketConfigChange
+}
+
+// HasChanges reports whether the bucket drifts from the document
+func (plan *BucketConfigPlan) HasChanges() bool {
+	return len(plan.Changes) > 0
+}
+
+// ExitCode returns the exit code like diff --exit-code: 0 if the bucket matches the document, 1 if it drifts.
+// Exit with a code above 1 for the errors.
+func (plan *BucketConfigPlan) ExitCode() int {
+	if plan.HasChanges() {
+		return 1
+	}
+	return 0
+}
+
+// String prints the plan, a line per section with + for create, ~ for update and - for delete.
+func (plan *BucketConfigPlan) String() string {
+	var b strings.Builder
+	if !plan.HasChanges() {
+		fmt.Fprintf(&b, "bucket %s: no changes\n", plan.Bucket)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "bucket %s: %d changes\n", plan.Bucket, len(plan.Changes))
+	symbols := map[BucketConfigAction]string{BucketConfigCreate: "+", BucketConfigUpdate: "~", BucketConfigDelete: "-"}
+	for _, change := range plan.Changes {
+		fmt.Fprintf(&b, "  %s %s\n", symbols[change.Action], change.Section)
+	}
+	return b.String()
+}
+
+// ExportBucketConfig reads all the sections of the bucket configuration into one document.
+// The sections which aren't configured are left nil.
+//
+// bucketName    the bucket name.
+//
+// *BucketConfig    the configuration, it's only valid when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (client Client) ExportBucketConfig(bucketName string, options ...Option) (*BucketConfig, error) {
+	config := &BucketConfig{Bucket: bucketName}
+	for _, section := range bucketConfigSections {
+		if err := section.read(client, bucketName, config, options); err != nil && !isNotConfigured(err) {
+			return nil, fmt.Errorf("export %s of bucket %s: %s", section.name, bucketName, err.Error())
+		}
+	}
+	return config, nil
+}
+
+// PlanBucketConfig compares the document with the live configuration of the bucket. The sections which are
+// nil or empty in the document are deleted, except acl which can't be deleted and is left unchanged.
+// Use plan.ExitCode to detect the drift.
+//
+// bucketName    the bucket name.
+// desired    the desired configuration.
+//
+// *BucketConfigPlan    the changes, it's only valid when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (client Client) PlanBucketConfig(bucketName string, desired *BucketConfig, options ...Option) (*BucketConfigPlan, error) {
+	plan, _, err := client.planBucketConfig(bucketName, desired, options)
+	return plan, err
+}
+
+// ApplyBucketConfig computes the plan as PlanBucketConfig, and applies the changed sections only, in the order
+// of the plan. It stops at the first error, the changes applied are marked in the returned plan.
+//
+// bucketName    the bucket name.
+// desired    the desired configuration.
+// planOnly    only computes the plan without changing the bucket.
+//
+// *BucketConfigPlan    the changes, which is returned with the errors of applying.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (client Client) ApplyBucketConfig(bucketName string, desired *BucketConfig, planOnly bool, options ...Option) (*BucketConfigPlan, error) {
+	plan, current, err := client.planBucketConfig(bucketName, desired, options)
+	if err != nil || planOnly {
+		return plan, err
+	}
+
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		section := findBucketConfigSection(change.Section)
+		if change.Action == BucketConfigDelete {
+			err = section.remove(client, bucketName, current, options)
+		} else {
+			err = section.write(client, bucketName, desired, current, options)
+		}
+		if err != nil {
+			return plan, fmt.Errorf("%s %s of bucket %s: %s", change.Action, change.Section, bucketName, err.Error())
+		}
+		change.Applied = true
+	}
+	return plan, nil
+}
+
+func (client Client) planBucketConfig(bucketName string, desired *BucketConfig, options []Option) (*BucketConfigPlan, *BucketConfig, error) {
+	if desired == nil {
+		return nil, nil, fmt.Errorf("the bucket config is nil")
+	}
+	current, err := client.ExportBucketConfig(bucketName, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plan := &BucketConfigPlan{Bucket: bucketName}
+	for _, section := range bucketConfigSections {
+		cur, des := section.value(current), section.value(desired)
+		curForm, err := canonicalSection(cur)
+		if err != nil {
+			return nil, nil, err
+		}
+		desForm, err := canonicalSection(des)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		change := BucketConfigChange{Section: section.name, Current: cur, Desired: des}
+		switch {
+		case reflect.DeepEqual(curForm, desForm):
+			continue
+		case desForm == nil:
+			if section.remove == nil {
+				continue
+			}
+			change.Action, change.Desired = BucketConfigDelete, nil
+		case curForm == nil:
+			change.Action, change.Current = BucketConfigCreate, nil
+		default:
+			change.Action = BucketConfigUpdate
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan, current, nil
+}
+
+// canonicalSection returns the JSON form of the section without the null, empty strings, empty arrays and
+// empty objects, which is nil if the section is empty
+func canonicalSection(section interface{}) (interface{}, error) {
+	if section == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(section)
+	if err != nil {
+		return nil, err
+	}
+	var form interface{}
+	if err = json.Unmarshal(data, &form); err != nil {
+		return nil, err
+	}
+	return pruneJSON(form), nil
+}
+
+func pruneJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if e = pruneJSON(e); e == nil {
+				delete(t, k)
+			} else {
+				t[k] = e
+			}
+		}
+		if len(t) == 0 {
+			return nil
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			return nil
+		}
+		for i := range t {
+			t[i] = pruneJSON(t[i])
+		}
+	case string:
+		if t == "" {
+			return nil
+		}
+	}
+	return v
+}
+
+// isNotConfigured reports whether the error means the section isn't configured
+func isNotConfigured(err error) bool {
+	serviceErr, ok := err.(ServiceError)
+	return ok && serviceErr.StatusCode == http.StatusNotFound && serviceErr.Code != "NoSuchBucket"
+}
+
+// bucketConfigSection reads, writes and removes a section of BucketConfig
+type bucketConfigSection struct {
+	name   string
+	value  func(config *BucketConfig) interface{} // The section to compare, nil if it's not configured
+	read   func(client Client, bucketName string, config *BucketConfig, options []Option) error
+	write  func(client Client, bucketName string, desired, current *BucketConfig, options []Option) error
+	remove func(client Client, bucketName string, current *BucketConfig, options []Option) error // Nil if it can't be removed
+}
+
+func findBucketConfigSection(name string) bucketConfigSection {
+	for _, section := range bucketConfigSections {
+		if section.name == name {
+			return section
+		}
+	}
+	panic("unknown bucket config section " + name)
+}
+
+// bucketConfigSections are in the order to apply, versioning goes before the sections which may depend on it
+var bucketConfigSections = []bucketConfigSection{
+	{
+		name: "acl",
+		value: func(config *BucketConfig) interface{} {
+			if config.ACL == "" {
+				return nil
+			}
+			return config.ACL
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketACL(bucketName, options...)
+			if err == nil {
+				config.ACL = out.GetCannedACL()
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.SetBucketACL(bucketName, desired.ACL, options...)
+		},
+	},
+	{
+		name: "policy",
+		value: func(config *BucketConfig) interface{} {
+			if config.Policy == nil {
+				return nil
+			}
+			return config.Policy
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			doc, err := client.GetBucketPolicyDocument(bucketName, options...)
+			if err == nil {
+				config.Policy = doc
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.SetBucketPolicyDocument(bucketName, desired.Policy, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketPolicy(bucketName, options...)
+		},
+	},
+	{
+		// Versioning can't be disabled once enabled, so Suspended is the same as not configured
+		name: "versioning",
+		value: func(config *BucketConfig) interface{} {
+			if config.Versioning != VersionEnabled {
+				return nil
+			}
+			return config.Versioning
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketVersioning(bucketName, options...)
+			if err == nil {
+				config.Versioning = VersioningStatus(out.Status)
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.SetBucketVersioning(bucketName, VersioningConfig{Status: string(desired.Versioning)}, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.SetBucketVersioning(bucketName, VersioningConfig{Status: string(VersionSuspended)}, options...)
+		},
+	},
+	{
+		name: "encryption",
+		value: func(config *BucketConfig) interface{} {
+			if config.Encryption == nil {
+				return nil
+			}
+			return config.Encryption
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketEncryption(bucketName, options...)
+			if err == nil && out.ServerSideEncryptionRule.ApplyServerSideEncryptionByDefault.SSEAlgorithm != "" {
+				config.Encryption = &out.ServerSideEncryptionRule
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.PutBucketEncryption(bucketName, *desired.Encryption, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketEncryption(bucketName, options...)
+		},
+	},
+	{
+		name: "cors",
+		value: func(config *BucketConfig) interface{} {
+			if len(config.CORS) == 0 {
+				return nil
+			}
+			return config.CORS
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketCORS(bucketName, options...)
+			if err == nil {
+				config.CORS = out.CORSRules
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.SetBucketCORS(bucketName, desired.CORS, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketCORS(bucketName, options...)
+		},
+	},
+	{
+		// The referer allowing the empty referer without the list is the same as not configured
+		name: "referer",
+		value: func(config *BucketConfig) interface{} {
+			if config.Referer == nil {
+				return nil
+			}
+			referer := RefererXML{AllowEmptyReferer: config.Referer.AllowEmptyReferer}
+			for _, r := range config.Referer.RefererList {
+				if r != "" {
+					referer.RefererList = append(referer.RefererList, r)
+				}
+			}
+			if referer.AllowEmptyReferer && len(referer.RefererList) == 0 {
+				return nil
+			}
+			return referer
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketReferer(bucketName, options...)
+			if err == nil {
+				referer := RefererXML(out)
+				config.Referer = &referer
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			var referers []string
+			for _, r := range desired.Referer.RefererList {
+				if r != "" {
+					referers = append(referers, r)
+				}
+			}
+			return client.SetBucketReferer(bucketName, referers, desired.Referer.AllowEmptyReferer, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.SetBucketReferer(bucketName, nil, true, options...)
+		},
+	},
+	{
+		name: "website",
+		value: func(config *BucketConfig) interface{} {
+			if config.Website == nil {
+				return nil
+			}
+			return config.Website
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketWebsite(bucketName, options...)
+			if err == nil {
+				website := WebsiteXML(out)
+				config.Website = &website
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.SetBucketWebsiteDetail(bucketName, *desired.Website, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketWebsite(bucketName, options...)
+		},
+	},
+	{
+		name: "logging",
+		value: func(config *BucketConfig) interface{} {
+			if config.Logging == nil {
+				return nil
+			}
+			return config.Logging
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketLogging(bucketName, options...)
+			if err == nil && out.LoggingEnabled.TargetBucket != "" {
+				config.Logging = &out.LoggingEnabled
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.SetBucketLogging(bucketName, desired.Logging.TargetBucket, desired.Logging.TargetPrefix, true, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketLogging(bucketName, options...)
+		},
+	},
+	{
+		name: "lifecycle",
+		value: func(config *BucketConfig) interface{} {
+			if len(config.Lifecycle) == 0 {
+				return nil
+			}
+			return config.Lifecycle
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketLifecycle(bucketName, options...)
+			if err == nil {
+				config.Lifecycle = out.Rules
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.SetBucketLifecycle(bucketName, desired.Lifecycle, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketLifecycle(bucketName, options...)
+		},
+	},
+	{
+		name: "tagging",
+		value: func(config *BucketConfig) interface{} {
+			if len(config.Tagging) == 0 {
+				return nil
+			}
+			return config.Tagging
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketTagging(bucketName, options...)
+			if err == nil {
+				config.Tagging = out.Tags
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.SetBucketTagging(bucketName, Tagging{Tags: desired.Tagging}, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketTagging(bucketName, options...)
+		},
+	},
+	{
+		name: "replication",
+		value: func(config *BucketConfig) interface{} {
+			if config.Replication == nil {
+				return nil
+			}
+			return config.Replication
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketReplication(bucketName, options...)
+			if err == nil && out.TargetBucket != "" {
+				replication := Replication(out)
+				config.Replication = &replication
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.PutBucketReplication(bucketName, *desired.Replication, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketReplication(bucketName, options...)
+		},
+	},
+	{
+		name: "mirror",
+		value: func(config *BucketConfig) interface{} {
+			if config.Mirror == nil {
+				return nil
+			}
+			return config.Mirror
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketMirror(bucketName, options...)
+			if err == nil {
+				config.Mirror = &BucketMirror{
+					Version:          out.Version,
+					UseDefaultRobots: out.UseDefaultRobots,
+					AsyncMirrorRule:  out.AsyncMirrorRule,
+					SyncMirrorRules:  out.SyncMirrorRules,
+				}
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.PutBucketMirror(bucketName, *desired.Mirror, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketMirror(bucketName, options...)
+		},
+	},
+	{
+		// Retention can't be deleted but disabled, so Disabled is the same as not configured
+		name: "retention",
+		value: func(config *BucketConfig) interface{} {
+			if config.Retention == nil || config.Retention.Status != "Enabled" {
+				return nil
+			}
+			return config.Retention
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketRetention(bucketName, options...)
+			if err == nil && out.Rule.Status != "" {
+				config.Retention = &out.Rule
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.PutBucketRetention(bucketName, *desired.Retention, options...)
+		},
+		remove: func(client Client, bucketName string, current *BucketConfig, options []Option) error {
+			return client.PutBucketRetention(bucketName, RetentionRule{Status: "Disabled", Days: current.Retention.Days}, options...)
+		},
+	},
+	{
+		name: "qos",
+		value: func(config *BucketConfig) interface{} {
+			if config.QoS == nil {
+				return nil
+			}
+			return config.QoS
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketQosInfo(bucketName, options...)
+			if err == nil {
+				config.QoS = &out
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, desired, _ *BucketConfig, options []Option) error {
+			return client.SetBucketQoSInfo(bucketName, *desired.QoS, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketQosInfo(bucketName, options...)
+		},
+	},
+	{
+		// The inventory configurations are compared by the ids, and written one by one
+		name: "inventory",
+		value: func(config *BucketConfig) interface{} {
+			if len(config.Inventory) == 0 {
+				return nil
+			}
+			return sortedInventory(config.Inventory)
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			var token string
+			for {
+				out, err := client.ListBucketInventory(bucketName, token, options...)
+				if err != nil {
+					return err
+				}
+				config.Inventory = append(config.Inventory, out.InventoryConfiguration...)
+				if out.IsTruncated == nil || !*out.IsTruncated || out.NextContinuationToken == "" {
+					return nil
+				}
+				token = out.NextContinuationToken
+			}
+		},
+		write: func(client Client, bucketName string, desired, current *BucketConfig, options []Option) error {
+			live := map[string]InventoryConfiguration{}
+			for _, inventory := range current.Inventory {
+				live[inventory.Id] = inventory
+			}
+			for _, inventory := range desired.Inventory {
+				if existing, ok := live[inventory.Id]; ok {
+					delete(live, inventory.Id)
+					a, _ := canonicalSection(existing)
+					b, _ := canonicalSection(inventory)
+					if reflect.DeepEqual(a, b) {
+						continue
+					}
+				}
+				if err := client.PutBucketInventory(bucketName, inventory, options...); err != nil {
+					return err
+				}
+			}
+			for _, inventory := range sortedInventory(current.Inventory) {
+				if _, ok := live[inventory.Id]; !ok {
+					continue
+				}
+				if err := client.DeleteBucketInventory(bucketName, inventory.Id, options...); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		remove: func(client Client, bucketName string, current *BucketConfig, options []Option) error {
+			for _, inventory := range current.Inventory {
+				if err := client.DeleteBucketInventory(bucketName, inventory.Id, options...); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name: "transferAcceleration",
+		value: func(config *BucketConfig) interface{} {
+			if !config.TransferAcceleration {
+				return nil
+			}
+			return true
+		},
+		read: func(client Client, bucketName string, config *BucketConfig, options []Option) error {
+			out, err := client.GetBucketTransferAcc(bucketName, options...)
+			if err == nil {
+				config.TransferAcceleration = out.Enabled
+			}
+			return err
+		},
+		write: func(client Client, bucketName string, _, _ *BucketConfig, options []Option) error {
+			return client.SetBucketTransferAcc(bucketName, TransferAccConfiguration{Enabled: true}, options...)
+		},
+		remove: func(client Client, bucketName string, _ *BucketConfig, options []Option) error {
+			return client.DeleteBucketTransferAcc(bucketName, options...)
+		},
+	},
+}
+
+// sortedInventory returns the copy of the inventory configurations sorted by the ids
+func sortedInventory(inventory []InventoryConfiguration) []InventoryConfiguration {
+	sorted := append([]InventoryConfiguration(nil), inventory...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	return sorted
+}
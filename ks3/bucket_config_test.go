@@ -0,0 +1,254 @@
+package ks3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/policy"
+	. "gopkg.in/check.v1"
+)
+
+type Ks3BucketConfigSuite struct {
+	server    *httptest.Server
+	resources map[string][]byte // The configurations by the sub-resources, and the inventories by inventory/<id>
+	writes    []string
+}
+
+var _ = Suite(&Ks3BucketConfigSuite{})
+
+var bucketConfigResources = []string{"acl", "policy", "versioning", "encryption", "cors", "referer", "website", "logging",
+	"lifecycle", "tagging", "crr", "mirror", "retention", "qosInfo", "inventory", "transferAcceleration"}
+
+func (s *Ks3BucketConfigSuite) SetUpTest(c *C) {
+	s.resources = map[string][]byte{}
+	s.writes = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var resource string
+		for _, name := range bucketConfigResources {
+			if _, ok := query[name]; ok {
+				resource = name
+			}
+		}
+		if resource == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		key := resource
+		if id := query.Get("id"); id != "" {
+			key += "/" + id
+		}
+
+		switch r.Method {
+		case "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			if resource == "acl" {
+				body = []byte(r.Header.Get(HTTPHeaderKs3ACL))
+			}
+			s.resources[key] = body
+			s.writes = append(s.writes, "PUT "+key)
+			if resource == "policy" {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		case "DELETE":
+			delete(s.resources, key)
+			s.writes = append(s.writes, "DELETE "+key)
+			if resource != "crr" {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		case "GET":
+			s.serveGet(w, key)
+		}
+	}))
+}
+
+func (s *Ks3BucketConfigSuite) serveGet(w http.ResponseWriter, key string) {
+	switch key {
+	case "acl":
+		out := AccessControlPolicy{}
+		if strings.HasPrefix(string(s.resources[key]), "public-read") {
+			out.ACL = append(out.ACL, Grant{Grantee: Grantee{Uri: ALL_USERS}, Permission: PermissionRead})
+		}
+		data, _ := xml.Marshal(out)
+		w.Write(data)
+		return
+	case "versioning":
+		if _, ok := s.resources[key]; !ok {
+			w.Write([]byte("<VersioningConfiguration></VersioningConfiguration>"))
+			return
+		}
+	case "inventory":
+		var ids []string
+		for k := range s.resources {
+			if strings.HasPrefix(k, "inventory/") {
+				ids = append(ids, k)
+			}
+		}
+		sort.Strings(ids)
+		out := ListInventoryConfigurationsResult{}
+		for _, id := range ids {
+			var inventory InventoryConfiguration
+			xml.Unmarshal(s.resources[id], &inventory)
+			out.InventoryConfiguration = append(out.InventoryConfiguration, inventory)
+		}
+		data, _ := xml.Marshal(out)
+		w.Write(data)
+		return
+	}
+
+	data, ok := s.resources[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<Error><Code>NoSuchConfiguration</Code></Error>"))
+		return
+	}
+	w.Write(data)
+}
+
+func (s *Ks3BucketConfigSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3BucketConfigSuite) newClient(c *C) *Client {
+	addr := s.server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	return client
+}
+
+func testBucketConfig() *BucketConfig {
+	enabled := true
+	return &BucketConfig{
+		ACL:        ACLPublicRead,
+		Policy:     policy.New().AddStatement(policy.ReadOnlyPrefix("config-bucket", "public/")...),
+		Versioning: VersionEnabled,
+		CORS: []CORSRule{
+			{AllowedOrigin: []string{"*"}, AllowedMethod: []string{"GET"}, MaxAgeSeconds: 100},
+		},
+		Lifecycle: []LifecycleRule{
+			{ID: "expire", Status: "Enabled", Filter: &LifecycleFilter{Prefix: "tmp/"}, Expiration: &LifecycleExpiration{Days: 7}},
+		},
+		Tagging: []Tag{{Key: "team", Value: "storage"}},
+		Inventory: []InventoryConfiguration{
+			{Id: "weekly", IsEnabled: &enabled, Schedule: Schedule{Frequency: "Weekly"}},
+			{Id: "daily", IsEnabled: &enabled, Schedule: Schedule{Frequency: "Daily"}},
+		},
+		TransferAcceleration: true,
+	}
+}
+
+func (s *Ks3BucketConfigSuite) TestApplyBucketConfig(c *C) {
+	client := s.newClient(c)
+	config, err := client.ExportBucketConfig("config-bucket")
+	c.Assert(err, IsNil)
+	c.Assert(config, DeepEquals, &BucketConfig{Bucket: "config-bucket", ACL: ACLPrivate})
+
+	// The plan only
+	desired := testBucketConfig()
+	plan, err := client.ApplyBucketConfig("config-bucket", desired, true)
+	c.Assert(err, IsNil)
+	c.Assert(plan.ExitCode(), Equals, 1)
+	c.Assert(plan.String(), Equals, "bucket config-bucket: 8 changes\n"+
+		"  ~ acl\n  + policy\n  + versioning\n  + cors\n  + lifecycle\n  + tagging\n  + inventory\n  + transferAcceleration\n")
+	c.Assert(plan.Changes[0].Current, Equals, ACLPrivate)
+	c.Assert(plan.Changes[0].Applied, Equals, false)
+	c.Assert(len(s.writes), Equals, 0)
+
+	plan, err = client.ApplyBucketConfig("config-bucket", desired, false)
+	c.Assert(err, IsNil)
+	c.Assert(len(plan.Changes), Equals, 8)
+	for _, change := range plan.Changes {
+		c.Assert(change.Applied, Equals, true)
+	}
+	c.Assert(s.writes, DeepEquals, []string{"PUT acl", "PUT policy", "PUT versioning", "PUT cors", "PUT lifecycle",
+		"PUT tagging", "PUT inventory/weekly", "PUT inventory/daily", "PUT transferAcceleration"})
+
+	// The live configuration matches the document
+	plan, err = client.PlanBucketConfig("config-bucket", desired)
+	c.Assert(err, IsNil)
+	c.Assert(plan.ExitCode(), Equals, 0)
+	c.Assert(plan.String(), Equals, "bucket config-bucket: no changes\n")
+
+	// The removed sections are deleted, and the inventories are changed one by one
+	s.writes = nil
+	desired.CORS = nil
+	desired.ACL = ""
+	desired.Versioning = VersionSuspended
+	desired.Tagging[0].Value = "platform"
+	desired.Inventory = desired.Inventory[1:]
+	plan, err = client.ApplyBucketConfig("config-bucket", desired, false)
+	c.Assert(err, IsNil)
+	c.Assert(plan.String(), Equals, "bucket config-bucket: 4 changes\n  - versioning\n  - cors\n  ~ tagging\n  ~ inventory\n")
+	c.Assert(s.writes, DeepEquals, []string{"PUT versioning", "DELETE cors", "PUT tagging", "DELETE inventory/weekly"})
+
+	config, err = client.ExportBucketConfig("config-bucket")
+	c.Assert(err, IsNil)
+	c.Assert(config.ACL, Equals, ACLPublicRead)
+	c.Assert(config.Versioning, Equals, VersionSuspended)
+	c.Assert(config.CORS, IsNil)
+	c.Assert(config.Tagging[0].Value, Equals, "platform")
+	c.Assert(len(config.Inventory), Equals, 1)
+
+	plan, err = client.PlanBucketConfig("config-bucket", desired)
+	c.Assert(err, IsNil)
+	c.Assert(plan.HasChanges(), Equals, false)
+
+	_, err = client.ApplyBucketConfig("config-bucket", nil, true)
+	c.Assert(err, ErrorMatches, "the bucket config is nil")
+}
+
+func (s *Ks3BucketConfigSuite) TestExportErrors(c *C) {
+	s.server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+	})
+	_, err := s.newClient(c).ExportBucketConfig("config-bucket")
+	c.Assert(err, ErrorMatches, "export acl of bucket config-bucket: .*AccessDenied.*")
+}
+
+func (s *Ks3BucketConfigSuite) TestBucketConfigJSON(c *C) {
+	config := testBucketConfig()
+	data, err := config.JSON()
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(data), "XMLName"), Equals, false)
+	c.Assert(strings.Contains(string(data), `"transferAcceleration": true`), Equals, true)
+
+	parsed, err := ParseBucketConfig(data)
+	c.Assert(err, IsNil)
+	c.Assert(parsed, DeepEquals, config)
+
+	_, err = ParseBucketConfig([]byte(`{"cros": []}`))
+	c.Assert(err, ErrorMatches, `invalid bucket config: json: unknown field "cros"`)
+}
+
+func (s *Ks3BucketConfigSuite) TestBucketConfigYAMLRoute(c *C) {
+	// The YAML converters such as sigs.k8s.io/yaml convert the JSON to a generic document by the JSON tags
+	// and back with the keys sorted, the config survives the round trip
+	config := testBucketConfig()
+	data, err := config.JSON()
+	c.Assert(err, IsNil)
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	c.Assert(decoder.Decode(&document), IsNil)
+	converted, err := json.Marshal(document)
+	c.Assert(err, IsNil)
+
+	parsed, err := ParseBucketConfig(converted)
+	c.Assert(err, IsNil)
+	c.Assert(parsed, DeepEquals, config)
+}
@@ -14,12 +14,12 @@ type ServerSideEncryptionConfiguration struct {
 }
 
 type ServerSideEncryptionRule struct {
-	XMLName xml.Name `xml:"Rule"`
+	XMLName xml.Name `xml:"Rule" json:"-"`
 	ApplyServerSideEncryptionByDefault ApplyServerSideEncryptionByDefault `xml:"ApplyServerSideEncryptionByDefault"`
 }
 
 type ApplyServerSideEncryptionByDefault struct {
-	XMLName      xml.Name `xml:"ApplyServerSideEncryptionByDefault"`
+	XMLName      xml.Name `xml:"ApplyServerSideEncryptionByDefault" json:"-"`
 	SSEAlgorithm string   `xml:"SSEAlgorithm,omitempty"`
 }
 
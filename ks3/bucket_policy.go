@@ -0,0 +1,44 @@
+package ks3
+
+import (
+	"fmt"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/policy"
+)
+
+// GetBucketPolicyDocument gets the policy of the bucket and parses it, GetBucketPolicy returns the raw JSON.
+//
+// bucketName    the bucket name.
+//
+// *policy.Document    the policy document, and it's only valid when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (client Client) GetBucketPolicyDocument(bucketName string, options ...Option) (*policy.Document, error) {
+	out, err := client.GetBucketPolicy(bucketName, options...)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Parse(out)
+}
+
+// SetBucketPolicyDocument validates the policy document and sets it to the bucket, SetBucketPolicy sets the raw JSON.
+// The document isn't sent if it's invalid, and the error is policy.ValidationErrors.
+//
+// bucketName    the bucket name.
+// doc    the policy document.
+//
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (client Client) SetBucketPolicyDocument(bucketName string, doc *policy.Document, options ...Option) error {
+	if doc == nil {
+		return fmt.Errorf("the policy document is nil")
+	}
+	if err := doc.Validate(); err != nil {
+		return err
+	}
+	out, err := doc.JSON()
+	if err != nil {
+		return err
+	}
+	return client.SetBucketPolicy(bucketName, out, options...)
+}
@@ -0,0 +1,91 @@
+package ks3
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/policy"
+	. "gopkg.in/check.v1"
+)
+
+type Ks3BucketPolicySuite struct {
+	server *httptest.Server
+	policy string
+	puts   int
+}
+
+var _ = Suite(&Ks3BucketPolicySuite{})
+
+func (s *Ks3BucketPolicySuite) SetUpSuite(c *C) {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["policy"]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			s.policy = string(body)
+			s.puts++
+			w.WriteHeader(http.StatusNoContent)
+		case "GET":
+			w.Write([]byte(s.policy))
+		}
+	}))
+}
+
+func (s *Ks3BucketPolicySuite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3BucketPolicySuite) newClient(c *C) *Client {
+	addr := s.server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	return client
+}
+
+func (s *Ks3BucketPolicySuite) TestBucketPolicyDocument(c *C) {
+	client := s.newClient(c)
+	doc := policy.New().
+		AddStatement(policy.ReadOnlyPrefix("policy-bucket", "public/")...).
+		AddStatement(policy.DenyOutsideIPs("policy-bucket", "10.0.0.0/8")...)
+	c.Assert(client.SetBucketPolicyDocument("policy-bucket", doc), IsNil)
+	c.Assert(s.puts, Equals, 1)
+
+	// The raw JSON is the same document
+	raw, err := client.GetBucketPolicy("policy-bucket")
+	c.Assert(err, IsNil)
+	expected, err := doc.JSON()
+	c.Assert(err, IsNil)
+	c.Assert(raw, Equals, expected)
+
+	got, err := client.GetBucketPolicyDocument("policy-bucket")
+	c.Assert(err, IsNil)
+	c.Assert(got, DeepEquals, doc)
+
+	// The invalid document isn't sent
+	invalid := policy.New().AddStatement(policy.NewStatement(policy.Allow).
+		WithPrincipals(policy.AnyPrincipal).
+		WithActions("ks3:GetObjects").
+		WithResources("policy-bucket/*"))
+	err = client.SetBucketPolicyDocument("policy-bucket", invalid)
+	errs, ok := err.(policy.ValidationErrors)
+	c.Assert(ok, Equals, true)
+	c.Assert(len(errs), Equals, 2)
+	c.Assert(s.puts, Equals, 1)
+	c.Assert(client.SetBucketPolicyDocument("policy-bucket", nil), NotNil)
+
+	// The raw JSON which isn't a document
+	c.Assert(client.SetBucketPolicy("policy-bucket", "not json"), IsNil)
+	_, err = client.GetBucketPolicyDocument("policy-bucket")
+	c.Assert(err, NotNil)
+}
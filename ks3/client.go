@@ -4,6 +4,7 @@ package ks3
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/json"
@@ -70,9 +71,15 @@ func New(endpoint, accessKeyID, accessKeySecret string, options ...ClientOption)
 		option(client)
 	}
 
-	if config.AuthVersion != AuthV1 && config.AuthVersion != AuthV2 {
+	if config.AuthVersion != AuthV1 && config.AuthVersion != AuthV2 && config.AuthVersion != AuthV4 {
 		return nil, fmt.Errorf("Init client Error, invalid Auth version: %v", config.AuthVersion)
 	}
+	if config.AuthVersion == AuthV4 && config.Region == "" {
+		config.Region = regionFromEndpoint(url.NetLoc)
+		if config.Region == "" {
+			return nil, fmt.Errorf("Init client Error, the region of v4 signature can't be parsed from endpoint %s", config.Endpoint)
+		}
+	}
 
 	// Create HTTP connection
 	err = conn.init(config, url, client.HTTPClient)
@@ -94,8 +101,8 @@ func (client Client) Bucket(bucketName string) (*Bucket, error) {
 	}
 
 	return &Bucket{
-		client,
-		bucketName,
+		Client:     client,
+		BucketName: bucketName,
 	}, nil
 }
 
@@ -2161,13 +2168,27 @@ func SetLocalAddr(localAddr net.Addr) ClientOption {
 	}
 }
 
-// AuthVersion  sets auth version: v1 or v2 signature which ks3_server needed
+// AuthVersion  sets auth version: v1, v2 or v4 signature which ks3_server needed
 func AuthVersion(authVersion AuthVersionType) ClientOption {
 	return func(client *Client) {
 		client.Config.AuthVersion = authVersion
 	}
 }
 
+// Region sets the region of v4 signature, such as cn-beijing
+func Region(region string) ClientOption {
+	return func(client *Client) {
+		client.Config.Region = region
+	}
+}
+
+// PayloadSigning sets how the request body is signed by v4 signature
+func PayloadSigning(payloadSigning PayloadSigningType) ClientOption {
+	return func(client *Client) {
+		client.Config.PayloadSigning = payloadSigning
+	}
+}
+
 // AdditionalHeaders sets special http headers needed to be signed
 func AdditionalHeaders(headers []string) ClientOption {
 	return func(client *Client) {
@@ -2240,7 +2261,10 @@ func (client Client) do(method, bucketName string, params map[string]interface{}
 		}
 	}
 
-	resp, err := client.Conn.Do(method, bucketName, "", params, headers, data, 0, nil)
+	ctxArg, _ := FindOption(options, contextArg, nil)
+	ctx, _ := ctxArg.(context.Context)
+
+	resp, err := client.Conn.DoWithContext(ctx, method, bucketName, "", params, headers, data, 0, nil)
 
 	// get response header
 	respHeader, _ := FindOption(options, responseHeader, nil)
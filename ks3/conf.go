@@ -2,6 +2,7 @@ package ks3
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -108,7 +109,9 @@ type Config struct {
 	CredentialsProvider  CredentialsProvider // User provides interface to get AccessKeyID, AccessKeySecret, SecurityToken
 	LocalAddr            net.Addr            // local client host info
 	UserSetUa            bool                // UserAgent is set by user or not
-	AuthVersion          AuthVersionType     //  v1 or v2 signature,default is v1
+	AuthVersion          AuthVersionType     //  v1, v2 or v4 signature,default is v1
+	Region               string              //  the region of v4 signature, it's parsed from the endpoint if empty
+	PayloadSigning       PayloadSigningType  //  how the request body is signed by v4 signature, default is PayloadSigned
 	AdditionalHeaders    []string            //  special http headers needed to be sign
 	RedirectEnabled      bool                //  only effective from go1.7 onward, enable http redirect or not
 	InsecureSkipVerify   bool                //  for https, Whether to skip verifying the server certificate file
@@ -199,6 +202,24 @@ func (config *Config) GetCredentials() Credentials {
 	return config.CredentialsProvider.GetCredentials()
 }
 
+// getCredentials gets the credentials for signing, it reports the error if the provider implements CredentialsRetriever
+func (config *Config) getCredentials() (Credentials, error) {
+	if retriever, ok := config.CredentialsProvider.(CredentialsRetriever); ok {
+		cre, err := retriever.Retrieve()
+		if err != nil {
+			return nil, err
+		}
+		if cre == nil {
+			return nil, errors.New("ks3: the credentials provider returns no credentials")
+		}
+		if cre.IsExpired(time.Now()) {
+			return nil, ErrCredentialsExpired
+		}
+		return cre, nil
+	}
+	return config.CredentialsProvider.GetCredentials(), nil
+}
+
 // getDefaultKs3Config gets the default configuration.
 func getDefaultKs3Config() *Config {
 	config := Config{}
@@ -20,6 +20,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
 )
 
 // Conn defines KS3 Conn
@@ -29,30 +31,7 @@ type Conn struct {
 	client *http.Client
 }
 
-var signKeyList = []string{"acl", "uploads", "location", "cors",
-	"logging", "website", "referer", "lifecycle",
-	"retention", "recycle", "recover", "clear",
-	"crr", "mirror", "inventory", "id", "encryption",
-	"delete", "append", "tagging", "objectMeta",
-	"uploadId", "partNumber", "security-token",
-	"position", "img", "style", "styleName",
-	"replication", "replicationProgress",
-	"replicationLocation", "cname", "bucketInfo",
-	"comp", "qos", "live", "status", "vod",
-	"startTime", "endTime", "symlink",
-	"x-ks3-process", "response-content-type", "x-ks3-traffic-limit",
-	"response-content-language", "response-expires",
-	"response-cache-control", "response-content-disposition",
-	"response-content-encoding", "udf", "udfName", "udfImage",
-	"udfId", "udfImageDesc", "udfApplication", "comp",
-	"udfApplicationLog", "restore", "callback", "callback-var", "qosInfo",
-	"policy", "stat", "encryption", "versions", "versioning", "versionId", "requestPayment",
-	"x-ks3-request-payer", "sequential", "asyncFetch",
-	"worm", "wormId", "wormExtend", "withHashContext",
-	"x-ks3-enable-md5", "x-ks3-enable-sha1", "x-ks3-enable-sha256",
-	"x-ks3-hash-ctx", "x-ks3-md5-ctx", "transferAcceleration",
-	"regionList",
-}
+var signKeyList = sign.SubResources()
 
 var policySignKeyList = []string{"X-Kss-Policy", "security-token"}
 
@@ -360,6 +339,11 @@ func (conn Conn) doRequest(ctx context.Context, method string, uri *url.URL, can
 		req = req.WithContext(ctx)
 	}
 
+	// The body is hashed before it's wrapped
+	if conn.config.AuthVersion == AuthV4 {
+		conn.setV4PayloadHash(req, data)
+	}
+
 	tracker := &readerTracker{completedBytes: 0}
 	fd, crc := conn.handleBody(req, data, initCRC, listener, tracker)
 	if fd != nil {
@@ -380,7 +364,10 @@ func (conn Conn) doRequest(ctx context.Context, method string, uri *url.URL, can
 	req.Header.Set(HTTPHeaderHost, req.Host)
 	req.Header.Set(HTTPHeaderUserAgent, conn.config.UserAgent)
 
-	akIf := conn.config.GetCredentials()
+	akIf, err := conn.config.getCredentials()
+	if err != nil {
+		return nil, err
+	}
 	if akIf.GetSecurityToken() != "" {
 		req.Header.Set(HTTPHeaderKs3SecurityToken, akIf.GetSecurityToken())
 	}
@@ -391,7 +378,7 @@ func (conn Conn) doRequest(ctx context.Context, method string, uri *url.URL, can
 		}
 	}
 
-	conn.signHeader(req, canonicalizedResource)
+	conn.signHeaderWithCredentials(req, canonicalizedResource, akIf)
 
 	// Transfer started
 	event := newProgressEvent(TransferStartedEvent, 0, req.ContentLength, 0)
@@ -429,61 +416,20 @@ func (conn Conn) doRequest(ctx context.Context, method string, uri *url.URL, can
 	return ks3Resp, err
 }
 
-func (conn Conn) signURL(method HTTPMethod, bucketName, objectName string, expiration int64, params map[string]interface{}, headers map[string]string) string {
-	akIf := conn.config.GetCredentials()
-	if akIf.GetSecurityToken() != "" {
-		params[HTTPParamSecurityToken] = akIf.GetSecurityToken()
-	}
-
-	m := strings.ToUpper(string(method))
-	req := &http.Request{
-		Method: m,
-		Header: make(http.Header),
-	}
-
-	if conn.config.IsAuthProxy {
-		auth := conn.config.ProxyUser + ":" + conn.config.ProxyPassword
-		basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
-		req.Header.Set("Proxy-Authorization", basic)
-	}
-
-	req.Header.Set(HTTPHeaderDate, strconv.FormatInt(expiration, 10))
-	req.Header.Set(HTTPHeaderUserAgent, conn.config.UserAgent)
-
-	if headers != nil {
-		for k, v := range headers {
-			req.Header.Set(k, v)
-		}
-	}
-
-	if conn.config.AuthVersion == AuthV2 {
-		params[HTTPParamSignatureVersion] = "KSS2"
-		params[HTTPParamExpiresV2] = strconv.FormatInt(expiration, 10)
-		params[HTTPParamAccessKeyIDV2] = conn.config.AccessKeyID
-		additionalList, _ := conn.getAdditionalHeaderKeys(req)
-		if len(additionalList) > 0 {
-			params[HTTPParamAdditionalHeadersV2] = strings.Join(additionalList, ";")
-		}
-	}
-
-	subResource := conn.getSubResource(params)
-	canonicalResource := conn.getResource(bucketName, objectName, subResource)
-	signedStr := conn.getSignedStr(req, canonicalResource, akIf.GetAccessKeySecret())
-
-	if conn.config.AuthVersion == AuthV1 {
-		params[HTTPParamExpires] = strconv.FormatInt(expiration, 10)
-		params[HTTPParamAccessKeyID] = akIf.GetAccessKeyID()
-		params[HTTPParamSignature] = signedStr
-	} else if conn.config.AuthVersion == AuthV2 {
-		params[HTTPParamSignatureV2] = signedStr
+// signURL signs the URL of the object for the client endpoint, the expiration is the unix time
+func (conn Conn) signURL(method HTTPMethod, bucketName, objectName string, expiration int64, params map[string]interface{}, headers map[string]string) (string, error) {
+	p, err := conn.newURLPresigner(*conn.Url, method, bucketName, expiration, params, headers)
+	if err != nil {
+		return "", err
 	}
-	str := encodeKS3Str(objectName)
-	urlParams := conn.getURLParams(params)
-	return conn.Url.getSignURL(bucketName, str, urlParams)
+	return p.sign(objectName), nil
 }
 
-func (conn Conn) signPolicyURL(bucketName string, expiration int64, params map[string]interface{}) string {
-	akIf := conn.config.GetCredentials()
+func (conn Conn) signPolicyURL(bucketName string, expiration int64, params map[string]interface{}) (string, error) {
+	akIf, err := conn.config.getCredentials()
+	if err != nil {
+		return "", err
+	}
 	if akIf.GetSecurityToken() != "" {
 		params[HTTPParamSecurityToken] = akIf.GetSecurityToken()
 	}
@@ -498,10 +444,10 @@ func (conn Conn) signPolicyURL(bucketName string, expiration int64, params map[s
 	params[HTTPParamSignature] = signedStr
 
 	urlParams := conn.getURLParams(params)
-	return conn.Url.getSignURL(bucketName, "", urlParams)
+	return conn.Url.getSignURL(bucketName, "", urlParams), nil
 }
 
-func (conn Conn) signRtmpURL(bucketName, channelName, playlistName string, expiration int64) string {
+func (conn Conn) signRtmpURL(bucketName, channelName, playlistName string, expiration int64) (string, error) {
 	params := map[string]interface{}{}
 	if playlistName != "" {
 		params[HTTPParamPlaylistName] = playlistName
@@ -509,7 +455,10 @@ func (conn Conn) signRtmpURL(bucketName, channelName, playlistName string, expir
 	expireStr := strconv.FormatInt(expiration, 10)
 	params[HTTPParamExpires] = expireStr
 
-	akIf := conn.config.GetCredentials()
+	akIf, err := conn.config.getCredentials()
+	if err != nil {
+		return "", err
+	}
 	if akIf.GetAccessKeyID() != "" {
 		params[HTTPParamAccessKeyID] = akIf.GetAccessKeyID()
 		if akIf.GetSecurityToken() != "" {
@@ -520,7 +469,7 @@ func (conn Conn) signRtmpURL(bucketName, channelName, playlistName string, expir
 	}
 
 	urlParams := conn.getURLParams(params)
-	return conn.Url.getSignRtmpURL(bucketName, channelName, urlParams)
+	return conn.Url.getSignRtmpURL(bucketName, channelName, urlParams), nil
 }
 
 func IsEmpty(r io.Reader) bool {
@@ -181,14 +181,16 @@ func (s *Ks3ConnSuite) TestSignRtmpURL(c *C) {
 	channelName := "test-sign-rtmp-url"
 	playlistName := "playlist.m3u8"
 	expiration := time.Now().Unix() + 3600
-	signedRtmpURL := conn.signRtmpURL(bucketName, channelName, playlistName, expiration)
+	signedRtmpURL, err := conn.signRtmpURL(bucketName, channelName, playlistName, expiration)
+	c.Assert(err, IsNil)
 	playURL := getPublishURL(bucketName, channelName)
 	hasPrefix := strings.HasPrefix(signedRtmpURL, playURL)
 	c.Assert(hasPrefix, Equals, true)
 
 	//empty playlist name
 	playlistName = ""
-	signedRtmpURL = conn.signRtmpURL(bucketName, channelName, playlistName, expiration)
+	signedRtmpURL, err = conn.signRtmpURL(bucketName, channelName, playlistName, expiration)
+	c.Assert(err, IsNil)
 	playURL = getPublishURL(bucketName, channelName)
 	hasPrefix = strings.HasPrefix(signedRtmpURL, playURL)
 	c.Assert(hasPrefix, Equals, true)
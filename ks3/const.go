@@ -205,12 +205,16 @@ const (
 	HTTPHeaderKs3Prefix                      = "X-Kss-"
 	HTTPHeaderKs3ObjectACL                   = "X-Kss-Acl"
 	HTTPHeaderKs3SecurityToken               = "X-Kss-Security-Token"
+	HTTPHeaderKs3ContentSha256               = "X-Kss-Content-Sha256"
 	HTTPHeaderKs3ServerSideEncryption        = "X-Kss-Server-Side-Encryption"
 	HTTPHeaderKs3ServerSideEncryptionKeyID   = "X-Kss-Server-Side-Encryption-Key-Id"
 	HTTPHeaderKs3ServerSideDataEncryption    = "X-Kss-Server-Side-Data-Encryption"
 	HTTPHeaderSSECAlgorithm                  = "X-Kss-Server-Side-Encryption-Customer-Algorithm"
 	HTTPHeaderSSECKey                        = "X-Kss-Server-Side-Encryption-Customer-Key"
 	HTTPHeaderSSECKeyMd5                     = "X-Kss-Server-Side-Encryption-Customer-Key-MD5"
+	HTTPHeaderCopySourceSSECAlgorithm        = "X-Kss-Copy-Source-Server-Side-Encryption-Customer-Algorithm"
+	HTTPHeaderCopySourceSSECKey              = "X-Kss-Copy-Source-Server-Side-Encryption-Customer-Key"
+	HTTPHeaderCopySourceSSECKeyMd5           = "X-Kss-Copy-Source-Server-Side-Encryption-Customer-Key-MD5"
 	HTTPHeaderKs3CopySource                  = "X-Kss-Copy-Source"
 	HTTPHeaderKs3CopySourceRange             = "X-Kss-Copy-Source-Range"
 	HTTPHeaderKs3CopySourceIfMatch           = "X-Kss-Copy-Source-If-Match"
@@ -223,9 +227,11 @@ const (
 	HTTPHeaderKs3CRC64                       = "X-Kss-Checksum-Crc64ecma"
 	HTTPHeaderKs3SymlinkTarget               = "X-Kss-Symlink-Target"
 	HTTPHeaderKs3StorageClass                = "X-Kss-Storage-Class"
+	HTTPHeaderKs3Restore                     = "X-Kss-Restore"
 	HTTPHeaderKs3Callback                    = "X-Kss-Callback"
 	HTTPHeaderKs3CallbackVar                 = "X-Kss-Callback-Var"
 	HTTPHeaderKs3Requester                   = "X-Kss-Request-Payer"
+	HTTPHeaderKs3SelectOutputRaw             = "X-Kss-Select-Output-Raw"
 	HTTPHeaderKs3Tagging                     = "X-Kss-Tagging"
 	HTTPHeaderKs3TaggingCount                = "X-Kss-Tagging-Count"
 	HTTPHeaderKs3TaggingDirective            = "X-Kss-Tagging-Directive"
@@ -293,6 +299,20 @@ const (
 	AuthV1 AuthVersionType = "v1"
 	// AuthV2 v2
 	AuthV2 AuthVersionType = "v2"
+	// AuthV4 v4, compatible with AWS Signature Version 4
+	AuthV4 AuthVersionType = "v4"
+)
+
+// PayloadSigningType how the request body is signed by v4 signature
+type PayloadSigningType string
+
+const (
+	// PayloadSigned signs the SHA256 of the seekable body, other bodies are unsigned. It's the default.
+	PayloadSigned PayloadSigningType = "signed"
+	// PayloadUnsigned sends UNSIGNED-PAYLOAD
+	PayloadUnsigned PayloadSigningType = "unsigned"
+	// PayloadStreaming signs the body in chunks, the body is unsigned if its length is unknown
+	PayloadStreaming PayloadSigningType = "streaming"
 )
 
 const ALL_USERS = "http://acs.ksyun.com/groups/global/AllUsers"
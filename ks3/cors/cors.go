@@ -0,0 +1,170 @@
+// Package cors evaluates the CORS rules of a bucket locally, so the browser CORS failures can be debugged
+// without trial and error against the live bucket:
+//
+//	result := cors.Match(rules, "https://www.example.com", "PUT", []string{"Content-Type", "X-Kss-Meta-Owner"})
+//	if !result.Matched {
+//		fmt.Println(result.Explain()) // why each rule didn't match
+//	}
+//	fmt.Println(result.Headers) // the Access-Control-* headers KS3 responds with
+//
+// Preflight sends the OPTIONS request by Bucket.OptionsMethod and checks the response against the rules.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// The response headers of CORS
+const (
+	HeaderAllowOrigin   = "Access-Control-Allow-Origin"
+	HeaderAllowMethods  = "Access-Control-Allow-Methods"
+	HeaderAllowHeaders  = "Access-Control-Allow-Headers"
+	HeaderExposeHeaders = "Access-Control-Expose-Headers"
+	HeaderMaxAge        = "Access-Control-Max-Age"
+)
+
+// responseHeaders are the headers compared by CheckResponse
+var responseHeaders = []string{HeaderAllowOrigin, HeaderAllowMethods, HeaderAllowHeaders, HeaderExposeHeaders, HeaderMaxAge}
+
+// MatchResult is the result of Match
+type MatchResult struct {
+	Matched bool
+	Index   int          // The index of the first matched rule, -1 if no rule matched
+	Rule    ks3.CORSRule // The first matched rule
+	Headers http.Header  // The Access-Control-* headers of the response, empty if no rule matched
+	Reasons []string     // Why the rules before the matched one (or all the rules) didn't match, one for each rule
+}
+
+// Explain describes why no rule matched, or which rule matched
+func (r *MatchResult) Explain() string {
+	if r.Matched {
+		return fmt.Sprintf("rule %d matched", r.Index)
+	}
+	if len(r.Reasons) == 0 {
+		return "no CORS rule is configured"
+	}
+	return strings.Join(r.Reasons, "; ")
+}
+
+// Match finds the first rule which allows the cross-origin request, as KS3 does for the preflight request and
+// the actual request. The origin must match an allowed origin, which may contain a wildcard * such as
+// https://*.example.com, the method must be an allowed method, and each request header must match an allowed
+// header, case-insensitively and with the wildcards too.
+//
+// The headers of the result are the ones KS3 responds with: the allowed origin is * if the rule allows any origin,
+// otherwise it's the origin of the request; the allowed methods and the exposed headers are the ones of the rule;
+// the allowed headers are the request headers in lower case.
+func Match(rules []ks3.CORSRule, origin, method string, requestHeaders []string) *MatchResult {
+	result := &MatchResult{Index: -1, Headers: http.Header{}}
+	for i, rule := range rules {
+		if reason := mismatch(rule, origin, method, requestHeaders); reason != "" {
+			result.Reasons = append(result.Reasons, fmt.Sprintf("rule %d: %s", i, reason))
+			continue
+		}
+		result.Matched, result.Index, result.Rule = true, i, rule
+		result.Headers = ruleHeaders(rule, origin, requestHeaders)
+		break
+	}
+	return result
+}
+
+// mismatch returns why the rule doesn't allow the request, empty if it does
+func mismatch(rule ks3.CORSRule, origin, method string, requestHeaders []string) string {
+	if origin == "" {
+		return "the request has no Origin header"
+	}
+	if !matchAny(rule.AllowedOrigin, origin) {
+		return fmt.Sprintf("the origin %q isn't in the allowed origins %q", origin, rule.AllowedOrigin)
+	}
+	allowed := false
+	for _, m := range rule.AllowedMethod {
+		if strings.EqualFold(strings.TrimSpace(m), method) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Sprintf("the method %q isn't in the allowed methods %q", method, rule.AllowedMethod)
+	}
+	for _, h := range requestHeaders {
+		if h = strings.TrimSpace(h); h != "" && !matchAny(rule.AllowedHeader, h) {
+			return fmt.Sprintf("the header %q isn't in the allowed headers %q", h, rule.AllowedHeader)
+		}
+	}
+	return ""
+}
+
+// ruleHeaders returns the response headers of the matched rule
+func ruleHeaders(rule ks3.CORSRule, origin string, requestHeaders []string) http.Header {
+	headers := http.Header{}
+	headers.Set(HeaderAllowOrigin, origin)
+	for _, o := range rule.AllowedOrigin {
+		if strings.TrimSpace(o) == "*" {
+			headers.Set(HeaderAllowOrigin, "*")
+			break
+		}
+	}
+	headers.Set(HeaderAllowMethods, strings.Join(trimAll(rule.AllowedMethod), ", "))
+	var lower []string
+	for _, h := range trimAll(requestHeaders) {
+		lower = append(lower, strings.ToLower(h))
+	}
+	if len(lower) > 0 {
+		headers.Set(HeaderAllowHeaders, strings.Join(lower, ", "))
+	}
+	if expose := trimAll(rule.ExposeHeader); len(expose) > 0 {
+		headers.Set(HeaderExposeHeaders, strings.Join(expose, ", "))
+	}
+	if rule.MaxAgeSeconds > 0 {
+		headers.Set(HeaderMaxAge, strconv.Itoa(rule.MaxAgeSeconds))
+	}
+	return headers
+}
+
+// trimAll trims the values and drops the empty ones
+func trimAll(values []string) []string {
+	var result []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// matchAny reports whether the value matches any pattern case-insensitively, the patterns may contain
+// a wildcard *
+func matchAny(patterns []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, p := range patterns {
+		if matchWildcard(strings.ToLower(strings.TrimSpace(p)), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard matches the value with the pattern, * matches any sequence
+func matchWildcard(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		pos := strings.Index(value, part)
+		if pos < 0 {
+			return false
+		}
+		value = value[pos+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
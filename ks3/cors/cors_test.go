@@ -0,0 +1,162 @@
+package cors
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+	. "gopkg.in/check.v1"
+)
+
+// Test hooks up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type Ks3CORSSuite struct{}
+
+var _ = Suite(&Ks3CORSSuite{})
+
+var testRules = []ks3.CORSRule{
+	{
+		AllowedOrigin: []string{"https://*.example.com"},
+		AllowedMethod: []string{"GET", "PUT"},
+		AllowedHeader: []string{"Content-Type", "x-kss-meta-*"},
+		ExposeHeader:  []string{"ETag", "x-kss-request-id"},
+		MaxAgeSeconds: 600,
+	},
+	{
+		AllowedOrigin: []string{"*"},
+		AllowedMethod: []string{"GET", "HEAD"},
+	},
+}
+
+func (s *Ks3CORSSuite) TestMatch(c *C) {
+	result := Match(testRules, "https://www.example.com", "PUT", []string{"Content-Type", "X-Kss-Meta-Owner"})
+	c.Assert(result.Matched, Equals, true)
+	c.Assert(result.Index, Equals, 0)
+	c.Assert(result.Explain(), Equals, "rule 0 matched")
+	c.Assert(result.Headers, DeepEquals, http.Header{
+		HeaderAllowOrigin:   {"https://www.example.com"},
+		HeaderAllowMethods:  {"GET, PUT"},
+		HeaderAllowHeaders:  {"content-type, x-kss-meta-owner"},
+		HeaderExposeHeaders: {"ETag, x-kss-request-id"},
+		HeaderMaxAge:        {"600"},
+	})
+
+	// The first rule doesn't allow the origin, the second allows any origin
+	result = Match(testRules, "https://example.org", "GET", nil)
+	c.Assert(result.Index, Equals, 1)
+	c.Assert(result.Reasons, DeepEquals, []string{`rule 0: the origin "https://example.org" isn't in the allowed origins ["https://*.example.com"]`})
+	c.Assert(result.Headers, DeepEquals, http.Header{
+		HeaderAllowOrigin:  {"*"},
+		HeaderAllowMethods: {"GET, HEAD"},
+	})
+
+	// The wildcard origin requires the subdomain
+	result = Match(testRules[:1], "https://example.com", "GET", nil)
+	c.Assert(result.Matched, Equals, false)
+
+	result = Match(testRules, "https://www.example.com", "DELETE", nil)
+	c.Assert(result.Matched, Equals, false)
+	c.Assert(result.Index, Equals, -1)
+	c.Assert(len(result.Headers), Equals, 0)
+	c.Assert(result.Explain(), Equals, `rule 0: the method "DELETE" isn't in the allowed methods ["GET" "PUT"]; `+
+		`rule 1: the method "DELETE" isn't in the allowed methods ["GET" "HEAD"]`)
+
+	result = Match(testRules[:1], "https://www.example.com", "PUT", []string{"Authorization"})
+	c.Assert(result.Explain(), Equals, `rule 0: the header "Authorization" isn't in the allowed headers ["Content-Type" "x-kss-meta-*"]`)
+
+	result = Match(testRules, "", "GET", nil)
+	c.Assert(result.Reasons[0], Equals, "rule 0: the request has no Origin header")
+	c.Assert(Match(nil, "https://www.example.com", "GET", nil).Explain(), Equals, "no CORS rule is configured")
+}
+
+func (s *Ks3CORSSuite) TestMatchWildcard(c *C) {
+	c.Assert(matchWildcard("*", ""), Equals, true)
+	c.Assert(matchWildcard("a*b*c", "abc"), Equals, true)
+	c.Assert(matchWildcard("a*b*c", "axxbyyc"), Equals, true)
+	c.Assert(matchWildcard("a*b*c", "axxcyyb"), Equals, false)
+	c.Assert(matchWildcard("*.com", "a.com.cn"), Equals, false)
+	c.Assert(matchWildcard("ab", "abc"), Equals, false)
+}
+
+func (s *Ks3CORSSuite) TestCheckResponse(c *C) {
+	result := Match(testRules, "https://www.example.com", "PUT", []string{"Content-Type"})
+	headers := http.Header{
+		HeaderAllowOrigin:   {"https://www.example.com"},
+		HeaderAllowMethods:  {"PUT,GET"},
+		HeaderAllowHeaders:  {"Content-Type"},
+		HeaderExposeHeaders: {"x-kss-request-id, etag"},
+		HeaderMaxAge:        {"600"},
+	}
+	c.Assert(CheckResponse(result, headers), IsNil)
+
+	headers.Set(HeaderMaxAge, "60")
+	headers.Del(HeaderExposeHeaders)
+	mismatches := CheckResponse(result, headers)
+	c.Assert(mismatches, DeepEquals, []Mismatch{
+		{Header: HeaderExposeHeaders, Expected: "ETag, x-kss-request-id"},
+		{Header: HeaderMaxAge, Expected: "600", Actual: "60"},
+	})
+	c.Assert(mismatches[1].String(), Equals, `Access-Control-Max-Age: expected "600", got "60"`)
+}
+
+func (s *Ks3CORSSuite) TestPreflight(c *C) {
+	// The server evaluates the rules by Match too, except it ignores the max age
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "OPTIONS")
+		var headers []string
+		if v := r.Header.Get(ks3.HTTPHeaderACReqHeaders); v != "" {
+			headers = strings.Split(v, ",")
+		}
+		result := Match(testRules, r.Header.Get(ks3.HTTPHeaderOrigin), r.Header.Get(ks3.HTTPHeaderACReqMethod), headers)
+		if !result.Matched {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		for k, v := range result.Headers {
+			if k != HeaderMaxAge {
+				w.Header()[k] = v
+			}
+		}
+	}))
+	defer server.Close()
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+	client, err := ks3.New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", ks3.HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("cors-bucket")
+	c.Assert(err, IsNil)
+
+	result, mismatches, err := Preflight(bucket, "a.txt", testRules, "https://www.example.com", "GET", nil)
+	c.Assert(err, IsNil)
+	c.Assert(result.Index, Equals, 0)
+	c.Assert(mismatches, DeepEquals, []Mismatch{{Header: HeaderMaxAge, Expected: "600"}})
+
+	result, mismatches, err = Preflight(bucket, "a.txt", testRules, "https://example.org", "HEAD", nil)
+	c.Assert(err, IsNil)
+	c.Assert(result.Index, Equals, 1)
+	c.Assert(mismatches, IsNil)
+
+	// Rejected as expected
+	result, mismatches, err = Preflight(bucket, "a.txt", testRules, "https://example.org", "PUT", nil)
+	c.Assert(err, IsNil)
+	c.Assert(result.Matched, Equals, false)
+	c.Assert(mismatches, IsNil)
+
+	// Rejected, but the local rules allow it
+	rules := append([]ks3.CORSRule{{AllowedOrigin: []string{"*"}, AllowedMethod: []string{"PUT"}}}, testRules...)
+	result, mismatches, err = Preflight(bucket, "a.txt", rules, "https://example.org", "PUT", nil)
+	c.Assert(err, IsNil)
+	c.Assert(result.Matched, Equals, true)
+	c.Assert(mismatches, DeepEquals, []Mismatch{{Header: HeaderAllowOrigin, Expected: "*"}})
+}
@@ -0,0 +1,81 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// Mismatch is a response header which differs from the one the rules expect
+type Mismatch struct {
+	Header   string
+	Expected string // Empty if the header isn't expected
+	Actual   string // Empty if the header is missing
+}
+
+// String describes the mismatch
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: expected %q, got %q", m.Header, m.Expected, m.Actual)
+}
+
+// CheckResponse compares the Access-Control-* headers of the response with the ones Match expects.
+// The lists such as the allowed methods are compared as sets, case-insensitively.
+// It returns nil if the response is consistent with the rules.
+func CheckResponse(result *MatchResult, headers http.Header) []Mismatch {
+	var mismatches []Mismatch
+	for _, name := range responseHeaders {
+		expected, actual := result.Headers.Get(name), headers.Get(name)
+		if name == HeaderAllowOrigin || name == HeaderMaxAge {
+			if expected != actual {
+				mismatches = append(mismatches, Mismatch{Header: name, Expected: expected, Actual: actual})
+			}
+			continue
+		}
+		if normalizeList(expected) != normalizeList(actual) {
+			mismatches = append(mismatches, Mismatch{Header: name, Expected: expected, Actual: actual})
+		}
+	}
+	return mismatches
+}
+
+// normalizeList sorts the comma separated values in lower case
+func normalizeList(value string) string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, strings.ToLower(v))
+		}
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+// Preflight sends the preflight request of the object by Bucket.OptionsMethod, and checks the response
+// against the rules, such as the ones of Client.GetBucketCORS. The request rejected with 403 is consistent
+// if no rule matches.
+//
+// It returns the expected result and the mismatches, the error is returned only if the request fails otherwise.
+func Preflight(bucket *ks3.Bucket, objectKey string, rules []ks3.CORSRule, origin, method string,
+	requestHeaders []string, options ...ks3.Option) (*MatchResult, []Mismatch, error) {
+	result := Match(rules, origin, method, requestHeaders)
+
+	options = append(options, ks3.Origin(origin), ks3.ACReqMethod(method))
+	if len(requestHeaders) > 0 {
+		options = append(options, ks3.ACReqHeaders(strings.Join(requestHeaders, ",")))
+	}
+	headers, err := bucket.OptionsMethod(objectKey, options...)
+	if err != nil {
+		serviceErr, ok := err.(ks3.ServiceError)
+		if !ok || serviceErr.StatusCode != http.StatusForbidden {
+			return result, nil, err
+		}
+		if result.Matched {
+			return result, []Mismatch{{Header: HeaderAllowOrigin, Expected: result.Headers.Get(HeaderAllowOrigin)}}, nil
+		}
+		return result, nil, nil
+	}
+	return result, CheckResponse(result, headers), nil
+}
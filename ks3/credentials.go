@@ -0,0 +1,431 @@
+package ks3
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables read by the credentials providers
+const (
+	EnvAccessKeyID           = "KS3_ACCESS_KEY_ID"
+	EnvAccessKeySecret       = "KS3_ACCESS_KEY_SECRET"
+	EnvSecurityToken         = "KS3_SECURITY_TOKEN"
+	EnvSharedCredentialsFile = "KS3_SHARED_CREDENTIALS_FILE"
+	EnvProfile               = "KS3_PROFILE"
+)
+
+// DefaultProfile is the profile name used when neither the provider nor KS3_PROFILE names one
+const DefaultProfile = "default"
+
+// DefaultExpiryWindow is how long before the expiration CachedCredentialsProvider refreshes the credentials
+const DefaultExpiryWindow = 5 * time.Minute
+
+// ErrCredentialsExpired is returned when the credentials are expired and can't be refreshed
+var ErrCredentialsExpired = errors.New("ks3: credentials are expired")
+
+// Ks3Credentials is the credentials with its expiration, it implements Credentials.
+type Ks3Credentials struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SecurityToken   string    // STS token, empty for the long-term credentials
+	Expiration      time.Time // The zero time means the credentials never expire
+	Source          string    // The name of the provider which returns the credentials
+}
+
+func (cre *Ks3Credentials) GetAccessKeyID() string {
+	return cre.AccessKeyID
+}
+
+func (cre *Ks3Credentials) GetAccessKeySecret() string {
+	return cre.AccessKeySecret
+}
+
+func (cre *Ks3Credentials) GetSecurityToken() string {
+	return cre.SecurityToken
+}
+
+// IsExpired checks whether the credentials are expired at the time
+func (cre *Ks3Credentials) IsExpired(now time.Time) bool {
+	return !cre.Expiration.IsZero() && !now.Before(cre.Expiration)
+}
+
+// CredentialsRetriever retrieves the credentials and reports the error, the providers in this file implement it.
+// If the Config.CredentialsProvider implements it too, the requests fail with the error instead of sending unsigned.
+type CredentialsRetriever interface {
+	Retrieve() (*Ks3Credentials, error)
+}
+
+// CredentialsRetrieverFunc is an adapter to use a function as CredentialsRetriever
+type CredentialsRetrieverFunc func() (*Ks3Credentials, error)
+
+// Retrieve calls f()
+func (f CredentialsRetrieverFunc) Retrieve() (*Ks3Credentials, error) {
+	return f()
+}
+
+// retrieveCredentials returns the credentials of the retriever, or the empty credentials if it fails
+func retrieveCredentials(retriever CredentialsRetriever) Credentials {
+	cre, err := retriever.Retrieve()
+	if err != nil || cre == nil {
+		return &Ks3Credentials{}
+	}
+	return cre
+}
+
+// StaticCredentialsProvider returns the fixed credentials
+type StaticCredentialsProvider struct {
+	credentials Ks3Credentials
+}
+
+// NewStaticCredentialsProvider creates the provider of the fixed credentials.
+//
+// accessKeyID    the access key ID.
+// accessKeySecret    the access key secret.
+// securityToken    the STS token, it's optional.
+//
+// *StaticCredentialsProvider    the credentials provider.
+//
+func NewStaticCredentialsProvider(accessKeyID, accessKeySecret, securityToken string) *StaticCredentialsProvider {
+	return &StaticCredentialsProvider{credentials: Ks3Credentials{
+		AccessKeyID:     accessKeyID,
+		AccessKeySecret: accessKeySecret,
+		SecurityToken:   securityToken,
+		Source:          "static",
+	}}
+}
+
+// Retrieve implements CredentialsRetriever
+func (p *StaticCredentialsProvider) Retrieve() (*Ks3Credentials, error) {
+	if p.credentials.AccessKeyID == "" || p.credentials.AccessKeySecret == "" {
+		return nil, errors.New("ks3: static credentials are empty")
+	}
+	cre := p.credentials
+	return &cre, nil
+}
+
+// GetCredentials implements CredentialsProvider
+func (p *StaticCredentialsProvider) GetCredentials() Credentials {
+	return retrieveCredentials(p)
+}
+
+// EnvCredentialsProvider reads the credentials from KS3_ACCESS_KEY_ID, KS3_ACCESS_KEY_SECRET and KS3_SECURITY_TOKEN
+type EnvCredentialsProvider struct {
+}
+
+// NewEnvCredentialsProvider creates the provider of the credentials in the environment variables.
+func NewEnvCredentialsProvider() *EnvCredentialsProvider {
+	return &EnvCredentialsProvider{}
+}
+
+// Retrieve implements CredentialsRetriever
+func (p *EnvCredentialsProvider) Retrieve() (*Ks3Credentials, error) {
+	cre := &Ks3Credentials{
+		AccessKeyID:     os.Getenv(EnvAccessKeyID),
+		AccessKeySecret: os.Getenv(EnvAccessKeySecret),
+		SecurityToken:   os.Getenv(EnvSecurityToken),
+		Source:          "env",
+	}
+	if cre.AccessKeyID == "" || cre.AccessKeySecret == "" {
+		return nil, fmt.Errorf("ks3: %s or %s is not set", EnvAccessKeyID, EnvAccessKeySecret)
+	}
+	return cre, nil
+}
+
+// GetCredentials implements CredentialsProvider
+func (p *EnvCredentialsProvider) GetCredentials() Credentials {
+	return retrieveCredentials(p)
+}
+
+// ProfileCredentialsProvider reads the credentials from a profile of the shared credentials file.
+// The file is in INI format, each section is a profile:
+//
+//	[default]
+//	access_key_id = AKLT...
+//	access_key_secret = ...
+//	security_token = ...
+//
+type ProfileCredentialsProvider struct {
+	Filename string // The file path, it's KS3_SHARED_CREDENTIALS_FILE or ~/.ks3/credentials if empty
+	Profile  string // The profile name, it's KS3_PROFILE or "default" if empty
+}
+
+// NewProfileCredentialsProvider creates the provider of a profile in the shared credentials file.
+//
+// filename    the file path, empty for the default file.
+// profile    the profile name, empty for the default profile.
+//
+// *ProfileCredentialsProvider    the credentials provider.
+//
+func NewProfileCredentialsProvider(filename, profile string) *ProfileCredentialsProvider {
+	return &ProfileCredentialsProvider{Filename: filename, Profile: profile}
+}
+
+func (p *ProfileCredentialsProvider) filename() (string, error) {
+	if p.Filename != "" {
+		return p.Filename, nil
+	}
+	if filename := os.Getenv(EnvSharedCredentialsFile); filename != "" {
+		return filename, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ks3", "credentials"), nil
+}
+
+func (p *ProfileCredentialsProvider) profile() string {
+	if p.Profile != "" {
+		return p.Profile
+	}
+	if profile := os.Getenv(EnvProfile); profile != "" {
+		return profile
+	}
+	return DefaultProfile
+}
+
+// Retrieve implements CredentialsRetriever
+func (p *ProfileCredentialsProvider) Retrieve() (*Ks3Credentials, error) {
+	filename, err := p.filename()
+	if err != nil {
+		return nil, err
+	}
+	profile := p.profile()
+
+	profiles, err := loadProfiles(filename)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("ks3: profile %s is not found in %s", profile, filename)
+	}
+
+	cre := &Ks3Credentials{
+		AccessKeyID:     values["access_key_id"],
+		AccessKeySecret: values["access_key_secret"],
+		SecurityToken:   values["security_token"],
+		Source:          "profile:" + profile,
+	}
+	if cre.AccessKeyID == "" || cre.AccessKeySecret == "" {
+		return nil, fmt.Errorf("ks3: access_key_id or access_key_secret is missing in profile %s of %s", profile, filename)
+	}
+	return cre, nil
+}
+
+// GetCredentials implements CredentialsProvider
+func (p *ProfileCredentialsProvider) GetCredentials() Credentials {
+	return retrieveCredentials(p)
+}
+
+// loadProfiles parses the INI file into the key-values of each section
+func loadProfiles(filename string) (map[string]map[string]string, error) {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	profiles := map[string]map[string]string{}
+	var section map[string]string
+	scanner := bufio.NewScanner(fd)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("ks3: invalid section at line %d of %s", lineNo, filename)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			// Accept "[profile name]" as well as "[name]"
+			name = strings.TrimSpace(strings.TrimPrefix(name, "profile "))
+			if profiles[name] == nil {
+				profiles[name] = map[string]string{}
+			}
+			section = profiles[name]
+			continue
+		}
+		pos := strings.Index(line, "=")
+		if pos < 0 || section == nil {
+			return nil, fmt.Errorf("ks3: invalid line %d of %s", lineNo, filename)
+		}
+		section[strings.ToLower(strings.TrimSpace(line[:pos]))] = strings.TrimSpace(line[pos+1:])
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// ChainCredentialsProvider tries the providers in order and returns the first credentials retrieved
+type ChainCredentialsProvider struct {
+	Providers []CredentialsRetriever
+}
+
+// NewChainCredentialsProvider creates the provider chain.
+//
+// providers    the providers to try in order.
+//
+// *ChainCredentialsProvider    the credentials provider.
+//
+func NewChainCredentialsProvider(providers ...CredentialsRetriever) *ChainCredentialsProvider {
+	return &ChainCredentialsProvider{Providers: providers}
+}
+
+// NewDefaultCredentialsChain creates the chain of the environment variables and the default shared profile.
+func NewDefaultCredentialsChain() *ChainCredentialsProvider {
+	return NewChainCredentialsProvider(NewEnvCredentialsProvider(), NewProfileCredentialsProvider("", ""))
+}
+
+// Retrieve implements CredentialsRetriever
+func (p *ChainCredentialsProvider) Retrieve() (*Ks3Credentials, error) {
+	var msgs []string
+	for _, provider := range p.Providers {
+		cre, err := provider.Retrieve()
+		if err == nil && cre != nil {
+			return cre, nil
+		}
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	return nil, fmt.Errorf("ks3: no valid credentials in the chain: [%s]", strings.Join(msgs, "; "))
+}
+
+// GetCredentials implements CredentialsProvider
+func (p *ChainCredentialsProvider) GetCredentials() Credentials {
+	return retrieveCredentials(p)
+}
+
+// CachedCredentialsProvider caches the credentials of the retriever and refreshes them ahead of the expiration.
+// It's safe for concurrent use, only one goroutine refreshes the credentials at a time and the lock isn't held
+// while it does, the others use the cached credentials until they expire or wait for the refresh.
+// The expired credentials are never returned, Retrieve returns the error if they can't be refreshed.
+type CachedCredentialsProvider struct {
+	retriever    CredentialsRetriever
+	expiryWindow time.Duration
+	now          func() time.Time // For testing purpose
+
+	mu          sync.Mutex
+	credentials *Ks3Credentials
+	refreshing  *credentialsRefresh // The running refresh, nil if none
+}
+
+// credentialsRefresh is a refresh shared by the goroutines waiting for it
+type credentialsRefresh struct {
+	done        chan struct{} // Closed when the refresh finishes
+	credentials *Ks3Credentials
+	err         error
+}
+
+// NewCachedCredentialsProvider creates the caching provider.
+//
+// retriever    the source of the credentials, such as the STS.
+// expiryWindow    how long before the expiration the credentials are refreshed, DefaultExpiryWindow is used if it's not positive.
+//
+// *CachedCredentialsProvider    the credentials provider.
+//
+func NewCachedCredentialsProvider(retriever CredentialsRetriever, expiryWindow time.Duration) *CachedCredentialsProvider {
+	if expiryWindow <= 0 {
+		expiryWindow = DefaultExpiryWindow
+	}
+	return &CachedCredentialsProvider{
+		retriever:    retriever,
+		expiryWindow: expiryWindow,
+		now:          time.Now,
+	}
+}
+
+// Retrieve implements CredentialsRetriever
+func (p *CachedCredentialsProvider) Retrieve() (*Ks3Credentials, error) {
+	p.mu.Lock()
+	now := p.now()
+	cached := p.credentials
+	if cached != nil && !p.needRefresh(cached, now) {
+		p.mu.Unlock()
+		cre := *cached
+		return &cre, nil
+	}
+	// The cached credentials are still usable within the expiry window
+	usable := cached != nil && !cached.IsExpired(now)
+	refresh := p.refreshing
+	if refresh == nil {
+		refresh = &credentialsRefresh{done: make(chan struct{})}
+		p.refreshing = refresh
+		p.mu.Unlock()
+		p.refresh(refresh)
+	} else {
+		p.mu.Unlock()
+		if usable {
+			cre := *cached
+			return &cre, nil
+		}
+		<-refresh.done
+	}
+
+	if refresh.err != nil {
+		if usable {
+			cre := *cached
+			return &cre, nil
+		}
+		return nil, refresh.err
+	}
+	cre := *refresh.credentials
+	return &cre, nil
+}
+
+// refresh retrieves the credentials without the lock and shares the result with the waiting goroutines
+func (p *CachedCredentialsProvider) refresh(refresh *credentialsRefresh) {
+	cre, err := p.retrieve()
+	if err == nil && cre == nil {
+		err = errors.New("ks3: the retriever returns no credentials")
+	}
+	if err == nil && cre.IsExpired(p.now()) {
+		err = ErrCredentialsExpired
+	}
+
+	p.mu.Lock()
+	if err == nil {
+		p.credentials = cre
+	}
+	p.refreshing = nil
+	p.mu.Unlock()
+
+	refresh.credentials, refresh.err = cre, err
+	close(refresh.done)
+}
+
+// retrieve calls the retriever, a panic is returned as the error, otherwise the refresh would never finish
+// and the waiting goroutines would block forever
+func (p *CachedCredentialsProvider) retrieve() (cre *Ks3Credentials, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			cre, err = nil, fmt.Errorf("ks3: the credentials retriever panicked: %v", r)
+		}
+	}()
+	return p.retriever.Retrieve()
+}
+
+// GetCredentials implements CredentialsProvider
+func (p *CachedCredentialsProvider) GetCredentials() Credentials {
+	return retrieveCredentials(p)
+}
+
+// Expire drops the cached credentials, so the next Retrieve refreshes them.
+func (p *CachedCredentialsProvider) Expire() {
+	p.mu.Lock()
+	p.credentials = nil
+	p.mu.Unlock()
+}
+
+func (p *CachedCredentialsProvider) needRefresh(cre *Ks3Credentials, now time.Time) bool {
+	return !cre.Expiration.IsZero() && !now.Add(p.expiryWindow).Before(cre.Expiration)
+}
@@ -0,0 +1,299 @@
+package ks3
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
+)
+
+// DefaultAssumeRoleDuration is the lifetime of the assumed role credentials requested by default
+const DefaultAssumeRoleDuration = time.Hour
+
+// minAssumeRoleRetryInterval is the minimum interval to retry the failed background refresh
+const minAssumeRoleRetryInterval = time.Second
+
+// AssumeRoleSigner signs the AssumeRole request with the credentials of the caller
+type AssumeRoleSigner func(req *http.Request, credentials *Ks3Credentials) error
+
+// AssumeRoleConfig defines the STS endpoint and the role to assume
+type AssumeRoleConfig struct {
+	Endpoint        string               // The URL of the STS endpoint, such as https://sts.api.ksyun.com
+	RoleKrn         string               // The role to assume
+	RoleSessionName string               // The session name, "ks3-go-sdk-<unix time>" if empty
+	Duration        time.Duration        // The lifetime of the credentials, DefaultAssumeRoleDuration if it's not positive
+	Policy          string               // The optional policy to restrict the permissions of the credentials
+	Params          url.Values           // The extra form params of the request, such as Version
+	Credentials     CredentialsRetriever // The credentials to sign the request
+	Signer          AssumeRoleSigner     // The request signer, signAssumeRoleRequest if nil
+	HTTPClient      *http.Client         // The HTTP client, a client with Timeout if nil
+	Timeout         time.Duration        // The timeout of the request if HTTPClient is nil, the Timeout of the default Config if it's not positive
+	ExpiryWindow    time.Duration        // How long before the expiration the credentials are refreshed, DefaultExpiryWindow if it's not positive
+	NoBackground    bool                 // Refresh the credentials when they are used instead of in the background
+}
+
+// AssumeRoleProvider gets the temporary credentials of a role from the STS endpoint.
+// The credentials are cached and refreshed in the background before they expire.
+type AssumeRoleProvider struct {
+	config AssumeRoleConfig
+	cache  *CachedCredentialsProvider
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	closed bool
+}
+
+// NewAssumeRoleProvider creates the provider of the assumed role credentials.
+//
+// config    the STS endpoint and the role, Endpoint, RoleKrn and Credentials are required.
+//
+// *AssumeRoleProvider    the credentials provider, Close it to stop the background refresh.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func NewAssumeRoleProvider(config AssumeRoleConfig) (*AssumeRoleProvider, error) {
+	if config.Endpoint == "" || config.RoleKrn == "" {
+		return nil, errors.New("ks3: the STS endpoint and the role are required")
+	}
+	if config.Credentials == nil {
+		return nil, errors.New("ks3: the credentials to assume the role are required")
+	}
+	if config.Duration <= 0 {
+		config.Duration = DefaultAssumeRoleDuration
+	}
+	if config.ExpiryWindow <= 0 {
+		config.ExpiryWindow = DefaultExpiryWindow
+	}
+	if config.ExpiryWindow >= config.Duration {
+		return nil, fmt.Errorf("ks3: expiry window %v must be shorter than the duration %v", config.ExpiryWindow, config.Duration)
+	}
+	if config.Signer == nil {
+		config.Signer = signAssumeRoleRequest
+	}
+	if config.HTTPClient == nil {
+		// The refresh must not hang, the callers wait for it once the credentials expire
+		if config.Timeout <= 0 {
+			config.Timeout = time.Duration(getDefaultKs3Config().Timeout) * time.Second
+		}
+		config.HTTPClient = &http.Client{Timeout: config.Timeout}
+	}
+
+	p := &AssumeRoleProvider{config: config}
+	p.cache = NewCachedCredentialsProvider(CredentialsRetrieverFunc(p.assumeRole), config.ExpiryWindow)
+	return p, nil
+}
+
+// Retrieve implements CredentialsRetriever
+func (p *AssumeRoleProvider) Retrieve() (*Ks3Credentials, error) {
+	return p.cache.Retrieve()
+}
+
+// GetCredentials implements CredentialsProvider
+func (p *AssumeRoleProvider) GetCredentials() Credentials {
+	return retrieveCredentials(p)
+}
+
+// Close stops the background refresh.
+func (p *AssumeRoleProvider) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
+
+// schedule refreshes the credentials in the background after d
+func (p *AssumeRoleProvider) schedule(d time.Duration) {
+	if p.config.NoBackground {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.timer = time.AfterFunc(d, p.backgroundRefresh)
+}
+
+func (p *AssumeRoleProvider) backgroundRefresh() {
+	// The cache calls assumeRole, which schedules the next refresh on success
+	cre, err := p.cache.Retrieve()
+	// The cache returns the credentials still in the expiry window if the refresh fails
+	if err != nil || p.cache.needRefresh(cre, time.Now()) {
+		retry := p.config.ExpiryWindow / 5
+		if retry < minAssumeRoleRetryInterval {
+			retry = minAssumeRoleRetryInterval
+		}
+		p.schedule(retry)
+	}
+}
+
+// assumeRole requests the credentials from the STS endpoint
+func (p *AssumeRoleProvider) assumeRole() (*Ks3Credentials, error) {
+	caller, err := p.config.Credentials.Retrieve()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionName := p.config.RoleSessionName
+	if sessionName == "" {
+		sessionName = "ks3-go-sdk-" + strconv.FormatInt(time.Now().Unix(), 10)
+	}
+	form := url.Values{}
+	for k, v := range p.config.Params {
+		form[k] = v
+	}
+	form.Set("Action", "AssumeRole")
+	form.Set("RoleKrn", p.config.RoleKrn)
+	form.Set("RoleSessionName", sessionName)
+	form.Set("DurationSeconds", strconv.FormatInt(int64(p.config.Duration/time.Second), 10))
+	if p.config.Policy != "" {
+		form.Set("Policy", p.config.Policy)
+	}
+
+	req, err := http.NewRequest("POST", p.config.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(HTTPHeaderContentType, "application/x-www-form-urlencoded")
+	req.Header.Set(HTTPHeaderDate, time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set(HTTPHeaderUserAgent, userAgent())
+	if caller.SecurityToken != "" {
+		req.Header.Set(HTTPHeaderKs3SecurityToken, caller.SecurityToken)
+	}
+	if err = p.config.Signer(req, caller); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, parseAssumeRoleError(resp, body, p.config.Endpoint)
+	}
+
+	cre, err := parseAssumeRoleResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	cre.Source = "sts:" + p.config.RoleKrn
+
+	if refreshIn := time.Until(cre.Expiration) - p.config.ExpiryWindow; refreshIn > 0 {
+		p.schedule(refreshIn)
+	}
+	return cre, nil
+}
+
+// signAssumeRoleRequest signs the request in KSS header signature
+func signAssumeRoleRequest(req *http.Request, credentials *Ks3Credentials) error {
+	req.Header.Set(HTTPHeaderAuthorization, sign.SignV2(credentials.AccessKeyID, credentials.AccessKeySecret, "", "", "", req))
+	return nil
+}
+
+// assumeRoleCredentials is the credentials in the AssumeRole response, both XML and JSON are accepted.
+// The names of STS, such as SecretAccessKey and SessionToken, and the names of KS3 are accepted.
+type assumeRoleCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	AccessKeySecret string
+	SessionToken    string
+	SecurityToken   string
+	Expiration      string
+}
+
+type assumeRoleResult struct {
+	Credentials assumeRoleCredentials
+}
+
+// assumeRoleResponse is <AssumeRoleResponse><AssumeRoleResult><Credentials>... or {"AssumeRoleResult":{"Credentials":...}},
+// the result wrapper is optional.
+type assumeRoleResponse struct {
+	AssumeRoleResult assumeRoleResult
+	Credentials      assumeRoleCredentials
+}
+
+// parseAssumeRoleResponse parses the credentials from the response body
+func parseAssumeRoleResponse(body []byte) (*Ks3Credentials, error) {
+	var out assumeRoleResponse
+	var err error
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(trimmed, &out)
+	} else {
+		err = xml.Unmarshal(body, &out)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ks3: invalid AssumeRole response: %v", err)
+	}
+
+	c := out.AssumeRoleResult.Credentials
+	if c.AccessKeyId == "" {
+		c = out.Credentials
+	}
+	cre := &Ks3Credentials{
+		AccessKeyID:     c.AccessKeyId,
+		AccessKeySecret: c.SecretAccessKey,
+		SecurityToken:   c.SessionToken,
+	}
+	if cre.AccessKeySecret == "" {
+		cre.AccessKeySecret = c.AccessKeySecret
+	}
+	if cre.SecurityToken == "" {
+		cre.SecurityToken = c.SecurityToken
+	}
+	if cre.AccessKeyID == "" || cre.AccessKeySecret == "" || cre.SecurityToken == "" {
+		return nil, errors.New("ks3: the credentials in AssumeRole response are incomplete")
+	}
+	if cre.Expiration, err = time.Parse(time.RFC3339, c.Expiration); err != nil {
+		return nil, fmt.Errorf("ks3: invalid expiration in AssumeRole response: %v", err)
+	}
+	return cre, nil
+}
+
+// parseAssumeRoleError converts the error response of the STS endpoint to ServiceError
+func parseAssumeRoleError(resp *http.Response, body []byte, endpoint string) error {
+	var out struct {
+		Code      string
+		Message   string
+		RequestId string
+		Error     struct {
+			Code    string
+			Message string
+		}
+	}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		json.Unmarshal(trimmed, &out)
+	} else {
+		xml.Unmarshal(body, &out)
+	}
+	if out.Code == "" {
+		out.Code, out.Message = out.Error.Code, out.Error.Message
+	}
+	return ServiceError{
+		Code:       out.Code,
+		Message:    out.Message,
+		RequestID:  out.RequestId,
+		Endpoint:   endpoint,
+		RawMessage: string(body),
+		StatusCode: resp.StatusCode,
+	}
+}
@@ -0,0 +1,203 @@
+package ks3
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3AssumeRoleSuite struct {
+	server   *httptest.Server
+	calls    int32
+	mu       sync.Mutex
+	lifetime time.Duration // The lifetime of the issued credentials
+	status   int           // The status code of the response
+	json     bool          // Respond in JSON instead of XML
+	delay    time.Duration // How long the response is delayed
+	req      *http.Request
+	form     map[string]string
+}
+
+var _ = Suite(&Ks3AssumeRoleSuite{})
+
+func (s *Ks3AssumeRoleSuite) SetUpSuite(c *C) {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&s.calls, 1)
+		r.ParseForm()
+		s.mu.Lock()
+		s.req = r
+		s.form = map[string]string{}
+		for k := range r.PostForm {
+			s.form[k] = r.PostForm.Get(k)
+		}
+		lifetime, status, useJSON, delay := s.lifetime, s.status, s.json, s.delay
+		s.mu.Unlock()
+		time.Sleep(delay)
+
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			fmt.Fprint(w, "<ErrorResponse><Error><Code>AccessDenied</Code><Message>Not authorized to assume the role.</Message>"+
+				"</Error><RequestId>request-id</RequestId></ErrorResponse>")
+			return
+		}
+
+		expiration := time.Now().Add(lifetime).UTC().Format(time.RFC3339)
+		if useJSON {
+			fmt.Fprintf(w, `{"RequestId":"request-id","AssumeRoleResult":{"Credentials":{"AccessKeyId":"sts-ak-%d",`+
+				`"AccessKeySecret":"sts-sk","SecurityToken":"sts-token-%d","Expiration":"%s"}}}`, n, n, expiration)
+			return
+		}
+		fmt.Fprintf(w, "<AssumeRoleResponse><AssumeRoleResult><Credentials><AccessKeyId>sts-ak-%d</AccessKeyId>"+
+			"<SecretAccessKey>sts-sk</SecretAccessKey><SessionToken>sts-token-%d</SessionToken>"+
+			"<Expiration>%s</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>", n, n, expiration)
+	}))
+}
+
+func (s *Ks3AssumeRoleSuite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3AssumeRoleSuite) SetUpTest(c *C) {
+	atomic.StoreInt32(&s.calls, 0)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lifetime = time.Hour
+	s.status = http.StatusOK
+	s.json = false
+	s.delay = 0
+}
+
+func (s *Ks3AssumeRoleSuite) newProvider(c *C, config AssumeRoleConfig) *AssumeRoleProvider {
+	config.Endpoint = s.server.URL
+	config.RoleKrn = "krn:ksc:iam::123456:role/partner"
+	config.Credentials = NewStaticCredentialsProvider("caller-ak", "caller-sk", "")
+	provider, err := NewAssumeRoleProvider(config)
+	c.Assert(err, IsNil)
+	return provider
+}
+
+func (s *Ks3AssumeRoleSuite) TestAssumeRole(c *C) {
+	provider := s.newProvider(c, AssumeRoleConfig{RoleSessionName: "session", Policy: "{}", Duration: 30 * time.Minute})
+	defer provider.Close()
+
+	cre, err := provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "sts-ak-1")
+	c.Assert(cre.AccessKeySecret, Equals, "sts-sk")
+	c.Assert(cre.SecurityToken, Equals, "sts-token-1")
+	c.Assert(cre.Expiration.After(time.Now().Add(59*time.Minute)), Equals, true)
+	c.Assert(cre.Source, Equals, "sts:krn:ksc:iam::123456:role/partner")
+
+	s.mu.Lock()
+	c.Assert(s.req.Method, Equals, "POST")
+	c.Assert(strings.HasPrefix(s.req.Header.Get(HTTPHeaderAuthorization), "KSS caller-ak:"), Equals, true)
+	c.Assert(s.form["Action"], Equals, "AssumeRole")
+	c.Assert(s.form["RoleKrn"], Equals, "krn:ksc:iam::123456:role/partner")
+	c.Assert(s.form["RoleSessionName"], Equals, "session")
+	c.Assert(s.form["DurationSeconds"], Equals, "1800")
+	c.Assert(s.form["Policy"], Equals, "{}")
+	s.mu.Unlock()
+
+	// Cached
+	cre, err = provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "sts-ak-1")
+	c.Assert(atomic.LoadInt32(&s.calls), Equals, int32(1))
+}
+
+func (s *Ks3AssumeRoleSuite) TestAssumeRoleJSON(c *C) {
+	s.json = true
+	provider := s.newProvider(c, AssumeRoleConfig{NoBackground: true})
+
+	cre, err := provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "sts-ak-1")
+	c.Assert(cre.AccessKeySecret, Equals, "sts-sk")
+	c.Assert(cre.SecurityToken, Equals, "sts-token-1")
+}
+
+func (s *Ks3AssumeRoleSuite) TestAssumeRoleError(c *C) {
+	s.status = http.StatusForbidden
+	provider := s.newProvider(c, AssumeRoleConfig{NoBackground: true})
+
+	_, err := provider.Retrieve()
+	c.Assert(err, NotNil)
+	srvErr, ok := err.(ServiceError)
+	c.Assert(ok, Equals, true)
+	c.Assert(srvErr.StatusCode, Equals, http.StatusForbidden)
+	c.Assert(srvErr.Code, Equals, "AccessDenied")
+	c.Assert(srvErr.RequestID, Equals, "request-id")
+	c.Assert(provider.GetCredentials().GetAccessKeyID(), Equals, "")
+}
+
+func (s *Ks3AssumeRoleSuite) TestBackgroundRefresh(c *C) {
+	s.lifetime = 3 * time.Second
+	provider := s.newProvider(c, AssumeRoleConfig{Duration: 3 * time.Second, ExpiryWindow: 2 * time.Second})
+	defer provider.Close()
+
+	cre, err := provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "sts-ak-1")
+
+	// Refreshed about 1 second later without being used
+	deadline := time.Now().Add(3 * time.Second)
+	for atomic.LoadInt32(&s.calls) < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Assert(atomic.LoadInt32(&s.calls) >= 2, Equals, true)
+
+	cre, err = provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Not(Equals), "sts-ak-1")
+	c.Assert(cre.IsExpired(time.Now()), Equals, false)
+
+	// No refresh after Close
+	provider.Close()
+	calls := atomic.LoadInt32(&s.calls)
+	time.Sleep(1500 * time.Millisecond)
+	c.Assert(atomic.LoadInt32(&s.calls), Equals, calls)
+}
+
+func (s *Ks3AssumeRoleSuite) TestClientWithAssumeRole(c *C) {
+	provider := s.newProvider(c, AssumeRoleConfig{NoBackground: true})
+
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "", "", SetCredentialsProvider(provider))
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("partner-bucket")
+	c.Assert(err, IsNil)
+
+	signedURL, err := bucket.SignURL("object", HTTPGet, 60)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(signedURL, "KSSAccessKeyId=sts-ak-1"), Equals, true)
+	c.Assert(strings.Contains(signedURL, "security-token=sts-token-1"), Equals, true)
+}
+
+func (s *Ks3AssumeRoleSuite) TestAssumeRoleTimeout(c *C) {
+	provider := s.newProvider(c, AssumeRoleConfig{NoBackground: true})
+	c.Assert(provider.config.HTTPClient.Timeout, Equals, 60*time.Second)
+
+	s.mu.Lock()
+	s.delay = 200 * time.Millisecond
+	s.mu.Unlock()
+	provider = s.newProvider(c, AssumeRoleConfig{NoBackground: true, Timeout: 20 * time.Millisecond})
+	start := time.Now()
+	_, err := provider.Retrieve()
+	c.Assert(err, NotNil)
+	c.Assert(time.Since(start) < 200*time.Millisecond, Equals, true)
+}
+
+func (s *Ks3AssumeRoleSuite) TestNewAssumeRoleProviderInvalid(c *C) {
+	_, err := NewAssumeRoleProvider(AssumeRoleConfig{RoleKrn: "role", Credentials: NewEnvCredentialsProvider()})
+	c.Assert(err, NotNil)
+	_, err = NewAssumeRoleProvider(AssumeRoleConfig{Endpoint: s.server.URL, RoleKrn: "role"})
+	c.Assert(err, NotNil)
+	_, err = NewAssumeRoleProvider(AssumeRoleConfig{Endpoint: s.server.URL, RoleKrn: "role",
+		Credentials: NewEnvCredentialsProvider(), Duration: time.Minute, ExpiryWindow: time.Minute})
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,412 @@
+package ks3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3CredentialsSuite struct {
+	dir string
+}
+
+var _ = Suite(&Ks3CredentialsSuite{})
+
+func (s *Ks3CredentialsSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+}
+
+func (s *Ks3CredentialsSuite) writeProfiles(c *C, content string) string {
+	filename := filepath.Join(s.dir, "credentials")
+	c.Assert(ioutil.WriteFile(filename, []byte(content), FilePermMode), IsNil)
+	return filename
+}
+
+// setEnv sets the environment variables and returns the function to restore them
+func setEnv(values map[string]string) func() {
+	old := map[string]*string{}
+	for k, v := range values {
+		if prev, ok := os.LookupEnv(k); ok {
+			old[k] = &prev
+		} else {
+			old[k] = nil
+		}
+		if v == "" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+	}
+	return func() {
+		for k, v := range old {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+func (s *Ks3CredentialsSuite) TestStaticCredentialsProvider(c *C) {
+	provider := NewStaticCredentialsProvider("ak", "sk", "token")
+	cre, err := provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "ak")
+	c.Assert(cre.AccessKeySecret, Equals, "sk")
+	c.Assert(cre.SecurityToken, Equals, "token")
+	c.Assert(provider.GetCredentials().GetAccessKeyID(), Equals, "ak")
+
+	_, err = NewStaticCredentialsProvider("", "sk", "").Retrieve()
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3CredentialsSuite) TestEnvCredentialsProvider(c *C) {
+	restore := setEnv(map[string]string{EnvAccessKeyID: "env-ak", EnvAccessKeySecret: "env-sk", EnvSecurityToken: "env-token"})
+	defer restore()
+
+	cre, err := NewEnvCredentialsProvider().Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "env-ak")
+	c.Assert(cre.AccessKeySecret, Equals, "env-sk")
+	c.Assert(cre.SecurityToken, Equals, "env-token")
+	c.Assert(cre.Source, Equals, "env")
+
+	os.Unsetenv(EnvAccessKeySecret)
+	_, err = NewEnvCredentialsProvider().Retrieve()
+	c.Assert(err, NotNil)
+	c.Assert(NewEnvCredentialsProvider().GetCredentials().GetAccessKeyID(), Equals, "")
+}
+
+func (s *Ks3CredentialsSuite) TestProfileCredentialsProvider(c *C) {
+	filename := s.writeProfiles(c, `
+# comment
+[default]
+access_key_id = default-ak
+access_key_secret = default-sk
+
+[profile dev]
+access_key_id=dev-ak
+access_key_secret=dev-sk
+security_token=dev-token
+
+[broken]
+access_key_id = broken-ak
+`)
+	cre, err := NewProfileCredentialsProvider(filename, "").Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "default-ak")
+	c.Assert(cre.AccessKeySecret, Equals, "default-sk")
+	c.Assert(cre.SecurityToken, Equals, "")
+
+	cre, err = NewProfileCredentialsProvider(filename, "dev").Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "dev-ak")
+	c.Assert(cre.SecurityToken, Equals, "dev-token")
+	c.Assert(cre.Source, Equals, "profile:dev")
+
+	_, err = NewProfileCredentialsProvider(filename, "broken").Retrieve()
+	c.Assert(err, NotNil)
+	_, err = NewProfileCredentialsProvider(filename, "none").Retrieve()
+	c.Assert(err, NotNil)
+	_, err = NewProfileCredentialsProvider(filepath.Join(s.dir, "none"), "").Retrieve()
+	c.Assert(err, NotNil)
+
+	// The file and the profile from the environment variables
+	restore := setEnv(map[string]string{EnvSharedCredentialsFile: filename, EnvProfile: "dev"})
+	defer restore()
+	cre, err = NewProfileCredentialsProvider("", "").Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "dev-ak")
+}
+
+func (s *Ks3CredentialsSuite) TestProfileInvalidFile(c *C) {
+	filename := s.writeProfiles(c, "access_key_id = ak\n")
+	_, err := NewProfileCredentialsProvider(filename, "").Retrieve()
+	c.Assert(err, NotNil)
+
+	filename = s.writeProfiles(c, "[default\naccess_key_id = ak\n")
+	_, err = NewProfileCredentialsProvider(filename, "").Retrieve()
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3CredentialsSuite) TestChainCredentialsProvider(c *C) {
+	restore := setEnv(map[string]string{EnvAccessKeyID: "", EnvAccessKeySecret: ""})
+	defer restore()
+	filename := s.writeProfiles(c, "[default]\naccess_key_id = file-ak\naccess_key_secret = file-sk\n")
+
+	chain := NewChainCredentialsProvider(NewEnvCredentialsProvider(), NewProfileCredentialsProvider(filename, ""),
+		NewStaticCredentialsProvider("static-ak", "static-sk", ""))
+	cre, err := chain.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "file-ak")
+
+	os.Setenv(EnvAccessKeyID, "env-ak")
+	os.Setenv(EnvAccessKeySecret, "env-sk")
+	cre, err = chain.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.AccessKeyID, Equals, "env-ak")
+
+	chain = NewChainCredentialsProvider(NewProfileCredentialsProvider(filepath.Join(s.dir, "none"), ""),
+		NewStaticCredentialsProvider("", "", ""))
+	_, err = chain.Retrieve()
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "static credentials are empty"), Equals, true)
+}
+
+// fakeClock is the clock of CachedCredentialsProvider in the tests
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *fakeClock) Add(d time.Duration) {
+	clock.mu.Lock()
+	clock.now = clock.now.Add(d)
+	clock.mu.Unlock()
+}
+
+func (s *Ks3CredentialsSuite) TestCachedCredentialsProvider(c *C) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var calls int32
+	var fail atomic.Value
+	fail.Store(false)
+	retriever := CredentialsRetrieverFunc(func() (*Ks3Credentials, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if fail.Load().(bool) {
+			return nil, errors.New("sts is unavailable")
+		}
+		return &Ks3Credentials{
+			AccessKeyID:     "ak",
+			AccessKeySecret: "sk",
+			SecurityToken:   "token-" + string(rune('0'+n)),
+			Expiration:      clock.Now().Add(time.Hour),
+		}, nil
+	})
+	provider := NewCachedCredentialsProvider(retriever, 10*time.Minute)
+	provider.now = clock.Now
+
+	cre, err := provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.SecurityToken, Equals, "token-1")
+
+	// Cached before the expiry window
+	clock.Add(49 * time.Minute)
+	cre, err = provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.SecurityToken, Equals, "token-1")
+	c.Assert(atomic.LoadInt32(&calls), Equals, int32(1))
+
+	// Refreshed ahead of the expiration
+	clock.Add(2 * time.Minute)
+	cre, err = provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.SecurityToken, Equals, "token-2")
+
+	// The refresh fails, the cached credentials are used until they expire
+	fail.Store(true)
+	clock.Add(55 * time.Minute)
+	cre, err = provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.SecurityToken, Equals, "token-2")
+
+	clock.Add(5 * time.Minute)
+	_, err = provider.Retrieve()
+	c.Assert(err, NotNil)
+	c.Assert(provider.GetCredentials().GetSecurityToken(), Equals, "")
+
+	// Recovered
+	fail.Store(false)
+	cre, err = provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(cre.IsExpired(clock.Now()), Equals, false)
+
+	// Expire forces the refresh
+	before := atomic.LoadInt32(&calls)
+	provider.Expire()
+	_, err = provider.Retrieve()
+	c.Assert(err, IsNil)
+	c.Assert(atomic.LoadInt32(&calls), Equals, before+1)
+}
+
+func (s *Ks3CredentialsSuite) TestCachedCredentialsProviderExpired(c *C) {
+	// The retriever returns the expired credentials
+	provider := NewCachedCredentialsProvider(CredentialsRetrieverFunc(func() (*Ks3Credentials, error) {
+		return &Ks3Credentials{AccessKeyID: "ak", AccessKeySecret: "sk", Expiration: time.Now().Add(-time.Second)}, nil
+	}), 0)
+	_, err := provider.Retrieve()
+	c.Assert(err, Equals, ErrCredentialsExpired)
+}
+
+func (s *Ks3CredentialsSuite) TestCachedCredentialsProviderConcurrent(c *C) {
+	var calls int32
+	provider := NewCachedCredentialsProvider(CredentialsRetrieverFunc(func() (*Ks3Credentials, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &Ks3Credentials{AccessKeyID: "ak", AccessKeySecret: "sk", SecurityToken: "token",
+			Expiration: time.Now().Add(time.Hour)}, nil
+	}), time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cre, err := provider.Retrieve()
+			c.Check(err, IsNil)
+			c.Check(cre.SecurityToken, Equals, "token")
+		}()
+	}
+	wg.Wait()
+	c.Assert(atomic.LoadInt32(&calls), Equals, int32(1))
+}
+
+func (s *Ks3CredentialsSuite) TestCachedCredentialsProviderSlowRefresh(c *C) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var calls int32
+	release := make(chan struct{})
+	provider := NewCachedCredentialsProvider(CredentialsRetrieverFunc(func() (*Ks3Credentials, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n > 1 {
+			<-release
+		}
+		return &Ks3Credentials{AccessKeyID: "ak", AccessKeySecret: "sk", SecurityToken: fmt.Sprintf("token-%d", n),
+			Expiration: clock.Now().Add(time.Hour)}, nil
+	}), 10*time.Minute)
+	provider.now = clock.Now
+	_, err := provider.Retrieve()
+	c.Assert(err, IsNil)
+
+	// The refresh hangs, the others use the cached credentials meanwhile
+	clock.Add(55 * time.Minute)
+	refreshed := make(chan *Ks3Credentials)
+	go func() {
+		cre, _ := provider.Retrieve()
+		refreshed <- cre
+	}()
+	for atomic.LoadInt32(&calls) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		cre, err := provider.Retrieve()
+		c.Assert(err, IsNil)
+		c.Assert(cre.SecurityToken, Equals, "token-1")
+	}
+
+	// The callers wait for the refresh once the cached credentials expire
+	clock.Add(10 * time.Minute)
+	waited := make(chan *Ks3Credentials)
+	go func() {
+		cre, _ := provider.Retrieve()
+		waited <- cre
+	}()
+	select {
+	case <-waited:
+		c.Fatal("the expired credentials are returned")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	c.Assert((<-refreshed).SecurityToken, Equals, "token-2")
+	c.Assert((<-waited).SecurityToken, Equals, "token-2")
+	c.Assert(atomic.LoadInt32(&calls), Equals, int32(2))
+}
+
+func (s *Ks3CredentialsSuite) TestCachedCredentialsProviderPanic(c *C) {
+	var calls int32
+	provider := NewCachedCredentialsProvider(CredentialsRetrieverFunc(func() (*Ks3Credentials, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			panic("boom")
+		}
+		return &Ks3Credentials{AccessKeyID: "ak", AccessKeySecret: "sk", Expiration: time.Now().Add(time.Hour)}, nil
+	}), 0)
+
+	// The panic is returned as the error and the refresh finishes, so the next Retrieve doesn't block
+	_, err := provider.Retrieve()
+	c.Assert(err, NotNil)
+	c.Assert(err.Error(), Matches, ".*panicked: boom")
+
+	retrieved := make(chan error)
+	go func() {
+		_, err := provider.Retrieve()
+		retrieved <- err
+	}()
+	select {
+	case err := <-retrieved:
+		c.Assert(err, IsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatal("Retrieve blocks after the retriever panicked")
+	}
+	c.Assert(atomic.LoadInt32(&calls), Equals, int32(2))
+}
+
+func (s *Ks3CredentialsSuite) TestClientWithProvider(c *C) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+
+	var expired int32
+	provider := NewCachedCredentialsProvider(CredentialsRetrieverFunc(func() (*Ks3Credentials, error) {
+		if atomic.LoadInt32(&expired) == 1 {
+			return nil, errors.New("sts is unavailable")
+		}
+		return &Ks3Credentials{AccessKeyID: "sts-ak", AccessKeySecret: "sts-sk", SecurityToken: "sts-token",
+			Expiration: time.Now().Add(time.Hour)}, nil
+	}), time.Minute)
+
+	client, err := New(server.URL, "", "", SetCredentialsProvider(provider),
+		HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("cred-bucket")
+	c.Assert(err, IsNil)
+
+	err = bucket.PutObject("object", strings.NewReader("data"))
+	c.Assert(err, IsNil)
+	c.Assert(header.Get(HTTPHeaderKs3SecurityToken), Equals, "sts-token")
+	c.Assert(strings.HasPrefix(header.Get(HTTPHeaderAuthorization), "KSS sts-ak:"), Equals, true)
+
+	signedURL, err := bucket.SignURL("object", HTTPGet, 60)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(signedURL, "security-token=sts-token"), Equals, true)
+	rtmpURL, err := bucket.SignRtmpURL("channel", "playlist.m3u8", 60)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(rtmpURL, "security-token=sts-token"), Equals, true)
+
+	// The credentials can't be refreshed, nothing is sent with them
+	atomic.StoreInt32(&expired, 1)
+	provider.Expire()
+	header = nil
+	err = bucket.PutObject("object", strings.NewReader("data"))
+	c.Assert(err, ErrorMatches, ".*sts is unavailable.*")
+	c.Assert(header, IsNil)
+
+	_, err = bucket.SignURL("object", HTTPGet, 60)
+	c.Assert(err, NotNil)
+	_, err = bucket.SignRtmpURL("channel", "playlist.m3u8", 60)
+	c.Assert(err, ErrorMatches, ".*sts is unavailable.*")
+}
@@ -0,0 +1,268 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Content encryption algorithms
+const (
+	AESCTRAlgorithm = "AES/CTR/NoPadding" // AES-256 in counter mode, the ciphertext has the same size as the plaintext
+	AESGCMAlgorithm = "AES/GCM/NoPadding" // AES-256-GCM over fixed size chunks, every chunk is authenticated
+)
+
+const (
+	dataKeySize = 32 // AES-256 data key
+	ctrIVSize   = aes.BlockSize
+	gcmIVSize   = 12
+	gcmTagSize  = 16
+
+	// DefaultGCMChunkSize is the plaintext size of an AES-GCM chunk, the part size of multipart uploads must be a multiple of it
+	DefaultGCMChunkSize = 64 * 1024
+)
+
+// contentCipher encrypts and decrypts the object data with the data key
+type contentCipher interface {
+	// algorithm returns the content encryption algorithm
+	algorithm() string
+	// alignment returns the plaintext alignment of the part boundaries and the decrypting offsets
+	alignment() int64
+	// cipherSize returns the ciphertext size of the plaintext size
+	cipherSize(plainSize int64) int64
+	// plainSize returns the plaintext size of the ciphertext size
+	plainSize(cipherSize int64) int64
+	// cipherRange returns the ciphertext range and the aligned plaintext offset of the plaintext range [start, end]
+	cipherRange(start, end, cipherTotal int64) (cipherStart, cipherEnd, alignedStart int64)
+	// encrypter encrypts r which starts at the aligned plaintext offset, plainTotal is -1 if it's unknown
+	encrypter(r io.Reader, offset, plainTotal int64) io.Reader
+	// decrypter decrypts r which starts at the aligned plaintext offset, cipherTotal is the size of the whole ciphertext
+	decrypter(r io.Reader, offset, cipherTotal int64) io.Reader
+}
+
+func newContentCipher(algorithm string, key, iv []byte, chunkSize int64) (contentCipher, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("ks3: invalid data key length %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case AESCTRAlgorithm:
+		if len(iv) != ctrIVSize {
+			return nil, fmt.Errorf("ks3: invalid AES-CTR iv length %d", len(iv))
+		}
+		return &ctrCipher{block: block, iv: iv}, nil
+	case AESGCMAlgorithm:
+		if len(iv) != gcmIVSize {
+			return nil, fmt.Errorf("ks3: invalid AES-GCM iv length %d", len(iv))
+		}
+		if chunkSize <= 0 {
+			return nil, fmt.Errorf("ks3: invalid AES-GCM chunk size %d", chunkSize)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		return &gcmCipher{aead: aead, iv: iv, chunkSize: chunkSize}, nil
+	}
+	return nil, fmt.Errorf("ks3: unsupported content encryption algorithm %q", algorithm)
+}
+
+// ctrCipher is the AES-CTR content cipher, the counter of the offset is iv + offset / 16
+type ctrCipher struct {
+	block cipher.Block
+	iv    []byte
+}
+
+func (c *ctrCipher) algorithm() string {
+	return AESCTRAlgorithm
+}
+
+func (c *ctrCipher) alignment() int64 {
+	return aes.BlockSize
+}
+
+func (c *ctrCipher) cipherSize(plainSize int64) int64 {
+	return plainSize
+}
+
+func (c *ctrCipher) plainSize(cipherSize int64) int64 {
+	return cipherSize
+}
+
+func (c *ctrCipher) cipherRange(start, end, cipherTotal int64) (int64, int64, int64) {
+	aligned := start - start%aes.BlockSize
+	return aligned, end, aligned
+}
+
+func (c *ctrCipher) encrypter(r io.Reader, offset, plainTotal int64) io.Reader {
+	return c.stream(r, offset)
+}
+
+func (c *ctrCipher) decrypter(r io.Reader, offset, cipherTotal int64) io.Reader {
+	return c.stream(r, offset)
+}
+
+func (c *ctrCipher) stream(r io.Reader, offset int64) io.Reader {
+	// Add the block number to the iv as a 128 bits big endian integer
+	counter := make([]byte, ctrIVSize)
+	copy(counter, c.iv)
+	carry := uint64(offset / aes.BlockSize)
+	for i := ctrIVSize - 1; i >= 0 && carry > 0; i-- {
+		sum := uint64(counter[i]) + carry&0xff
+		counter[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+	return cipher.StreamReader{S: cipher.NewCTR(c.block, counter), R: r}
+}
+
+// gcmCipher is the chunked AES-GCM content cipher.
+//
+// The plaintext is split into chunks of chunkSize bytes, every chunk is sealed with the nonce of iv xor chunk index,
+// and the additional data marks the last chunk so that a truncated object can't be decrypted.
+type gcmCipher struct {
+	aead      cipher.AEAD
+	iv        []byte
+	chunkSize int64
+}
+
+func (c *gcmCipher) algorithm() string {
+	return AESGCMAlgorithm
+}
+
+func (c *gcmCipher) alignment() int64 {
+	return c.chunkSize
+}
+
+func (c *gcmCipher) cipherSize(plainSize int64) int64 {
+	chunks := (plainSize + c.chunkSize - 1) / c.chunkSize
+	return plainSize + chunks*gcmTagSize
+}
+
+func (c *gcmCipher) plainSize(cipherSize int64) int64 {
+	cipherChunk := c.chunkSize + gcmTagSize
+	chunks := (cipherSize + cipherChunk - 1) / cipherChunk
+	return cipherSize - chunks*gcmTagSize
+}
+
+func (c *gcmCipher) cipherRange(start, end, cipherTotal int64) (int64, int64, int64) {
+	cipherChunk := c.chunkSize + gcmTagSize
+	first, last := start/c.chunkSize, end/c.chunkSize
+	cipherEnd := (last+1)*cipherChunk - 1
+	if cipherEnd >= cipherTotal {
+		cipherEnd = cipherTotal - 1
+	}
+	return first * cipherChunk, cipherEnd, first * c.chunkSize
+}
+
+func (c *gcmCipher) nonce(index int64) []byte {
+	nonce := make([]byte, gcmIVSize)
+	copy(nonce, c.iv)
+	var idx [8]byte
+	binary.BigEndian.PutUint64(idx[:], uint64(index))
+	for i := range idx {
+		nonce[gcmIVSize-8+i] ^= idx[i]
+	}
+	return nonce
+}
+
+func additionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+func (c *gcmCipher) encrypter(r io.Reader, offset, plainTotal int64) io.Reader {
+	return &gcmReader{cipher: c, r: r, index: offset / c.chunkSize, total: plainTotal, encrypt: true}
+}
+
+func (c *gcmCipher) decrypter(r io.Reader, offset, cipherTotal int64) io.Reader {
+	cipherChunk := c.chunkSize + gcmTagSize
+	chunks := (cipherTotal + cipherChunk - 1) / cipherChunk
+	return &gcmReader{cipher: c, r: r, index: offset / c.chunkSize, total: chunks, encrypt: false}
+}
+
+// gcmReader seals or opens the chunks of r one by one
+type gcmReader struct {
+	cipher  *gcmCipher
+	r       io.Reader
+	index   int64  // Index of the next chunk
+	total   int64  // Plaintext size when encrypting (-1 if unknown), chunk count when decrypting
+	encrypt bool   // Encrypt or decrypt
+	buf     []byte // Output of the current chunk which is not read yet
+	peek    []byte // The byte read ahead to detect the end of the plaintext of unknown size
+	err     error
+}
+
+func (g *gcmReader) Read(p []byte) (int, error) {
+	for len(g.buf) == 0 {
+		if g.err != nil {
+			return 0, g.err
+		}
+		g.err = g.nextChunk()
+	}
+	n := copy(p, g.buf)
+	g.buf = g.buf[n:]
+	return n, nil
+}
+
+func (g *gcmReader) nextChunk() error {
+	size := g.cipher.chunkSize
+	if !g.encrypt {
+		size += gcmTagSize
+	}
+
+	chunk := make([]byte, size)
+	n := copy(chunk, g.peek)
+	g.peek = nil
+	m, err := io.ReadFull(g.r, chunk[n:])
+	n += m
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if n == 0 {
+		return io.EOF
+	}
+	chunk = chunk[:n]
+
+	var last bool
+	if g.encrypt {
+		if g.total >= 0 {
+			last = (g.index+1)*g.cipher.chunkSize >= g.total
+		} else if err == io.EOF {
+			last = true
+		} else {
+			// Read ahead to know whether it's the last chunk
+			var b [1]byte
+			if k, perr := io.ReadFull(g.r, b[:]); k == 1 {
+				g.peek = b[:]
+			} else if perr == io.EOF {
+				last = true
+			} else {
+				return perr
+			}
+		}
+		g.buf = g.cipher.aead.Seal(chunk[:0], g.cipher.nonce(g.index), chunk, additionalData(last))
+	} else {
+		if g.index >= g.total {
+			return errors.New("ks3: the encrypted data is longer than the object")
+		}
+		last = g.index == g.total-1
+		g.buf, err = g.cipher.aead.Open(chunk[:0], g.cipher.nonce(g.index), chunk, additionalData(last))
+		if err != nil {
+			return fmt.Errorf("ks3: failed to decrypt chunk %d: %v", g.index, err)
+		}
+	}
+	g.index++
+	return nil
+}
@@ -0,0 +1,457 @@
+// Package crypto encrypts the objects on the client side before they're uploaded to KS3.
+//
+// Every object is encrypted with its own random data key, the data key is wrapped by a MasterKeyProvider
+// and stored with the IV in the X-Kss-Meta- headers of the object. The master key never leaves the client.
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// CryptoBucket encrypts the objects written by PutObject, UploadFile and the multipart methods,
+// and decrypts the objects read by GetObject, GetObjectToFile and DownloadFile.
+// Objects without the encryption metadata are read as they are.
+//
+// The other methods of the embedded ks3.Bucket don't encrypt or decrypt the data.
+type CryptoBucket struct {
+	ks3.Bucket
+	MasterKey MasterKeyProvider // Master key to wrap and unwrap the data keys
+	Algorithm string            // Content encryption algorithm of the new objects, AESCTRAlgorithm or AESGCMAlgorithm
+}
+
+// MultipartContext holds the encryption materials shared by the parts of a multipart upload.
+type MultipartContext struct {
+	DataSize int64 // Plaintext size of the object, it's required by AESGCMAlgorithm
+	PartSize int64 // Plaintext size of every part except the last one, it must be a multiple of 16 for AES-CTR and of DefaultGCMChunkSize for AES-GCM
+	env      *envelope
+}
+
+// decryptReader reads the decrypted data and closes the response body
+type decryptReader struct {
+	io.Reader
+	io.Closer
+}
+
+// NewCryptoBucket creates the encrypting bucket.
+//
+// bucket    the bucket to store the encrypted objects.
+// masterKey    the master key to wrap and unwrap the data keys.
+// algorithm    the content encryption algorithm of the new objects, AESCTRAlgorithm or AESGCMAlgorithm.
+//
+// *CryptoBucket    the encrypting bucket.
+// error    it's nil if no error, otherwise it's an error object.
+func NewCryptoBucket(bucket *ks3.Bucket, masterKey MasterKeyProvider, algorithm string) (*CryptoBucket, error) {
+	if bucket == nil || masterKey == nil {
+		return nil, errors.New("ks3: crypto bucket requires the bucket and the master key")
+	}
+	if algorithm != AESCTRAlgorithm && algorithm != AESGCMAlgorithm {
+		return nil, fmt.Errorf("ks3: unsupported content encryption algorithm %q", algorithm)
+	}
+	return &CryptoBucket{Bucket: *bucket, MasterKey: masterKey, Algorithm: algorithm}, nil
+}
+
+// PutObject encrypts the data and uploads it as a new object.
+//
+// objectKey    the object key.
+// reader    the plaintext data. Its size is taken from the reader or the ContentLength option, otherwise the data is uploaded in chunked encoding.
+// options    the options for uploading the object, check out ks3.Bucket.PutObject for the reference.
+//
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket CryptoBucket) PutObject(objectKey string, reader io.Reader, options ...ks3.Option) error {
+	plainSize := int64(-1)
+	if n, err := ks3.GetReaderLen(reader); err == nil {
+		plainSize = n
+	} else if v, _ := ks3.FindOption(options, ks3.HTTPHeaderContentLength, nil); v != nil {
+		if plainSize, err = strconv.ParseInt(v.(string), 10, 64); err != nil {
+			return fmt.Errorf("ks3: invalid content length %q", v)
+		}
+	}
+
+	env, err := newEnvelope(bucket.MasterKey, bucket.Algorithm, plainSize)
+	if err != nil {
+		return err
+	}
+	opts, err := env.options()
+	if err != nil {
+		return err
+	}
+
+	// The length and MD5 of the plaintext don't match the uploaded data
+	options = ks3.DeleteOption(options, ks3.HTTPHeaderContentLength)
+	options = ks3.DeleteOption(options, ks3.HTTPHeaderContentMD5)
+	options = append(options, opts...)
+
+	body := env.cipher.encrypter(reader, 0, plainSize)
+	if plainSize >= 0 {
+		body = &io.LimitedReader{R: body, N: env.cipher.cipherSize(plainSize)}
+	}
+	return bucket.Bucket.PutObject(objectKey, body, options...)
+}
+
+// PutObjectFromFile encrypts the local file and uploads it as a new object.
+//
+// objectKey    the object key.
+// filePath    the local file path to upload.
+// options    the options for uploading the object, check out ks3.Bucket.PutObject for the reference.
+//
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket CryptoBucket) PutObjectFromFile(objectKey, filePath string, options ...ks3.Option) error {
+	fd, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	return bucket.PutObject(objectKey, fd, options...)
+}
+
+// GetObject downloads and decrypts the object.
+//
+// objectKey    the object key.
+// options    the options for downloading the object. The Range option selects the plaintext range,
+// the object metadata is read first to map it to the encrypted range.
+//
+// io.ReadCloser    the reader of the plaintext, it must be closed after the usage.
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket CryptoBucket) GetObject(objectKey string, options ...ks3.Option) (io.ReadCloser, error) {
+	hasRange, value, err := ks3.IsOptionSet(options, ks3.HTTPHeaderRange)
+	if err != nil {
+		return nil, err
+	}
+	if hasRange {
+		return bucket.getObjectRange(objectKey, value.(string), ks3.DeleteOption(options, ks3.HTTPHeaderRange))
+	}
+
+	result, err := bucket.Bucket.DoGetObject(&ks3.GetObjectRequest{ObjectKey: objectKey}, options)
+	if err != nil {
+		return nil, err
+	}
+	resp := result.Response
+
+	env, err := parseEnvelope(resp.Headers, bucket.MasterKey)
+	if err != nil || env == nil {
+		if err != nil {
+			resp.Close()
+		}
+		return resp, err
+	}
+
+	cipherTotal, err := strconv.ParseInt(resp.Headers.Get(ks3.HTTPHeaderContentLength), 10, 64)
+	if err != nil {
+		if env.plainSize < 0 {
+			resp.Close()
+			return nil, errors.New("ks3: unknown size of the encrypted object")
+		}
+		cipherTotal = env.cipher.cipherSize(env.plainSize)
+	}
+	return &decryptReader{Reader: env.cipher.decrypter(resp, 0, cipherTotal), Closer: resp}, nil
+}
+
+// GetObjectToFile downloads and decrypts the object to the local file.
+//
+// objectKey    the object key.
+// filePath    the local file to store the plaintext.
+// options    the options for downloading the object, check out GetObject for the reference.
+//
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket CryptoBucket) GetObjectToFile(objectKey, filePath string, options ...ks3.Option) error {
+	body, err := bucket.GetObject(objectKey, options...)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return writeFile(filePath, func(w io.Writer) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+}
+
+// DownloadFile downloads and decrypts the object part by part with ranged reads.
+//
+// objectKey    the object key.
+// filePath    the local file to store the plaintext.
+// partSize    the plaintext size of every ranged read.
+// options    the options for downloading the object, Range is not supported.
+//
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket CryptoBucket) DownloadFile(objectKey, filePath string, partSize int64, options ...ks3.Option) error {
+	if partSize < 1 {
+		return errors.New("ks3: part size smaller than 1")
+	}
+
+	options = ks3.DeleteOption(options, ks3.HTTPHeaderRange)
+	meta, err := bucket.Bucket.GetObjectDetailedMeta(objectKey, options...)
+	if err != nil {
+		return err
+	}
+	env, err := parseEnvelope(meta, bucket.MasterKey)
+	if err != nil {
+		return err
+	}
+	if env == nil {
+		return bucket.Bucket.DownloadFile(objectKey, filePath, partSize, options...)
+	}
+
+	cipherTotal, err := strconv.ParseInt(meta.Get(ks3.HTTPHeaderContentLength), 10, 64)
+	if err != nil {
+		return err
+	}
+	plainTotal := env.cipher.plainSize(cipherTotal)
+	if etag := meta.Get(ks3.HTTPHeaderEtag); etag != "" {
+		options = append(options, ks3.IfMatch(etag))
+	}
+
+	return writeFile(filePath, func(w io.Writer) error {
+		for start := int64(0); start < plainTotal; start += partSize {
+			end := start + partSize - 1
+			if end >= plainTotal {
+				end = plainTotal - 1
+			}
+			body, err := bucket.readRange(objectKey, env, cipherTotal, start, end, options)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, body)
+			body.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UploadFile encrypts the local file and uploads it with a multipart upload, the parts are uploaded one by one.
+//
+// objectKey    the object key.
+// filePath    the local file to upload.
+// partSize    the plaintext part size, it must be a multiple of 16 for AES-CTR and of DefaultGCMChunkSize for AES-GCM.
+// options    the options for initiating the multipart upload, such as the metadata and ACL.
+//
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket CryptoBucket) UploadFile(objectKey, filePath string, partSize int64, options ...ks3.Option) error {
+	if partSize < ks3.MinPartSize || partSize > ks3.MaxPartSize {
+		return errors.New("ks3: part size invalid range (100KB, 5GB]")
+	}
+
+	fd, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	st, err := fd.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := st.Size()
+	if size <= partSize {
+		return bucket.PutObject(objectKey, fd, options...)
+	}
+
+	mc := &MultipartContext{DataSize: size, PartSize: partSize}
+	imur, err := bucket.InitiateMultipartUpload(objectKey, mc, options...)
+	if err != nil {
+		return err
+	}
+
+	var parts []ks3.UploadPart
+	for number, offset := 1, int64(0); offset < size; number, offset = number+1, offset+partSize {
+		n := partSize
+		if offset+n > size {
+			n = size - offset
+		}
+		part, err := bucket.UploadPart(imur, mc, io.NewSectionReader(fd, offset, n), n, number)
+		if err != nil {
+			bucket.Bucket.AbortMultipartUpload(imur)
+			return err
+		}
+		parts = append(parts, part)
+	}
+
+	_, err = bucket.Bucket.CompleteMultipartUpload(imur, parts)
+	if err != nil {
+		bucket.Bucket.AbortMultipartUpload(imur)
+	}
+	return err
+}
+
+// InitiateMultipartUpload generates the encryption materials and initiates the multipart upload.
+//
+// objectKey    the object key.
+// mc    the multipart context, DataSize and PartSize must be set. The materials are saved to it for UploadPart.
+// options    the options for initiating the multipart upload, check out ks3.Bucket.InitiateMultipartUpload for the reference.
+//
+// InitiateMultipartUploadResult    the result of initiating the multipart upload.
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket CryptoBucket) InitiateMultipartUpload(objectKey string, mc *MultipartContext, options ...ks3.Option) (ks3.InitiateMultipartUploadResult, error) {
+	var imur ks3.InitiateMultipartUploadResult
+	if mc == nil || mc.PartSize <= 0 {
+		return imur, errors.New("ks3: multipart context requires the part size")
+	}
+	if bucket.Algorithm == AESGCMAlgorithm && mc.DataSize <= 0 {
+		return imur, errors.New("ks3: AES-GCM multipart upload requires the data size")
+	}
+
+	plainSize := mc.DataSize
+	if plainSize <= 0 {
+		plainSize = -1
+	}
+	env, err := newEnvelope(bucket.MasterKey, bucket.Algorithm, plainSize)
+	if err != nil {
+		return imur, err
+	}
+	if mc.PartSize%env.cipher.alignment() != 0 {
+		return imur, fmt.Errorf("ks3: part size %d is not a multiple of %d", mc.PartSize, env.cipher.alignment())
+	}
+	opts, err := env.options()
+	if err != nil {
+		return imur, err
+	}
+
+	imur, err = bucket.Bucket.InitiateMultipartUpload(objectKey, append(options, opts...)...)
+	if err != nil {
+		return imur, err
+	}
+	mc.env = env
+	return imur, nil
+}
+
+// UploadPart encrypts the part data and uploads it.
+//
+// imur    the result of InitiateMultipartUpload.
+// mc    the multipart context used by InitiateMultipartUpload.
+// reader    the plaintext of the part.
+// partSize    the plaintext size of the part, it must equal mc.PartSize except for the last part.
+// partNumber    the part number, the plaintext offset of the part is (partNumber - 1) * mc.PartSize.
+// options    the options for uploading the part.
+//
+// UploadPart    the part number and ETag to complete the multipart upload.
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket CryptoBucket) UploadPart(imur ks3.InitiateMultipartUploadResult, mc *MultipartContext, reader io.Reader,
+	partSize int64, partNumber int, options ...ks3.Option) (ks3.UploadPart, error) {
+	if mc == nil || mc.env == nil {
+		return ks3.UploadPart{}, errors.New("ks3: multipart context is not initiated")
+	}
+	if partNumber < 1 || partSize <= 0 || partSize > mc.PartSize {
+		return ks3.UploadPart{}, fmt.Errorf("ks3: invalid part %d of size %d", partNumber, partSize)
+	}
+
+	offset := int64(partNumber-1) * mc.PartSize
+	body := mc.env.cipher.encrypter(io.LimitReader(reader, partSize), offset, mc.env.plainSize)
+	return bucket.Bucket.UploadPart(imur, body, mc.env.cipher.cipherSize(partSize), partNumber, options...)
+}
+
+// getObjectRange reads the object metadata and decrypts the plaintext range
+func (bucket CryptoBucket) getObjectRange(objectKey, rangeValue string, options []ks3.Option) (io.ReadCloser, error) {
+	meta, err := bucket.Bucket.GetObjectDetailedMeta(objectKey, options...)
+	if err != nil {
+		return nil, err
+	}
+	env, err := parseEnvelope(meta, bucket.MasterKey)
+	if err != nil {
+		return nil, err
+	}
+	if env == nil {
+		return bucket.Bucket.GetObject(objectKey, append(options, ks3.SetHeader(ks3.HTTPHeaderRange, rangeValue))...)
+	}
+
+	cipherTotal, err := strconv.ParseInt(meta.Get(ks3.HTTPHeaderContentLength), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := parseRange(rangeValue, env.cipher.plainSize(cipherTotal))
+	if err != nil {
+		return nil, err
+	}
+	if etag := meta.Get(ks3.HTTPHeaderEtag); etag != "" {
+		options = append(options, ks3.IfMatch(etag))
+	}
+	return bucket.readRange(objectKey, env, cipherTotal, start, end, options)
+}
+
+// readRange downloads the encrypted range covering the plaintext range [start, end] and decrypts it
+func (bucket CryptoBucket) readRange(objectKey string, env *envelope, cipherTotal, start, end int64, options []ks3.Option) (io.ReadCloser, error) {
+	cipherStart, cipherEnd, alignedStart := env.cipher.cipherRange(start, end, cipherTotal)
+	body, err := bucket.Bucket.GetObject(objectKey, append(options, ks3.Range(cipherStart, cipherEnd))...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := env.cipher.decrypter(body, alignedStart, cipherTotal)
+	if _, err = io.CopyN(ioutil.Discard, r, start-alignedStart); err != nil {
+		body.Close()
+		return nil, err
+	}
+	return &decryptReader{Reader: io.LimitReader(r, end-start+1), Closer: body}, nil
+}
+
+// parseRange parses the single range of the Range header to [start, end] of the object size
+func parseRange(value string, size int64) (int64, int64, error) {
+	invalid := fmt.Errorf("ks3: invalid range %q of the object size %d", value, size)
+	if !strings.HasPrefix(value, "bytes=") || strings.Contains(value, ",") {
+		return 0, 0, invalid
+	}
+	spec := strings.TrimSpace(value[len("bytes="):])
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return 0, 0, invalid
+	}
+
+	var start, end int64
+	var err error
+	if i == 0 {
+		// Suffix range
+		n, err := strconv.ParseInt(spec[1:], 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, invalid
+		}
+		if n > size {
+			n = size
+		}
+		start, end = size-n, size-1
+	} else {
+		if start, err = strconv.ParseInt(spec[:i], 10, 64); err != nil {
+			return 0, 0, invalid
+		}
+		end = size - 1
+		if spec[i+1:] != "" {
+			if end, err = strconv.ParseInt(spec[i+1:], 10, 64); err != nil || end < start {
+				return 0, 0, invalid
+			}
+			if end >= size {
+				end = size - 1
+			}
+		}
+	}
+	if start < 0 || start >= size {
+		return 0, 0, invalid
+	}
+	return start, end, nil
+}
+
+// writeFile writes the file through a temp file, so the file is not changed if the writing fails
+func writeFile(filePath string, write func(w io.Writer) error) error {
+	tempFilePath := filePath + ks3.TempFileSuffix
+	fd, err := os.OpenFile(tempFilePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, ks3.FilePermMode)
+	if err != nil {
+		return err
+	}
+
+	err = write(fd)
+	fd.Close()
+	if err != nil {
+		os.Remove(tempFilePath)
+		return err
+	}
+	return os.Rename(tempFilePath, filePath)
+}
@@ -0,0 +1,424 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+	. "gopkg.in/check.v1"
+)
+
+// Test hooks up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type Ks3CryptoSuite struct {
+	server *fakeServer
+	bucket *ks3.Bucket
+	rsaKey *rsa.PrivateKey
+	dir    string
+}
+
+var _ = Suite(&Ks3CryptoSuite{})
+
+// fakeServer is an in-memory bucket supporting the object and multipart APIs
+type fakeServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	objects map[string]*fakeObject
+	uploads map[string]*fakeObject
+	ranges  []string // Range headers of the GET requests
+}
+
+type fakeObject struct {
+	data   []byte
+	header http.Header
+	parts  map[int][]byte
+}
+
+func newFakeServer() *fakeServer {
+	s := &fakeServer{objects: map[string]*fakeObject{}, uploads: map[string]*fakeObject{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *fakeServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+
+	_, initiate := query["uploads"]
+
+	switch {
+	case r.Method == "POST" && initiate:
+		id := strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[id] = &fakeObject{header: metaHeader(r.Header), parts: map[int][]byte{}}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>b</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, id)
+	case r.Method == "PUT" && query.Get("uploadId") != "":
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		s.uploads[query.Get("uploadId")].parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+	case r.Method == "POST" && query.Get("uploadId") != "":
+		upload := s.uploads[query.Get("uploadId")]
+		var numbers []int
+		for n := range upload.parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		for _, n := range numbers {
+			upload.data = append(upload.data, upload.parts[n]...)
+		}
+		s.objects[key] = upload
+		delete(s.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key><ETag>\"etag\"</ETag></CompleteMultipartUploadResult>", key)
+	case r.Method == "DELETE" && query.Get("uploadId") != "":
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "PUT":
+		s.objects[key] = &fakeObject{data: body, header: metaHeader(r.Header)}
+	case r.Method == "GET" || r.Method == "HEAD":
+		obj, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == "GET" {
+			s.ranges = append(s.ranges, r.Header.Get("Range"))
+		}
+		for k, v := range obj.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Etag", fmt.Sprintf(`"%d"`, len(obj.data)))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(obj.data))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func metaHeader(h http.Header) http.Header {
+	meta := http.Header{}
+	for k, v := range h {
+		if strings.HasPrefix(k, ks3.HTTPHeaderKs3MetaPrefix) {
+			meta[k] = v
+		}
+	}
+	return meta
+}
+
+func (s *Ks3CryptoSuite) SetUpSuite(c *C) {
+	s.server = newFakeServer()
+
+	// The bucket domain is dialed to the local server
+	addr := s.server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	client, err := ks3.New(s.server.URL, "ak", "sk", ks3.HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	s.bucket, err = client.Bucket("crypto-bucket")
+	c.Assert(err, IsNil)
+
+	s.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	s.dir = c.MkDir()
+}
+
+func (s *Ks3CryptoSuite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3CryptoSuite) masterKeys(c *C) []MasterKeyProvider {
+	rsaKey, err := NewRSAMasterKey(nil, s.rsaKey, map[string]string{"key": "rsa-1"})
+	c.Assert(err, IsNil)
+	aesKey, err := NewAESMasterKey(bytes.Repeat([]byte{7}, 32), nil)
+	c.Assert(err, IsNil)
+	return []MasterKeyProvider{rsaKey, aesKey}
+}
+
+func randomData(c *C, n int) []byte {
+	data := make([]byte, n)
+	_, err := rand.Read(data)
+	c.Assert(err, IsNil)
+	return data
+}
+
+func readAll(c *C, rc io.ReadCloser) []byte {
+	defer rc.Close()
+	bs, err := ioutil.ReadAll(rc)
+	c.Assert(err, IsNil)
+	return bs
+}
+
+func (s *Ks3CryptoSuite) TestPutGetObject(c *C) {
+	data := randomData(c, 3*DefaultGCMChunkSize+123)
+	for _, masterKey := range s.masterKeys(c) {
+		for _, alg := range []string{AESCTRAlgorithm, AESGCMAlgorithm} {
+			bucket, err := NewCryptoBucket(s.bucket, masterKey, alg)
+			c.Assert(err, IsNil)
+			key := "put-" + alg
+			c.Assert(bucket.PutObject(key, bytes.NewReader(data)), IsNil)
+
+			// The stored data is encrypted
+			stored := s.server.objects[key]
+			c.Assert(bytes.Contains(stored.data, data[:64]), Equals, false)
+			c.Assert(stored.header.Get("X-Kss-Meta-Client-Side-Encryption-Cek-Alg"), Equals, alg)
+			c.Assert(stored.header.Get("X-Kss-Meta-Client-Side-Encryption-Wrap-Alg"), Equals, masterKey.WrapAlgorithm())
+			c.Assert(stored.header.Get("X-Kss-Meta-Client-Side-Encryption-Unencrypted-Content-Length"), Equals, strconv.Itoa(len(data)))
+			c.Assert(stored.header.Get("X-Kss-Meta-Client-Side-Encryption-Key"), Not(Equals), "")
+			c.Assert(stored.header.Get("X-Kss-Meta-Client-Side-Encryption-Start"), Not(Equals), "")
+
+			body, err := bucket.GetObject(key)
+			c.Assert(err, IsNil)
+			c.Assert(bytes.Equal(readAll(c, body), data), Equals, true)
+		}
+	}
+}
+
+func (s *Ks3CryptoSuite) TestPutObjectUnknownSize(c *C) {
+	data := randomData(c, 2*DefaultGCMChunkSize)
+	masterKey := s.masterKeys(c)[1]
+	for _, alg := range []string{AESCTRAlgorithm, AESGCMAlgorithm} {
+		bucket, err := NewCryptoBucket(s.bucket, masterKey, alg)
+		c.Assert(err, IsNil)
+
+		// A reader of unknown size is uploaded in chunked encoding
+		c.Assert(bucket.PutObject("stream", struct{ io.Reader }{bytes.NewReader(data)}), IsNil)
+		body, err := bucket.GetObject("stream")
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(readAll(c, body), data), Equals, true)
+
+		c.Assert(bucket.PutObject("empty", strings.NewReader("")), IsNil)
+		body, err = bucket.GetObject("empty")
+		c.Assert(err, IsNil)
+		c.Assert(len(readAll(c, body)), Equals, 0)
+	}
+}
+
+func (s *Ks3CryptoSuite) TestRangeRead(c *C) {
+	data := randomData(c, 3*DefaultGCMChunkSize+100)
+	size := int64(len(data))
+	ranges := [][2]int64{
+		{0, 0}, {5, 20}, {15, 16}, {100, DefaultGCMChunkSize + 10},
+		{DefaultGCMChunkSize - 1, 2 * DefaultGCMChunkSize}, {size - 50, size - 1}, {0, size - 1},
+	}
+
+	for _, alg := range []string{AESCTRAlgorithm, AESGCMAlgorithm} {
+		bucket, err := NewCryptoBucket(s.bucket, s.masterKeys(c)[0], alg)
+		c.Assert(err, IsNil)
+		c.Assert(bucket.PutObject("range", bytes.NewReader(data)), IsNil)
+
+		for _, r := range ranges {
+			body, err := bucket.GetObject("range", ks3.Range(r[0], r[1]))
+			c.Assert(err, IsNil)
+			c.Assert(bytes.Equal(readAll(c, body), data[r[0]:r[1]+1]), Equals, true, Commentf("%s %v", alg, r))
+		}
+
+		body, err := bucket.GetObject("range", ks3.NormalizedRange("-30"))
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(readAll(c, body), data[size-30:]), Equals, true)
+		body, err = bucket.GetObject("range", ks3.NormalizedRange("1000-"))
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(readAll(c, body), data[1000:]), Equals, true)
+
+		_, err = bucket.GetObject("range", ks3.Range(size, size+10))
+		c.Assert(err, NotNil)
+	}
+
+	// Only the chunks covering the range are downloaded
+	s.server.ranges = nil
+	bucket, _ := NewCryptoBucket(s.bucket, s.masterKeys(c)[0], AESGCMAlgorithm)
+	body, err := bucket.GetObject("range", ks3.Range(DefaultGCMChunkSize+1, DefaultGCMChunkSize+2))
+	c.Assert(err, IsNil)
+	readAll(c, body)
+	c.Assert(s.server.ranges, DeepEquals, []string{fmt.Sprintf("bytes=%d-%d", DefaultGCMChunkSize+gcmTagSize, 2*(DefaultGCMChunkSize+gcmTagSize)-1)})
+}
+
+func (s *Ks3CryptoSuite) TestUploadDownloadFile(c *C) {
+	data := randomData(c, 5*DefaultGCMChunkSize+1000)
+	src := filepath.Join(s.dir, "src")
+	c.Assert(ioutil.WriteFile(src, data, 0644), IsNil)
+
+	for _, alg := range []string{AESCTRAlgorithm, AESGCMAlgorithm} {
+		bucket, err := NewCryptoBucket(s.bucket, s.masterKeys(c)[1], alg)
+		c.Assert(err, IsNil)
+		c.Assert(bucket.UploadFile("multipart", src, 2*DefaultGCMChunkSize, ks3.Meta("Owner", "test")), IsNil)
+		c.Assert(s.server.objects["multipart"].header.Get("X-Kss-Meta-Owner"), Equals, "test")
+
+		body, err := bucket.GetObject("multipart")
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(readAll(c, body), data), Equals, true)
+
+		dst := filepath.Join(s.dir, "dst-"+strings.Replace(alg, "/", "-", -1))
+		c.Assert(bucket.DownloadFile("multipart", dst, 100*1000), IsNil)
+		bs, err := ioutil.ReadFile(dst)
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(bs, data), Equals, true)
+
+		c.Assert(bucket.GetObjectToFile("multipart", dst), IsNil)
+		bs, err = ioutil.ReadFile(dst)
+		c.Assert(err, IsNil)
+		c.Assert(bytes.Equal(bs, data), Equals, true)
+		_, err = os.Stat(dst + ks3.TempFileSuffix)
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+
+	// The part size must be aligned to the cipher
+	bucket, _ := NewCryptoBucket(s.bucket, s.masterKeys(c)[1], AESGCMAlgorithm)
+	c.Assert(bucket.UploadFile("multipart", src, 100*1024+16), NotNil)
+	bucket, _ = NewCryptoBucket(s.bucket, s.masterKeys(c)[1], AESCTRAlgorithm)
+	c.Assert(bucket.UploadFile("multipart", src, 100*1024+1), NotNil)
+}
+
+func (s *Ks3CryptoSuite) TestMultipartOutOfOrder(c *C) {
+	data := randomData(c, 3*DefaultGCMChunkSize+10)
+	bucket, err := NewCryptoBucket(s.bucket, s.masterKeys(c)[0], AESGCMAlgorithm)
+	c.Assert(err, IsNil)
+
+	_, err = bucket.InitiateMultipartUpload("parts", &MultipartContext{PartSize: DefaultGCMChunkSize})
+	c.Assert(err, NotNil)
+
+	mc := &MultipartContext{DataSize: int64(len(data)), PartSize: DefaultGCMChunkSize}
+	imur, err := bucket.InitiateMultipartUpload("parts", mc)
+	c.Assert(err, IsNil)
+
+	var parts []ks3.UploadPart
+	for _, number := range []int{4, 2, 1, 3} {
+		start := int64(number-1) * mc.PartSize
+		end := start + mc.PartSize
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		part, err := bucket.UploadPart(imur, mc, bytes.NewReader(data[start:end]), end-start, number)
+		c.Assert(err, IsNil)
+		parts = append(parts, part)
+	}
+	_, err = bucket.Bucket.CompleteMultipartUpload(imur, parts)
+	c.Assert(err, IsNil)
+
+	body, err := bucket.GetObject("parts")
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Equal(readAll(c, body), data), Equals, true)
+}
+
+func (s *Ks3CryptoSuite) TestTampered(c *C) {
+	data := randomData(c, 2*DefaultGCMChunkSize+10)
+	bucket, err := NewCryptoBucket(s.bucket, s.masterKeys(c)[1], AESGCMAlgorithm)
+	c.Assert(err, IsNil)
+	c.Assert(bucket.PutObject("tampered", bytes.NewReader(data)), IsNil)
+
+	// Modified data
+	s.server.objects["tampered"].data[10] ^= 1
+	body, err := bucket.GetObject("tampered")
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(body)
+	body.Close()
+	c.Assert(err, NotNil)
+
+	// Truncated at the chunk boundary
+	s.server.objects["tampered"].data[10] ^= 1
+	obj := s.server.objects["tampered"]
+	obj.data = obj.data[:DefaultGCMChunkSize+gcmTagSize]
+	body, err = bucket.GetObject("tampered")
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(body)
+	body.Close()
+	c.Assert(err, NotNil)
+
+	// Wrong master key
+	c.Assert(bucket.PutObject("tampered", bytes.NewReader(data)), IsNil)
+	otherKey, _ := NewAESMasterKey(bytes.Repeat([]byte{8}, 32), nil)
+	other, _ := NewCryptoBucket(s.bucket, otherKey, AESGCMAlgorithm)
+	_, err = other.GetObject("tampered")
+	c.Assert(err, NotNil)
+	rsaBucket, _ := NewCryptoBucket(s.bucket, s.masterKeys(c)[0], AESGCMAlgorithm)
+	_, err = rsaBucket.GetObject("tampered")
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3CryptoSuite) TestPlainObject(c *C) {
+	c.Assert(s.bucket.PutObject("plain", strings.NewReader("0123456789")), IsNil)
+	bucket, err := NewCryptoBucket(s.bucket, s.masterKeys(c)[0], AESCTRAlgorithm)
+	c.Assert(err, IsNil)
+
+	body, err := bucket.GetObject("plain")
+	c.Assert(err, IsNil)
+	c.Assert(string(readAll(c, body)), Equals, "0123456789")
+	body, err = bucket.GetObject("plain", ks3.Range(2, 4))
+	c.Assert(err, IsNil)
+	c.Assert(string(readAll(c, body)), Equals, "234")
+}
+
+func (s *Ks3CryptoSuite) TestRSAMasterKeyFromPEM(c *C) {
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(s.rsaKey)})
+	publicDER, err := x509.MarshalPKIXPublicKey(&s.rsaKey.PublicKey)
+	c.Assert(err, IsNil)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	encrypter, err := NewRSAMasterKeyFromPEM(publicPEM, nil, nil)
+	c.Assert(err, IsNil)
+	decrypter, err := NewRSAMasterKeyFromPEM(nil, privatePEM, nil)
+	c.Assert(err, IsNil)
+
+	wrapped, err := encrypter.WrapKey([]byte("0123456789abcdef0123456789abcdef"))
+	c.Assert(err, IsNil)
+	_, err = encrypter.UnwrapKey(wrapped, nil)
+	c.Assert(err, NotNil)
+	key, err := decrypter.UnwrapKey(wrapped, nil)
+	c.Assert(err, IsNil)
+	c.Assert(string(key), Equals, "0123456789abcdef0123456789abcdef")
+
+	_, err = NewRSAMasterKeyFromPEM([]byte("invalid"), nil, nil)
+	c.Assert(err, NotNil)
+	_, err = NewRSAMasterKey(nil, nil, nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3CryptoSuite) TestParseRange(c *C) {
+	cases := []struct {
+		value      string
+		start, end int64
+		ok         bool
+	}{
+		{"bytes=0-9", 0, 9, true},
+		{"bytes=5-", 5, 99, true},
+		{"bytes=-10", 90, 99, true},
+		{"bytes=-200", 0, 99, true},
+		{"bytes=90-200", 90, 99, true},
+		{"bytes=100-", 0, 0, false},
+		{"bytes=9-5", 0, 0, false},
+		{"bytes=0-1,3-4", 0, 0, false},
+		{"items=0-1", 0, 0, false},
+	}
+	for _, tc := range cases {
+		start, end, err := parseRange(tc.value, 100)
+		c.Assert(err == nil, Equals, tc.ok, Commentf(tc.value))
+		if tc.ok {
+			c.Assert(start, Equals, tc.start)
+			c.Assert(end, Equals, tc.end)
+		}
+	}
+}
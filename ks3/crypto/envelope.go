@@ -0,0 +1,145 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// Metadata names of the encryption envelope, they're stored as X-Kss-Meta- headers
+const (
+	MetaKey                      = "Client-Side-Encryption-Key"                        // Base64 of the wrapped data key
+	MetaStart                    = "Client-Side-Encryption-Start"                      // Base64 of the IV
+	MetaCekAlg                   = "Client-Side-Encryption-Cek-Alg"                    // Content encryption algorithm
+	MetaWrapAlg                  = "Client-Side-Encryption-Wrap-Alg"                   // Key wrap algorithm
+	MetaMatDesc                  = "Client-Side-Encryption-Matdesc"                    // JSON of the material description
+	MetaChunkSize                = "Client-Side-Encryption-Chunk-Size"                 // Plaintext chunk size of AES-GCM
+	MetaUnencryptedContentLength = "Client-Side-Encryption-Unencrypted-Content-Length" // Plaintext size of the object
+)
+
+// envelope holds the encryption materials of an object
+type envelope struct {
+	cekAlg     string
+	wrapAlg    string
+	wrappedKey []byte
+	iv         []byte
+	matDesc    map[string]string
+	chunkSize  int64
+	plainSize  int64 // -1 if unknown
+	cipher     contentCipher
+}
+
+// newEnvelope generates the data key and IV of a new object and wraps the data key
+func newEnvelope(provider MasterKeyProvider, algorithm string, plainSize int64) (*envelope, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	env := &envelope{
+		cekAlg:    algorithm,
+		wrapAlg:   provider.WrapAlgorithm(),
+		matDesc:   provider.MaterialDescription(),
+		plainSize: plainSize,
+	}
+	switch algorithm {
+	case AESCTRAlgorithm:
+		env.iv = make([]byte, ctrIVSize)
+	case AESGCMAlgorithm:
+		env.iv = make([]byte, gcmIVSize)
+		env.chunkSize = DefaultGCMChunkSize
+	default:
+		return nil, fmt.Errorf("ks3: unsupported content encryption algorithm %q", algorithm)
+	}
+	if _, err := io.ReadFull(rand.Reader, env.iv); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if env.cipher, err = newContentCipher(algorithm, key, env.iv, env.chunkSize); err != nil {
+		return nil, err
+	}
+	if env.wrappedKey, err = provider.WrapKey(key); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// options returns the metadata options of the envelope
+func (env *envelope) options() ([]ks3.Option, error) {
+	options := []ks3.Option{
+		ks3.Meta(MetaKey, base64.StdEncoding.EncodeToString(env.wrappedKey)),
+		ks3.Meta(MetaStart, base64.StdEncoding.EncodeToString(env.iv)),
+		ks3.Meta(MetaCekAlg, env.cekAlg),
+		ks3.Meta(MetaWrapAlg, env.wrapAlg),
+	}
+	if len(env.matDesc) > 0 {
+		bs, err := json.Marshal(env.matDesc)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, ks3.Meta(MetaMatDesc, string(bs)))
+	}
+	if env.chunkSize > 0 {
+		options = append(options, ks3.Meta(MetaChunkSize, strconv.FormatInt(env.chunkSize, 10)))
+	}
+	if env.plainSize >= 0 {
+		options = append(options, ks3.Meta(MetaUnencryptedContentLength, strconv.FormatInt(env.plainSize, 10)))
+	}
+	return options, nil
+}
+
+// parseEnvelope reads the envelope from the object headers and unwraps the data key.
+// It returns nil if the object isn't encrypted on the client side.
+func parseEnvelope(header http.Header, provider MasterKeyProvider) (*envelope, error) {
+	wrappedKey := header.Get(ks3.HTTPHeaderKs3MetaPrefix + MetaKey)
+	if wrappedKey == "" {
+		return nil, nil
+	}
+
+	var err error
+	env := &envelope{
+		cekAlg:    header.Get(ks3.HTTPHeaderKs3MetaPrefix + MetaCekAlg),
+		wrapAlg:   header.Get(ks3.HTTPHeaderKs3MetaPrefix + MetaWrapAlg),
+		plainSize: -1,
+	}
+	if env.wrapAlg != provider.WrapAlgorithm() {
+		return nil, fmt.Errorf("ks3: the data key is wrapped with %q but the master key supports %q", env.wrapAlg, provider.WrapAlgorithm())
+	}
+	if env.wrappedKey, err = base64.StdEncoding.DecodeString(wrappedKey); err != nil {
+		return nil, fmt.Errorf("ks3: invalid wrapped data key: %v", err)
+	}
+	if env.iv, err = base64.StdEncoding.DecodeString(header.Get(ks3.HTTPHeaderKs3MetaPrefix + MetaStart)); err != nil {
+		return nil, fmt.Errorf("ks3: invalid encryption iv: %v", err)
+	}
+	if s := header.Get(ks3.HTTPHeaderKs3MetaPrefix + MetaMatDesc); s != "" {
+		if err = json.Unmarshal([]byte(s), &env.matDesc); err != nil {
+			return nil, fmt.Errorf("ks3: invalid material description: %v", err)
+		}
+	}
+	if s := header.Get(ks3.HTTPHeaderKs3MetaPrefix + MetaChunkSize); s != "" {
+		if env.chunkSize, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("ks3: invalid encryption chunk size: %v", err)
+		}
+	}
+	if s := header.Get(ks3.HTTPHeaderKs3MetaPrefix + MetaUnencryptedContentLength); s != "" {
+		if env.plainSize, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("ks3: invalid unencrypted content length: %v", err)
+		}
+	}
+
+	key, err := provider.UnwrapKey(env.wrappedKey, env.matDesc)
+	if err != nil {
+		return nil, fmt.Errorf("ks3: failed to unwrap the data key: %v", err)
+	}
+	if env.cipher, err = newContentCipher(env.cekAlg, key, env.iv, env.chunkSize); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
@@ -0,0 +1,193 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Key wrap algorithms
+const (
+	RSAWrapAlgorithm = "RSA/NONE/OAEPWithSHA-256Padding" // RSA-OAEP with SHA-256
+	AESWrapAlgorithm = "AES/GCM/KeyWrap"                 // AES-GCM with a random nonce prepended to the wrapped key
+)
+
+// MasterKeyProvider wraps and unwraps the per-object data keys.
+//
+// The wrapped data key, the wrap algorithm and the material description are stored in the object metadata.
+// UnwrapKey receives the material description of the object, so a provider holding several master keys
+// can choose the key which wrapped the data key.
+type MasterKeyProvider interface {
+	// WrapAlgorithm returns the algorithm used to wrap the data keys
+	WrapAlgorithm() string
+	// MaterialDescription describes the master key, it's stored with the object
+	MaterialDescription() map[string]string
+	// WrapKey encrypts the data key
+	WrapKey(key []byte) ([]byte, error)
+	// UnwrapKey decrypts the data key wrapped with the master key described by matDesc
+	UnwrapKey(wrapped []byte, matDesc map[string]string) ([]byte, error)
+}
+
+// RSAMasterKey wraps the data keys with an RSA key pair.
+// The public key is required to encrypt objects and the private key is required to decrypt them.
+type RSAMasterKey struct {
+	publicKey  *rsa.PublicKey
+	privateKey *rsa.PrivateKey
+	matDesc    map[string]string
+}
+
+// NewRSAMasterKey creates the RSA master key provider.
+//
+// publicKey    the public key to wrap the data keys, it's taken from privateKey if nil.
+// privateKey    the private key to unwrap the data keys, it can be nil if objects are only encrypted.
+// matDesc    the material description stored with the objects, it can be nil.
+//
+// *RSAMasterKey    the master key provider.
+// error    it's nil if no error, otherwise it's an error object.
+func NewRSAMasterKey(publicKey *rsa.PublicKey, privateKey *rsa.PrivateKey, matDesc map[string]string) (*RSAMasterKey, error) {
+	if publicKey == nil && privateKey != nil {
+		publicKey = &privateKey.PublicKey
+	}
+	if publicKey == nil {
+		return nil, errors.New("ks3: RSA master key requires a public or private key")
+	}
+	return &RSAMasterKey{publicKey: publicKey, privateKey: privateKey, matDesc: matDesc}, nil
+}
+
+// NewRSAMasterKeyFromPEM creates the RSA master key provider from PEM encoded keys.
+//
+// publicPEM    the PKIX or PKCS #1 public key, it can be empty if privatePEM is set.
+// privatePEM    the PKCS #1 or PKCS #8 private key, it can be empty if objects are only encrypted.
+// matDesc    the material description stored with the objects, it can be nil.
+//
+// *RSAMasterKey    the master key provider.
+// error    it's nil if no error, otherwise it's an error object.
+func NewRSAMasterKeyFromPEM(publicPEM, privatePEM []byte, matDesc map[string]string) (*RSAMasterKey, error) {
+	var publicKey *rsa.PublicKey
+	var privateKey *rsa.PrivateKey
+
+	if len(publicPEM) > 0 {
+		block, _ := pem.Decode(publicPEM)
+		if block == nil {
+			return nil, errors.New("ks3: invalid PEM public key")
+		}
+		if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+			publicKey = key
+		} else {
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("ks3: invalid public key: %v", err)
+			}
+			rsaKey, ok := key.(*rsa.PublicKey)
+			if !ok {
+				return nil, errors.New("ks3: the public key is not an RSA key")
+			}
+			publicKey = rsaKey
+		}
+	}
+
+	if len(privatePEM) > 0 {
+		block, _ := pem.Decode(privatePEM)
+		if block == nil {
+			return nil, errors.New("ks3: invalid PEM private key")
+		}
+		if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			privateKey = key
+		} else {
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("ks3: invalid private key: %v", err)
+			}
+			rsaKey, ok := key.(*rsa.PrivateKey)
+			if !ok {
+				return nil, errors.New("ks3: the private key is not an RSA key")
+			}
+			privateKey = rsaKey
+		}
+	}
+
+	return NewRSAMasterKey(publicKey, privateKey, matDesc)
+}
+
+// WrapAlgorithm implements MasterKeyProvider.
+func (m *RSAMasterKey) WrapAlgorithm() string {
+	return RSAWrapAlgorithm
+}
+
+// MaterialDescription implements MasterKeyProvider.
+func (m *RSAMasterKey) MaterialDescription() map[string]string {
+	return m.matDesc
+}
+
+// WrapKey implements MasterKeyProvider.
+func (m *RSAMasterKey) WrapKey(key []byte) ([]byte, error) {
+	return rsa.EncryptOAEP(sha256.New(), rand.Reader, m.publicKey, key, nil)
+}
+
+// UnwrapKey implements MasterKeyProvider.
+func (m *RSAMasterKey) UnwrapKey(wrapped []byte, matDesc map[string]string) ([]byte, error) {
+	if m.privateKey == nil {
+		return nil, errors.New("ks3: RSA master key has no private key to decrypt")
+	}
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, m.privateKey, wrapped, nil)
+}
+
+// AESMasterKey wraps the data keys with a symmetric AES key.
+type AESMasterKey struct {
+	aead    cipher.AEAD
+	matDesc map[string]string
+}
+
+// NewAESMasterKey creates the symmetric master key provider.
+//
+// key    the AES key of 16, 24 or 32 bytes.
+// matDesc    the material description stored with the objects, it can be nil.
+//
+// *AESMasterKey    the master key provider.
+// error    it's nil if no error, otherwise it's an error object.
+func NewAESMasterKey(key []byte, matDesc map[string]string) (*AESMasterKey, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESMasterKey{aead: aead, matDesc: matDesc}, nil
+}
+
+// WrapAlgorithm implements MasterKeyProvider.
+func (m *AESMasterKey) WrapAlgorithm() string {
+	return AESWrapAlgorithm
+}
+
+// MaterialDescription implements MasterKeyProvider.
+func (m *AESMasterKey) MaterialDescription() map[string]string {
+	return m.matDesc
+}
+
+// WrapKey implements MasterKeyProvider.
+func (m *AESMasterKey) WrapKey(key []byte) ([]byte, error) {
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return m.aead.Seal(nonce, nonce, key, nil), nil
+}
+
+// UnwrapKey implements MasterKeyProvider.
+func (m *AESMasterKey) UnwrapKey(wrapped []byte, matDesc map[string]string) ([]byte, error) {
+	nonceSize := m.aead.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, errors.New("ks3: invalid wrapped data key")
+	}
+	return m.aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], nil)
+}
@@ -284,18 +284,18 @@ func (bucket Bucket) downloadFile(objectKey, filePath string, partSize int64, op
 	// Get the object detailed meta for object whole size
 	// must delete header:range to get whole object size
 	skipOptions := DeleteOption(options, HTTPHeaderRange)
-	meta, err := bucket.GetObjectDetailedMeta(objectKey, skipOptions...)
+	header, err := bucket.GetObjectDetailedMeta(objectKey, skipOptions...)
 	if err != nil {
 		return err
 	}
-
-	objectSize, err := strconv.ParseInt(meta.Get(HTTPHeaderContentLength), 10, 64)
+	meta, err := transferObjectMeta(header)
 	if err != nil {
 		return err
 	}
+	objectSize := meta.ContentLength
 
 	enableCRC := false
-	if bucket.GetConfig().IsEnableCRC && meta.Get(HTTPHeaderKs3CRC64) != "" {
+	if bucket.GetConfig().IsEnableCRC && meta.CRC64 != nil {
 		if uRange == nil || (!uRange.HasStart && !uRange.HasEnd) {
 			enableCRC = true
 		}
@@ -350,7 +350,7 @@ func (bucket Bucket) downloadFile(objectKey, filePath string, partSize int64, op
 
 	if enableCRC {
 		clientCRC := combineCRCInDownloadParts(parts)
-		serverCRC, _ := strconv.ParseUint(meta.Get(HTTPHeaderKs3CRC64), 10, 64)
+		serverCRC := *meta.CRC64
 		err = CheckDownloadCRC(clientCRC, serverCRC)
 		bucket.Client.Config.WriteLog(Debug, "check file crc64, bucketName:%s, objectKey:%s, client crc:%d, server crc:%d", bucket.BucketName, objectKey, clientCRC, serverCRC)
 		if err != nil {
@@ -386,34 +386,29 @@ type objectStat struct {
 }
 
 // isValid flags of checkpoint data is valid. It returns true when the data is valid and the checkpoint is valid and the object is not updated.
-func (cp *downloadCheckpoint) isValid(meta http.Header, uRange *UnpackedRange) (bool, error) {
+func (cp *downloadCheckpoint) isValid(meta *ObjectMeta, uRange *UnpackedRange) bool {
 	newMd5, _ := cp.checksum()
 
 	if cp.Magic != downloadCpMagic || newMd5 != cp.MD5 {
-		return false, nil
-	}
-
-	objectSize, err := strconv.ParseInt(meta.Get(HTTPHeaderContentLength), 10, 64)
-	if err != nil {
-		return false, err
+		return false
 	}
 
 	// Compare the object size, last modified time and etag
-	if cp.ObjStat.Size != objectSize ||
-		cp.ObjStat.LastModified != meta.Get(HTTPHeaderLastModified) ||
-		cp.ObjStat.Etag != meta.Get(HTTPHeaderEtag) {
-		return false, nil
+	if cp.ObjStat.Size != meta.ContentLength ||
+		cp.ObjStat.LastModified != meta.Header.Get(HTTPHeaderLastModified) ||
+		cp.ObjStat.Etag != meta.ETag {
+		return false
 	}
 
 	// Check the download range
 	if uRange != nil {
-		start, end := AdjustRange(uRange, objectSize)
+		start, end := AdjustRange(uRange, meta.ContentLength)
 		if start != cp.Start || end != cp.End {
-			return false, nil
+			return false
 		}
 	}
 
-	return true, nil
+	return true
 }
 
 // load checkpoint from local file
@@ -485,34 +480,28 @@ func (cp *downloadCheckpoint) getCompletedBytes() int64 {
 }
 
 // prepare initiates download tasks
-func (cp *downloadCheckpoint) prepare(meta http.Header, bucket *Bucket, objectKey, filePath string, partSize int64, uRange *UnpackedRange) error {
+func (cp *downloadCheckpoint) prepare(meta *ObjectMeta, bucket *Bucket, objectKey, filePath string, partSize int64, uRange *UnpackedRange) {
 	cp.Magic = downloadCpMagic
 	cp.FilePath = filePath
 	cp.Object = objectKey
 
-	objectSize, err := strconv.ParseInt(meta.Get(HTTPHeaderContentLength), 10, 64)
-	if err != nil {
-		return err
-	}
-
-	cp.ObjStat.Size = objectSize
-	cp.ObjStat.LastModified = meta.Get(HTTPHeaderLastModified)
-	cp.ObjStat.Etag = meta.Get(HTTPHeaderEtag)
+	// The checkpoint keeps the raw last modified time to compare
+	cp.ObjStat.Size = meta.ContentLength
+	cp.ObjStat.LastModified = meta.Header.Get(HTTPHeaderLastModified)
+	cp.ObjStat.Etag = meta.ETag
 
-	if bucket.GetConfig().IsEnableCRC && meta.Get(HTTPHeaderKs3CRC64) != "" {
+	if bucket.GetConfig().IsEnableCRC && meta.CRC64 != nil {
 		if uRange == nil || (!uRange.HasStart && !uRange.HasEnd) {
 			cp.EnableCRC = true
-			cp.CRC, _ = strconv.ParseUint(meta.Get(HTTPHeaderKs3CRC64), 10, 64)
+			cp.CRC = *meta.CRC64
 		}
 	}
 
-	cp.Parts = getDownloadParts(objectSize, partSize, uRange)
+	cp.Parts = getDownloadParts(meta.ContentLength, partSize, uRange)
 	cp.PartStat = make([]bool, len(cp.Parts))
 	for i := range cp.PartStat {
 		cp.PartStat[i] = false
 	}
-
-	return nil
 }
 
 func (cp *downloadCheckpoint) complete(cpFilePath, downFilepath string, disableTempFile bool) error {
@@ -557,17 +546,18 @@ func (bucket Bucket) downloadFileWithCp(objectKey, filePath string, partSize int
 	// Get the object detailed meta for object whole size
 	// must delete header:range to get whole object size
 	skipOptions := DeleteOption(options, HTTPHeaderRange)
-	meta, err := bucket.GetObjectDetailedMeta(objectKey, skipOptions...)
+	header, err := bucket.GetObjectDetailedMeta(objectKey, skipOptions...)
+	if err != nil {
+		return err
+	}
+	meta, err := transferObjectMeta(header)
 	if err != nil {
 		return err
 	}
 
 	// Load error or data invalid. Re-initialize the download.
-	valid, err := dcp.isValid(meta, uRange)
-	if err != nil || !valid {
-		if err = dcp.prepare(meta, &bucket, objectKey, filePath, partSize, uRange); err != nil {
-			return err
-		}
+	if !dcp.isValid(meta, uRange) {
+		dcp.prepare(meta, &bucket, objectKey, filePath, partSize, uRange)
 		os.Remove(cpFilePath)
 	}
 
@@ -628,7 +618,7 @@ func (bucket Bucket) downloadFileWithCp(objectKey, filePath string, partSize int
 
 	if dcp.EnableCRC {
 		clientCRC := combineCRCInDownloadParts(dcp.Parts)
-		serverCRC, _ := strconv.ParseUint(meta.Get(HTTPHeaderKs3CRC64), 10, 64)
+		serverCRC := dcp.CRC
 		err = CheckDownloadCRC(clientCRC, serverCRC)
 		bucket.Client.Config.WriteLog(Debug, "check file crc64, bucketName:%s, objectKey:%s, client crc:%d, server crc:%d", bucket.BucketName, objectKey, clientCRC, serverCRC)
 		if err != nil {
@@ -674,17 +664,18 @@ func (bucket Bucket) downloadFileWithURL(signedURL, filePath string, partSize in
 	// Get the object detailed meta for object whole size
 	// must delete header:range to get whole object size
 	skipOptions := DeleteOption(options, HTTPHeaderRange)
-	meta, err := bucket.GetObjectMetaWithURL(signedURL, skipOptions...)
+	header, err := bucket.GetObjectMetaWithURL(signedURL, skipOptions...)
+	if err != nil {
+		return err
+	}
+	meta, err := transferObjectMeta(header)
 	if err != nil {
 		return err
 	}
 
 	// Load error or data invalid. Re-initialize the download.
-	valid, err := dcp.isValid(meta, uRange)
-	if err != nil || !valid {
-		if err = dcp.prepare(meta, &bucket, signedURL, filePath, partSize, uRange); err != nil {
-			return err
-		}
+	if !dcp.isValid(meta, uRange) {
+		dcp.prepare(meta, &bucket, signedURL, filePath, partSize, uRange)
 		os.Remove(cpFilePath)
 	}
 
@@ -745,7 +736,7 @@ func (bucket Bucket) downloadFileWithURL(signedURL, filePath string, partSize in
 
 	if dcp.EnableCRC {
 		clientCRC := combineCRCInDownloadParts(dcp.Parts)
-		serverCRC, _ := strconv.ParseUint(meta.Get(HTTPHeaderKs3CRC64), 10, 64)
+		serverCRC := dcp.CRC
 		err = CheckDownloadCRC(clientCRC, serverCRC)
 		bucket.Client.Config.WriteLog(Debug, "check file crc64, bucketName:%s, signedURL:%s, client crc:%d, server crc:%d", bucket.BucketName, signedURL, clientCRC, serverCRC)
 		if err != nil {
@@ -78,6 +78,28 @@ func (e CRCCheckError) Error() string {
 		e.operation, e.clientCRC, e.serverCRC, e.requestID)
 }
 
+// SSECustomerKeyError is returned when the SSE-C key of a request is missing or invalid
+type SSECustomerKeyError struct {
+	Bucket string // The bucket name of the request
+	Object string // The object name of the request
+	Reason string // Why the key is rejected
+	Err    error  // The error returned from KS3, it's nil if the key is rejected by the client
+}
+
+// Error implements interface error
+func (e SSECustomerKeyError) Error() string {
+	msg := fmt.Sprintf("ks3: SSE-C key error of bucket %s object %s: %s", e.Bucket, e.Object, e.Reason)
+	if e.Err != nil {
+		msg += "; " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the error returned from KS3
+func (e SSECustomerKeyError) Unwrap() error {
+	return e.Err
+}
+
 func CheckDownloadCRC(clientCRC, serverCRC uint64) error {
 	if clientCRC == serverCRC {
 		return nil
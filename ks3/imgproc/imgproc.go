@@ -0,0 +1,400 @@
+// Package imgproc builds and parses the x-kss-process strings of image processing.
+//
+// A Builder collects the operations, validates their parameters and renders them in a fixed order,
+// so the same builder always produces the same process string:
+//
+//	process, err := imgproc.New().
+//		Resize(imgproc.ResizeOptions{Mode: imgproc.ResizeFill, Width: 200, Height: 200}).
+//		Rotate(90).
+//		Format("webp").
+//		Quality(80).
+//		Build()
+//
+//	body, err := bucket.GetObject("photo.jpg", ks3.Process(process))
+//
+// The rendered string is used by the Process option, Bucket.ProcessObject (together with SaveAs) and Bucket.SignURL.
+package imgproc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// Resize modes
+const (
+	ResizeLfit  = "lfit"  // Scale proportionally to fit inside the width and height
+	ResizeMfit  = "mfit"  // Scale proportionally to cover the width and height
+	ResizeFill  = "fill"  // Scale proportionally to cover the width and height, then crop the center
+	ResizePad   = "pad"   // Scale proportionally to fit inside the width and height, then pad with Color
+	ResizeFixed = "fixed" // Scale to the exact width and height
+)
+
+// Gravity values of Crop and Watermark
+const (
+	GravityNorthWest = "nw"
+	GravityNorth     = "north"
+	GravityNorthEast = "ne"
+	GravityWest      = "west"
+	GravityCenter    = "center"
+	GravityEast      = "east"
+	GravitySouthWest = "sw"
+	GravitySouth     = "south"
+	GravitySouthEast = "se"
+)
+
+const (
+	processPrefix = "image/"
+	saveAsPrefix  = "sys/saveas"
+	maxImageSide  = 16384 // Max width or height of the image in pixels
+	maxOffset     = 4096  // Max watermark offset in pixels
+)
+
+var (
+	resizeModes = []string{ResizeLfit, ResizeMfit, ResizeFill, ResizePad, ResizeFixed}
+	gravities   = []string{GravityNorthWest, GravityNorth, GravityNorthEast, GravityWest, GravityCenter,
+		GravityEast, GravitySouthWest, GravitySouth, GravitySouthEast}
+	formats = []string{"jpg", "jpeg", "png", "webp", "bmp", "gif", "tiff"}
+)
+
+// ResizeOptions defines the parameters of the resize operation
+type ResizeOptions struct {
+	Mode    string // Resize mode, the server uses ResizeLfit if it's empty
+	Width   int    // Target width, 1-16384
+	Height  int    // Target height, 1-16384
+	Long    int    // Target length of the long side, 1-16384
+	Short   int    // Target length of the short side, 1-16384
+	Percent int    // Scale percentage, 1-1000. It can't be used with the sizes above
+	Color   string // Padding color of ResizePad in RRGGBB
+}
+
+// CropOptions defines the parameters of the crop operation
+type CropOptions struct {
+	X       int    // Horizontal start point relative to Gravity
+	Y       int    // Vertical start point relative to Gravity
+	Width   int    // Crop width, 0 means to the right edge
+	Height  int    // Crop height, 0 means to the bottom edge
+	Gravity string // Origin of X and Y, the server uses GravityNorthWest if it's empty
+}
+
+// WatermarkOptions defines the parameters of the watermark operation, either Text or Image must be set
+type WatermarkOptions struct {
+	Text         string // Text of the watermark
+	Image        string // Object key of the watermark image in the same bucket
+	Font         string // Font of the text
+	Color        string // Color of the text in RRGGBB
+	Size         int    // Font size of the text, 1-1000
+	Transparency int    // Transparency, 0-100, 0 means the server default
+	Gravity      string // Position of the watermark, the server uses GravitySouthEast if it's empty
+	X            int    // Horizontal margin, 0-4096
+	Y            int    // Vertical margin, 0-4096
+}
+
+// Builder builds the image process string.
+//
+// Every operation appears at most once, setting it again replaces the previous parameters.
+// The operations are rendered in the order resize, crop, rotate, watermark, quality, format
+// regardless of the order of the calls. The first invalid parameter is kept and returned by Err and Build.
+type Builder struct {
+	resize          *ResizeOptions
+	crop            *CropOptions
+	rotate          *int
+	watermark       *WatermarkOptions
+	quality         *int
+	absoluteQuality bool
+	format          string
+	saveBucket      string
+	saveKey         string
+	err             error
+}
+
+// New creates an empty image process builder.
+func New() *Builder {
+	return &Builder{}
+}
+
+// Resize scales the image.
+func (b *Builder) Resize(opts ResizeOptions) *Builder {
+	if opts.Mode != "" && !contains(resizeModes, opts.Mode) {
+		return b.fail("invalid resize mode %q", opts.Mode)
+	}
+	if opts.Percent != 0 {
+		if opts.Percent < 1 || opts.Percent > 1000 {
+			return b.fail("resize percent %d out of range 1-1000", opts.Percent)
+		}
+		if opts.Width != 0 || opts.Height != 0 || opts.Long != 0 || opts.Short != 0 {
+			return b.fail("resize percent can't be used with width, height, long or short")
+		}
+	} else {
+		if opts.Width == 0 && opts.Height == 0 && opts.Long == 0 && opts.Short == 0 {
+			return b.fail("resize requires width, height, long, short or percent")
+		}
+		for _, v := range []int{opts.Width, opts.Height, opts.Long, opts.Short} {
+			if v < 0 || v > maxImageSide {
+				return b.fail("resize size %d out of range 1-%d", v, maxImageSide)
+			}
+		}
+	}
+	if opts.Color != "" {
+		if opts.Mode != ResizePad {
+			return b.fail("resize color is only valid in %s mode", ResizePad)
+		}
+		if !isColor(opts.Color) {
+			return b.fail("invalid resize color %q", opts.Color)
+		}
+	}
+	b.resize = &opts
+	return b
+}
+
+// Crop cuts a rectangle from the image.
+func (b *Builder) Crop(opts CropOptions) *Builder {
+	if opts.X < 0 || opts.Y < 0 || opts.X > maxImageSide || opts.Y > maxImageSide {
+		return b.fail("crop start point (%d, %d) out of range 0-%d", opts.X, opts.Y, maxImageSide)
+	}
+	if opts.Width < 0 || opts.Height < 0 || opts.Width > maxImageSide || opts.Height > maxImageSide {
+		return b.fail("crop size %dx%d out of range 0-%d", opts.Width, opts.Height, maxImageSide)
+	}
+	if opts.Gravity != "" && !contains(gravities, opts.Gravity) {
+		return b.fail("invalid crop gravity %q", opts.Gravity)
+	}
+	b.crop = &opts
+	return b
+}
+
+// Rotate rotates the image clockwise by degrees, 0-360.
+func (b *Builder) Rotate(degrees int) *Builder {
+	if degrees < 0 || degrees > 360 {
+		return b.fail("rotate degrees %d out of range 0-360", degrees)
+	}
+	b.rotate = &degrees
+	return b
+}
+
+// Watermark adds a text or image watermark.
+func (b *Builder) Watermark(opts WatermarkOptions) *Builder {
+	if (opts.Text == "") == (opts.Image == "") {
+		return b.fail("watermark requires either text or image")
+	}
+	if opts.Image != "" && (opts.Font != "" || opts.Color != "" || opts.Size != 0) {
+		return b.fail("watermark font, color and size are only valid for text")
+	}
+	if opts.Color != "" && !isColor(opts.Color) {
+		return b.fail("invalid watermark color %q", opts.Color)
+	}
+	if opts.Size < 0 || opts.Size > 1000 {
+		return b.fail("watermark size %d out of range 1-1000", opts.Size)
+	}
+	if opts.Transparency < 0 || opts.Transparency > 100 {
+		return b.fail("watermark transparency %d out of range 0-100", opts.Transparency)
+	}
+	if opts.Gravity != "" && !contains(gravities, opts.Gravity) {
+		return b.fail("invalid watermark gravity %q", opts.Gravity)
+	}
+	if opts.X < 0 || opts.Y < 0 || opts.X > maxOffset || opts.Y > maxOffset {
+		return b.fail("watermark offset (%d, %d) out of range 0-%d", opts.X, opts.Y, maxOffset)
+	}
+	b.watermark = &opts
+	return b
+}
+
+// Quality sets the relative quality of jpg and webp images, 1-100.
+func (b *Builder) Quality(q int) *Builder {
+	return b.setQuality(q, false)
+}
+
+// AbsoluteQuality sets the absolute quality of jpg and webp images, 1-100.
+func (b *Builder) AbsoluteQuality(q int) *Builder {
+	return b.setQuality(q, true)
+}
+
+func (b *Builder) setQuality(q int, absolute bool) *Builder {
+	if q < 1 || q > 100 {
+		return b.fail("quality %d out of range 1-100", q)
+	}
+	b.quality = &q
+	b.absoluteQuality = absolute
+	return b
+}
+
+// Format converts the image to the format, such as jpg, png or webp.
+func (b *Builder) Format(format string) *Builder {
+	format = strings.ToLower(format)
+	if !contains(formats, format) {
+		return b.fail("unsupported format %q", format)
+	}
+	b.format = format
+	return b
+}
+
+// SaveAs saves the processed image to the object of bucketName, it's only used by Bucket.ProcessObject.
+// The processed image is saved to the source bucket if bucketName is empty.
+func (b *Builder) SaveAs(bucketName, objectKey string) *Builder {
+	if objectKey == "" {
+		return b.fail("save as object key is empty")
+	}
+	b.saveBucket = bucketName
+	b.saveKey = objectKey
+	return b
+}
+
+// Err returns the first invalid parameter of the builder.
+func (b *Builder) Err() error {
+	return b.err
+}
+
+// Build validates the builder and renders the process string.
+//
+// string    the process string, such as "image/resize,m_fill,w_200,h_200/format,webp".
+// error    it's nil if no error, otherwise it's an error object.
+func (b *Builder) Build() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+
+	var ops []string
+	if b.resize != nil {
+		ops = append(ops, renderResize(b.resize))
+	}
+	if b.crop != nil {
+		ops = append(ops, renderCrop(b.crop))
+	}
+	if b.rotate != nil {
+		ops = append(ops, "rotate,"+strconv.Itoa(*b.rotate))
+	}
+	if b.watermark != nil {
+		ops = append(ops, renderWatermark(b.watermark))
+	}
+	if b.quality != nil {
+		if b.absoluteQuality {
+			ops = append(ops, "quality,Q_"+strconv.Itoa(*b.quality))
+		} else {
+			ops = append(ops, "quality,q_"+strconv.Itoa(*b.quality))
+		}
+	}
+	if b.format != "" {
+		ops = append(ops, "format,"+b.format)
+	}
+	if len(ops) == 0 {
+		return "", fmt.Errorf("imgproc: no image operation")
+	}
+
+	process := processPrefix + strings.Join(ops, "/")
+	if b.saveKey != "" {
+		process += "|" + saveAsPrefix + ",o_" + encodeParam(b.saveKey)
+		if b.saveBucket != "" {
+			process += ",b_" + encodeParam(b.saveBucket)
+		}
+	}
+	return process, nil
+}
+
+// String returns the process string, it's empty if the builder is invalid.
+func (b *Builder) String() string {
+	process, _ := b.Build()
+	return process
+}
+
+// Option returns the Process option of the process string for GetObject and SignURL.
+func (b *Builder) Option() (ks3.Option, error) {
+	if b.saveKey != "" {
+		return nil, fmt.Errorf("imgproc: save as is only supported by ProcessObject")
+	}
+	process, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return ks3.Process(process), nil
+}
+
+func (b *Builder) fail(format string, args ...interface{}) *Builder {
+	if b.err == nil {
+		b.err = fmt.Errorf("imgproc: "+format, args...)
+	}
+	return b
+}
+
+func renderResize(opts *ResizeOptions) string {
+	params := []string{"resize"}
+	params = appendString(params, "m", opts.Mode)
+	params = appendInt(params, "w", opts.Width)
+	params = appendInt(params, "h", opts.Height)
+	params = appendInt(params, "l", opts.Long)
+	params = appendInt(params, "s", opts.Short)
+	params = appendInt(params, "p", opts.Percent)
+	params = appendString(params, "color", strings.ToUpper(opts.Color))
+	return strings.Join(params, ",")
+}
+
+func renderCrop(opts *CropOptions) string {
+	params := []string{"crop"}
+	params = appendInt(params, "x", opts.X)
+	params = appendInt(params, "y", opts.Y)
+	params = appendInt(params, "w", opts.Width)
+	params = appendInt(params, "h", opts.Height)
+	params = appendString(params, "g", opts.Gravity)
+	return strings.Join(params, ",")
+}
+
+func renderWatermark(opts *WatermarkOptions) string {
+	params := []string{"watermark"}
+	if opts.Text != "" {
+		params = append(params, "text_"+encodeParam(opts.Text))
+	} else {
+		params = append(params, "image_"+encodeParam(opts.Image))
+	}
+	if opts.Font != "" {
+		params = append(params, "type_"+encodeParam(opts.Font))
+	}
+	params = appendString(params, "color", strings.ToUpper(opts.Color))
+	params = appendInt(params, "size", opts.Size)
+	params = appendInt(params, "t", opts.Transparency)
+	params = appendString(params, "g", opts.Gravity)
+	params = appendInt(params, "x", opts.X)
+	params = appendInt(params, "y", opts.Y)
+	return strings.Join(params, ",")
+}
+
+func appendString(params []string, name, value string) []string {
+	if value == "" {
+		return params
+	}
+	return append(params, name+"_"+value)
+}
+
+func appendInt(params []string, name string, value int) []string {
+	if value == 0 {
+		return params
+	}
+	return append(params, name+"_"+strconv.Itoa(value))
+}
+
+// encodeParam encodes the text parameters with URL safe base64 without padding
+func encodeParam(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func decodeParam(s string) (string, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	return string(bs), err
+}
+
+func isColor(s string) bool {
+	if len(s) != 6 {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 16, 32)
+	return err == nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package imgproc
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+	. "gopkg.in/check.v1"
+)
+
+// Test hooks up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type Ks3ImgprocSuite struct{}
+
+var _ = Suite(&Ks3ImgprocSuite{})
+
+func (s *Ks3ImgprocSuite) TestBuild(c *C) {
+	// The operations are rendered in the fixed order
+	process, err := New().
+		Format("WEBP").
+		Quality(80).
+		Rotate(90).
+		Crop(CropOptions{X: 10, Y: 20, Width: 100, Height: 50, Gravity: GravityCenter}).
+		Resize(ResizeOptions{Mode: ResizePad, Width: 200, Height: 100, Color: "ff0000"}).
+		Build()
+	c.Assert(err, IsNil)
+	c.Assert(process, Equals, "image/resize,m_pad,w_200,h_100,color_FF0000/crop,x_10,y_20,w_100,h_50,g_center/rotate,90/quality,q_80/format,webp")
+
+	process, err = New().Resize(ResizeOptions{Percent: 50}).AbsoluteQuality(90).Build()
+	c.Assert(err, IsNil)
+	c.Assert(process, Equals, "image/resize,p_50/quality,Q_90")
+
+	// Setting an operation again replaces it
+	process, err = New().Rotate(90).Rotate(180).Build()
+	c.Assert(err, IsNil)
+	c.Assert(process, Equals, "image/rotate,180")
+
+	_, err = New().Build()
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3ImgprocSuite) TestWatermark(c *C) {
+	b := New().Watermark(WatermarkOptions{Text: "Hello 金山云", Color: "000000", Size: 40, Transparency: 50, Gravity: GravitySouthEast, X: 10, Y: 10})
+	c.Assert(b.String(), Equals, "image/watermark,text_SGVsbG8g6YeR5bGx5LqR,color_000000,size_40,t_50,g_se,x_10,y_10")
+
+	b = New().Watermark(WatermarkOptions{Image: "logo.png"})
+	c.Assert(b.String(), Equals, "image/watermark,image_bG9nby5wbmc")
+
+	c.Assert(New().Watermark(WatermarkOptions{}).Err(), NotNil)
+	c.Assert(New().Watermark(WatermarkOptions{Text: "a", Image: "b"}).Err(), NotNil)
+	c.Assert(New().Watermark(WatermarkOptions{Image: "b", Size: 10}).Err(), NotNil)
+	c.Assert(New().Watermark(WatermarkOptions{Text: "a", Transparency: 101}).Err(), NotNil)
+}
+
+func (s *Ks3ImgprocSuite) TestValidate(c *C) {
+	c.Assert(New().Resize(ResizeOptions{}).Err(), NotNil)
+	c.Assert(New().Resize(ResizeOptions{Width: 16385}).Err(), NotNil)
+	c.Assert(New().Resize(ResizeOptions{Mode: "zoom", Width: 10}).Err(), NotNil)
+	c.Assert(New().Resize(ResizeOptions{Percent: 50, Width: 10}).Err(), NotNil)
+	c.Assert(New().Resize(ResizeOptions{Width: 10, Color: "FF0000"}).Err(), NotNil)
+	c.Assert(New().Resize(ResizeOptions{Mode: ResizePad, Width: 10, Color: "red"}).Err(), NotNil)
+	c.Assert(New().Crop(CropOptions{X: -1}).Err(), NotNil)
+	c.Assert(New().Crop(CropOptions{Gravity: "top"}).Err(), NotNil)
+	c.Assert(New().Rotate(361).Err(), NotNil)
+	c.Assert(New().Quality(0).Err(), NotNil)
+	c.Assert(New().Format("svg").Err(), NotNil)
+	c.Assert(New().SaveAs("bucket", "").Err(), NotNil)
+
+	// The first error is kept and the builder renders nothing
+	b := New().Rotate(400).Quality(0).Format("png")
+	c.Assert(b.Err().Error(), Equals, "imgproc: rotate degrees 400 out of range 0-360")
+	c.Assert(b.String(), Equals, "")
+	_, err := b.Build()
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3ImgprocSuite) TestParse(c *C) {
+	processes := []string{
+		"image/resize,m_pad,w_200,h_100,color_FF0000/crop,x_10,y_20,w_100,h_50,g_center/rotate,90/quality,q_80/format,webp",
+		"image/resize,l_300/quality,Q_90",
+		"image/watermark,text_SGVsbG8g6YeR5bGx5LqR,type_ZmFuZ3poZW5naGVpdGk,color_000000,size_40,t_50,g_se,x_10,y_10",
+		"image/resize,w_100|sys/saveas,o_dGFyZ2V0L3RodW1iLmpwZw,b_ZGVzdC1idWNrZXQ",
+	}
+	for _, process := range processes {
+		b, err := Parse(process)
+		c.Assert(err, IsNil)
+		c.Assert(b.String(), Equals, process)
+	}
+
+	// The operations are reordered and the padded base64 is accepted
+	b, err := Parse("image/format,png/watermark,image_bG9nby5wbmc=/resize,w_100")
+	c.Assert(err, IsNil)
+	c.Assert(b.String(), Equals, "image/resize,w_100/watermark,image_bG9nby5wbmc/format,png")
+
+	// The parsed builder can be changed
+	c.Assert(b.Quality(75).Format("jpg").String(), Equals, "image/resize,w_100/watermark,image_bG9nby5wbmc/quality,q_75/format,jpg")
+
+	invalid := []string{
+		"resize,w_100",
+		"image/",
+		"image/blur,r_3",
+		"image/resize,x_100",
+		"image/resize,w_abc",
+		"image/resize,w_100/resize,h_100",
+		"image/rotate",
+		"image/rotate,500",
+		"image/quality,q_80,Q_80",
+		"image/format,svg",
+		"image/watermark,text_***",
+		"image/resize,w_100|sys/copy,o_dGVzdA",
+		"image/resize,w_100|sys/saveas,b_dGVzdA",
+	}
+	for _, process := range invalid {
+		_, err := Parse(process)
+		c.Assert(err, NotNil, Commentf("%s", process))
+	}
+}
+
+func (s *Ks3ImgprocSuite) TestSignURL(c *C) {
+	client, err := ks3.New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk")
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("test-bucket")
+	c.Assert(err, IsNil)
+
+	opt, err := New().Resize(ResizeOptions{Width: 100}).Format("webp").Option()
+	c.Assert(err, IsNil)
+	signedURL, err := bucket.SignURL("photo.jpg", ks3.HTTPGet, 60, opt)
+	c.Assert(err, IsNil)
+	u, err := url.Parse(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(u.Query().Get("x-kss-process"), Equals, "image/resize,w_100/format,webp")
+	c.Assert(u.Query().Get("Signature"), Not(Equals), "")
+
+	// Save as is only valid for ProcessObject
+	_, err = New().Rotate(90).SaveAs("", "a.jpg").Option()
+	c.Assert(err, NotNil)
+	_, err = New().Rotate(400).Option()
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3ImgprocSuite) TestProcessObject(c *C) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		body = string(bs)
+		w.Write([]byte(`{"bucket":"dest-bucket","fileSize":100,"object":"target/thumb.jpg","status":"OK"}`))
+	}))
+	defer server.Close()
+
+	// The bucket domain is dialed to the local server
+	addr := server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	client, err := ks3.New(server.URL, "ak", "sk", ks3.HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("test-bucket")
+	c.Assert(err, IsNil)
+
+	process := New().Resize(ResizeOptions{Width: 100}).SaveAs("dest-bucket", "target/thumb.jpg").String()
+	res, err := bucket.ProcessObject("photo.jpg", process)
+	c.Assert(err, IsNil)
+	c.Assert(res.Object, Equals, "target/thumb.jpg")
+	c.Assert(body, Equals, "x-ks3-process=image/resize,w_100|sys/saveas,o_dGFyZ2V0L3RodW1iLmpwZw,b_ZGVzdC1idWNrZXQ")
+}
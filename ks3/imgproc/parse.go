@@ -0,0 +1,225 @@
+package imgproc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses a process string back into a builder, the parameters are validated as if the builder methods were called.
+//
+// process    the process string, such as "image/resize,w_100/quality,q_80|sys/saveas,o_dGVzdC5qcGc".
+//
+// *Builder    the builder of the process string.
+// error    it's nil if no error, otherwise it's an error object.
+func Parse(process string) (*Builder, error) {
+	b := New()
+	image := process
+	if i := strings.Index(process, "|"); i >= 0 {
+		image = process[:i]
+		if err := parseSaveAs(b, process[i+1:]); err != nil {
+			return nil, err
+		}
+	}
+
+	if !strings.HasPrefix(image, processPrefix) {
+		return nil, fmt.Errorf("imgproc: process %q doesn't start with %q", process, processPrefix)
+	}
+
+	seen := map[string]bool{}
+	for _, op := range strings.Split(image[len(processPrefix):], "/") {
+		fields := strings.Split(op, ",")
+		name := fields[0]
+		if seen[name] {
+			return nil, fmt.Errorf("imgproc: duplicated operation %q", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "resize":
+			err = parseResize(b, fields[1:])
+		case "crop":
+			err = parseCrop(b, fields[1:])
+		case "rotate":
+			err = parseSingle(fields, func(v string) error {
+				degrees, err := strconv.Atoi(v)
+				b.Rotate(degrees)
+				return err
+			})
+		case "watermark":
+			err = parseWatermark(b, fields[1:])
+		case "quality":
+			err = parseQuality(b, fields[1:])
+		case "format":
+			err = parseSingle(fields, func(v string) error {
+				b.Format(v)
+				return nil
+			})
+		default:
+			err = fmt.Errorf("unknown operation")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("imgproc: invalid operation %q: %v", op, err)
+		}
+	}
+
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b, nil
+}
+
+func parseResize(b *Builder, params []string) error {
+	var opts ResizeOptions
+	err := parseParams(params, func(k, v string) (err error) {
+		switch k {
+		case "m":
+			opts.Mode = v
+		case "w":
+			opts.Width, err = strconv.Atoi(v)
+		case "h":
+			opts.Height, err = strconv.Atoi(v)
+		case "l":
+			opts.Long, err = strconv.Atoi(v)
+		case "s":
+			opts.Short, err = strconv.Atoi(v)
+		case "p":
+			opts.Percent, err = strconv.Atoi(v)
+		case "color":
+			opts.Color = v
+		default:
+			err = fmt.Errorf("unknown parameter %q", k)
+		}
+		return
+	})
+	if err == nil {
+		b.Resize(opts)
+	}
+	return err
+}
+
+func parseCrop(b *Builder, params []string) error {
+	var opts CropOptions
+	err := parseParams(params, func(k, v string) (err error) {
+		switch k {
+		case "x":
+			opts.X, err = strconv.Atoi(v)
+		case "y":
+			opts.Y, err = strconv.Atoi(v)
+		case "w":
+			opts.Width, err = strconv.Atoi(v)
+		case "h":
+			opts.Height, err = strconv.Atoi(v)
+		case "g":
+			opts.Gravity = v
+		default:
+			err = fmt.Errorf("unknown parameter %q", k)
+		}
+		return
+	})
+	if err == nil {
+		b.Crop(opts)
+	}
+	return err
+}
+
+func parseWatermark(b *Builder, params []string) error {
+	var opts WatermarkOptions
+	err := parseParams(params, func(k, v string) (err error) {
+		switch k {
+		case "text":
+			opts.Text, err = decodeParam(v)
+		case "image":
+			opts.Image, err = decodeParam(v)
+		case "type":
+			opts.Font, err = decodeParam(v)
+		case "color":
+			opts.Color = v
+		case "size":
+			opts.Size, err = strconv.Atoi(v)
+		case "t":
+			opts.Transparency, err = strconv.Atoi(v)
+		case "g":
+			opts.Gravity = v
+		case "x":
+			opts.X, err = strconv.Atoi(v)
+		case "y":
+			opts.Y, err = strconv.Atoi(v)
+		default:
+			err = fmt.Errorf("unknown parameter %q", k)
+		}
+		return
+	})
+	if err == nil {
+		b.Watermark(opts)
+	}
+	return err
+}
+
+func parseQuality(b *Builder, params []string) error {
+	if len(params) != 1 {
+		return fmt.Errorf("quality requires one parameter")
+	}
+	return parseParams(params, func(k, v string) error {
+		q, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		switch k {
+		case "q":
+			b.Quality(q)
+		case "Q":
+			b.AbsoluteQuality(q)
+		default:
+			return fmt.Errorf("unknown parameter %q", k)
+		}
+		return nil
+	})
+}
+
+func parseSaveAs(b *Builder, saveAs string) error {
+	fields := strings.Split(saveAs, ",")
+	if fields[0] != saveAsPrefix {
+		return fmt.Errorf("imgproc: unknown process %q", saveAs)
+	}
+	var bucketName, objectKey string
+	err := parseParams(fields[1:], func(k, v string) (err error) {
+		switch k {
+		case "o":
+			objectKey, err = decodeParam(v)
+		case "b":
+			bucketName, err = decodeParam(v)
+		default:
+			err = fmt.Errorf("unknown parameter %q", k)
+		}
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("imgproc: invalid process %q: %v", saveAs, err)
+	}
+	b.SaveAs(bucketName, objectKey)
+	return nil
+}
+
+// parseSingle parses the operations with a single value, such as "rotate,90"
+func parseSingle(fields []string, set func(v string) error) error {
+	if len(fields) != 2 || fields[1] == "" {
+		return fmt.Errorf("%s requires one value", fields[0])
+	}
+	return set(fields[1])
+}
+
+// parseParams splits the "name_value" parameters, the value may contain "_"
+func parseParams(params []string, set func(k, v string) error) error {
+	for _, param := range params {
+		i := strings.Index(param, "_")
+		if i <= 0 {
+			return fmt.Errorf("invalid parameter %q", param)
+		}
+		if err := set(param[:i], param[i+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+// Package inventory reads the inventory reports of a bucket, which are configured by Client.PutBucketInventory.
+//
+// A report is delivered to the bucket and the prefix of KS3BucketDestination, as a manifest and the gzip
+// CSV data files it lists:
+//
+//	<prefix>/<source bucket>/<inventory id>/<YYYY-MM-DDTHH-MMZ>/manifest.json
+//	<prefix>/<source bucket>/<inventory id>/data/<uuid>.csv.gz
+//
+// Find the latest manifest and read the rows of all the data files:
+//
+//	dest, _ := client.Bucket(config.Destination.KS3BucketDestination.Bucket)
+//	source := inventory.BucketSource(dest)
+//	_, manifest, err := inventory.LatestManifest(source, config.Destination.KS3BucketDestination, "bucket", config.Id)
+//	reader := inventory.NewReader(source, manifest)
+//	err = reader.Each(func(r inventory.Record) error {
+//		fmt.Println(r.Key, r.Size, r.StorageClass)
+//		return nil
+//	})
+//
+// DirSource reads the reports downloaded to a local directory the same way.
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The columns of the data files, the ones except Bucket, Key, VersionId, IsLatest and IsDeleteMarker are
+// the optional fields of OptionalFields
+const (
+	FieldBucket              = "Bucket"
+	FieldKey                 = "Key"
+	FieldVersionID           = "VersionId"
+	FieldIsLatest            = "IsLatest"
+	FieldIsDeleteMarker      = "IsDeleteMarker"
+	FieldSize                = "Size"
+	FieldLastModifiedDate    = "LastModifiedDate"
+	FieldETag                = "ETag"
+	FieldStorageClass        = "StorageClass"
+	FieldIsMultipartUploaded = "IsMultipartUploaded"
+	FieldEncryptionStatus    = "EncryptionStatus"
+)
+
+// Manifest is the manifest.json of a report
+type Manifest struct {
+	SourceBucket      string `json:"sourceBucket"`
+	DestinationBucket string `json:"destinationBucket"`
+	Version           string `json:"version"`
+	CreationTimestamp string `json:"creationTimestamp"` // The milliseconds since the epoch
+	FileFormat        string `json:"fileFormat"`
+	FileSchema        string `json:"fileSchema"` // The comma separated columns of the data files
+	Files             []File `json:"files"`
+}
+
+// File is a data file of the report
+type File struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5Checksum string `json:"MD5checksum"` // The hex MD5 of the gzip file
+}
+
+// ParseManifest parses the manifest.json
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	manifest := &Manifest{}
+	if err := json.NewDecoder(r).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("invalid inventory manifest: %s", err.Error())
+	}
+	if manifest.FileFormat != "" && !strings.EqualFold(manifest.FileFormat, "CSV") {
+		return nil, fmt.Errorf("unsupported inventory file format: %s", manifest.FileFormat)
+	}
+	if len(manifest.Schema()) == 0 {
+		return nil, fmt.Errorf("invalid inventory manifest: the file schema is empty")
+	}
+	return manifest, nil
+}
+
+// Schema returns the columns of the data files
+func (m *Manifest) Schema() []string {
+	var columns []string
+	for _, c := range strings.Split(m.FileSchema, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// CreationTime returns when the report was created, zero if the timestamp is malformed
+func (m *Manifest) CreationTime() time.Time {
+	ms, err := strconv.ParseInt(m.CreationTimestamp, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// Record is a row of the data files, the fields which aren't in the schema are zero
+type Record struct {
+	Bucket              string
+	Key                 string
+	VersionID           string
+	IsLatest            bool
+	IsDeleteMarker      bool
+	Size                int64
+	LastModified        time.Time
+	ETag                string
+	StorageClass        string
+	IsMultipartUploaded bool
+	EncryptionStatus    string
+	Fields              map[string]string // All the columns by the names of the schema, the key isn't decoded
+}
+
+// ParseRecord converts the row of the data file by the schema. The key is URL encoded in the data files,
+// it's decoded if it's well-formed.
+func ParseRecord(schema []string, row []string) (Record, error) {
+	record := Record{Fields: make(map[string]string, len(schema))}
+	if len(row) != len(schema) {
+		return record, fmt.Errorf("the row has %d columns, the schema has %d", len(row), len(schema))
+	}
+	for i, column := range schema {
+		value := row[i]
+		record.Fields[column] = value
+		var err error
+		switch column {
+		case FieldBucket:
+			record.Bucket = value
+		case FieldKey:
+			record.Key = value
+			if decoded, err := url.PathUnescape(value); err == nil {
+				record.Key = decoded
+			}
+		case FieldVersionID:
+			record.VersionID = value
+		case FieldIsLatest:
+			record.IsLatest, err = parseBool(value)
+		case FieldIsDeleteMarker:
+			record.IsDeleteMarker, err = parseBool(value)
+		case FieldSize:
+			if value != "" {
+				record.Size, err = strconv.ParseInt(value, 10, 64)
+			}
+		case FieldLastModifiedDate:
+			if value != "" {
+				record.LastModified, err = time.Parse(time.RFC3339, value)
+			}
+		case FieldETag:
+			record.ETag = strings.Trim(value, `"`)
+		case FieldStorageClass:
+			record.StorageClass = value
+		case FieldIsMultipartUploaded:
+			record.IsMultipartUploaded, err = parseBool(value)
+		case FieldEncryptionStatus:
+			record.EncryptionStatus = value
+		}
+		if err != nil {
+			return record, fmt.Errorf("invalid %s %q", column, value)
+		}
+	}
+	return record, nil
+}
+
+// parseBool parses the boolean column, empty is false
+func parseBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,232 @@
+package inventory
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+	. "gopkg.in/check.v1"
+)
+
+// Test hooks up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type Ks3InventorySuite struct {
+	dir     string
+	objects map[string][]byte
+}
+
+var _ = Suite(&Ks3InventorySuite{})
+
+var testDest = ks3.KS3BucketDestination{Format: "CSV", Bucket: "dest-bucket", Prefix: "reports/"}
+
+const testSchema = "Bucket, Key, Size, LastModifiedDate, ETag, StorageClass, IsMultipartUploaded"
+
+func gzipRows(c *C, rows string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(rows))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	return buf.Bytes()
+}
+
+func (s *Ks3InventorySuite) addManifest(c *C, folder string, files map[string][]byte) {
+	manifest := Manifest{
+		SourceBucket:      "src",
+		DestinationBucket: "dest-bucket",
+		Version:           "2019-09-01",
+		CreationTimestamp: "1704067200000",
+		FileFormat:        "CSV",
+		FileSchema:        testSchema,
+	}
+	var keys []string
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		sum := md5.Sum(files[key])
+		manifest.Files = append(manifest.Files, File{Key: key, Size: int64(len(files[key])), MD5Checksum: hex.EncodeToString(sum[:])})
+		s.objects[key] = files[key]
+	}
+	data, err := json.Marshal(manifest)
+	c.Assert(err, IsNil)
+	s.objects["reports/src/daily/"+folder+"/manifest.json"] = data
+	sum := md5.Sum(data)
+	s.objects["reports/src/daily/"+folder+"/manifest.checksum"] = []byte(hex.EncodeToString(sum[:]) + "\n")
+}
+
+func (s *Ks3InventorySuite) SetUpTest(c *C) {
+	s.objects = map[string][]byte{}
+	s.addManifest(c, "2023-12-31T16-00Z", map[string][]byte{
+		"reports/src/daily/data/old.csv.gz": gzipRows(c, "\"src\",\"old\",\"1\",\"2023-01-01T00:00:00.000Z\",\"e\",\"STANDARD\",\"false\"\n"),
+	})
+	s.addManifest(c, "2024-01-01T16-00Z", map[string][]byte{
+		"reports/src/daily/data/a.csv.gz": gzipRows(c,
+			"\"src\",\"dir/a%20b.txt\",\"100\",\"2024-01-01T10:00:00.000Z\",\"\"\"0cc175b9c0f1b6a831c399e269772661\"\"\",\"STANDARD\",\"false\"\n"+
+				"\"src\",\"dir/c.bin\",\"2048\",\"2024-01-01T11:00:00.000Z\",\"abc-2\",\"STANDARD_IA\",\"true\"\n"),
+		"reports/src/daily/data/b.csv.gz": gzipRows(c,
+			"\"src\",\"e.log\",\"0\",\"2024-01-02T00:00:00.000Z\",\"d41d8cd98f00b204e9800998ecf8427e\",\"ARCHIVE\",\"false\"\n"),
+	})
+
+	s.dir = c.MkDir()
+	for key, data := range s.objects {
+		p := filepath.Join(s.dir, filepath.FromSlash(key))
+		c.Assert(os.MkdirAll(filepath.Dir(p), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(p, data, 0644), IsNil)
+	}
+}
+
+func readAllRecords(c *C, reader *Reader) map[string]Record {
+	records := map[string]Record{}
+	err := reader.Each(func(r Record) error {
+		records[r.Key] = r
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return records
+}
+
+func (s *Ks3InventorySuite) checkReport(c *C, source Source) {
+	key, manifest, err := LatestManifest(source, testDest, "src", "daily")
+	c.Assert(err, IsNil)
+	c.Assert(key, Equals, "reports/src/daily/2024-01-01T16-00Z/manifest.json")
+	c.Assert(manifest.Schema(), DeepEquals, []string{"Bucket", "Key", "Size", "LastModifiedDate", "ETag", "StorageClass", "IsMultipartUploaded"})
+	c.Assert(manifest.CreationTime().Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)), Equals, true)
+	c.Assert(len(manifest.Files), Equals, 2)
+
+	records := readAllRecords(c, NewReader(source, manifest))
+	c.Assert(len(records), Equals, 3)
+	a := records["dir/a b.txt"]
+	c.Assert(a.Bucket, Equals, "src")
+	c.Assert(a.Size, Equals, int64(100))
+	c.Assert(a.LastModified.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)), Equals, true)
+	c.Assert(a.ETag, Equals, "0cc175b9c0f1b6a831c399e269772661")
+	c.Assert(a.StorageClass, Equals, "STANDARD")
+	c.Assert(a.Fields[FieldKey], Equals, "dir/a%20b.txt")
+	c.Assert(records["dir/c.bin"].IsMultipartUploaded, Equals, true)
+	c.Assert(records["e.log"].StorageClass, Equals, "ARCHIVE")
+}
+
+func (s *Ks3InventorySuite) TestDirSource(c *C) {
+	s.checkReport(c, DirSource(s.dir))
+}
+
+func (s *Ks3InventorySuite) TestBucketSource(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if key != "" {
+			data, ok := s.objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+			return
+		}
+		// List one key per page
+		prefix, marker := r.URL.Query().Get("prefix"), r.URL.Query().Get("marker")
+		var keys []string
+		for k := range s.objects {
+			if strings.HasPrefix(k, prefix) && k > marker {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		result := ks3.ListObjectsResult{Name: "dest-bucket", Prefix: prefix, IsTruncated: len(keys) > 1}
+		if len(keys) > 0 {
+			result.Objects = []ks3.ObjectProperties{{Key: keys[0]}}
+		}
+		data, _ := xml.Marshal(result)
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+	client, err := ks3.New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", ks3.HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("dest-bucket")
+	c.Assert(err, IsNil)
+	s.checkReport(c, BucketSource(bucket))
+}
+
+func (s *Ks3InventorySuite) TestVerify(c *C) {
+	source := DirSource(s.dir)
+	_, manifest, err := LatestManifest(source, testDest, "src", "daily")
+	c.Assert(err, IsNil)
+
+	// The corrupted data file
+	manifest.Files[1].MD5Checksum = "00000000000000000000000000000000"
+	err = NewReader(source, manifest).Each(func(Record) error { return nil })
+	c.Assert(err, ErrorMatches, "reports/src/daily/data/b.csv.gz: the MD5 .* doesn't match 0+ of the manifest")
+
+	reader := NewReader(source, manifest)
+	reader.VerifyMD5 = false
+	c.Assert(len(readAllRecords(c, reader)), Equals, 3)
+
+	// The error of fn stops the reading
+	manifest.Files[1] = manifest.Files[0]
+	reader = NewReader(source, manifest)
+	reader.Concurrency = 1
+	calls := 0
+	err = reader.Each(func(Record) error {
+		calls++
+		return fmt.Errorf("stop")
+	})
+	c.Assert(err, ErrorMatches, "stop")
+	c.Assert(calls, Equals, 1)
+
+	// The corrupted manifest
+	checksum := filepath.Join(s.dir, "reports/src/daily/2024-01-01T16-00Z/manifest.checksum")
+	c.Assert(ioutil.WriteFile(checksum, []byte("0"), 0644), IsNil)
+	_, _, err = LatestManifest(source, testDest, "src", "daily")
+	c.Assert(err, ErrorMatches, "the MD5 of .*manifest.json doesn't match .*manifest.checksum")
+
+	_, _, err = LatestManifest(source, testDest, "src", "weekly")
+	c.Assert(err, ErrorMatches, "no inventory manifest under reports/src/weekly/")
+}
+
+func (s *Ks3InventorySuite) TestParse(c *C) {
+	_, err := ParseManifest(strings.NewReader(`{"fileFormat": "ORC", "fileSchema": "Bucket, Key"}`))
+	c.Assert(err, ErrorMatches, "unsupported inventory file format: ORC")
+	_, err = ParseManifest(strings.NewReader(`{"fileFormat": "CSV"}`))
+	c.Assert(err, NotNil)
+	_, err = ParseManifest(strings.NewReader(`not json`))
+	c.Assert(err, NotNil)
+
+	schema := []string{FieldBucket, FieldKey, FieldVersionID, FieldIsLatest, FieldIsDeleteMarker, FieldSize, FieldEncryptionStatus}
+	record, err := ParseRecord(schema, []string{"b", "100%", "v1", "true", "false", "", "SSE-KS3"})
+	c.Assert(err, IsNil)
+	c.Assert(record.Key, Equals, "100%")
+	c.Assert(record.VersionID, Equals, "v1")
+	c.Assert(record.IsLatest, Equals, true)
+	c.Assert(record.EncryptionStatus, Equals, "SSE-KS3")
+
+	_, err = ParseRecord(schema, []string{"b", "k"})
+	c.Assert(err, ErrorMatches, "the row has 2 columns, the schema has 7")
+	_, err = ParseRecord(schema, []string{"b", "k", "v1", "yes", "false", "", ""})
+	c.Assert(err, ErrorMatches, `invalid IsLatest "yes"`)
+}
@@ -0,0 +1,169 @@
+package inventory
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// DefaultConcurrency is the number of the data files read at the same time by default
+const DefaultConcurrency = 4
+
+// errAborted stops the workers of Each after an error
+var errAborted = errors.New("inventory: aborted")
+
+// Reader reads the rows of the data files of a manifest
+type Reader struct {
+	Concurrency int  // The number of the data files read at the same time
+	VerifyMD5   bool // Whether to verify the MD5 and the size of the data files, it's true by default
+
+	source   Source
+	manifest *Manifest
+}
+
+// NewReader creates the reader of the manifest
+func NewReader(source Source, manifest *Manifest) *Reader {
+	return &Reader{Concurrency: DefaultConcurrency, VerifyMD5: true, source: source, manifest: manifest}
+}
+
+// Each calls fn for each row of all the data files, the data files are read concurrently, but fn is called
+// sequentially in the calling goroutine. The rows of a file are in order, the files are interleaved.
+//
+// It stops at the first error of fn or of reading the files. The MD5 of a file is verified after all its rows
+// are read, so the rows of a corrupted file may have been passed to fn before the error is returned.
+func (r *Reader) Each(fn func(Record) error) error {
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	files := make(chan File)
+	records := make(chan Record, 64)
+	done := make(chan struct{})
+	var once sync.Once
+	var firstErr error
+	abort := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(done)
+		})
+	}
+
+	go func() {
+		defer close(files)
+		for _, f := range r.manifest.Files {
+			select {
+			case files <- f:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				err := r.ReadFile(f, func(record Record) error {
+					select {
+					case records <- record:
+						return nil
+					case <-done:
+						return errAborted
+					}
+				})
+				if err != nil && err != errAborted {
+					abort(err)
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(records)
+	}()
+
+	for record := range records {
+		select {
+		case <-done:
+			continue
+		default:
+		}
+		if err := fn(record); err != nil {
+			abort(err)
+		}
+	}
+	return firstErr
+}
+
+// ReadFile calls fn for each row of the data file in order
+func (r *Reader) ReadFile(file File, fn func(Record) error) error {
+	body, err := r.source.Open(file.Key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	hash := md5.New()
+	counter := &countingReader{r: io.TeeReader(body, hash)}
+	gz, err := gzip.NewReader(counter)
+	if err != nil {
+		return fmt.Errorf("%s: %s", file.Key, err.Error())
+	}
+	defer gz.Close()
+
+	schema := r.manifest.Schema()
+	rows := csv.NewReader(gz)
+	rows.FieldsPerRecord = -1
+	for line := 1; ; line++ {
+		row, err := rows.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", file.Key, err.Error())
+		}
+		record, err := ParseRecord(schema, row)
+		if err != nil {
+			return fmt.Errorf("%s: line %d: %s", file.Key, line, err.Error())
+		}
+		if err = fn(record); err != nil {
+			return err
+		}
+	}
+
+	if !r.VerifyMD5 {
+		return nil
+	}
+	if _, err = io.Copy(ioutil.Discard, counter); err != nil {
+		return fmt.Errorf("%s: %s", file.Key, err.Error())
+	}
+	if file.Size > 0 && counter.n != file.Size {
+		return fmt.Errorf("%s: the size %d doesn't match %d of the manifest", file.Key, counter.n, file.Size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); file.MD5Checksum != "" && !strings.EqualFold(sum, file.MD5Checksum) {
+		return fmt.Errorf("%s: the MD5 %s doesn't match %s of the manifest", file.Key, sum, file.MD5Checksum)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
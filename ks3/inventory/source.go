@@ -0,0 +1,157 @@
+package inventory
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// Source is where the reports are read from, the keys are the object keys of the destination bucket
+type Source interface {
+	// List returns the keys with the prefix
+	List(prefix string) ([]string, error)
+	// Open opens the object of the key
+	Open(key string) (io.ReadCloser, error)
+}
+
+type bucketSource struct {
+	bucket *ks3.Bucket
+}
+
+// BucketSource reads the reports from the destination bucket
+func BucketSource(bucket *ks3.Bucket) Source {
+	return bucketSource{bucket: bucket}
+}
+
+func (s bucketSource) List(prefix string) ([]string, error) {
+	var keys []string
+	it := ks3.NewListObjectsIterator(s.bucket, ks3.Prefix(prefix))
+	for it.HasNext() {
+		result, err := it.NextPage()
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range result.Objects {
+			keys = append(keys, o.Key)
+		}
+	}
+	return keys, nil
+}
+
+func (s bucketSource) Open(key string) (io.ReadCloser, error) {
+	return s.bucket.GetObject(key)
+}
+
+type dirSource struct {
+	dir string
+}
+
+// DirSource reads the reports from the local directory, such as the fixtures of the tests.
+// The key a/b.csv.gz is the file a/b.csv.gz under the directory.
+func DirSource(dir string) Source {
+	return dirSource{dir: dir}
+}
+
+func (s dirSource) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (s dirSource) Open(key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+}
+
+// ReportPrefix returns the prefix of the reports of the inventory under the destination
+func ReportPrefix(dest ks3.KS3BucketDestination, sourceBucket, inventoryID string) string {
+	prefix := sourceBucket + "/" + inventoryID + "/"
+	if p := strings.Trim(dest.Prefix, "/"); p != "" {
+		prefix = p + "/" + prefix
+	}
+	return prefix
+}
+
+// LatestManifest finds the latest report of the inventory and parses its manifest. If the manifest.checksum
+// is beside the manifest, the MD5 of the manifest is verified.
+//
+// It returns the key and the manifest.
+func LatestManifest(source Source, dest ks3.KS3BucketDestination, sourceBucket, inventoryID string) (string, *Manifest, error) {
+	prefix := ReportPrefix(dest, sourceBucket, inventoryID)
+	keys, err := source.List(prefix)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var manifests []string
+	checksums := map[string]bool{}
+	for _, key := range keys {
+		switch path.Base(key) {
+		case "manifest.json":
+			manifests = append(manifests, key)
+		case "manifest.checksum":
+			checksums[key] = true
+		}
+	}
+	if len(manifests) == 0 {
+		return "", nil, fmt.Errorf("no inventory manifest under %s", prefix)
+	}
+	// The folders of the reports are the creation times, so the last one is the latest
+	sort.Strings(manifests)
+	key := manifests[len(manifests)-1]
+
+	data, err := readAll(source, key)
+	if err != nil {
+		return "", nil, err
+	}
+	checksumKey := path.Join(path.Dir(key), "manifest.checksum")
+	if checksums[checksumKey] {
+		checksum, err := readAll(source, checksumKey)
+		if err != nil {
+			return "", nil, err
+		}
+		sum := md5.Sum(data)
+		if !strings.EqualFold(strings.TrimSpace(string(checksum)), hex.EncodeToString(sum[:])) {
+			return "", nil, fmt.Errorf("the MD5 of %s doesn't match %s", key, checksumKey)
+		}
+	}
+
+	manifest, err := ParseManifest(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, err
+	}
+	return key, manifest, nil
+}
+
+func readAll(source Source, key string) ([]byte, error) {
+	r, err := source.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
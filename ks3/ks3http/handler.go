@@ -0,0 +1,219 @@
+// Package ks3http serves KS3 bucket objects over plain net/http.
+package ks3http
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// DefaultRedirectExpires is the lifetime in seconds of the signed URL used when Options.Redirect is set.
+const DefaultRedirectExpires = 300
+
+// forwardedHeaders lists the KS3 response headers copied to the client.
+var forwardedHeaders = []string{
+	ks3.HTTPHeaderContentType,
+	ks3.HTTPHeaderContentLength,
+	ks3.HTTPHeaderContentEncoding,
+	ks3.HTTPHeaderContentDisposition,
+	ks3.HTTPHeaderContentLanguage,
+	ks3.HTTPHeaderEtag,
+	ks3.HTTPHeaderLastModified,
+	ks3.HTTPHeaderCacheControl,
+	ks3.HTTPHeaderExpires,
+	"Content-Range",
+	"Accept-Ranges",
+}
+
+// Options defines the behavior of the handler returned by NewHandler.
+type Options struct {
+	StripPrefix     string                                          // URL path prefix removed before the path is mapped to an object key
+	KeyPrefix       string                                          // Object key prefix prepended to the mapped key
+	IndexDocument   string                                          // Object name served for paths ending with "/", such as index.html
+	KeyFunc         func(r *http.Request) string                    // Custom mapping from request to object key, it overrides the path mapping
+	Redirect        bool                                            // Redirect to a signed URL instead of proxying the object data
+	RedirectExpires int64                                           // Lifetime of the signed URL in seconds, DefaultRedirectExpires by default
+	ObjectOptions   []ks3.Option                                    // Options added to every object request, such as VersionId or RequestPayer
+	ErrorHandler    func(http.ResponseWriter, *http.Request, error) // Called for errors that are not mapped to a status code
+}
+
+// Handler is an http.Handler that serves the objects of a bucket.
+type Handler struct {
+	bucket *ks3.Bucket
+	opts   Options
+}
+
+// NewHandler creates a handler serving the objects of bucket.
+//
+// bucket    the bucket to serve objects from.
+// opts    the handler options, nil means default options.
+//
+// *Handler    the handler instance.
+func NewHandler(bucket *ks3.Bucket, opts *Options) *Handler {
+	h := &Handler{bucket: bucket}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.RedirectExpires <= 0 {
+		h.opts.RedirectExpires = DefaultRedirectExpires
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := h.objectKey(r)
+	if key == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if h.opts.Redirect {
+		h.serveRedirect(w, r, key)
+		return
+	}
+
+	options := h.requestOptions(r)
+	params, err := ks3.GetRawParams(options)
+	if err != nil {
+		h.serveError(w, r, err, nil)
+		return
+	}
+
+	resp, err := h.bucket.Do(r.Method, key, params, options, nil, nil)
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotModified {
+			copyHeaders(w.Header(), resp.Headers)
+			w.Header().Del(ks3.HTTPHeaderContentLength)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		var header http.Header
+		if resp != nil {
+			header = resp.Headers
+		}
+		h.serveError(w, r, err, header)
+		return
+	}
+
+	copyHeaders(w.Header(), resp.Headers)
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, resp.Body)
+}
+
+// objectKey maps the request to an object key
+func (h *Handler) objectKey(r *http.Request) string {
+	if h.opts.KeyFunc != nil {
+		return h.opts.KeyFunc(r)
+	}
+
+	p := r.URL.Path
+	if h.opts.StripPrefix != "" {
+		if !strings.HasPrefix(p, h.opts.StripPrefix) {
+			return ""
+		}
+		p = p[len(h.opts.StripPrefix):]
+	}
+
+	isDir := p == "" || strings.HasSuffix(p, "/")
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if isDir {
+		if h.opts.IndexDocument == "" {
+			return ""
+		}
+		if p != "" {
+			p += "/"
+		}
+		p += h.opts.IndexDocument
+	}
+	if p == "" {
+		return ""
+	}
+	return h.opts.KeyPrefix + p
+}
+
+// requestOptions converts the conditional and range headers of the request to object options
+func (h *Handler) requestOptions(r *http.Request) []ks3.Option {
+	options := make([]ks3.Option, 0, len(h.opts.ObjectOptions)+3)
+	options = append(options, h.opts.ObjectOptions...)
+
+	if rng := r.Header.Get(ks3.HTTPHeaderRange); rng != "" {
+		options = append(options, ks3.NormalizedFixRange(rng))
+	}
+	if inm := r.Header.Get(ks3.HTTPHeaderIfNoneMatch); inm != "" {
+		options = append(options, ks3.IfNoneMatch(inm))
+	}
+	if ims := r.Header.Get(ks3.HTTPHeaderIfModifiedSince); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			options = append(options, ks3.IfModifiedSince(t))
+		}
+	}
+	return options
+}
+
+// serveRedirect redirects the client to a signed URL of the object
+func (h *Handler) serveRedirect(w http.ResponseWriter, r *http.Request, key string) {
+	method := ks3.HTTPGet
+	if r.Method == http.MethodHead {
+		method = ks3.HTTPHead
+	}
+
+	signedURL, err := h.bucket.SignURL(key, method, h.opts.RedirectExpires, h.opts.ObjectOptions...)
+	if err != nil {
+		h.serveError(w, r, err, nil)
+		return
+	}
+
+	// The signed URL expires, so intermediaries must not cache the redirect
+	w.Header().Set(ks3.HTTPHeaderCacheControl, "no-store")
+	w.Header().Set(ks3.HTTPHeaderExpires, time.Unix(0, 0).UTC().Format(http.TimeFormat))
+	http.Redirect(w, r, signedURL, http.StatusTemporaryRedirect)
+}
+
+// serveError writes the status code matching err, header is the one of the error response if any
+func (h *Handler) serveError(w http.ResponseWriter, r *http.Request, err error, header http.Header) {
+	if srvErr, ok := err.(ks3.ServiceError); ok && srvErr.StatusCode >= 400 && srvErr.StatusCode < 500 {
+		if srvErr.StatusCode == http.StatusNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		// RFC 7233 requires the current length of the object in 416 responses
+		if srvErr.StatusCode == http.StatusRequestedRangeNotSatisfiable && header != nil {
+			if v := header.Get("Content-Range"); v != "" {
+				w.Header().Set("Content-Range", v)
+			}
+		}
+		http.Error(w, http.StatusText(srvErr.StatusCode), srvErr.StatusCode)
+		return
+	}
+
+	if h.opts.ErrorHandler != nil {
+		h.opts.ErrorHandler(w, r, err)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
+
+func copyHeaders(dst, src http.Header) {
+	for _, k := range forwardedHeaders {
+		if v := src.Get(k); v != "" {
+			dst.Set(k, v)
+		}
+	}
+}
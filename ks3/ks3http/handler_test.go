@@ -0,0 +1,169 @@
+package ks3http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+	. "gopkg.in/check.v1"
+)
+
+// Test hooks up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type Ks3HTTPHandlerSuite struct {
+	server    *httptest.Server
+	transport *http.Transport
+	bucket    *ks3.Bucket
+	modTime   time.Time
+}
+
+var _ = Suite(&Ks3HTTPHandlerSuite{})
+
+const testContent = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+func (s *Ks3HTTPHandlerSuite) SetUpSuite(c *C) {
+	s.modTime = time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dir/index.html" && r.URL.Path != "/a.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>"))
+			return
+		}
+		if r.Header.Get("Range") == "bytes=100-" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(testContent)))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			w.Write([]byte("<Error><Code>InvalidRange</Code><Message>The requested range is not satisfiable</Message></Error>"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Etag", `"etag-1"`)
+		http.ServeContent(w, r, "", s.modTime, strings.NewReader(testContent))
+	}))
+
+	// Virtual hosted bucket domains are dialed to the local server
+	addr := s.server.Listener.Addr().String()
+	s.transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	client, err := ks3.New(s.server.URL, "ak", "sk", ks3.HTTPClient(&http.Client{Transport: s.transport}))
+	c.Assert(err, IsNil)
+	s.bucket, err = client.Bucket("test-bucket")
+	c.Assert(err, IsNil)
+}
+
+func (s *Ks3HTTPHandlerSuite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3HTTPHandlerSuite) serve(h http.Handler, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *Ks3HTTPHandlerSuite) TestGetObject(c *C) {
+	h := NewHandler(s.bucket, nil)
+	rec := s.serve(h, "GET", "/a.txt", nil)
+	c.Assert(rec.Code, Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), Equals, testContent)
+	c.Assert(rec.Header().Get("Content-Type"), Equals, "text/plain")
+	c.Assert(rec.Header().Get("Etag"), Equals, `"etag-1"`)
+	c.Assert(rec.Header().Get("Cache-Control"), Equals, "max-age=60")
+	c.Assert(rec.Header().Get("Last-Modified"), Equals, s.modTime.Format(http.TimeFormat))
+}
+
+func (s *Ks3HTTPHandlerSuite) TestHeadObject(c *C) {
+	h := NewHandler(s.bucket, nil)
+	rec := s.serve(h, "HEAD", "/a.txt", nil)
+	c.Assert(rec.Code, Equals, http.StatusOK)
+	c.Assert(rec.Body.Len(), Equals, 0)
+	c.Assert(rec.Header().Get("Etag"), Equals, `"etag-1"`)
+}
+
+func (s *Ks3HTTPHandlerSuite) TestRange(c *C) {
+	h := NewHandler(s.bucket, nil)
+	rec := s.serve(h, "GET", "/a.txt", map[string]string{"Range": "bytes=2-5"})
+	c.Assert(rec.Code, Equals, http.StatusPartialContent)
+	c.Assert(rec.Body.String(), Equals, "2345")
+	c.Assert(rec.Header().Get("Content-Range"), Equals, "bytes 2-5/36")
+
+	// The length of the object is forwarded if the range is unsatisfiable
+	rec = s.serve(h, "GET", "/a.txt", map[string]string{"Range": "bytes=100-"})
+	c.Assert(rec.Code, Equals, http.StatusRequestedRangeNotSatisfiable)
+	c.Assert(rec.Header().Get("Content-Range"), Equals, "bytes */36")
+}
+
+func (s *Ks3HTTPHandlerSuite) TestConditional(c *C) {
+	h := NewHandler(s.bucket, nil)
+	rec := s.serve(h, "GET", "/a.txt", map[string]string{"If-None-Match": `"etag-1"`})
+	c.Assert(rec.Code, Equals, http.StatusNotModified)
+	c.Assert(rec.Body.Len(), Equals, 0)
+
+	rec = s.serve(h, "GET", "/a.txt", map[string]string{"If-Modified-Since": s.modTime.Add(time.Hour).Format(http.TimeFormat)})
+	c.Assert(rec.Code, Equals, http.StatusNotModified)
+
+	rec = s.serve(h, "GET", "/a.txt", map[string]string{"If-Modified-Since": s.modTime.Add(-time.Hour).Format(http.TimeFormat)})
+	c.Assert(rec.Code, Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), Equals, testContent)
+}
+
+func (s *Ks3HTTPHandlerSuite) TestNotFound(c *C) {
+	h := NewHandler(s.bucket, nil)
+	rec := s.serve(h, "GET", "/missing.txt", nil)
+	c.Assert(rec.Code, Equals, http.StatusNotFound)
+
+	rec = s.serve(h, "GET", "/dir/", nil)
+	c.Assert(rec.Code, Equals, http.StatusNotFound)
+
+	rec = s.serve(h, "POST", "/a.txt", nil)
+	c.Assert(rec.Code, Equals, http.StatusMethodNotAllowed)
+}
+
+func (s *Ks3HTTPHandlerSuite) TestKeyMapping(c *C) {
+	h := NewHandler(s.bucket, &Options{StripPrefix: "/static", IndexDocument: "index.html"})
+	c.Assert(h.objectKey(httptest.NewRequest("GET", "/static/dir/", nil)), Equals, "dir/index.html")
+	c.Assert(h.objectKey(httptest.NewRequest("GET", "/static/../a.txt", nil)), Equals, "a.txt")
+	c.Assert(h.objectKey(httptest.NewRequest("GET", "/other/a.txt", nil)), Equals, "")
+
+	rec := s.serve(h, "GET", "/static/dir/", nil)
+	c.Assert(rec.Code, Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), Equals, testContent)
+
+	h = NewHandler(s.bucket, &Options{KeyPrefix: "dir/"})
+	c.Assert(h.objectKey(httptest.NewRequest("GET", "/index.html", nil)), Equals, "dir/index.html")
+}
+
+func (s *Ks3HTTPHandlerSuite) TestRedirect(c *C) {
+	h := NewHandler(s.bucket, &Options{Redirect: true, RedirectExpires: 60})
+	rec := s.serve(h, "GET", "/a.txt", nil)
+	c.Assert(rec.Code, Equals, http.StatusTemporaryRedirect)
+	location := rec.Header().Get("Location")
+	c.Assert(strings.HasPrefix(location, "http://test-bucket."+s.server.Listener.Addr().String()+"/a.txt?"), Equals, true)
+	c.Assert(strings.Contains(location, "Signature="), Equals, true)
+	c.Assert(rec.Header().Get("Cache-Control"), Equals, "no-store")
+
+	// The signed URL is served by the origin
+	resp, err := (&http.Client{Transport: s.transport}).Get(location)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	c.Assert(buf.String(), Equals, testContent)
+}
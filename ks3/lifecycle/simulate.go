@@ -0,0 +1,460 @@
+// Package lifecycle predicts what a bucket lifecycle configuration does to the objects, before it's set by
+// Client.SetBucketLifecycle:
+//
+//	list, err := bucket.ListObjects(ks3.Prefix("logs/"))
+//	report, err := lifecycle.Simulate(config, lifecycle.FromObjects(list.Objects), time.Now())
+//	for _, action := range report.Actions {
+//		fmt.Println(action.Object.Key, action.Type, action.StorageClass, action.Date, action.Due)
+//	}
+//
+// The versions and the delete markers of ListObjectVersions are converted by FromVersions, and the incomplete
+// multipart uploads of ListMultipartUploads by FromUploads. The tags are not in the listings, set Object.Tags
+// if the rules filter by tags.
+package lifecycle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// ActionType is the type of the lifecycle action
+type ActionType string
+
+// The lifecycle actions
+const (
+	// Expire deletes the current version. In the versioned bucket, a delete marker is added instead,
+	// and the version becomes noncurrent.
+	Expire ActionType = "Expire"
+	// Transition changes the storage class of the current version
+	Transition ActionType = "Transition"
+	// ExpireNoncurrent deletes the noncurrent version
+	ExpireNoncurrent ActionType = "ExpireNoncurrentVersion"
+	// TransitionNoncurrent changes the storage class of the noncurrent version
+	TransitionNoncurrent ActionType = "TransitionNoncurrentVersion"
+	// RemoveDeleteMarker removes the delete marker which has no noncurrent version
+	RemoveDeleteMarker ActionType = "RemoveExpiredDeleteMarker"
+	// AbortUpload aborts the incomplete multipart upload
+	AbortUpload ActionType = "AbortIncompleteMultipartUpload"
+)
+
+// location is the time zone the days of the rules are counted in, the same as ks3.Iso8601DateFormat
+var location = time.FixedZone("UTC+8", 8*60*60)
+
+// Object is an object, a version, a delete marker or an incomplete multipart upload of the listing
+type Object struct {
+	Key            string
+	VersionID      string // The version ID, empty if the bucket isn't versioned
+	IsLatest       bool   // Whether it's the current version, the objects of the unversioned bucket are current
+	IsDeleteMarker bool
+	UploadID       string // The ID of the incomplete multipart upload, LastModified is the initiated time
+	LastModified   time.Time
+	LastAccessed   time.Time // The last access time of the transitions by access time, LastModified if it's zero
+	// NoncurrentSince is when the version became noncurrent. If it's zero, it's the last modified time of the
+	// next newer version of the listing.
+	NoncurrentSince time.Time
+	StorageClass    ks3.StorageClassType
+	Size            int64
+	Tags            []ks3.Tag
+}
+
+// Action is an action the lifecycle rule does to the object
+type Action struct {
+	Object       Object
+	RuleID       string
+	Type         ActionType
+	StorageClass ks3.StorageClassType // The target storage class of the transitions
+	Date         time.Time            // When the action takes effect
+	Due          bool                 // Whether the action has taken effect at the simulated time
+}
+
+// Report is the result of the simulation
+type Report struct {
+	Now     time.Time
+	Actions []Action // The actions ordered by the date, the key and the version
+}
+
+// Due returns the actions which have taken effect at the simulated time
+func (r *Report) Due() []Action {
+	var actions []Action
+	for _, a := range r.Actions {
+		if a.Due {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+// ForKey returns the actions of all the versions and uploads of the key
+func (r *Report) ForKey(key string) []Action {
+	var actions []Action
+	for _, a := range r.Actions {
+		if a.Object.Key == key {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+// FromObjects converts the objects of ListObjects, they're the current versions
+func FromObjects(objects []ks3.ObjectProperties) []Object {
+	result := make([]Object, len(objects))
+	for i, o := range objects {
+		result[i] = Object{Key: o.Key, IsLatest: true, LastModified: o.LastModified,
+			StorageClass: ks3.StorageClassType(o.StorageClass), Size: o.Size}
+	}
+	return result
+}
+
+// FromVersions converts the versions and the delete markers of ListObjectVersions
+func FromVersions(result ks3.ListObjectVersionsResult) []Object {
+	objects := make([]Object, 0, len(result.ObjectVersions)+len(result.ObjectDeleteMarkers))
+	for _, v := range result.ObjectVersions {
+		objects = append(objects, Object{Key: v.Key, VersionID: v.VersionId, IsLatest: v.IsLatest,
+			LastModified: v.LastModified, StorageClass: ks3.StorageClassType(v.StorageClass), Size: v.Size})
+	}
+	for _, m := range result.ObjectDeleteMarkers {
+		objects = append(objects, Object{Key: m.Key, VersionID: m.VersionId, IsLatest: m.IsLatest,
+			IsDeleteMarker: true, LastModified: m.LastModified})
+	}
+	return objects
+}
+
+// FromUploads converts the incomplete multipart uploads of ListMultipartUploads
+func FromUploads(uploads []ks3.UncompletedUpload) []Object {
+	objects := make([]Object, len(uploads))
+	for i, u := range uploads {
+		objects[i] = Object{Key: u.Key, UploadID: u.UploadID, LastModified: u.Initiated}
+	}
+	return objects
+}
+
+// Simulate predicts the actions of the enabled rules of the configuration on the objects at the time now.
+//
+// The days of the rules are counted from the last modified time (or the time the version became noncurrent)
+// and rounded up to the next midnight of UTC+8. The dates apply to the objects modified before them.
+// If several rules expire an object, the earliest one wins, and the transitions after the expiration are dropped.
+// Only the first transition to each storage class is kept.
+//
+// It returns an error if a date of the rules is malformed.
+func Simulate(config ks3.LifecycleConfiguration, objects []Object, now time.Time) (*Report, error) {
+	rules := make([]rule, 0, len(config.Rules))
+	for _, r := range config.Rules {
+		if r.Status != "Enabled" {
+			continue
+		}
+		parsed, err := parseRule(r)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed)
+	}
+
+	objects = withNoncurrentSince(objects)
+	report := &Report{Now: now}
+	for _, o := range objects {
+		var actions []Action
+		for _, r := range rules {
+			if r.matches(o) {
+				actions = append(actions, r.actions(o, objects)...)
+			}
+		}
+		actions = reduce(actions)
+		report.Actions = append(report.Actions, actions...)
+
+		// The expired current version becomes noncurrent behind the delete marker in the versioned bucket
+		if len(actions) == 0 || o.VersionID == "" || !o.IsLatest || o.IsDeleteMarker {
+			continue
+		}
+		for _, a := range actions {
+			if a.Type != Expire {
+				continue
+			}
+			noncurrent := o
+			noncurrent.IsLatest, noncurrent.NoncurrentSince = false, a.Date
+			var following []Action
+			for _, r := range rules {
+				if r.matches(o) {
+					following = append(following, r.noncurrentActions(noncurrent)...)
+				}
+			}
+			for _, f := range reduce(following) {
+				f.Object = o
+				report.Actions = append(report.Actions, f)
+			}
+		}
+	}
+
+	for i := range report.Actions {
+		report.Actions[i].Due = !report.Actions[i].Date.After(now)
+	}
+	sort.SliceStable(report.Actions, func(i, j int) bool {
+		a, b := report.Actions[i], report.Actions[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		if a.Object.Key != b.Object.Key {
+			return a.Object.Key < b.Object.Key
+		}
+		return a.Object.VersionID < b.Object.VersionID
+	})
+	return report, nil
+}
+
+// withNoncurrentSince fills the time the noncurrent versions became noncurrent
+func withNoncurrentSince(objects []Object) []Object {
+	versions := map[string][]int{}
+	for i, o := range objects {
+		if o.UploadID == "" && o.VersionID != "" {
+			versions[o.Key] = append(versions[o.Key], i)
+		}
+	}
+
+	result := append([]Object(nil), objects...)
+	for _, indexes := range versions {
+		// The newest first, the current version is the newest
+		sort.SliceStable(indexes, func(i, j int) bool {
+			a, b := result[indexes[i]], result[indexes[j]]
+			if a.IsLatest != b.IsLatest {
+				return a.IsLatest
+			}
+			return a.LastModified.After(b.LastModified)
+		})
+		for n := 1; n < len(indexes); n++ {
+			o := &result[indexes[n]]
+			if !o.IsLatest && o.NoncurrentSince.IsZero() {
+				o.NoncurrentSince = result[indexes[n-1]].LastModified
+			}
+		}
+	}
+	return result
+}
+
+// rule is the parsed lifecycle rule
+type rule struct {
+	ks3.LifecycleRule
+	prefix string
+	tags   []ks3.Tag
+
+	expirationDate time.Time
+	transitionDate []time.Time
+	abortDate      time.Time
+}
+
+func parseRule(r ks3.LifecycleRule) (rule, error) {
+	parsed := rule{LifecycleRule: r, prefix: r.Prefix}
+	if r.Filter != nil {
+		if r.Filter.Prefix != "" {
+			parsed.prefix = r.Filter.Prefix
+		}
+		if r.Filter.And != nil {
+			if r.Filter.And.Prefix != "" {
+				parsed.prefix = r.Filter.And.Prefix
+			}
+			parsed.tags = r.Filter.And.Tag
+		}
+	}
+
+	var err error
+	if r.Expiration != nil && r.Expiration.Date != "" {
+		if parsed.expirationDate, err = parseDate(r.ID, r.Expiration.Date); err != nil {
+			return parsed, err
+		}
+	}
+	parsed.transitionDate = make([]time.Time, len(r.Transitions))
+	for i, t := range r.Transitions {
+		if t.Date != "" {
+			if parsed.transitionDate[i], err = parseDate(r.ID, t.Date); err != nil {
+				return parsed, err
+			}
+		}
+	}
+	if r.AbortIncompleteMultipartUpload != nil && r.AbortIncompleteMultipartUpload.Date != "" {
+		if parsed.abortDate, err = parseDate(r.ID, r.AbortIncompleteMultipartUpload.Date); err != nil {
+			return parsed, err
+		}
+	}
+	return parsed, nil
+}
+
+// parseDate parses the date of the rule, such as 2024-01-01T00:00:00+08:00
+func parseDate(id, value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05.000Z07:00", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("lifecycle rule %q: invalid date %q", id, value)
+}
+
+// afterDays returns the time days after t, rounded up to the next midnight
+func afterDays(t time.Time, days int) time.Time {
+	t = t.In(location).AddDate(0, 0, days)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+	if midnight.Before(t) {
+		midnight = midnight.AddDate(0, 0, 1)
+	}
+	return midnight
+}
+
+// matches reports whether the rule applies to the object by the prefix and the tags
+func (r rule) matches(o Object) bool {
+	if !strings.HasPrefix(o.Key, r.prefix) {
+		return false
+	}
+	for _, want := range r.tags {
+		found := false
+		for _, tag := range o.Tags {
+			if tag.Key == want.Key && tag.Value == want.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// actions returns the actions of the rule on the object, objects is the listing of the delete markers
+func (r rule) actions(o Object, objects []Object) []Action {
+	newAction := func(t ActionType, class ks3.StorageClassType, date time.Time) Action {
+		return Action{Object: o, RuleID: r.ID, Type: t, StorageClass: class, Date: date}
+	}
+
+	var actions []Action
+	switch {
+	case o.UploadID != "":
+		abort := r.AbortIncompleteMultipartUpload
+		if abort == nil {
+			break
+		}
+		if abort.DaysAfterInitiation > 0 {
+			actions = append(actions, newAction(AbortUpload, "", afterDays(o.LastModified, abort.DaysAfterInitiation)))
+		} else if !r.abortDate.IsZero() && o.LastModified.Before(r.abortDate) {
+			actions = append(actions, newAction(AbortUpload, "", r.abortDate))
+		}
+
+	case o.IsDeleteMarker:
+		if o.IsLatest && r.Expiration != nil && r.Expiration.ExpiredObjectDeleteMarker != nil && *r.Expiration.ExpiredObjectDeleteMarker {
+			if date, ok := r.lastVersionGone(o, objects); ok {
+				actions = append(actions, newAction(RemoveDeleteMarker, "", date))
+			}
+		}
+
+	case o.IsLatest:
+		if date, ok := r.expiration(o); ok {
+			actions = append(actions, newAction(Expire, "", date))
+		}
+		for i, t := range r.Transitions {
+			base := o.LastModified
+			if t.IsAccessTime != nil && *t.IsAccessTime && !o.LastAccessed.IsZero() {
+				base = o.LastAccessed
+			}
+			if t.Days > 0 {
+				actions = append(actions, newAction(Transition, t.StorageClass, afterDays(base, t.Days)))
+			} else if date := r.transitionDate[i]; !date.IsZero() && o.LastModified.Before(date) {
+				actions = append(actions, newAction(Transition, t.StorageClass, date))
+			}
+		}
+
+	default:
+		actions = append(actions, r.noncurrentActions(o)...)
+	}
+	return actions
+}
+
+// expiration returns when the current version expires
+func (r rule) expiration(o Object) (time.Time, bool) {
+	if r.Expiration == nil {
+		return time.Time{}, false
+	}
+	if r.Expiration.Days > 0 {
+		return afterDays(o.LastModified, r.Expiration.Days), true
+	}
+	if !r.expirationDate.IsZero() && o.LastModified.Before(r.expirationDate) {
+		return r.expirationDate, true
+	}
+	return time.Time{}, false
+}
+
+// noncurrentActions returns the actions on the noncurrent version
+func (r rule) noncurrentActions(o Object) []Action {
+	if o.NoncurrentSince.IsZero() {
+		return nil
+	}
+	var actions []Action
+	if e := r.NoncurrentVersionExpiration; e != nil && e.NoncurrentDays > 0 {
+		actions = append(actions, Action{Object: o, RuleID: r.ID, Type: ExpireNoncurrent,
+			Date: afterDays(o.NoncurrentSince, e.NoncurrentDays)})
+	}
+	for _, t := range r.NoncurrentVersionTransitions {
+		if t.NoncurrentDays > 0 {
+			actions = append(actions, Action{Object: o, RuleID: r.ID, Type: TransitionNoncurrent,
+				StorageClass: t.StorageClass, Date: afterDays(o.NoncurrentSince, t.NoncurrentDays)})
+		}
+	}
+	return actions
+}
+
+// lastVersionGone returns when the last noncurrent version of the delete marker's key expires by the rule,
+// ok is false if any version never expires
+func (r rule) lastVersionGone(marker Object, objects []Object) (time.Time, bool) {
+	date := afterDays(marker.LastModified, 0)
+	for _, o := range objects {
+		if o.Key != marker.Key || o.IsDeleteMarker || o.UploadID != "" || o.VersionID == marker.VersionID {
+			continue
+		}
+		e := r.NoncurrentVersionExpiration
+		if o.IsLatest || e == nil || e.NoncurrentDays <= 0 || o.NoncurrentSince.IsZero() {
+			return time.Time{}, false
+		}
+		if expires := afterDays(o.NoncurrentSince, e.NoncurrentDays); expires.After(date) {
+			date = expires
+		}
+	}
+	return date, true
+}
+
+// reduce merges the actions of all the rules on an object: the earliest expiration wins, the transitions
+// from then on are dropped, and only the earliest transition to each storage class is kept
+func reduce(actions []Action) []Action {
+	var end time.Time
+	for _, a := range actions {
+		if isRemoval(a.Type) && (end.IsZero() || a.Date.Before(end)) {
+			end = a.Date
+		}
+	}
+
+	var result []Action
+	removed := false
+	transitions := map[ks3.StorageClassType]bool{}
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].Date.Before(actions[j].Date) })
+	for _, a := range actions {
+		if isRemoval(a.Type) {
+			if removed {
+				continue
+			}
+			removed = true
+		} else {
+			if !end.IsZero() && !a.Date.Before(end) {
+				continue
+			}
+			if a.StorageClass == a.Object.StorageClass || transitions[a.StorageClass] {
+				continue
+			}
+			transitions[a.StorageClass] = true
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+// isRemoval reports whether the action removes the object, the version, the delete marker or the upload
+func isRemoval(t ActionType) bool {
+	return t != Transition && t != TransitionNoncurrent
+}
@@ -0,0 +1,195 @@
+package lifecycle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type Ks3LifecycleSimulateSuite struct{}
+
+var _ = Suite(&Ks3LifecycleSimulateSuite{})
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2024, month, d, 0, 0, 0, 0, location)
+}
+
+func simulate(c *C, config ks3.LifecycleConfiguration, objects []Object, now time.Time) *Report {
+	report, err := Simulate(config, objects, now)
+	c.Assert(err, IsNil)
+	return report
+}
+
+type summary struct {
+	Key     string
+	Version string
+	Type    ActionType
+	Class   ks3.StorageClassType
+	Date    time.Time
+}
+
+func summarize(actions []Action) []summary {
+	result := []summary{}
+	for _, a := range actions {
+		result = append(result, summary{a.Object.Key, a.Object.VersionID, a.Type, a.StorageClass, a.Date})
+	}
+	return result
+}
+
+func (s *Ks3LifecycleSimulateSuite) TestCurrentVersions(c *C) {
+	config := ks3.LifecycleConfiguration{Rules: []ks3.LifecycleRule{{
+		ID:     "logs",
+		Status: "Enabled",
+		Filter: &ks3.LifecycleFilter{Prefix: "logs/"},
+		Transitions: []ks3.LifecycleTransition{
+			{Days: 30, StorageClass: ks3.StorageIA},
+			{Days: 90, StorageClass: ks3.StorageArchive},
+		},
+		Expiration: &ks3.LifecycleExpiration{Days: 60},
+	}, {
+		ID:         "disabled",
+		Status:     "Disabled",
+		Expiration: &ks3.LifecycleExpiration{Days: 1},
+	}}}
+	objects := FromObjects([]ks3.ObjectProperties{
+		{Key: "logs/a.log", LastModified: day(1, 1).Add(10 * time.Hour), StorageClass: "STANDARD"},
+		{Key: "logs/b.log", LastModified: day(1, 1), StorageClass: "STANDARD_IA"},
+		{Key: "data/c.bin", LastModified: day(1, 1), StorageClass: "STANDARD"},
+	})
+
+	// The days are rounded up to the next midnight, the archive transitions are after the expirations,
+	// and b.log is already in STANDARD_IA
+	report := simulate(c, config, objects, day(2, 15))
+	c.Assert(summarize(report.Actions), DeepEquals, []summary{
+		{"logs/a.log", "", Transition, ks3.StorageIA, day(2, 1)},
+		{"logs/b.log", "", Expire, "", day(3, 1)},
+		{"logs/a.log", "", Expire, "", day(3, 2)},
+	})
+	c.Assert(report.Actions[0].RuleID, Equals, "logs")
+	c.Assert(summarize(report.Due()), DeepEquals, summarize(report.Actions[:1]))
+	c.Assert(summarize(report.ForKey("logs/b.log")), DeepEquals, summarize(report.Actions[1:2]))
+	c.Assert(len(report.ForKey("data/c.bin")), Equals, 0)
+
+	// The earliest expiration of the overlapping rules wins
+	config.Rules[1].Status = "Enabled"
+	config.Rules[1].ID = "all"
+	report = simulate(c, config, objects, day(2, 15))
+	c.Assert(summarize(report.Actions), DeepEquals, []summary{
+		{"data/c.bin", "", Expire, "", day(1, 2)},
+		{"logs/b.log", "", Expire, "", day(1, 2)},
+		{"logs/a.log", "", Expire, "", day(1, 3)},
+	})
+	c.Assert(report.Actions[2].RuleID, Equals, "all")
+	c.Assert(len(report.Due()), Equals, 3)
+}
+
+func (s *Ks3LifecycleSimulateSuite) TestTagsAndDates(c *C) {
+	config := ks3.LifecycleConfiguration{Rules: []ks3.LifecycleRule{{
+		ID:     "test-images",
+		Status: "Enabled",
+		Filter: &ks3.LifecycleFilter{And: &ks3.LifecycleAnd{
+			Prefix: "img/",
+			Tag:    []ks3.Tag{{Key: "env", Value: "test"}, {Key: "team", Value: "a"}},
+		}},
+		Transitions: []ks3.LifecycleTransition{{Date: "2024-03-01T00:00:00+08:00", StorageClass: ks3.StorageIA}},
+		Expiration:  &ks3.LifecycleExpiration{Date: "2024-06-01T00:00:00+08:00"},
+	}}}
+	tags := []ks3.Tag{{Key: "team", Value: "a"}, {Key: "env", Value: "test"}}
+	objects := []Object{
+		{Key: "img/a.jpg", IsLatest: true, LastModified: day(1, 1), Tags: tags},
+		{Key: "img/b.jpg", IsLatest: true, LastModified: day(1, 1), Tags: tags[:1]},
+		{Key: "img/c.jpg", IsLatest: true, LastModified: day(4, 1), Tags: tags},
+		{Key: "doc/d.txt", IsLatest: true, LastModified: day(1, 1), Tags: tags},
+	}
+
+	// The dates apply to the objects modified before them
+	report := simulate(c, config, objects, day(1, 1))
+	c.Assert(summarize(report.Actions), DeepEquals, []summary{
+		{"img/a.jpg", "", Transition, ks3.StorageIA, day(3, 1)},
+		{"img/a.jpg", "", Expire, "", day(6, 1)},
+		{"img/c.jpg", "", Expire, "", day(6, 1)},
+	})
+	c.Assert(len(report.Due()), Equals, 0)
+
+	config.Rules[0].Expiration.Date = "next week"
+	_, err := Simulate(config, objects, day(1, 1))
+	c.Assert(err, ErrorMatches, `lifecycle rule "test-images": invalid date "next week"`)
+}
+
+func (s *Ks3LifecycleSimulateSuite) TestVersions(c *C) {
+	config := ks3.LifecycleConfiguration{Rules: []ks3.LifecycleRule{{
+		ID:                           "versions",
+		Status:                       "Enabled",
+		Expiration:                   &ks3.LifecycleExpiration{Days: 100},
+		NoncurrentVersionExpiration:  &ks3.LifecycleVersionExpiration{NoncurrentDays: 30},
+		NoncurrentVersionTransitions: []ks3.LifecycleVersionTransition{{NoncurrentDays: 10, StorageClass: ks3.StorageIA}},
+	}}}
+	objects := FromVersions(ks3.ListObjectVersionsResult{
+		ObjectVersions: []ks3.ObjectVersionProperties{
+			{Key: "v", VersionId: "1", LastModified: day(1, 1), StorageClass: "STANDARD"},
+			{Key: "v", VersionId: "3", IsLatest: true, LastModified: day(3, 1), StorageClass: "STANDARD"},
+			{Key: "v", VersionId: "2", LastModified: day(2, 1), StorageClass: "STANDARD"},
+		},
+	})
+
+	// The noncurrent days are counted from when the next newer version was created,
+	// and the expired current version becomes noncurrent
+	report := simulate(c, config, objects, day(3, 5))
+	c.Assert(summarize(report.Actions), DeepEquals, []summary{
+		{"v", "1", TransitionNoncurrent, ks3.StorageIA, day(2, 11)},
+		{"v", "1", ExpireNoncurrent, "", day(3, 2)},
+		{"v", "2", TransitionNoncurrent, ks3.StorageIA, day(3, 11)},
+		{"v", "2", ExpireNoncurrent, "", day(3, 31)},
+		{"v", "3", Expire, "", day(6, 9)},
+		{"v", "3", TransitionNoncurrent, ks3.StorageIA, day(6, 19)},
+		{"v", "3", ExpireNoncurrent, "", day(7, 9)},
+	})
+	c.Assert(len(report.Due()), Equals, 2)
+}
+
+func (s *Ks3LifecycleSimulateSuite) TestDeleteMarkersAndUploads(c *C) {
+	removeMarkers := true
+	config := ks3.LifecycleConfiguration{Rules: []ks3.LifecycleRule{{
+		ID:                             "cleanup",
+		Status:                         "Enabled",
+		Expiration:                     &ks3.LifecycleExpiration{ExpiredObjectDeleteMarker: &removeMarkers},
+		NoncurrentVersionExpiration:    &ks3.LifecycleVersionExpiration{NoncurrentDays: 30},
+		AbortIncompleteMultipartUpload: &ks3.LifecycleAbortIncompleteMultipartUpload{DaysAfterInitiation: 7},
+	}}}
+	objects := FromVersions(ks3.ListObjectVersionsResult{
+		ObjectDeleteMarkers: []ks3.ObjectDeleteMarkerProperties{
+			{Key: "d", VersionId: "2", IsLatest: true, LastModified: day(3, 1)},
+			{Key: "e", VersionId: "1", IsLatest: true, LastModified: day(3, 1).Add(12 * time.Hour)},
+		},
+		ObjectVersions: []ks3.ObjectVersionProperties{
+			{Key: "d", VersionId: "1", LastModified: day(1, 1), StorageClass: "STANDARD"},
+		},
+	})
+	objects = append(objects, FromUploads([]ks3.UncompletedUpload{
+		{Key: "big.zip", UploadID: "upload", Initiated: day(1, 1).Add(5 * time.Hour)},
+	})...)
+
+	// The delete marker is removed after the last noncurrent version expires
+	report := simulate(c, config, objects, day(3, 1))
+	c.Assert(summarize(report.Actions), DeepEquals, []summary{
+		{"big.zip", "", AbortUpload, "", day(1, 9)},
+		{"e", "1", RemoveDeleteMarker, "", day(3, 2)},
+		{"d", "1", ExpireNoncurrent, "", day(3, 31)},
+		{"d", "2", RemoveDeleteMarker, "", day(3, 31)},
+	})
+	c.Assert(report.Actions[0].Object.UploadID, Equals, "upload")
+
+	// The delete marker is kept if a version never expires
+	config.Rules[0].NoncurrentVersionExpiration = nil
+	report = simulate(c, config, objects, day(3, 1))
+	c.Assert(summarize(report.Actions), DeepEquals, []summary{
+		{"big.zip", "", AbortUpload, "", day(1, 9)},
+		{"e", "1", RemoveDeleteMarker, "", day(3, 2)},
+	})
+}
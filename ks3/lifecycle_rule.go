@@ -0,0 +1,378 @@
+package ks3
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LifecycleRuleBuilder builds a lifecycle rule step by step, the rule is checked by Build
+//
+//	rules, err := ks3.NewLifecycleBuilder().
+//		AddRule(ks3.NewLifecycleRule("logs").
+//			Prefix("logs/").
+//			TransitionAfterDays(30, ks3.StorageIA).
+//			TransitionAfterDays(90, ks3.StorageArchive).
+//			ExpireAfterDays(365).
+//			ExpireNoncurrentAfterDays(30)).
+//		AddRule(ks3.NewLifecycleRule("uploads").
+//			Prefix("tmp/").
+//			AbortMultipartUploadAfterDays(7)).
+//		Build()
+//	err = client.SetBucketLifecycle("bucket", rules)
+type LifecycleRuleBuilder struct {
+	rule   LifecycleRule
+	prefix string
+	tags   []Tag
+}
+
+// NewLifecycleRule creates the builder of the enabled rule
+func NewLifecycleRule(id string) *LifecycleRuleBuilder {
+	return &LifecycleRuleBuilder{rule: LifecycleRule{ID: id, Status: "Enabled"}}
+}
+
+// Prefix sets the key prefix the rule applies to
+func (b *LifecycleRuleBuilder) Prefix(prefix string) *LifecycleRuleBuilder {
+	b.prefix = prefix
+	return b
+}
+
+// Tags adds the tags the objects must have, all of them
+func (b *LifecycleRuleBuilder) Tags(tags ...Tag) *LifecycleRuleBuilder {
+	b.tags = append(b.tags, tags...)
+	return b
+}
+
+// Enabled sets the status of the rule, it's enabled by default
+func (b *LifecycleRuleBuilder) Enabled(enabled bool) *LifecycleRuleBuilder {
+	b.rule.Status = "Enabled"
+	if !enabled {
+		b.rule.Status = "Disabled"
+	}
+	return b
+}
+
+// ExpireAfterDays expires the objects in days after the last modified time
+func (b *LifecycleRuleBuilder) ExpireAfterDays(days int) *LifecycleRuleBuilder {
+	b.expiration().Days = days
+	return b
+}
+
+// ExpireOnDate expires the objects modified before the date
+func (b *LifecycleRuleBuilder) ExpireOnDate(year, month, day int) *LifecycleRuleBuilder {
+	b.expiration().Date = lifecycleDate(year, month, day)
+	return b
+}
+
+// ExpireDeleteMarkers removes the delete markers whose noncurrent versions are all expired
+func (b *LifecycleRuleBuilder) ExpireDeleteMarkers() *LifecycleRuleBuilder {
+	enabled := true
+	b.expiration().ExpiredObjectDeleteMarker = &enabled
+	return b
+}
+
+// TransitionAfterDays changes the storage class of the objects in days after the last modified time
+func (b *LifecycleRuleBuilder) TransitionAfterDays(days int, storageClass StorageClassType) *LifecycleRuleBuilder {
+	b.rule.Transitions = append(b.rule.Transitions, LifecycleTransition{Days: days, StorageClass: storageClass})
+	return b
+}
+
+// TransitionOnDate changes the storage class of the objects modified before the date
+func (b *LifecycleRuleBuilder) TransitionOnDate(year, month, day int, storageClass StorageClassType) *LifecycleRuleBuilder {
+	b.rule.Transitions = append(b.rule.Transitions, LifecycleTransition{Date: lifecycleDate(year, month, day), StorageClass: storageClass})
+	return b
+}
+
+// AbortMultipartUploadAfterDays aborts the incomplete multipart uploads in days after the initiation
+func (b *LifecycleRuleBuilder) AbortMultipartUploadAfterDays(days int) *LifecycleRuleBuilder {
+	b.rule.AbortIncompleteMultipartUpload = &LifecycleAbortIncompleteMultipartUpload{DaysAfterInitiation: days}
+	return b
+}
+
+// AbortMultipartUploadOnDate aborts the multipart uploads initiated before the date
+func (b *LifecycleRuleBuilder) AbortMultipartUploadOnDate(year, month, day int) *LifecycleRuleBuilder {
+	b.rule.AbortIncompleteMultipartUpload = &LifecycleAbortIncompleteMultipartUpload{Date: lifecycleDate(year, month, day)}
+	return b
+}
+
+// ExpireNoncurrentAfterDays deletes the noncurrent versions in days after they become noncurrent
+func (b *LifecycleRuleBuilder) ExpireNoncurrentAfterDays(days int) *LifecycleRuleBuilder {
+	b.rule.NoncurrentVersionExpiration = &LifecycleVersionExpiration{NoncurrentDays: days}
+	return b
+}
+
+// TransitionNoncurrentAfterDays changes the storage class of the noncurrent versions in days after they become noncurrent
+func (b *LifecycleRuleBuilder) TransitionNoncurrentAfterDays(days int, storageClass StorageClassType) *LifecycleRuleBuilder {
+	b.rule.NoncurrentVersionTransitions = append(b.rule.NoncurrentVersionTransitions,
+		LifecycleVersionTransition{NoncurrentDays: days, StorageClass: storageClass})
+	return b
+}
+
+// Build returns the rule, or the error if it's invalid, see ValidateLifecycleRules
+func (b *LifecycleRuleBuilder) Build() (LifecycleRule, error) {
+	rule := b.rule
+	rule.Transitions = append([]LifecycleTransition(nil), b.rule.Transitions...)
+	rule.NoncurrentVersionTransitions = append([]LifecycleVersionTransition(nil), b.rule.NoncurrentVersionTransitions...)
+	if len(b.tags) > 0 {
+		rule.Filter = &LifecycleFilter{And: &LifecycleAnd{Prefix: b.prefix, Tag: append([]Tag(nil), b.tags...)}}
+	} else {
+		rule.Filter = &LifecycleFilter{Prefix: b.prefix}
+	}
+	if err := validateLifecycleRule(rule); err != nil {
+		return LifecycleRule{}, err
+	}
+	return rule, nil
+}
+
+func (b *LifecycleRuleBuilder) expiration() *LifecycleExpiration {
+	if b.rule.Expiration == nil {
+		b.rule.Expiration = &LifecycleExpiration{}
+	}
+	return b.rule.Expiration
+}
+
+// lifecycleDate formats the date of the rule, the same as BuildLifecycleRuleByDate
+func lifecycleDate(year, month, day int) string {
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Format(Iso8601DateFormat)
+}
+
+// LifecycleBuilder builds the rules of the bucket lifecycle, the whole rule set is checked by Build
+type LifecycleBuilder struct {
+	rules []*LifecycleRuleBuilder
+}
+
+// NewLifecycleBuilder creates the builder of the rule set
+func NewLifecycleBuilder() *LifecycleBuilder {
+	return &LifecycleBuilder{}
+}
+
+// AddRule adds the rules
+func (b *LifecycleBuilder) AddRule(rules ...*LifecycleRuleBuilder) *LifecycleBuilder {
+	b.rules = append(b.rules, rules...)
+	return b
+}
+
+// Build returns the rules for SetBucketLifecycle, or the error of the first invalid rule
+func (b *LifecycleBuilder) Build() ([]LifecycleRule, error) {
+	if len(b.rules) == 0 {
+		return nil, fmt.Errorf("invalid rules, the length of rules is zero")
+	}
+	rules := make([]LifecycleRule, 0, len(b.rules))
+	for _, builder := range b.rules {
+		rule, err := builder.Build()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if err := ValidateLifecycleRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ValidateLifecycleRules checks the rule set thoroughly, it's stricter than the check of SetBucketLifecycle:
+//
+// - the rule IDs are unique, and the prefixes of the rules don't overlap unless they require different values of a tag
+// - each action sets one of days and date, and a rule doesn't mix days and dates
+// - the transitions are ordered by the days (or the dates), and the storage classes get colder one by one
+// - the expiration comes after the last transition, so do the noncurrent ones
+//
+func ValidateLifecycleRules(rules []LifecycleRule) error {
+	if err := verifyLifecycleRules(rules); err != nil {
+		return err
+	}
+	ids := map[string]bool{}
+	for i, rule := range rules {
+		if err := validateLifecycleRule(rule); err != nil {
+			return err
+		}
+		if rule.ID != "" {
+			if ids[rule.ID] {
+				return fmt.Errorf("invalid rule %q, the rule ID is duplicated", rule.ID)
+			}
+			ids[rule.ID] = true
+		}
+		for _, other := range rules[:i] {
+			if lifecycleRulesOverlap(other, rule) {
+				return fmt.Errorf("invalid rule %q, the prefix %q overlaps the prefix %q of rule %q",
+					rule.ID, lifecycleRulePrefix(rule), lifecycleRulePrefix(other), other.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// lifecycleStorageClassRank is the order of the storage classes the transitions go through, the colder the higher
+var lifecycleStorageClassRank = map[StorageClassType]int{
+	StorageIA:              1,
+	StorageDeepIA:          2,
+	StorageArchive:         3,
+	StorageColdArchive:     4,
+	StorageDeepColdArchive: 5,
+}
+
+// lifecycleWhen is when the action of the rule takes effect, either days or date is set
+type lifecycleWhen struct {
+	name string
+	days int
+	date time.Time
+}
+
+// after reports whether w takes effect after o, the days and the dates aren't comparable
+func (w lifecycleWhen) after(o lifecycleWhen) bool {
+	if !w.date.IsZero() || !o.date.IsZero() {
+		return w.date.After(o.date)
+	}
+	return w.days > o.days
+}
+
+// validateLifecycleRule checks the actions of the rule
+func validateLifecycleRule(rule LifecycleRule) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("invalid rule %q, %s", rule.ID, fmt.Sprintf(format, args...))
+	}
+	if rule.Status != "Enabled" && rule.Status != "Disabled" {
+		return invalid("the value of status must be Enabled or Disabled")
+	}
+
+	parse := func(name string, days int, date string) (lifecycleWhen, error) {
+		w := lifecycleWhen{name: name, days: days}
+		if (days != 0) == (date != "") {
+			return w, invalid("%s must set one of Date and Days", name)
+		}
+		if days < 0 {
+			return w, invalid("the days of %s must be positive", name)
+		}
+		if date != "" {
+			t, err := time.Parse(time.RFC3339, date)
+			if err != nil {
+				return w, invalid("the date of %s is malformed: %s", name, date)
+			}
+			w.date = t
+		}
+		return w, nil
+	}
+
+	var actions []lifecycleWhen
+	var expiration *lifecycleWhen
+	if e := rule.Expiration; e != nil && (e.Days != 0 || e.Date != "" || e.ExpiredObjectDeleteMarker == nil) {
+		w, err := parse("expiration", e.Days, e.Date)
+		if err != nil {
+			return err
+		}
+		actions, expiration = append(actions, w), &w
+	}
+	if a := rule.AbortIncompleteMultipartUpload; a != nil {
+		w, err := parse("abort multipart upload", a.DaysAfterInitiation, a.Date)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, w)
+	}
+	var transitions []lifecycleWhen
+	rank := 0
+	for i, t := range rule.Transitions {
+		w, err := parse(fmt.Sprintf("transition %d", i), t.Days, t.Date)
+		if err != nil {
+			return err
+		}
+		if next, ok := lifecycleStorageClassRank[t.StorageClass]; !ok {
+			return invalid("can't transition to the storage class %q", t.StorageClass)
+		} else if next <= rank {
+			return invalid("transition %d to %s must be colder than the storage class before", i, t.StorageClass)
+		} else {
+			rank = next
+		}
+		if len(transitions) > 0 {
+			last := transitions[len(transitions)-1]
+			if !w.after(last) {
+				return invalid("%s must be after %s", w.name, last.name)
+			}
+		}
+		transitions = append(transitions, w)
+	}
+	actions = append(actions, transitions...)
+
+	for i := 1; i < len(actions); i++ {
+		if actions[i].date.IsZero() != actions[0].date.IsZero() {
+			return invalid("%s and %s mix days and dates", actions[0].name, actions[i].name)
+		}
+	}
+	if expiration != nil && len(transitions) > 0 {
+		last := transitions[len(transitions)-1]
+		if !expiration.after(last) {
+			return invalid("the expiration must be after %s", last.name)
+		}
+	}
+
+	// The noncurrent versions
+	lastDays := 0
+	rank = 0
+	for i, t := range rule.NoncurrentVersionTransitions {
+		if t.NoncurrentDays <= 0 {
+			return invalid("the days of noncurrent transition %d must be positive", i)
+		}
+		if next, ok := lifecycleStorageClassRank[t.StorageClass]; !ok {
+			return invalid("can't transition to the storage class %q", t.StorageClass)
+		} else if next <= rank {
+			return invalid("noncurrent transition %d to %s must be colder than the storage class before", i, t.StorageClass)
+		} else {
+			rank = next
+		}
+		if t.NoncurrentDays <= lastDays {
+			return invalid("noncurrent transition %d must be after noncurrent transition %d", i, i-1)
+		}
+		lastDays = t.NoncurrentDays
+	}
+	if e := rule.NoncurrentVersionExpiration; e != nil {
+		if e.NoncurrentDays <= 0 {
+			return invalid("the days of noncurrent expiration must be positive")
+		}
+		if e.NoncurrentDays <= lastDays {
+			return invalid("the noncurrent expiration must be after the last noncurrent transition")
+		}
+	}
+	return nil
+}
+
+// lifecycleRulePrefix returns the key prefix the rule applies to
+func lifecycleRulePrefix(rule LifecycleRule) string {
+	if rule.Filter != nil {
+		if rule.Filter.And != nil && rule.Filter.And.Prefix != "" {
+			return rule.Filter.And.Prefix
+		}
+		if rule.Filter.Prefix != "" {
+			return rule.Filter.Prefix
+		}
+	}
+	return rule.Prefix
+}
+
+// lifecycleRuleTags returns the tags the rule filters by
+func lifecycleRuleTags(rule LifecycleRule) []Tag {
+	if rule.Filter != nil && rule.Filter.And != nil {
+		return rule.Filter.And.Tag
+	}
+	return nil
+}
+
+// lifecycleRulesOverlap reports whether an object could match both the rules: one prefix contains the other,
+// and the tags don't conflict. An object could carry the tags of both the rules, so the tags conflict only if
+// the rules require different values of the same key.
+func lifecycleRulesOverlap(a, b LifecycleRule) bool {
+	prefixA, prefixB := lifecycleRulePrefix(a), lifecycleRulePrefix(b)
+	if !strings.HasPrefix(prefixA, prefixB) && !strings.HasPrefix(prefixB, prefixA) {
+		return false
+	}
+	values := map[string]string{}
+	for _, tag := range lifecycleRuleTags(a) {
+		values[tag.Key] = tag.Value
+	}
+	for _, tag := range lifecycleRuleTags(b) {
+		if value, ok := values[tag.Key]; ok && value != tag.Value {
+			return false
+		}
+	}
+	return true
+}
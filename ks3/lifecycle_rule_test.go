@@ -0,0 +1,144 @@
+package ks3
+
+import (
+	"encoding/xml"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3LifecycleRuleSuite struct{}
+
+var _ = Suite(&Ks3LifecycleRuleSuite{})
+
+func (s *Ks3LifecycleRuleSuite) TestBuild(c *C) {
+	rules, err := NewLifecycleBuilder().
+		AddRule(NewLifecycleRule("logs").
+			Prefix("logs/").
+			TransitionAfterDays(30, StorageIA).
+			TransitionAfterDays(90, StorageArchive).
+			ExpireAfterDays(365).
+			TransitionNoncurrentAfterDays(10, StorageIA).
+			ExpireNoncurrentAfterDays(30)).
+		AddRule(NewLifecycleRule("tagged").
+			Prefix("data/").
+			Tags(Tag{Key: "env", Value: "test"}).
+			ExpireOnDate(2030, 1, 1).
+			AbortMultipartUploadOnDate(2029, 1, 1).
+			Enabled(false)).
+		AddRule(NewLifecycleRule("markers").
+			Prefix("versions/").
+			ExpireDeleteMarkers().
+			ExpireNoncurrentAfterDays(7)).
+		Build()
+	c.Assert(err, IsNil)
+	c.Assert(len(rules), Equals, 3)
+	c.Assert(rules[0].Filter.Prefix, Equals, "logs/")
+	c.Assert(rules[0].NoncurrentVersionExpiration.NoncurrentDays, Equals, 30)
+	c.Assert(rules[1].Status, Equals, "Disabled")
+	c.Assert(rules[1].Filter.And.Prefix, Equals, "data/")
+	c.Assert(rules[1].Expiration.Date, Equals, "2030-01-01T00:00:00+08:00")
+	c.Assert(*rules[2].Expiration.ExpiredObjectDeleteMarker, Equals, true)
+	c.Assert(ValidateLifecycleRules(rules), IsNil)
+
+	// The noncurrent actions are in the XML of the rule
+	bs, err := xml.Marshal(LifecycleConfiguration{Rules: rules[:1]})
+	c.Assert(err, IsNil)
+	body := string(bs)
+	c.Assert(strings.Contains(body, "<NoncurrentVersionExpiration><NoncurrentDays>30</NoncurrentDays></NoncurrentVersionExpiration>"), Equals, true)
+	c.Assert(strings.Contains(body, "<NoncurrentVersionTransition><NoncurrentDays>10</NoncurrentDays><StorageClass>STANDARD_IA</StorageClass></NoncurrentVersionTransition>"), Equals, true)
+
+	var parsed LifecycleConfiguration
+	c.Assert(xml.Unmarshal(bs, &parsed), IsNil)
+	c.Assert(parsed.Rules[0].NoncurrentVersionTransitions[0].StorageClass, Equals, StorageIA)
+	c.Assert(parsed.Rules[0].NoncurrentVersionExpiration.NoncurrentDays, Equals, 30)
+}
+
+func (s *Ks3LifecycleRuleSuite) TestInvalidRule(c *C) {
+	cases := []struct {
+		rule  *LifecycleRuleBuilder
+		error string
+	}{
+		{NewLifecycleRule("r").TransitionAfterDays(90, StorageIA).TransitionAfterDays(30, StorageArchive),
+			`invalid rule "r", transition 1 must be after transition 0`},
+		{NewLifecycleRule("r").TransitionAfterDays(30, StorageArchive).TransitionAfterDays(90, StorageIA),
+			`invalid rule "r", transition 1 to STANDARD_IA must be colder than the storage class before`},
+		{NewLifecycleRule("r").TransitionAfterDays(30, StorageStandard),
+			`invalid rule "r", can't transition to the storage class "STANDARD"`},
+		{NewLifecycleRule("r").TransitionAfterDays(30, StorageIA).ExpireAfterDays(30),
+			`invalid rule "r", the expiration must be after transition 0`},
+		{NewLifecycleRule("r").TransitionOnDate(2030, 1, 1, StorageIA).ExpireOnDate(2029, 1, 1),
+			`invalid rule "r", the expiration must be after transition 0`},
+		{NewLifecycleRule("r").TransitionOnDate(2030, 1, 1, StorageIA).ExpireAfterDays(30),
+			`invalid rule "r", expiration and transition 0 mix days and dates`},
+		{NewLifecycleRule("r").ExpireAfterDays(30).AbortMultipartUploadOnDate(2030, 1, 1),
+			`invalid rule "r", expiration and abort multipart upload mix days and dates`},
+		{NewLifecycleRule("r").ExpireAfterDays(30).ExpireOnDate(2030, 1, 1),
+			`invalid rule "r", expiration must set one of Date and Days`},
+		{NewLifecycleRule("r").ExpireAfterDays(-1),
+			`invalid rule "r", the days of expiration must be positive`},
+		{NewLifecycleRule("r").TransitionNoncurrentAfterDays(30, StorageIA).ExpireNoncurrentAfterDays(30),
+			`invalid rule "r", the noncurrent expiration must be after the last noncurrent transition`},
+		{NewLifecycleRule("r").TransitionNoncurrentAfterDays(30, StorageArchive).TransitionNoncurrentAfterDays(60, StorageIA),
+			`invalid rule "r", noncurrent transition 1 to STANDARD_IA must be colder than the storage class before`},
+		{NewLifecycleRule("r").ExpireNoncurrentAfterDays(0),
+			`invalid rule "r", the days of noncurrent expiration must be positive`},
+	}
+	for _, t := range cases {
+		_, err := t.rule.Build()
+		c.Assert(err, NotNil, Commentf(t.error))
+		c.Assert(err.Error(), Equals, t.error)
+
+		_, err = NewLifecycleBuilder().AddRule(t.rule).Build()
+		c.Assert(err, NotNil, Commentf(t.error))
+	}
+
+	// The malformed date of the rule which isn't built by the builder
+	rule := BuildLifecycleRuleByDays("r", "", true, 30)
+	rule.Expiration = &LifecycleExpiration{Date: "2030-01-01"}
+	c.Assert(ValidateLifecycleRules([]LifecycleRule{rule}), ErrorMatches, `invalid rule "r", the date of expiration is malformed: 2030-01-01`)
+}
+
+func (s *Ks3LifecycleRuleSuite) TestInvalidRuleSet(c *C) {
+	_, err := NewLifecycleBuilder().Build()
+	c.Assert(err, NotNil)
+
+	_, err = NewLifecycleBuilder().
+		AddRule(NewLifecycleRule("r").Prefix("a/").ExpireAfterDays(1)).
+		AddRule(NewLifecycleRule("r").Prefix("b/").ExpireAfterDays(1)).
+		Build()
+	c.Assert(err, ErrorMatches, `invalid rule "r", the rule ID is duplicated`)
+
+	_, err = NewLifecycleBuilder().
+		AddRule(NewLifecycleRule("logs").Prefix("logs/").ExpireAfterDays(1)).
+		AddRule(NewLifecycleRule("app-logs").Prefix("logs/app/").ExpireAfterDays(1)).
+		Build()
+	c.Assert(err, ErrorMatches, `invalid rule "app-logs", the prefix "logs/app/" overlaps the prefix "logs/" of rule "logs"`)
+
+	// The empty prefix overlaps all the prefixes
+	_, err = NewLifecycleBuilder().
+		AddRule(NewLifecycleRule("logs").Prefix("logs/").ExpireAfterDays(1)).
+		AddRule(NewLifecycleRule("all").AbortMultipartUploadAfterDays(1)).
+		Build()
+	c.Assert(err, NotNil)
+
+	// The rules filtering by different tags could share the prefix, but not the same tags
+	tagged := func(id, value string) *LifecycleRuleBuilder {
+		return NewLifecycleRule(id).Prefix("logs/").Tags(Tag{Key: "env", Value: value}).ExpireAfterDays(1)
+	}
+	_, err = NewLifecycleBuilder().AddRule(tagged("test", "test"), tagged("prod", "prod")).Build()
+	c.Assert(err, IsNil)
+	_, err = NewLifecycleBuilder().AddRule(tagged("test", "test"), tagged("test2", "test")).Build()
+	c.Assert(err, NotNil)
+	_, err = NewLifecycleBuilder().AddRule(tagged("test", "test"), NewLifecycleRule("logs").Prefix("logs/").ExpireAfterDays(1)).Build()
+	c.Assert(err, NotNil)
+	// An object could carry both the tag sets, or the superset of the tags
+	team := NewLifecycleRule("team").Prefix("logs/").Tags(Tag{Key: "env", Value: "prod"}, Tag{Key: "team", Value: "a"}).ExpireAfterDays(1)
+	_, err = NewLifecycleBuilder().AddRule(tagged("prod", "prod"), team).Build()
+	c.Assert(err, ErrorMatches, `invalid rule "team", the prefix "logs/" overlaps the prefix "logs/" of rule "prod"`)
+	owner := NewLifecycleRule("owner").Prefix("logs/app/").Tags(Tag{Key: "owner", Value: "b"}).ExpireAfterDays(1)
+	_, err = NewLifecycleBuilder().AddRule(tagged("prod", "prod"), owner).Build()
+	c.Assert(err, NotNil)
+	_, err = NewLifecycleBuilder().AddRule(tagged("test", "test"), team).Build()
+	c.Assert(err, IsNil)
+}
@@ -116,17 +116,18 @@ func (bucket Bucket) GetVodPlaylist(channelName string, startTime, endTime time.
 // GetLiveChannelStat   Get the state of the live-channel
 //
 // channelName  the name of the channel
+// options      the options of the request, such as WithContext
 //
 // LiveChannelStat  the state of the live-channel
 // error        nil if success, otherwise error
 //
-func (bucket Bucket) GetLiveChannelStat(channelName string) (LiveChannelStat, error) {
+func (bucket Bucket) GetLiveChannelStat(channelName string, options ...Option) (LiveChannelStat, error) {
 	var out LiveChannelStat
 	params := map[string]interface{}{}
 	params["live"] = nil
 	params["comp"] = "stat"
 
-	resp, err := bucket.do("GET", channelName, params, nil, nil, nil)
+	resp, err := bucket.do("GET", channelName, params, options, nil, nil)
 	if err != nil {
 		return out, err
 	}
@@ -253,5 +254,5 @@ func (bucket Bucket) SignRtmpURL(channelName, playlistName string, expires int64
 	}
 	expiration := time.Now().Unix() + expires
 
-	return bucket.Client.Conn.signRtmpURL(bucket.BucketName, channelName, playlistName, expiration), nil
+	return bucket.Client.Conn.signRtmpURL(bucket.BucketName, channelName, playlistName, expiration)
 }
@@ -9,7 +9,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"strconv"
 	"time"
 )
 
@@ -29,6 +28,11 @@ func (bucket Bucket) CopyFile(srcBucket *Bucket, srcObjectKey, destObjectKey str
 		return errors.New("ks3: part size invalid range (1024KB, 5GB]")
 	}
 
+	// The SSE-C key of the source bucket view is the key of the copy source
+	if set, _, _ := IsOptionSet(options, HTTPHeaderCopySourceSSECKey); !set && srcBucket.sseCustomerKey != nil {
+		options = append(options, CopySourceSSECustomer(srcBucket.sseCustomerKey))
+	}
+
 	cpConf := getCpConfig(options)
 	routines := getRoutines(options)
 
@@ -117,7 +121,8 @@ func copyWorker(id int, arg copyWorkerArg, jobs <-chan copyPart, results chan<-
 }
 
 func UploadPartCopyAcrossRegion(arg copyWorkerArg, chunk copyPart, chunkSize int64, respHeader *http.Header) (UploadPart, error) {
-	reader, err := arg.srcBucket.GetObject(arg.srcObjectKey, Range(chunk.Start, chunk.End), GetResponseHeader(respHeader))
+	getOptions := append(copySourceAsSSECustomer(arg.options), Range(chunk.Start, chunk.End), GetResponseHeader(respHeader))
+	reader, err := arg.srcBucket.GetObject(arg.srcObjectKey, getOptions...)
 	if err != nil {
 		return UploadPart{}, err
 	}
@@ -125,7 +130,7 @@ func UploadPartCopyAcrossRegion(arg copyWorkerArg, chunk copyPart, chunkSize int
 	// 尝试获取源块的crc64值，只有当上传的块与下载的块range一致时，才能获取到
 	srcPartCrc64 := respHeader.Get(HTTPHeaderKs3CRC64)
 
-	options := arg.options
+	options := deleteCopySourceSSECustomerOption(arg.options)
 	options = append(options, GetResponseHeader(respHeader))
 	// 如果开启了crc校验且获取到了源块的crc64值，则设置上传块的crc64值
 	if arg.destbucket.GetConfig().IsEnableCRC && srcPartCrc64 != "" {
@@ -179,25 +184,24 @@ func (bucket Bucket) copyFile(srcBucket *Bucket, srcObjectKey, destObjectKey str
 	listener := GetProgressListener(options)
 
 	// choice valid options
-	headerOptions := ChoiceHeadObjectOption(options)
+	headerOptions := append(ChoiceHeadObjectOption(options), copySourceAsSSECustomer(options)...)
 	partOptions := ChoiceTransferPartOption(options)
 	completeOptions := ChoiceCompletePartOption(options)
 	abortOptions := ChoiceAbortPartOption(options)
 
-	meta, err := srcBucket.GetObjectDetailedMeta(srcObjectKey, headerOptions...)
+	header, err := srcBucket.GetObjectDetailedMeta(srcObjectKey, headerOptions...)
 	if err != nil {
 		return err
 	}
-
-	objectSize, err := strconv.ParseInt(meta.Get(HTTPHeaderContentLength), 10, 0)
+	meta, err := transferObjectMeta(header)
 	if err != nil {
 		return err
 	}
 
 	// Get copy parts
-	parts := getCopyParts(objectSize, partSize)
+	parts := getCopyParts(meta.ContentLength, partSize)
 	// Initialize the multipart upload
-	imur, err := bucket.InitiateMultipartUpload(destObjectKey, options...)
+	imur, err := bucket.InitiateMultipartUpload(destObjectKey, deleteCopySourceSSECustomerOption(options)...)
 	if err != nil {
 		return err
 	}
@@ -278,7 +282,7 @@ type copyCheckpoint struct {
 }
 
 // isValid checks if the data is valid which means CP is valid and object is not updated.
-func (cp copyCheckpoint) isValid(meta http.Header) (bool, error) {
+func (cp copyCheckpoint) isValid(meta *ObjectMeta) bool {
 	// Compare CP's magic number and the MD5.
 	cpb := cp
 	cpb.MD5 = ""
@@ -287,22 +291,17 @@ func (cp copyCheckpoint) isValid(meta http.Header) (bool, error) {
 	b64 := base64.StdEncoding.EncodeToString(sum[:])
 
 	if cp.Magic != copyCpMagic || b64 != cp.MD5 {
-		return false, nil
-	}
-
-	objectSize, err := strconv.ParseInt(meta.Get(HTTPHeaderContentLength), 10, 64)
-	if err != nil {
-		return false, err
+		return false
 	}
 
 	// Compare the object size and last modified time and etag.
-	if cp.ObjStat.Size != objectSize ||
-		cp.ObjStat.LastModified != meta.Get(HTTPHeaderLastModified) ||
-		cp.ObjStat.Etag != meta.Get(HTTPHeaderEtag) {
-		return false, nil
+	if cp.ObjStat.Size != meta.ContentLength ||
+		cp.ObjStat.LastModified != meta.Header.Get(HTTPHeaderLastModified) ||
+		cp.ObjStat.Etag != meta.ETag {
+		return false
 	}
 
-	return true, nil
+	return true
 }
 
 // load loads from the checkpoint file
@@ -369,7 +368,7 @@ func (cp copyCheckpoint) getCompletedBytes() int64 {
 }
 
 // prepare initializes the multipart upload
-func (cp *copyCheckpoint) prepare(meta http.Header, srcBucket *Bucket, srcObjectKey string, destBucket *Bucket, destObjectKey string,
+func (cp *copyCheckpoint) prepare(meta *ObjectMeta, srcBucket *Bucket, srcObjectKey string, destBucket *Bucket, destObjectKey string,
 	partSize int64, options []Option) error {
 	// CP
 	cp.Magic = copyCpMagic
@@ -378,17 +377,12 @@ func (cp *copyCheckpoint) prepare(meta http.Header, srcBucket *Bucket, srcObject
 	cp.DestBucketName = destBucket.BucketName
 	cp.DestObjectKey = destObjectKey
 
-	objectSize, err := strconv.ParseInt(meta.Get(HTTPHeaderContentLength), 10, 64)
-	if err != nil {
-		return err
-	}
-
-	cp.ObjStat.Size = objectSize
-	cp.ObjStat.LastModified = meta.Get(HTTPHeaderLastModified)
-	cp.ObjStat.Etag = meta.Get(HTTPHeaderEtag)
+	cp.ObjStat.Size = meta.ContentLength
+	cp.ObjStat.LastModified = meta.Header.Get(HTTPHeaderLastModified)
+	cp.ObjStat.Etag = meta.ETag
 
 	// Parts
-	cp.Parts = getCopyParts(objectSize, partSize)
+	cp.Parts = getCopyParts(meta.ContentLength, partSize)
 	cp.PartStat = make([]bool, len(cp.Parts))
 	for i := range cp.PartStat {
 		cp.PartStat[i] = false
@@ -396,7 +390,7 @@ func (cp *copyCheckpoint) prepare(meta http.Header, srcBucket *Bucket, srcObject
 	cp.CopyParts = make([]UploadPart, len(cp.Parts))
 
 	// Init copy
-	imur, err := destBucket.InitiateMultipartUpload(destObjectKey, options...)
+	imur, err := destBucket.InitiateMultipartUpload(destObjectKey, deleteCopySourceSSECustomerOption(options)...)
 	if err != nil {
 		return err
 	}
@@ -428,18 +422,21 @@ func (bucket Bucket) copyFileWithCp(srcBucket *Bucket, srcObjectKey, destObjectK
 	}
 
 	// choice valid options
-	headerOptions := ChoiceHeadObjectOption(options)
+	headerOptions := append(ChoiceHeadObjectOption(options), copySourceAsSSECustomer(options)...)
 	partOptions := ChoiceTransferPartOption(options)
 	completeOptions := ChoiceCompletePartOption(options)
 
-	meta, err := srcBucket.GetObjectDetailedMeta(srcObjectKey, headerOptions...)
+	header, err := srcBucket.GetObjectDetailedMeta(srcObjectKey, headerOptions...)
+	if err != nil {
+		return err
+	}
+	meta, err := transferObjectMeta(header)
 	if err != nil {
 		return err
 	}
 
 	// Load error or the CP data is invalid---reinitialize
-	valid, err := ccp.isValid(meta)
-	if err != nil || !valid {
+	if !ccp.isValid(meta) {
 		if err = ccp.prepare(meta, srcBucket, srcObjectKey, &bucket, destObjectKey, partSize, options); err != nil {
 			return err
 		}
@@ -0,0 +1,220 @@
+package ks3
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// MaxPartNumber is the max part number of the multipart upload
+const MaxPartNumber = 10000
+
+// signMultipartURL signs the URL of the multipart upload step, the sub-resources are added to the parameters of the options
+func (bucket Bucket) signMultipartURL(method HTTPMethod, objectKey string, expiration int64,
+	subResources map[string]interface{}, options []Option) (string, error) {
+	p, err := bucket.newURLPresigner(method, expiration, subResources, options)
+	if err != nil {
+		return "", err
+	}
+	return p.sign(objectKey), nil
+}
+
+// multipartExpiration converts the expiration in seconds to the unix time
+func multipartExpiration(now time.Time, expiredInSec int64) (int64, error) {
+	if expiredInSec <= 0 {
+		return 0, fmt.Errorf("invalid expires: %d, expires must bigger than 0", expiredInSec)
+	}
+	return now.Unix() + expiredInSec, nil
+}
+
+func checkPartNumber(partNumber int) error {
+	if partNumber < 1 || partNumber > MaxPartNumber {
+		return fmt.Errorf("invalid part number: %d, it must be in [1, %d]", partNumber, MaxPartNumber)
+	}
+	return nil
+}
+
+// SignInitiateMultipartUploadURL signs the URL to initiate the multipart upload by POST ?uploads.
+//
+// objectKey    the object name.
+// expiredInSec    the expiration in seconds.
+// options    the headers of the object, such as ContentType, Meta and ObjectACL. The client must send the same headers.
+//
+// string    returns the signed URL, when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) SignInitiateMultipartUploadURL(objectKey string, expiredInSec int64, options ...Option) (string, error) {
+	expiration, err := multipartExpiration(time.Now(), expiredInSec)
+	if err != nil {
+		return "", err
+	}
+	return bucket.signMultipartURL(HTTPPost, objectKey, expiration, map[string]interface{}{"uploads": nil}, options)
+}
+
+// SignUploadPartURL signs the URL to upload the part by PUT ?partNumber=N&uploadId=ID.
+//
+// imur    the returned value of InitiateMultipartUpload.
+// partNumber    the part number (ranges from 1 to 10,000).
+// expiredInSec    the expiration in seconds.
+// options    the options of the part, such as ContentType and TrafficLimitHeader. The client must send the same headers.
+//
+// string    returns the signed URL, when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) SignUploadPartURL(imur InitiateMultipartUploadResult, partNumber int, expiredInSec int64,
+	options ...Option) (string, error) {
+	expiration, err := multipartExpiration(time.Now(), expiredInSec)
+	if err != nil {
+		return "", err
+	}
+	return bucket.signUploadPartURL(imur, partNumber, expiration, options)
+}
+
+func (bucket Bucket) signUploadPartURL(imur InitiateMultipartUploadResult, partNumber int, expiration int64,
+	options []Option) (string, error) {
+	if err := checkPartNumber(partNumber); err != nil {
+		return "", err
+	}
+	subResources := map[string]interface{}{"uploadId": imur.UploadID, "partNumber": strconv.Itoa(partNumber)}
+	return bucket.signMultipartURL(HTTPPut, imur.Key, expiration, subResources, options)
+}
+
+// SignCompleteMultipartUploadURL signs the URL to complete the multipart upload by POST ?uploadId=ID,
+// the body is the XML of the parts, the same as CompleteMultipartUpload.
+//
+// imur    the returned value of InitiateMultipartUpload.
+// expiredInSec    the expiration in seconds.
+//
+// string    returns the signed URL, when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) SignCompleteMultipartUploadURL(imur InitiateMultipartUploadResult, expiredInSec int64,
+	options ...Option) (string, error) {
+	expiration, err := multipartExpiration(time.Now(), expiredInSec)
+	if err != nil {
+		return "", err
+	}
+	return bucket.signMultipartURL(HTTPPost, imur.Key, expiration, map[string]interface{}{"uploadId": imur.UploadID}, options)
+}
+
+// SignAbortMultipartUploadURL signs the URL to abort the multipart upload by DELETE ?uploadId=ID.
+//
+// imur    the returned value of InitiateMultipartUpload.
+// expiredInSec    the expiration in seconds.
+//
+// string    returns the signed URL, when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) SignAbortMultipartUploadURL(imur InitiateMultipartUploadResult, expiredInSec int64,
+	options ...Option) (string, error) {
+	expiration, err := multipartExpiration(time.Now(), expiredInSec)
+	if err != nil {
+		return "", err
+	}
+	return bucket.signMultipartURL(HTTPDelete, imur.Key, expiration, map[string]interface{}{"uploadId": imur.UploadID}, options)
+}
+
+// SignListUploadedPartsURL signs the URL to list the uploaded parts by GET ?uploadId=ID.
+//
+// imur    the returned value of InitiateMultipartUpload.
+// expiredInSec    the expiration in seconds.
+// options    the options of listing, such as MaxParts and PartNumberMarker.
+//
+// string    returns the signed URL, when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) SignListUploadedPartsURL(imur InitiateMultipartUploadResult, expiredInSec int64,
+	options ...Option) (string, error) {
+	expiration, err := multipartExpiration(time.Now(), expiredInSec)
+	if err != nil {
+		return "", err
+	}
+	return bucket.signMultipartURL(HTTPGet, imur.Key, expiration, map[string]interface{}{"uploadId": imur.UploadID}, options)
+}
+
+// PartExpiryFunc returns the expiration in seconds of the URL of the part
+type PartExpiryFunc func(partNumber int) int64
+
+// StaggeredPartExpiry gives the later parts longer expiration, since they're uploaded later.
+// The part N expires in base+(N-1)*step seconds.
+func StaggeredPartExpiry(base, step int64) PartExpiryFunc {
+	return func(partNumber int) int64 {
+		return base + int64(partNumber-1)*step
+	}
+}
+
+// PresignedPart is the signed URL to upload the part
+type PresignedPart struct {
+	PartNumber int       `json:"partNumber"`
+	URL        string    `json:"url"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// MultipartUploadManifest is the signed URLs of a multipart upload, the server gives it to the client
+// which uploads the parts and completes the upload without the credentials.
+type MultipartUploadManifest struct {
+	Bucket       string          `json:"bucket"`
+	Key          string          `json:"key"`
+	UploadID     string          `json:"uploadId"`
+	Parts        []PresignedPart `json:"parts"`
+	CompleteURL  string          `json:"completeUrl"`
+	AbortURL     string          `json:"abortUrl"`
+	ListPartsURL string          `json:"listPartsUrl"`
+	Expiration   time.Time       `json:"expiration"` // The expiration of the complete, abort and list URLs
+}
+
+// SignMultipartUploadManifest signs the URLs of all the parts and the URLs to complete, abort and list the upload.
+//
+// imur    the returned value of InitiateMultipartUpload.
+// partCount    the count of the parts (ranges from 1 to 10,000).
+// expiredInSec    the expiration in seconds of the complete, abort and list URLs, and the parts if partExpiry is nil.
+// partExpiry    the expiration in seconds of each part, it could be nil.
+// options    the options of the parts, such as ContentType. The client must send the same headers.
+//
+// *MultipartUploadManifest    the signed URLs.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) SignMultipartUploadManifest(imur InitiateMultipartUploadResult, partCount int, expiredInSec int64,
+	partExpiry PartExpiryFunc, options ...Option) (*MultipartUploadManifest, error) {
+	if partCount < 1 || partCount > MaxPartNumber {
+		return nil, fmt.Errorf("invalid part count: %d, it must be in [1, %d]", partCount, MaxPartNumber)
+	}
+	now := time.Now()
+	expiration, err := multipartExpiration(now, expiredInSec)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &MultipartUploadManifest{
+		Bucket:     bucket.BucketName,
+		Key:        imur.Key,
+		UploadID:   imur.UploadID,
+		Parts:      make([]PresignedPart, 0, partCount),
+		Expiration: time.Unix(expiration, 0),
+	}
+	for i := 1; i <= partCount; i++ {
+		partExpiration := expiration
+		if partExpiry != nil {
+			if partExpiration, err = multipartExpiration(now, partExpiry(i)); err != nil {
+				return nil, fmt.Errorf("part %d: %s", i, err.Error())
+			}
+		}
+		signedURL, err := bucket.signUploadPartURL(imur, i, partExpiration, options)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Parts = append(manifest.Parts, PresignedPart{PartNumber: i, URL: signedURL, Expiration: time.Unix(partExpiration, 0)})
+	}
+
+	uploadID := map[string]interface{}{"uploadId": imur.UploadID}
+	if manifest.CompleteURL, err = bucket.signMultipartURL(HTTPPost, imur.Key, expiration, uploadID, nil); err != nil {
+		return nil, err
+	}
+	if manifest.AbortURL, err = bucket.signMultipartURL(HTTPDelete, imur.Key, expiration, uploadID, nil); err != nil {
+		return nil, err
+	}
+	if manifest.ListPartsURL, err = bucket.signMultipartURL(HTTPGet, imur.Key, expiration, uploadID, nil); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
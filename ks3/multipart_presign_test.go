@@ -0,0 +1,121 @@
+package ks3
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
+	. "gopkg.in/check.v1"
+)
+
+type Ks3MultipartPresignSuite struct{}
+
+var _ = Suite(&Ks3MultipartPresignSuite{})
+
+var presignIMUR = InitiateMultipartUploadResult{Bucket: "presign-bucket", Key: "dir/big file.bin", UploadID: "upload-id"}
+
+func newPresignBucket(c *C, options ...ClientOption) *Bucket {
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", options...)
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("presign-bucket")
+	c.Assert(err, IsNil)
+	return bucket
+}
+
+// verifyPresigned verifies the signed URL as the server does and returns its query
+func verifyPresigned(c *C, method, signedURL string, headers map[string]string) url.Values {
+	req, err := http.NewRequest(method, signedURL, nil)
+	c.Assert(err, IsNil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	result, err := sign.Verify(req, verifyLookup)
+	c.Assert(err, IsNil, Commentf("%s %s", method, signedURL))
+	c.Assert(result.Object, Equals, presignIMUR.Key)
+	return req.URL.Query()
+}
+
+func (s *Ks3MultipartPresignSuite) TestSignSteps(c *C) {
+	for _, version := range []AuthVersionType{AuthV1, AuthV2, AuthV4} {
+		bucket := newPresignBucket(c, AuthVersion(version))
+
+		signedURL, err := bucket.SignInitiateMultipartUploadURL(presignIMUR.Key, 60, ContentType("video/mp4"))
+		c.Assert(err, IsNil)
+		query := verifyPresigned(c, "POST", signedURL, map[string]string{HTTPHeaderContentType: "video/mp4"})
+		_, ok := query["uploads"]
+		c.Assert(ok, Equals, true)
+
+		signedURL, err = bucket.SignUploadPartURL(presignIMUR, 3, 60)
+		c.Assert(err, IsNil)
+		query = verifyPresigned(c, "PUT", signedURL, nil)
+		c.Assert(query.Get("uploadId"), Equals, "upload-id")
+		c.Assert(query.Get("partNumber"), Equals, "3")
+
+		signedURL, err = bucket.SignCompleteMultipartUploadURL(presignIMUR, 60)
+		c.Assert(err, IsNil)
+		c.Assert(verifyPresigned(c, "POST", signedURL, nil).Get("uploadId"), Equals, "upload-id")
+
+		signedURL, err = bucket.SignAbortMultipartUploadURL(presignIMUR, 60)
+		c.Assert(err, IsNil)
+		c.Assert(verifyPresigned(c, "DELETE", signedURL, nil).Get("uploadId"), Equals, "upload-id")
+
+		signedURL, err = bucket.SignListUploadedPartsURL(presignIMUR, 60, MaxParts(10))
+		c.Assert(err, IsNil)
+		query = verifyPresigned(c, "GET", signedURL, nil)
+		c.Assert(query.Get("uploadId"), Equals, "upload-id")
+		c.Assert(query.Get("max-parts"), Equals, "10")
+	}
+}
+
+func (s *Ks3MultipartPresignSuite) TestSignStepsInvalid(c *C) {
+	bucket := newPresignBucket(c)
+	_, err := bucket.SignUploadPartURL(presignIMUR, 0, 60)
+	c.Assert(err, NotNil)
+	_, err = bucket.SignUploadPartURL(presignIMUR, MaxPartNumber+1, 60)
+	c.Assert(err, NotNil)
+	_, err = bucket.SignInitiateMultipartUploadURL("object", 0)
+	c.Assert(err, NotNil)
+	_, err = bucket.SignCompleteMultipartUploadURL(presignIMUR, -1)
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3MultipartPresignSuite) TestManifest(c *C) {
+	bucket := newPresignBucket(c, SetCredentialsProvider(NewStaticCredentialsProvider("sts-ak", "sk", "token")))
+	before := time.Now().Unix()
+	manifest, err := bucket.SignMultipartUploadManifest(presignIMUR, 5, 600, StaggeredPartExpiry(60, 30))
+	c.Assert(err, IsNil)
+
+	c.Assert(manifest.Bucket, Equals, "presign-bucket")
+	c.Assert(manifest.Key, Equals, presignIMUR.Key)
+	c.Assert(manifest.UploadID, Equals, "upload-id")
+	c.Assert(len(manifest.Parts), Equals, 5)
+	for i, part := range manifest.Parts {
+		c.Assert(part.PartNumber, Equals, i+1)
+		query := verifyPresigned(c, "PUT", part.URL, nil)
+		c.Assert(query.Get("partNumber"), Equals, strconv.Itoa(i+1))
+		c.Assert(query.Get("security-token"), Equals, "token")
+
+		expires := part.Expiration.Unix()
+		c.Assert(query.Get(HTTPParamExpires), Equals, strconv.FormatInt(expires, 10))
+		c.Assert(expires-before >= int64(60+30*i), Equals, true)
+		c.Assert(expires-before <= int64(60+30*i+1), Equals, true)
+	}
+	c.Assert(manifest.Expiration.Unix()-before >= 600, Equals, true)
+
+	verifyPresigned(c, "POST", manifest.CompleteURL, nil)
+	verifyPresigned(c, "DELETE", manifest.AbortURL, nil)
+	verifyPresigned(c, "GET", manifest.ListPartsURL, nil)
+
+	// The same expiration without partExpiry
+	manifest, err = bucket.SignMultipartUploadManifest(presignIMUR, 2, 600, nil, ContentType("application/octet-stream"))
+	c.Assert(err, IsNil)
+	c.Assert(manifest.Parts[1].Expiration, Equals, manifest.Expiration)
+	verifyPresigned(c, "PUT", manifest.Parts[1].URL, map[string]string{HTTPHeaderContentType: "application/octet-stream"})
+
+	_, err = bucket.SignMultipartUploadManifest(presignIMUR, 0, 600, nil)
+	c.Assert(err, NotNil)
+	_, err = bucket.SignMultipartUploadManifest(presignIMUR, 2, 600, StaggeredPartExpiry(0, 10))
+	c.Assert(err, NotNil)
+}
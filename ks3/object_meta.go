@@ -0,0 +1,176 @@
+package ks3
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectMeta is the metadata of an object parsed from the response headers of HEAD
+type ObjectMeta struct {
+	ContentLength      int64 // -1 if Content-Length is missing
+	ContentType        string
+	ContentEncoding    string
+	ContentDisposition string
+	ContentLanguage    string
+	CacheControl       string
+	Expires            string
+	ETag               string // As returned, with the quotes
+	LastModified       time.Time
+	CRC64              *uint64 // Nil if the object has no CRC64
+	StorageClass       StorageClassType
+	Restore            string // The raw X-Kss-Restore header, empty if the object isn't being restored or restored
+	VersionID          string
+	TaggingCount       int
+
+	ServerSideEncryption      string // The algorithm of SSE-KS3 or SSE-KMS
+	ServerSideEncryptionKeyID string // The KMS key of SSE-KMS
+	SSECAlgorithm             string // The algorithm of SSE-C
+	SSECKeyMD5                string // The MD5 of the SSE-C key
+
+	SymlinkTarget      string // The target of the symlink
+	NextAppendPosition *int64 // Nil if the object isn't appendable
+
+	Metadata map[string]string // The X-Kss-Meta-* headers without the prefix, the keys are lowercase
+	Header   http.Header       // The raw headers
+}
+
+// ParseObjectMeta parses the response headers of HEAD or GET object.
+//
+// header    the response headers.
+//
+// *ObjectMeta    the metadata, it's only valid when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func ParseObjectMeta(header http.Header) (*ObjectMeta, error) {
+	return parseObjectMeta(header, true)
+}
+
+// transferObjectMeta parses the headers for DownloadFile and CopyFile, which need the object size only.
+// The size is required, the malformed headers of the other fields are ignored.
+func transferObjectMeta(header http.Header) (*ObjectMeta, error) {
+	meta, err := parseObjectMeta(header, false)
+	if err != nil {
+		return nil, err
+	}
+	if meta.ContentLength < 0 {
+		return nil, fmt.Errorf("ks3: the object size is unknown, %s is missing", HTTPHeaderContentLength)
+	}
+	return meta, nil
+}
+
+// parseObjectMeta parses the headers, the malformed headers other than Content-Length are errors if strict,
+// otherwise their fields are left unset
+func parseObjectMeta(header http.Header, strict bool) (*ObjectMeta, error) {
+	meta := &ObjectMeta{
+		ContentType:               header.Get(HTTPHeaderContentType),
+		ContentEncoding:           header.Get(HTTPHeaderContentEncoding),
+		ContentDisposition:        header.Get(HTTPHeaderContentDisposition),
+		ContentLanguage:           header.Get(HTTPHeaderContentLanguage),
+		CacheControl:              header.Get(HTTPHeaderCacheControl),
+		Expires:                   header.Get(HTTPHeaderExpires),
+		ETag:                      header.Get(HTTPHeaderEtag),
+		StorageClass:              StorageClassType(header.Get(HTTPHeaderKs3StorageClass)),
+		Restore:                   header.Get(HTTPHeaderKs3Restore),
+		VersionID:                 GetVersionId(header),
+		ServerSideEncryption:      header.Get(HTTPHeaderKs3ServerSideEncryption),
+		ServerSideEncryptionKeyID: header.Get(HTTPHeaderKs3ServerSideEncryptionKeyID),
+		SSECAlgorithm:             header.Get(HTTPHeaderSSECAlgorithm),
+		SSECKeyMD5:                header.Get(HTTPHeaderSSECKeyMd5),
+		SymlinkTarget:             header.Get(HTTPHeaderKs3SymlinkTarget),
+		Metadata:                  map[string]string{},
+		Header:                    header,
+	}
+
+	var err error
+	meta.ContentLength = -1
+	if header.Get(HTTPHeaderContentLength) != "" {
+		if meta.ContentLength, err = parseMetaInt(header, HTTPHeaderContentLength); err != nil {
+			return nil, err
+		}
+	}
+
+	// The errors of the optional fields
+	var errs []error
+	if value := header.Get(HTTPHeaderLastModified); value != "" {
+		if meta.LastModified, err = http.ParseTime(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q", HTTPHeaderLastModified, value))
+		}
+	}
+	if value := header.Get(HTTPHeaderKs3CRC64); value != "" {
+		if crc, err := strconv.ParseUint(value, 10, 64); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q", HTTPHeaderKs3CRC64, value))
+		} else {
+			meta.CRC64 = &crc
+		}
+	}
+	if count, err := parseMetaInt(header, HTTPHeaderKs3TaggingCount); err != nil {
+		errs = append(errs, err)
+	} else {
+		meta.TaggingCount = int(count)
+	}
+	if header.Get(HTTPHeaderKs3NextAppendPosition) != "" {
+		if position, err := parseMetaInt(header, HTTPHeaderKs3NextAppendPosition); err != nil {
+			errs = append(errs, err)
+		} else {
+			meta.NextAppendPosition = &position
+		}
+	}
+	if strict && len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	for name, values := range header {
+		if len(values) > 0 && len(name) > len(HTTPHeaderKs3MetaPrefix) &&
+			strings.EqualFold(name[:len(HTTPHeaderKs3MetaPrefix)], HTTPHeaderKs3MetaPrefix) {
+			meta.Metadata[strings.ToLower(name[len(HTTPHeaderKs3MetaPrefix):])] = values[0]
+		}
+	}
+	return meta, nil
+}
+
+// parseMetaInt parses the integer header, it's 0 if the header is missing
+func parseMetaInt(header http.Header, name string) (int64, error) {
+	value := header.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+// HeadObject gets the metadata of the object as ObjectMeta, GetObjectDetailedMeta returns the raw headers.
+//
+// objectKey    the object key.
+// options    the options of HEAD, such as VersionId and the SSE-C headers.
+//
+// *ObjectMeta    the metadata, it's only valid when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) HeadObject(objectKey string, options ...Option) (*ObjectMeta, error) {
+	header, err := bucket.GetObjectDetailedMeta(objectKey, options...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseObjectMeta(header)
+}
+
+// HeadObjectWithURL gets the metadata of the object with the signed URL as ObjectMeta.
+//
+// signedURL    the signed URL of HEAD.
+//
+// *ObjectMeta    the metadata, it's only valid when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) HeadObjectWithURL(signedURL string, options ...Option) (*ObjectMeta, error) {
+	header, err := bucket.GetObjectMetaWithURL(signedURL, options...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseObjectMeta(header)
+}
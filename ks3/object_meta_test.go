@@ -0,0 +1,155 @@
+package ks3
+
+import (
+	"bytes"
+	"context"
+	"hash/crc64"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3ObjectMetaSuite struct{}
+
+var _ = Suite(&Ks3ObjectMetaSuite{})
+
+func (s *Ks3ObjectMetaSuite) TestParseObjectMeta(c *C) {
+	header := http.Header{}
+	header.Set(HTTPHeaderContentLength, "1024")
+	header.Set(HTTPHeaderContentType, "text/plain")
+	header.Set(HTTPHeaderEtag, `"0cc175b9c0f1b6a831c399e269772661"`)
+	header.Set(HTTPHeaderLastModified, "Tue, 02 Jan 2024 07:04:05 GMT")
+	header.Set(HTTPHeaderKs3CRC64, "18446744073709551615")
+	header.Set(HTTPHeaderKs3StorageClass, "ARCHIVE")
+	header.Set(HTTPHeaderKs3Restore, `ongoing-request="true"`)
+	header.Set("X-Kss-Version-Id", "v1")
+	header.Set(HTTPHeaderKs3TaggingCount, "2")
+	header.Set(HTTPHeaderKs3ServerSideEncryption, "AES256")
+	header.Set(HTTPHeaderKs3NextAppendPosition, "1024")
+	header.Set("X-Kss-Meta-Author", "alice")
+	header["x-kss-meta-Raw-Key"] = []string{"raw"}
+
+	meta, err := ParseObjectMeta(header)
+	c.Assert(err, IsNil)
+	c.Assert(meta.ContentLength, Equals, int64(1024))
+	c.Assert(meta.ContentType, Equals, "text/plain")
+	c.Assert(meta.ETag, Equals, `"0cc175b9c0f1b6a831c399e269772661"`)
+	c.Assert(meta.LastModified.Equal(time.Date(2024, 1, 2, 7, 4, 5, 0, time.UTC)), Equals, true)
+	c.Assert(*meta.CRC64, Equals, uint64(18446744073709551615))
+	c.Assert(meta.StorageClass, Equals, StorageArchive)
+	c.Assert(meta.Restore, Equals, `ongoing-request="true"`)
+	c.Assert(meta.VersionID, Equals, "v1")
+	c.Assert(meta.TaggingCount, Equals, 2)
+	c.Assert(meta.ServerSideEncryption, Equals, "AES256")
+	c.Assert(*meta.NextAppendPosition, Equals, int64(1024))
+	c.Assert(meta.Metadata, DeepEquals, map[string]string{"author": "alice", "raw-key": "raw"})
+	c.Assert(meta.Header.Get(HTTPHeaderContentType), Equals, "text/plain")
+
+	meta, err = ParseObjectMeta(http.Header{})
+	c.Assert(err, IsNil)
+	c.Assert(meta.ContentLength, Equals, int64(-1))
+	c.Assert(meta.CRC64, IsNil)
+	c.Assert(meta.NextAppendPosition, IsNil)
+	c.Assert(meta.LastModified.IsZero(), Equals, true)
+
+	for name, message := range map[string]string{
+		HTTPHeaderContentLength:   `invalid Content-Length "abc"`,
+		HTTPHeaderLastModified:    `invalid Last-Modified "abc"`,
+		HTTPHeaderKs3CRC64:        `invalid X-Kss-Checksum-Crc64ecma "abc"`,
+		HTTPHeaderKs3TaggingCount: `invalid X-Kss-Tagging-Count "abc"`,
+	} {
+		header := http.Header{}
+		header.Set(name, "abc")
+		_, err = ParseObjectMeta(header)
+		c.Assert(err, ErrorMatches, message)
+	}
+
+	// The transfers need the size only
+	header = http.Header{}
+	header.Set(HTTPHeaderContentLength, "1024")
+	header.Set(HTTPHeaderLastModified, "abc")
+	header.Set(HTTPHeaderKs3CRC64, "abc")
+	header.Set(HTTPHeaderKs3TaggingCount, "abc")
+	meta, err = transferObjectMeta(header)
+	c.Assert(err, IsNil)
+	c.Assert(meta.ContentLength, Equals, int64(1024))
+	c.Assert(meta.CRC64, IsNil)
+	header.Del(HTTPHeaderContentLength)
+	_, err = transferObjectMeta(header)
+	c.Assert(err, ErrorMatches, "ks3: the object size is unknown, Content-Length is missing")
+}
+
+func (s *Ks3ObjectMetaSuite) TestHeadObjectAndDownloadFile(c *C) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	crc := crc64.Checksum(data, crc64.MakeTable(crc64.ECMA))
+	modified := time.Date(2024, 1, 2, 7, 4, 5, 0, time.UTC)
+	var tagging string   // The X-Kss-Tagging-Count header
+	var stripLength bool // The proxy strips Content-Length of HEAD
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" && stripLength {
+			return
+		}
+		if tagging != "" {
+			w.Header().Set(HTTPHeaderKs3TaggingCount, tagging)
+		}
+		w.Header().Set(HTTPHeaderEtag, `"etag"`)
+		if r.Header.Get(HTTPHeaderRange) == "" {
+			w.Header().Set(HTTPHeaderKs3CRC64, strconv.FormatUint(crc, 10))
+		}
+		w.Header().Set("X-Kss-Meta-Author", "alice")
+		http.ServeContent(w, r, "", modified, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("meta-bucket")
+	c.Assert(err, IsNil)
+
+	meta, err := bucket.HeadObject("object")
+	c.Assert(err, IsNil)
+	c.Assert(meta.ContentLength, Equals, int64(len(data)))
+	c.Assert(meta.LastModified.Equal(modified), Equals, true)
+	c.Assert(*meta.CRC64, Equals, crc)
+	c.Assert(meta.Metadata["author"], Equals, "alice")
+
+	dir := c.MkDir()
+	for _, options := range [][]Option{
+		{Routines(3)},
+		{Routines(3), Checkpoint(true, filepath.Join(dir, "object.cp"))},
+	} {
+		filePath := filepath.Join(dir, "object")
+		c.Assert(bucket.DownloadFile("object", filePath, 300, options...), IsNil)
+		downloaded, err := ioutil.ReadFile(filePath)
+		c.Assert(err, IsNil)
+		c.Assert(downloaded, DeepEquals, data)
+	}
+
+	// The malformed headers which the download doesn't need are ignored
+	tagging = "abc"
+	_, err = bucket.HeadObject("object")
+	c.Assert(err, NotNil)
+	filePath := filepath.Join(dir, "tagged")
+	c.Assert(bucket.DownloadFile("object", filePath, 300, Routines(3)), IsNil)
+	downloaded, err := ioutil.ReadFile(filePath)
+	c.Assert(err, IsNil)
+	c.Assert(downloaded, DeepEquals, data)
+
+	// The download fails instead of writing an empty file if the size is unknown
+	stripLength = true
+	err = bucket.DownloadFile("object", filepath.Join(dir, "stripped"), 300)
+	c.Assert(err, ErrorMatches, ".*Content-Length is missing")
+	err = bucket.DownloadFile("object", filepath.Join(dir, "stripped"), 300, Checkpoint(true, ""))
+	c.Assert(err, ErrorMatches, ".*Content-Length is missing")
+}
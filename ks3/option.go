@@ -33,6 +33,8 @@ const (
 	contextArg         = "x-context-arg"
 	disableTempFileFlag = "disable-temp-file"
 	acrossRegion        = "across-region"
+	signEndpointArg     = "x-sign-endpoint"
+	restoreProgressArg  = "x-restore-progress"
 )
 
 type (
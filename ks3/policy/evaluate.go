@@ -0,0 +1,319 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decision is the result of the evaluation
+type Decision string
+
+// The decisions of Evaluate
+const (
+	Allowed      Decision = "Allow"        // An Allow statement matched and no Deny statement matched
+	ExplicitDeny Decision = "ExplicitDeny" // A Deny statement matched, it overrides the Allow statements
+	ImplicitDeny Decision = "ImplicitDeny" // No statement matched, the request is decided by the ACLs
+)
+
+// Request is the request to evaluate
+type Request struct {
+	Principal       string              // The account ID or the KRN of the user, empty for the anonymous user
+	Action          string              // The action, such as ActionGetObject
+	Resource        string              // The KRN of the bucket or the object, see BucketResource and ObjectResource
+	SourceIP        string              // The value of acs:SourceIp
+	Referer         string              // The value of acs:Referer
+	UserAgent       string              // The value of acs:UserAgent
+	SecureTransport bool                // The value of acs:SecureTransport
+	CurrentTime     time.Time           // The value of acs:CurrentTime, now if it's zero
+	Context         map[string][]string // The values of other keys, such as ks3:prefix. They override the fields above.
+}
+
+// MatchedStatement is the statement which applies to the request
+type MatchedStatement struct {
+	Index     int // The index in the document
+	Statement Statement
+}
+
+// EvalResult is the result of the evaluation
+type EvalResult struct {
+	Decision Decision
+	Matched  []MatchedStatement // All the statements which apply to the request, in the order of the document
+}
+
+// IsAllowed reports whether the policy allows the request
+func (r *EvalResult) IsAllowed() bool {
+	return r.Decision == Allowed
+}
+
+// Evaluate evaluates the request against the document as the server does: an explicit deny overrides any allow,
+// and the request is implicitly denied if no statement matches. The actions and resources are matched with the
+// wildcards * and ?, the actions are case insensitive. All the operators of a condition must be true,
+// and an operator is true if any value of each key matches.
+//
+// It returns an error if the request or the document is malformed, such as the invalid CIDR of IpAddress.
+func Evaluate(doc *Document, req *Request) (*EvalResult, error) {
+	if doc == nil || req == nil {
+		return nil, fmt.Errorf("the document and the request are required")
+	}
+	if req.Action == "" {
+		return nil, fmt.Errorf("the action of the request is empty")
+	}
+	target, err := ParseResource(req.Resource)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(target.Bucket, "*?") {
+		return nil, fmt.Errorf("the resource of the request can't contain the wildcards: %s", req.Resource)
+	}
+
+	ctx := req.contextValues()
+	result := &EvalResult{Decision: ImplicitDeny}
+	for i, s := range doc.Statement {
+		matched, err := s.matches(req, target, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %s", i, err.Error())
+		}
+		if !matched {
+			continue
+		}
+		result.Matched = append(result.Matched, MatchedStatement{Index: i, Statement: s})
+		if s.Effect == Deny {
+			result.Decision = ExplicitDeny
+		} else if s.Effect == Allow && result.Decision != ExplicitDeny {
+			result.Decision = Allowed
+		}
+	}
+	return result, nil
+}
+
+// contextValues returns the values of the condition keys of the request
+func (req *Request) contextValues() map[string][]string {
+	now := req.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	values := map[string][]string{
+		KeySecureTransport: {strconv.FormatBool(req.SecureTransport)},
+		KeyCurrentTime:     {now.UTC().Format(time.RFC3339)},
+	}
+	if req.SourceIP != "" {
+		values[KeySourceIP] = []string{req.SourceIP}
+	}
+	if req.Referer != "" {
+		values[KeyReferer] = []string{req.Referer}
+	}
+	if req.UserAgent != "" {
+		values[KeyUserAgent] = []string{req.UserAgent}
+	}
+	for k, v := range req.Context {
+		values[k] = v
+	}
+	return values
+}
+
+// matches reports whether the statement applies to the request
+func (s Statement) matches(req *Request, target *Resource, ctx map[string][]string) (bool, error) {
+	if s.Principal == nil || !matchPrincipal(s.Principal.KSC, req.Principal) {
+		return false, nil
+	}
+	if len(s.Action) > 0 && !matchAny(s.Action, req.Action, true) {
+		return false, nil
+	}
+	if len(s.NotAction) > 0 && matchAny(s.NotAction, req.Action, true) {
+		return false, nil
+	}
+	if len(s.Resource) > 0 && !matchResources(s.Resource, target) {
+		return false, nil
+	}
+	if len(s.NotResource) > 0 && matchResources(s.NotResource, target) {
+		return false, nil
+	}
+	for _, op := range s.Condition.operators() {
+		keys := s.Condition[op]
+		for _, key := range sortedKeys(keys) {
+			ok, err := evalCondition(op, ctx[key], keys[key])
+			if err != nil {
+				return false, fmt.Errorf("%s of key %s: %s", op, key, err.Error())
+			}
+			if !ok {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// matchPrincipal reports whether the principal of the request is in the list.
+// The account ID and the KRN of the account match all the users of the account.
+func matchPrincipal(principals []string, principal string) bool {
+	account := principalAccount(principal)
+	for _, p := range principals {
+		switch {
+		case p == AnyPrincipal:
+			return true
+		case principal == "":
+			continue
+		case p == principal:
+			return true
+		case account != "" && (p == account || p == AccountPrincipal(account)):
+			return true
+		}
+	}
+	return false
+}
+
+// principalAccount returns the account ID of the principal
+func principalAccount(principal string) string {
+	if accountIDRegexp.MatchString(principal) {
+		return principal
+	}
+	if strings.HasPrefix(principal, principalPrefix) {
+		rest := principal[len(principalPrefix):]
+		if pos := strings.Index(rest, ":"); pos > 0 {
+			return rest[:pos]
+		}
+	}
+	return ""
+}
+
+// matchAny reports whether the value matches any pattern
+func matchAny(patterns []string, value string, ignoreCase bool) bool {
+	for _, p := range patterns {
+		if matchWildcard(p, value, ignoreCase) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchResources reports whether the target matches any resource, the malformed resources match nothing
+func matchResources(resources []string, target *Resource) bool {
+	for _, r := range resources {
+		if r == "*" {
+			return true
+		}
+		pattern, err := ParseResource(r)
+		if err != nil || pattern.Object != target.Object {
+			continue
+		}
+		if matchWildcard(pattern.Bucket, target.Bucket, false) && matchWildcard(pattern.Key, target.Key, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// negatedOperators are true if the key is missing
+var negatedOperators = map[ConditionOperator]bool{
+	StringNotEquals: true, StringNotEqualsIgnoreCase: true, StringNotLike: true,
+	NumericNotEquals: true, DateNotEquals: true, NotIpAddress: true,
+}
+
+// evalCondition evaluates the operator on the values of the request, the key matches if any value of the request
+// matches any value of the condition. For the negated operators, the key matches if no value matches.
+func evalCondition(op ConditionOperator, actual []string, expected []string) (bool, error) {
+	base, ifExists, ok := baseOperator(op)
+	if !ok {
+		return false, fmt.Errorf("unknown operator")
+	}
+	if base == Null {
+		if len(expected) == 0 {
+			return false, fmt.Errorf("no value")
+		}
+		isNull, err := strconv.ParseBool(expected[0])
+		if err != nil {
+			return false, fmt.Errorf("not a boolean")
+		}
+		return (len(actual) == 0) == isNull, nil
+	}
+	if len(actual) == 0 {
+		return ifExists || negatedOperators[base], nil
+	}
+
+	positive := base
+	if negatedOperators[base] {
+		positive = ConditionOperator(strings.Replace(string(base), "Not", "", 1))
+	}
+	for _, a := range actual {
+		for _, e := range expected {
+			matched, err := compareValue(positive, a, e)
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return !negatedOperators[base], nil
+			}
+		}
+	}
+	return negatedOperators[base], nil
+}
+
+// compareValue compares the value of the request with the value of the condition by the positive operator
+func compareValue(op ConditionOperator, actual, expected string) (bool, error) {
+	switch op {
+	case StringEquals:
+		return actual == expected, nil
+	case StringEqualsIgnoreCase:
+		return strings.EqualFold(actual, expected), nil
+	case StringLike:
+		return matchWildcard(expected, actual, false), nil
+	case Bool:
+		e, err := strconv.ParseBool(expected)
+		if err != nil {
+			return false, fmt.Errorf("not a boolean: %s", expected)
+		}
+		a, err := strconv.ParseBool(actual)
+		return err == nil && a == e, nil
+	case IpAddress:
+		ipNet, err := parseCIDR(expected)
+		if err != nil {
+			return false, err
+		}
+		ip := net.ParseIP(actual)
+		return ip != nil && ipNet.Contains(ip), nil
+	}
+
+	if strings.HasPrefix(string(op), "Numeric") {
+		e, err := strconv.ParseFloat(expected, 64)
+		if err != nil {
+			return false, fmt.Errorf("not a number: %s", expected)
+		}
+		a, err := strconv.ParseFloat(actual, 64)
+		if err != nil {
+			return false, nil
+		}
+		return compareOrder(string(op[len("Numeric"):]), a-e), nil
+	}
+	if strings.HasPrefix(string(op), "Date") {
+		e, err := parseDate(expected)
+		if err != nil {
+			return false, err
+		}
+		a, err := parseDate(actual)
+		if err != nil {
+			return false, nil
+		}
+		return compareOrder(string(op[len("Date"):]), float64(a.Sub(e))), nil
+	}
+	return false, fmt.Errorf("unknown operator")
+}
+
+// compareOrder applies the comparison such as LessThan to the difference of the two values
+func compareOrder(comparison string, diff float64) bool {
+	switch comparison {
+	case "Equals":
+		return diff == 0
+	case "LessThan":
+		return diff < 0
+	case "LessThanEquals":
+		return diff <= 0
+	case "GreaterThan":
+		return diff > 0
+	case "GreaterThanEquals":
+		return diff >= 0
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package policy
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3PolicyEvaluateSuite struct{}
+
+var _ = Suite(&Ks3PolicyEvaluateSuite{})
+
+func evaluate(c *C, doc *Document, req *Request) *EvalResult {
+	result, err := Evaluate(doc, req)
+	c.Assert(err, IsNil)
+	return result
+}
+
+func matchedIndexes(result *EvalResult) []int {
+	indexes := []int{}
+	for _, m := range result.Matched {
+		indexes = append(indexes, m.Index)
+	}
+	return indexes
+}
+
+func (s *Ks3PolicyEvaluateSuite) TestExplicitDeny(c *C) {
+	doc := New().
+		AddStatement(ReadOnlyPrefix("bucket", "public/")...).
+		AddStatement(DenyOutsideIPs("bucket", "10.0.0.0/8")...)
+
+	// Allowed inside the IPs
+	result := evaluate(c, doc, &Request{Action: ActionGetObject, Resource: ObjectResource("bucket", "public/a.txt"), SourceIP: "10.1.2.3"})
+	c.Assert(result.Decision, Equals, Allowed)
+	c.Assert(result.IsAllowed(), Equals, true)
+	c.Assert(matchedIndexes(result), DeepEquals, []int{0})
+
+	// The deny overrides the allow
+	result = evaluate(c, doc, &Request{Action: ActionGetObject, Resource: ObjectResource("bucket", "public/a.txt"), SourceIP: "192.168.1.1"})
+	c.Assert(result.Decision, Equals, ExplicitDeny)
+	c.Assert(result.IsAllowed(), Equals, false)
+	c.Assert(matchedIndexes(result), DeepEquals, []int{0, 2})
+	c.Assert(result.Matched[1].Statement.Effect, Equals, Deny)
+
+	// The missing source IP is denied by NotIpAddress
+	result = evaluate(c, doc, &Request{Action: ActionGetObject, Resource: ObjectResource("bucket", "public/a.txt")})
+	c.Assert(result.Decision, Equals, ExplicitDeny)
+
+	// Not granted
+	result = evaluate(c, doc, &Request{Action: ActionGetObject, Resource: ObjectResource("bucket", "private/a.txt"), SourceIP: "10.1.2.3"})
+	c.Assert(result.Decision, Equals, ImplicitDeny)
+	c.Assert(len(result.Matched), Equals, 0)
+	result = evaluate(c, doc, &Request{Action: ActionPutObject, Resource: ObjectResource("bucket", "public/a.txt"), SourceIP: "10.1.2.3"})
+	c.Assert(result.Decision, Equals, ImplicitDeny)
+}
+
+func (s *Ks3PolicyEvaluateSuite) TestWildcards(c *C) {
+	doc := New().AddStatement(NewStatement(Allow).
+		WithPrincipals("123").
+		WithActions("ks3:get*", "ks3:List?ucket").
+		WithResources("krn:ksc:ks3:::logs-*", "krn:ksc:ks3:::logs-*/2024/??/*.gz"))
+
+	for _, req := range []Request{
+		{Principal: "123", Action: ActionGetObject, Resource: ObjectResource("logs-a", "2024/01/x.gz")},
+		{Principal: "krn:ksc:iam::123:user/alice", Action: ActionGetObjectAcl, Resource: ObjectResource("logs-b", "2024/12/d/x.gz")},
+		{Principal: AccountPrincipal("123"), Action: ActionListBucket, Resource: BucketResource("logs-c")},
+	} {
+		req := req
+		c.Assert(evaluate(c, doc, &req).Decision, Equals, Allowed, Commentf("%v", req))
+	}
+	for _, req := range []Request{
+		{Principal: "456", Action: ActionGetObject, Resource: ObjectResource("logs-a", "2024/01/x.gz")},
+		{Action: ActionGetObject, Resource: ObjectResource("logs-a", "2024/01/x.gz")},
+		{Principal: "123", Action: ActionPutObject, Resource: ObjectResource("logs-a", "2024/01/x.gz")},
+		{Principal: "123", Action: ActionGetObject, Resource: ObjectResource("logs-a", "2024/1/x.gz")},
+		{Principal: "123", Action: ActionGetObject, Resource: ObjectResource("data", "2024/01/x.gz")},
+		{Principal: "123", Action: ActionGetBucketAcl, Resource: ObjectResource("logs-a", "")},
+	} {
+		req := req
+		c.Assert(evaluate(c, doc, &req).Decision, Equals, ImplicitDeny, Commentf("%v", req))
+	}
+
+	// The user principal only matches the user
+	doc = New().AddStatement(NewStatement(Allow).
+		WithPrincipals(UserPrincipal("123", "alice")).
+		WithNotActions(ActionDeleteObject).
+		WithNotResources(ObjectResource("bucket", "secret/*")))
+	req := &Request{Principal: UserPrincipal("123", "alice"), Action: ActionPutObject, Resource: ObjectResource("bucket", "a")}
+	c.Assert(evaluate(c, doc, req).Decision, Equals, Allowed)
+	req.Principal = UserPrincipal("123", "bob")
+	c.Assert(evaluate(c, doc, req).Decision, Equals, ImplicitDeny)
+	req.Principal, req.Action = UserPrincipal("123", "alice"), ActionDeleteObject
+	c.Assert(evaluate(c, doc, req).Decision, Equals, ImplicitDeny)
+	req.Action, req.Resource = ActionGetObject, ObjectResource("bucket", "secret/a")
+	c.Assert(evaluate(c, doc, req).Decision, Equals, ImplicitDeny)
+}
+
+func (s *Ks3PolicyEvaluateSuite) TestConditions(c *C) {
+	deadline := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	doc := New().AddStatement(NewStatement(Allow).
+		WithPrincipals(AnyPrincipal).
+		WithActions(ActionGetObject).
+		WithResources(ObjectResource("bucket", "*")).
+		WithCondition(StringLike, KeyReferer, "https://*.example.com/*", "https://example.com/*").
+		WithCondition(Bool, KeySecureTransport, "true").
+		WithCondition(DateLessThan, KeyCurrentTime, deadline.Format(time.RFC3339)).
+		WithCondition(StringEquals+IfExistsSuffix, KeyUserAgent, "app/1.0"))
+
+	req := &Request{
+		Action:          ActionGetObject,
+		Resource:        ObjectResource("bucket", "a.jpg"),
+		Referer:         "https://www.example.com/page",
+		SecureTransport: true,
+		CurrentTime:     deadline.Add(-time.Hour),
+	}
+	c.Assert(evaluate(c, doc, req).Decision, Equals, Allowed)
+
+	// IfExists matches the present key by the operator
+	req.UserAgent = "curl"
+	c.Assert(evaluate(c, doc, req).Decision, Equals, ImplicitDeny)
+	req.UserAgent = "app/1.0"
+	c.Assert(evaluate(c, doc, req).Decision, Equals, Allowed)
+
+	req.Referer = "https://www.example.org/page"
+	c.Assert(evaluate(c, doc, req).Decision, Equals, ImplicitDeny)
+	req.Referer = ""
+	c.Assert(evaluate(c, doc, req).Decision, Equals, ImplicitDeny)
+	req.Referer = "https://example.com/"
+
+	req.SecureTransport = false
+	c.Assert(evaluate(c, doc, req).Decision, Equals, ImplicitDeny)
+	req.SecureTransport = true
+
+	req.CurrentTime = deadline
+	c.Assert(evaluate(c, doc, req).Decision, Equals, ImplicitDeny)
+	req.CurrentTime = deadline.Add(-time.Second)
+	c.Assert(evaluate(c, doc, req).Decision, Equals, Allowed)
+
+	// The context overrides the fields
+	req.Context = map[string][]string{KeySecureTransport: {"false"}}
+	c.Assert(evaluate(c, doc, req).Decision, Equals, ImplicitDeny)
+}
+
+func (s *Ks3PolicyEvaluateSuite) TestOperators(c *C) {
+	cases := []struct {
+		op       ConditionOperator
+		actual   []string
+		expected []string
+		result   bool
+	}{
+		{StringEquals, []string{"a"}, []string{"b", "a"}, true},
+		{StringEquals, []string{"A"}, []string{"a"}, false},
+		{StringEquals, nil, []string{"a"}, false},
+		{StringNotEquals, []string{"a"}, []string{"b", "c"}, true},
+		{StringNotEquals, []string{"a"}, []string{"b", "a"}, false},
+		{StringNotEquals, nil, []string{"a"}, true},
+		{StringEqualsIgnoreCase, []string{"A"}, []string{"a"}, true},
+		{StringLike, []string{"dir/a.txt"}, []string{"dir/*"}, true},
+		{StringNotLike, []string{"dir/a.txt"}, []string{"dir/*"}, false},
+		{StringLike + IfExistsSuffix, nil, []string{"dir/*"}, true},
+		{IpAddress, []string{"192.168.1.1"}, []string{"192.168.0.0/16"}, true},
+		{IpAddress, []string{"192.169.1.1"}, []string{"192.168.0.0/16", "10.0.0.1"}, false},
+		{IpAddress, []string{"10.0.0.1"}, []string{"10.0.0.1"}, true},
+		{IpAddress, []string{"2001:db8::1"}, []string{"2001:db8::/32"}, true},
+		{IpAddress, []string{"not ip"}, []string{"10.0.0.0/8"}, false},
+		{NotIpAddress, []string{"192.168.1.1"}, []string{"10.0.0.0/8"}, true},
+		{NotIpAddress, []string{"10.1.1.1"}, []string{"10.0.0.0/8"}, false},
+		{NumericLessThan, []string{"100"}, []string{"1000"}, true},
+		{NumericGreaterThanEquals, []string{"100"}, []string{"100"}, true},
+		{NumericNotEquals, []string{"100"}, []string{"100"}, false},
+		{DateGreaterThan, []string{"2030-01-01T00:00:01Z"}, []string{"2030-01-01T00:00:00Z"}, true},
+		{DateLessThanEquals, []string{"2030-01-01T00:00:00Z"}, []string{"2030-01-01"}, true},
+		{Bool, []string{"false"}, []string{"true"}, false},
+		{Null, nil, []string{"true"}, true},
+		{Null, []string{"a"}, []string{"true"}, false},
+		{Null, []string{"a"}, []string{"false"}, true},
+	}
+	for _, t := range cases {
+		result, err := evalCondition(t.op, t.actual, t.expected)
+		c.Assert(err, IsNil, Commentf("%v", t))
+		c.Assert(result, Equals, t.result, Commentf("%v", t))
+	}
+
+	for _, t := range []struct {
+		op       ConditionOperator
+		expected string
+	}{{"StringMatches", "a"}, {IpAddress, "10.0.0.300"}, {NumericEquals, "ten"}, {DateEquals, "tomorrow"}, {Bool, "yes"}} {
+		_, err := evalCondition(t.op, []string{"a"}, []string{t.expected})
+		c.Assert(err, NotNil, Commentf("%v", t))
+	}
+}
+
+func (s *Ks3PolicyEvaluateSuite) TestInvalid(c *C) {
+	doc := New().AddStatement(AllowFromIPs("bucket", []string{"10.0.0.300"})...)
+	_, err := Evaluate(doc, &Request{Action: ActionGetObject, Resource: ObjectResource("bucket", "a"), SourceIP: "10.0.0.1"})
+	c.Assert(err, ErrorMatches, "statement 0: IpAddress of key acs:SourceIp: .*")
+
+	doc = New().AddStatement(ReadOnlyPrefix("bucket", "")...)
+	_, err = Evaluate(doc, &Request{Resource: ObjectResource("bucket", "a")})
+	c.Assert(err, NotNil)
+	_, err = Evaluate(doc, &Request{Action: ActionGetObject, Resource: "bucket/a"})
+	c.Assert(err, NotNil)
+	_, err = Evaluate(doc, &Request{Action: ActionGetObject, Resource: ObjectResource("b*", "a")})
+	c.Assert(err, NotNil)
+	_, err = Evaluate(nil, &Request{Action: ActionGetObject, Resource: ObjectResource("bucket", "a")})
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,57 @@
+package policy
+
+// ReadOnlyPrefix allows the principals to list and read the objects under the prefix, everyone if no principal is given.
+// The prefix of ListObjects is restricted by the ks3:prefix condition, the whole bucket is granted if the prefix is empty.
+func ReadOnlyPrefix(bucket, prefix string, principals ...string) []*Statement {
+	if len(principals) == 0 {
+		principals = []string{AnyPrincipal}
+	}
+	read := NewStatement(Allow).
+		WithPrincipals(principals...).
+		WithActions(ActionGetObject).
+		WithResources(ObjectResource(bucket, prefix+"*"))
+	list := NewStatement(Allow).
+		WithPrincipals(principals...).
+		WithActions(ActionListBucket).
+		WithResources(BucketResource(bucket))
+	if prefix != "" {
+		list.WithCondition(StringLike, KeyPrefix, prefix+"*")
+	}
+	return []*Statement{read, list}
+}
+
+// CrossAccountWrite allows the other accounts to upload the objects under the prefix, including the multipart uploads.
+// The uploaded objects are owned by the bucket owner.
+func CrossAccountWrite(bucket, prefix string, accountIDs ...string) []*Statement {
+	principals := make([]string, len(accountIDs))
+	for i, id := range accountIDs {
+		principals[i] = AccountPrincipal(id)
+	}
+	return []*Statement{NewStatement(Allow).
+		WithPrincipals(principals...).
+		WithActions(ActionPutObject, ActionAbortMultipartUpload, ActionListMultipartUploadParts).
+		WithResources(ObjectResource(bucket, prefix+"*"))}
+}
+
+// AllowFromIPs allows everyone to do the actions on the objects from the IPs or CIDRs, such as 10.0.0.0/8.
+// ActionGetObject is allowed if no action is given.
+func AllowFromIPs(bucket string, ips []string, actions ...string) []*Statement {
+	if len(actions) == 0 {
+		actions = []string{ActionGetObject}
+	}
+	return []*Statement{NewStatement(Allow).
+		WithPrincipals(AnyPrincipal).
+		WithActions(actions...).
+		WithResources(ObjectResource(bucket, "*")).
+		WithCondition(IpAddress, KeySourceIP, ips...)}
+}
+
+// DenyOutsideIPs denies all the requests to the bucket and its objects outside the IPs or CIDRs,
+// it takes effect even if other statements or the ACLs allow the requests.
+func DenyOutsideIPs(bucket string, ips ...string) []*Statement {
+	return []*Statement{NewStatement(Deny).
+		WithPrincipals(AnyPrincipal).
+		WithActions(ActionAll).
+		WithResources(BucketResource(bucket), ObjectResource(bucket, "*")).
+		WithCondition(NotIpAddress, KeySourceIP, ips...)}
+}
@@ -0,0 +1,245 @@
+// Package policy models the bucket policy documents of KS3.
+//
+// A Document is a list of statements, each allows or denies the actions on the resources for the principals,
+// optionally under conditions. The documents are built with the fluent helpers or the common grants,
+// validated and then set by Client.SetBucketPolicyDocument:
+//
+//	doc := policy.New().
+//		AddStatement(policy.ReadOnlyPrefix("bucket", "public/")...).
+//		AddStatement(policy.NewStatement(policy.Allow).
+//			WithPrincipals(policy.AccountPrincipal("1234567890")).
+//			WithActions(policy.ActionPutObject).
+//			WithResources(policy.ObjectResource("bucket", "uploads/*")))
+//
+//	if err := doc.Validate(); err != nil {
+//		// the unknown actions, malformed resources and so on
+//	}
+//	err := client.SetBucketPolicyDocument("bucket", doc)
+//
+// The raw JSON is still accepted by Client.SetBucketPolicy, and Parse converts it to a Document.
+//
+// Evaluate simulates the decision of the server locally, so the document could be checked before it's set:
+//
+//	result, err := policy.Evaluate(doc, &policy.Request{
+//		Action:   policy.ActionGetObject,
+//		Resource: policy.ObjectResource("bucket", "public/index.html"),
+//		SourceIP: "10.0.0.1",
+//	})
+//	// result.Decision is Allowed, ExplicitDeny or ImplicitDeny, and result.Matched lists the matched statements
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultVersion is the version of the documents created by New
+const DefaultVersion = "1"
+
+// Effect is the effect of the statement
+type Effect string
+
+// The effects of the statement
+const (
+	Allow Effect = "Allow"
+	Deny  Effect = "Deny"
+)
+
+// Document is the bucket policy document
+type Document struct {
+	Version   string      `json:"Version,omitempty"`
+	Statement []Statement `json:"Statement"`
+}
+
+// Statement allows or denies the actions on the resources for the principals.
+// Either Action or NotAction is set, so are Resource and NotResource.
+type Statement struct {
+	Sid         string     `json:"Sid,omitempty"`
+	Effect      Effect     `json:"Effect"`
+	Principal   *Principal `json:"Principal,omitempty"`
+	Action      StringList `json:"Action,omitempty"`
+	NotAction   StringList `json:"NotAction,omitempty"`
+	Resource    StringList `json:"Resource,omitempty"`
+	NotResource StringList `json:"NotResource,omitempty"`
+	Condition   Condition  `json:"Condition,omitempty"`
+}
+
+// StringList is a list of strings, it's a single string or an array in JSON
+type StringList []string
+
+// UnmarshalJSON accepts both the single string and the array
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("the value must be a string or an array of strings: %s", string(data))
+	}
+	*l = list
+	return nil
+}
+
+// Principal is the users the statement applies to, the IDs are "*", the account IDs or the KRNs of the users.
+// It's {"KSC": [...]} in JSON, the single string and the array are accepted too.
+type Principal struct {
+	KSC StringList `json:"KSC"`
+}
+
+// UnmarshalJSON accepts {"KSC": ...}, the single string and the array
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var v struct {
+			KSC StringList `json:"KSC"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		p.KSC = v.KSC
+		return nil
+	}
+	return p.KSC.UnmarshalJSON(data)
+}
+
+// ConditionOperator is the operator of the condition
+type ConditionOperator string
+
+// The condition operators, all the values of a key are ORed, and all the keys and operators are ANDed.
+// The operators except Null accept the IfExists suffix, which matches the missing key.
+const (
+	StringEquals              ConditionOperator = "StringEquals"
+	StringNotEquals           ConditionOperator = "StringNotEquals"
+	StringEqualsIgnoreCase    ConditionOperator = "StringEqualsIgnoreCase"
+	StringNotEqualsIgnoreCase ConditionOperator = "StringNotEqualsIgnoreCase"
+	StringLike                ConditionOperator = "StringLike"
+	StringNotLike             ConditionOperator = "StringNotLike"
+	NumericEquals             ConditionOperator = "NumericEquals"
+	NumericNotEquals          ConditionOperator = "NumericNotEquals"
+	NumericLessThan           ConditionOperator = "NumericLessThan"
+	NumericLessThanEquals     ConditionOperator = "NumericLessThanEquals"
+	NumericGreaterThan        ConditionOperator = "NumericGreaterThan"
+	NumericGreaterThanEquals  ConditionOperator = "NumericGreaterThanEquals"
+	DateEquals                ConditionOperator = "DateEquals"
+	DateNotEquals             ConditionOperator = "DateNotEquals"
+	DateLessThan              ConditionOperator = "DateLessThan"
+	DateLessThanEquals        ConditionOperator = "DateLessThanEquals"
+	DateGreaterThan           ConditionOperator = "DateGreaterThan"
+	DateGreaterThanEquals     ConditionOperator = "DateGreaterThanEquals"
+	Bool                      ConditionOperator = "Bool"
+	IpAddress                 ConditionOperator = "IpAddress"
+	NotIpAddress              ConditionOperator = "NotIpAddress"
+	Null                      ConditionOperator = "Null"
+
+	// IfExistsSuffix is appended to the operator to match the missing key, such as StringLikeIfExists
+	IfExistsSuffix = "IfExists"
+)
+
+// The condition keys
+const (
+	KeySourceIP        = "acs:SourceIp"        // The IP of the client
+	KeyUserAgent       = "acs:UserAgent"       // The User-Agent header
+	KeyReferer         = "acs:Referer"         // The Referer header
+	KeyCurrentTime     = "acs:CurrentTime"     // The time of the request, in ISO 8601
+	KeySecureTransport = "acs:SecureTransport" // Whether the request is sent by HTTPS
+	KeyPrefix          = "ks3:prefix"          // The prefix of ListObjects
+	KeyDelimiter       = "ks3:delimiter"       // The delimiter of ListObjects
+	KeyMaxKeys         = "ks3:max-keys"        // The max-keys of ListObjects
+	KeyACL             = "ks3:x-kss-acl"       // The X-Kss-Acl header
+)
+
+// Condition maps the operator to the keys and their values
+type Condition map[ConditionOperator]map[string]StringList
+
+// New creates an empty document of the default version
+func New() *Document {
+	return &Document{Version: DefaultVersion}
+}
+
+// Parse parses the JSON of the document, such as the one returned by Client.GetBucketPolicy.
+// It doesn't validate the document, call Validate if needed.
+func Parse(data string) (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal([]byte(data), doc); err != nil {
+		return nil, fmt.Errorf("invalid policy document: %s", err.Error())
+	}
+	return doc, nil
+}
+
+// AddStatement appends the statements, nil statements are skipped
+func (d *Document) AddStatement(statements ...*Statement) *Document {
+	for _, s := range statements {
+		if s != nil {
+			d.Statement = append(d.Statement, *s)
+		}
+	}
+	return d
+}
+
+// JSON marshals the document to JSON
+func (d *Document) JSON() (string, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// NewStatement creates the statement with the effect
+func NewStatement(effect Effect) *Statement {
+	return &Statement{Effect: effect}
+}
+
+// WithSid sets the ID of the statement
+func (s *Statement) WithSid(sid string) *Statement {
+	s.Sid = sid
+	return s
+}
+
+// WithPrincipals adds the principals, see AnyPrincipal, AccountPrincipal and UserPrincipal
+func (s *Statement) WithPrincipals(principals ...string) *Statement {
+	if s.Principal == nil {
+		s.Principal = &Principal{}
+	}
+	s.Principal.KSC = append(s.Principal.KSC, principals...)
+	return s
+}
+
+// WithActions adds the actions, such as ActionGetObject or "ks3:Get*"
+func (s *Statement) WithActions(actions ...string) *Statement {
+	s.Action = append(s.Action, actions...)
+	return s
+}
+
+// WithNotActions adds the actions the statement doesn't apply to
+func (s *Statement) WithNotActions(actions ...string) *Statement {
+	s.NotAction = append(s.NotAction, actions...)
+	return s
+}
+
+// WithResources adds the resources, see BucketResource and ObjectResource
+func (s *Statement) WithResources(resources ...string) *Statement {
+	s.Resource = append(s.Resource, resources...)
+	return s
+}
+
+// WithNotResources adds the resources the statement doesn't apply to
+func (s *Statement) WithNotResources(resources ...string) *Statement {
+	s.NotResource = append(s.NotResource, resources...)
+	return s
+}
+
+// WithCondition adds the values of the key to the condition
+func (s *Statement) WithCondition(op ConditionOperator, key string, values ...string) *Statement {
+	if s.Condition == nil {
+		s.Condition = Condition{}
+	}
+	if s.Condition[op] == nil {
+		s.Condition[op] = map[string]StringList{}
+	}
+	s.Condition[op][key] = append(s.Condition[op][key], values...)
+	return s
+}
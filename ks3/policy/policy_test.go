@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Test hooks up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type Ks3PolicySuite struct{}
+
+var _ = Suite(&Ks3PolicySuite{})
+
+func (s *Ks3PolicySuite) TestParse(c *C) {
+	// The policy of the client tests, the principal and the actions are arrays
+	doc, err := Parse(`{
+	  "Statement": [
+		{
+		  "Effect": "Allow",
+		  "Action": ["ks3:ListBucket", "ks3:GetObject"],
+		  "Principal": {"KSC": ["*"]},
+		  "Resource": ["krn:ksc:ks3:::bucket", "krn:ksc:ks3:::bucket/*"],
+		  "Condition": {"IpAddress": {"acs:SourceIp": "10.0.0.0/8"}}
+		}
+	  ]
+	}`)
+	c.Assert(err, IsNil)
+	c.Assert(len(doc.Statement), Equals, 1)
+	st := doc.Statement[0]
+	c.Assert(st.Effect, Equals, Allow)
+	c.Assert(st.Principal.KSC, DeepEquals, StringList{"*"})
+	c.Assert(st.Action, DeepEquals, StringList{ActionListBucket, ActionGetObject})
+	c.Assert(st.Condition[IpAddress][KeySourceIP], DeepEquals, StringList{"10.0.0.0/8"})
+	c.Assert(doc.Validate(), IsNil)
+
+	// The single strings and the legacy resources
+	doc, err = Parse(`{"Version":"1","Statement":[{"Effect":"Deny","Action":"ks3:*","Principal":["123456"],
+		"Resource":"acs:ks3:*:*:bucket/*"}]}`)
+	c.Assert(err, IsNil)
+	st = doc.Statement[0]
+	c.Assert(st.Action, DeepEquals, StringList{ActionAll})
+	c.Assert(st.Principal.KSC, DeepEquals, StringList{"123456"})
+	c.Assert(st.Resource, DeepEquals, StringList{"acs:ks3:*:*:bucket/*"})
+	c.Assert(doc.Validate(), IsNil)
+
+	_, err = Parse(`{"Statement":[{"Action":1}]}`)
+	c.Assert(err, NotNil)
+	_, err = Parse(`not json`)
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3PolicySuite) TestBuild(c *C) {
+	doc := New().AddStatement(NewStatement(Allow).
+		WithSid("upload").
+		WithPrincipals(AccountPrincipal("1234567890"), UserPrincipal("1234567890", "alice")).
+		WithActions(ActionPutObject).
+		WithResources(ObjectResource("bucket", "uploads/*")).
+		WithCondition(StringEquals, KeyACL, "private"), nil)
+	out, err := doc.JSON()
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, `{"Version":"1","Statement":[{"Sid":"upload","Effect":"Allow",`+
+		`"Principal":{"KSC":["krn:ksc:iam::1234567890:root","krn:ksc:iam::1234567890:user/alice"]},`+
+		`"Action":["ks3:PutObject"],"Resource":["krn:ksc:ks3:::bucket/uploads/*"],`+
+		`"Condition":{"StringEquals":{"ks3:x-kss-acl":["private"]}}}]}`)
+	c.Assert(doc.Validate(), IsNil)
+
+	// Round trip
+	parsed, err := Parse(out)
+	c.Assert(err, IsNil)
+	c.Assert(parsed, DeepEquals, doc)
+}
+
+func (s *Ks3PolicySuite) TestGrants(c *C) {
+	doc := New().
+		AddStatement(ReadOnlyPrefix("bucket", "public/")...).
+		AddStatement(CrossAccountWrite("bucket", "logs/", "111", "222")...).
+		AddStatement(AllowFromIPs("bucket", []string{"192.168.0.0/16"})...).
+		AddStatement(DenyOutsideIPs("bucket", "10.0.0.0/8", "172.16.0.1")...)
+	c.Assert(doc.Validate(), IsNil)
+	c.Assert(len(doc.Statement), Equals, 5)
+
+	read, list := doc.Statement[0], doc.Statement[1]
+	c.Assert(read.Principal.KSC, DeepEquals, StringList{AnyPrincipal})
+	c.Assert(read.Action, DeepEquals, StringList{ActionGetObject})
+	c.Assert(read.Resource, DeepEquals, StringList{"krn:ksc:ks3:::bucket/public/*"})
+	c.Assert(list.Action, DeepEquals, StringList{ActionListBucket})
+	c.Assert(list.Resource, DeepEquals, StringList{"krn:ksc:ks3:::bucket"})
+	c.Assert(list.Condition[StringLike][KeyPrefix], DeepEquals, StringList{"public/*"})
+
+	write := doc.Statement[2]
+	c.Assert(write.Principal.KSC, DeepEquals, StringList{"krn:ksc:iam::111:root", "krn:ksc:iam::222:root"})
+	c.Assert(write.Resource, DeepEquals, StringList{"krn:ksc:ks3:::bucket/logs/*"})
+
+	c.Assert(doc.Statement[3].Condition[IpAddress][KeySourceIP], DeepEquals, StringList{"192.168.0.0/16"})
+	deny := doc.Statement[4]
+	c.Assert(deny.Effect, Equals, Deny)
+	c.Assert(deny.Action, DeepEquals, StringList{ActionAll})
+	c.Assert(deny.Condition[NotIpAddress][KeySourceIP], DeepEquals, StringList{"10.0.0.0/8", "172.16.0.1"})
+
+	// The whole bucket for the given principals
+	statements := ReadOnlyPrefix("bucket", "", "123")
+	c.Assert(statements[0].Principal.KSC, DeepEquals, StringList{"123"})
+	c.Assert(statements[1].Condition, IsNil)
+}
+
+func (s *Ks3PolicySuite) TestValidate(c *C) {
+	doc := New().AddStatement(
+		NewStatement(Allow).
+			WithPrincipals("*").
+			WithActions("ks3:GetObjet", "ks3:Get*", "ks3:Foo*").
+			WithResources("krn:ksc:ks3:::Bucket/*", "arn:aws:s3:::bucket", "krn:ksc:ks3:::/key").
+			WithCondition("StringMatches", "acs:UserAgent", "curl").
+			WithCondition(IpAddress+IfExistsSuffix, KeySourceIP, "10.0.0.300").
+			WithCondition(NumericLessThan, KeyMaxKeys, "ten"),
+		NewStatement("Permit").
+			WithPrincipals("alice").
+			WithActions(ActionGetObject).WithNotActions(ActionPutObject))
+	err := doc.Validate()
+	c.Assert(err, NotNil)
+	errs, ok := err.(ValidationErrors)
+	c.Assert(ok, Equals, true)
+
+	var problems []string
+	for _, e := range errs {
+		problems = append(problems, e.Field+" "+e.Value)
+	}
+	c.Assert(problems, DeepEquals, []string{
+		"Action ks3:GetObjet",
+		"Action ks3:Foo*",
+		"Resource krn:ksc:ks3:::Bucket/*",
+		"Resource arn:aws:s3:::bucket",
+		"Resource krn:ksc:ks3:::/key",
+		"Condition 10.0.0.300",
+		"Condition ten",
+		"Condition StringMatches",
+		"Effect Permit",
+		"Principal alice",
+		"Action ",
+		"Resource ",
+	})
+	c.Assert(errs[0].Statement, Equals, 0)
+	c.Assert(errs[len(errs)-1].Statement, Equals, 1)
+	c.Assert(errs[0].Error(), Equals, `policy: statement 0: Action "ks3:GetObjet": unknown action`)
+
+	c.Assert(New().Validate(), NotNil)
+	c.Assert((&Document{Version: "2", Statement: New().AddStatement(ReadOnlyPrefix("bucket", "")...).Statement}).Validate(), NotNil)
+
+	// The duplicate statement IDs
+	doc = New().AddStatement(ReadOnlyPrefix("bucket", "a/")...)
+	doc.Statement[0].Sid, doc.Statement[1].Sid = "read", "read"
+	c.Assert(doc.Validate(), ErrorMatches, `.*duplicate statement ID`)
+}
+
+func (s *Ks3PolicySuite) TestParseResource(c *C) {
+	r, err := ParseResource(ObjectResource("bucket", "dir/*"))
+	c.Assert(err, IsNil)
+	c.Assert(*r, Equals, Resource{Bucket: "bucket", Key: "dir/*", Object: true})
+
+	r, err = ParseResource(BucketResource("bucket"))
+	c.Assert(err, IsNil)
+	c.Assert(*r, Equals, Resource{Bucket: "bucket"})
+
+	r, err = ParseResource("acs:ks3:*:1234567890:*/*")
+	c.Assert(err, IsNil)
+	c.Assert(*r, Equals, Resource{Bucket: "*", Key: "*", Object: true})
+
+	for _, resource := range []string{"", "bucket/key", "krn:ksc:ks3:::", "acs:ks3:bucket", "krn:ksc:ks3:::bu_cket"} {
+		_, err = ParseResource(resource)
+		c.Assert(err, NotNil, Commentf(resource))
+	}
+}
+
+func (s *Ks3PolicySuite) TestMatchWildcard(c *C) {
+	c.Assert(matchWildcard("ks3:Get*", "ks3:GetObject", false), Equals, true)
+	c.Assert(matchWildcard("ks3:get*", "ks3:GetObject", true), Equals, true)
+	c.Assert(matchWildcard("ks3:get*", "ks3:GetObject", false), Equals, false)
+	c.Assert(matchWildcard("*a", "*ba", false), Equals, true)
+	c.Assert(matchWildcard("dir/?.txt", "dir/中.txt", false), Equals, true)
+	c.Assert(matchWildcard("a*b*c", "aXbYbZc", false), Equals, true)
+	c.Assert(matchWildcard("a*b*c", "aXbYbZ", false), Equals, false)
+	c.Assert(matchWildcard("", "", false), Equals, true)
+}
+
+func (s *Ks3PolicySuite) TestStringList(c *C) {
+	var l StringList
+	c.Assert(json.Unmarshal([]byte(`"a"`), &l), IsNil)
+	c.Assert(l, DeepEquals, StringList{"a"})
+	c.Assert(json.Unmarshal([]byte(`["a","b"]`), &l), IsNil)
+	c.Assert(l, DeepEquals, StringList{"a", "b"})
+	c.Assert(json.Unmarshal([]byte(`{}`), &l), NotNil)
+
+	var p Principal
+	c.Assert(json.Unmarshal([]byte(`"*"`), &p), IsNil)
+	c.Assert(p.KSC, DeepEquals, StringList{"*"})
+}
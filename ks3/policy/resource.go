@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The actions of KS3, the wildcards such as "ks3:*" and "ks3:Get*" are accepted too
+const (
+	ActionAll = "ks3:*"
+
+	ActionListBucket                 = "ks3:ListBucket"
+	ActionListBucketMultipartUploads = "ks3:ListBucketMultipartUploads"
+	ActionGetBucketAcl               = "ks3:GetBucketAcl"
+	ActionPutBucketAcl               = "ks3:PutBucketAcl"
+	ActionGetBucketPolicy            = "ks3:GetBucketPolicy"
+	ActionPutBucketPolicy            = "ks3:PutBucketPolicy"
+	ActionDeleteBucketPolicy         = "ks3:DeleteBucketPolicy"
+	ActionGetBucketCORS              = "ks3:GetBucketCORS"
+	ActionPutBucketCORS              = "ks3:PutBucketCORS"
+	ActionGetBucketLogging           = "ks3:GetBucketLogging"
+	ActionPutBucketLogging           = "ks3:PutBucketLogging"
+	ActionGetBucketLifecycle         = "ks3:GetBucketLifecycle"
+	ActionPutBucketLifecycle         = "ks3:PutBucketLifecycle"
+	ActionGetBucketWebsite           = "ks3:GetBucketWebsite"
+	ActionPutBucketWebsite           = "ks3:PutBucketWebsite"
+	ActionGetBucketLocation          = "ks3:GetBucketLocation"
+	ActionDeleteBucket               = "ks3:DeleteBucket"
+
+	ActionGetObject                = "ks3:GetObject"
+	ActionGetObjectAcl             = "ks3:GetObjectAcl"
+	ActionPutObject                = "ks3:PutObject"
+	ActionPutObjectAcl             = "ks3:PutObjectAcl"
+	ActionDeleteObject             = "ks3:DeleteObject"
+	ActionRestoreObject            = "ks3:RestoreObject"
+	ActionListMultipartUploadParts = "ks3:ListMultipartUploadParts"
+	ActionAbortMultipartUpload     = "ks3:AbortMultipartUpload"
+)
+
+// Actions is the list of the known actions, the validator rejects the ones not in it
+var Actions = []string{
+	ActionListBucket,
+	ActionListBucketMultipartUploads,
+	ActionGetBucketAcl,
+	ActionPutBucketAcl,
+	ActionGetBucketPolicy,
+	ActionPutBucketPolicy,
+	ActionDeleteBucketPolicy,
+	ActionGetBucketCORS,
+	ActionPutBucketCORS,
+	ActionGetBucketLogging,
+	ActionPutBucketLogging,
+	ActionGetBucketLifecycle,
+	ActionPutBucketLifecycle,
+	ActionGetBucketWebsite,
+	ActionPutBucketWebsite,
+	ActionGetBucketLocation,
+	ActionDeleteBucket,
+	ActionGetObject,
+	ActionGetObjectAcl,
+	ActionPutObject,
+	ActionPutObjectAcl,
+	ActionDeleteObject,
+	ActionRestoreObject,
+	ActionListMultipartUploadParts,
+	ActionAbortMultipartUpload,
+}
+
+const (
+	resourcePrefix       = "krn:ksc:ks3:::"
+	legacyResourcePrefix = "acs:ks3:"
+	principalPrefix      = "krn:ksc:iam::"
+)
+
+// AnyPrincipal is the principal of everyone, including the anonymous users
+const AnyPrincipal = "*"
+
+// AccountPrincipal returns the KRN of the account, such as krn:ksc:iam::1234567890:root
+func AccountPrincipal(accountID string) string {
+	return principalPrefix + accountID + ":root"
+}
+
+// UserPrincipal returns the KRN of the user of the account, such as krn:ksc:iam::1234567890:user/alice
+func UserPrincipal(accountID, userName string) string {
+	return principalPrefix + accountID + ":user/" + userName
+}
+
+// BucketResource returns the KRN of the bucket, such as krn:ksc:ks3:::bucket
+func BucketResource(bucket string) string {
+	return resourcePrefix + bucket
+}
+
+// ObjectResource returns the KRN of the objects, the key may contain the wildcards, such as krn:ksc:ks3:::bucket/dir/*
+func ObjectResource(bucket, key string) string {
+	return resourcePrefix + bucket + "/" + key
+}
+
+// Resource is the parsed KRN of the bucket or the objects
+type Resource struct {
+	Bucket string // The bucket, it may contain the wildcards
+	Key    string // The key of the objects, it may contain the wildcards
+	Object bool   // True if it's the resource of the objects, even if Key is empty
+}
+
+// ParseResource parses the KRN of the bucket or the objects.
+// Both krn:ksc:ks3:::bucket/key and the legacy acs:ks3:region:account:bucket/key are accepted.
+func ParseResource(resource string) (*Resource, error) {
+	var rest string
+	switch {
+	case resource == "*":
+		return &Resource{Bucket: "*", Key: "*", Object: true}, nil
+	case strings.HasPrefix(resource, resourcePrefix):
+		rest = resource[len(resourcePrefix):]
+	case strings.HasPrefix(resource, legacyResourcePrefix):
+		// acs:ks3:<region>:<account>:<bucket>/<key>
+		parts := strings.SplitN(resource[len(legacyResourcePrefix):], ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("malformed resource %q, it should be acs:ks3:<region>:<account>:<bucket>[/<key>]", resource)
+		}
+		rest = parts[2]
+	default:
+		return nil, fmt.Errorf("malformed resource %q, it should be %s<bucket>[/<key>]", resource, resourcePrefix)
+	}
+
+	r := &Resource{Bucket: rest}
+	if pos := strings.Index(rest, "/"); pos >= 0 {
+		r.Bucket, r.Key, r.Object = rest[:pos], rest[pos+1:], true
+	}
+	if err := checkBucketPattern(r.Bucket); err != nil {
+		return nil, fmt.Errorf("malformed resource %q: %s", resource, err.Error())
+	}
+	return r, nil
+}
+
+// checkBucketPattern checks the bucket name of the resource, which may contain the wildcards
+func checkBucketPattern(bucket string) error {
+	if bucket == "" {
+		return fmt.Errorf("the bucket is empty")
+	}
+	if len(bucket) > 63 {
+		return fmt.Errorf("the bucket is longer than 63 characters")
+	}
+	for _, c := range bucket {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '*' || c == '?') {
+			return fmt.Errorf("invalid character %q in the bucket", c)
+		}
+	}
+	return nil
+}
+
+// knownAction reports whether the action, which may contain the wildcards, matches any known action
+func knownAction(action string) bool {
+	if !strings.HasPrefix(action, "ks3:") {
+		return action == "*"
+	}
+	for _, known := range Actions {
+		if matchWildcard(action, known, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard reports whether the value matches the pattern, * matches any characters and ? matches one character
+func matchWildcard(pattern, value string, ignoreCase bool) bool {
+	if ignoreCase {
+		pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+	}
+	ps, vs := []rune(pattern), []rune(value)
+	// The positions to retry when the last * matches one more character
+	star, retry := -1, 0
+	p, v := 0, 0
+	for v < len(vs) {
+		switch {
+		case p < len(ps) && ps[p] == '*':
+			star, retry = p, v
+			p++
+		case p < len(ps) && (ps[p] == '?' || ps[p] == vs[v]):
+			p++
+			v++
+		case star >= 0:
+			retry++
+			p, v = star+1, retry
+		default:
+			return false
+		}
+	}
+	for p < len(ps) && ps[p] == '*' {
+		p++
+	}
+	return p == len(ps)
+}
@@ -0,0 +1,233 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError is a problem of the document found by Validate
+type ValidationError struct {
+	Statement int    // The index of the statement, -1 for the document
+	Field     string // The field of the statement, such as Action and Resource
+	Value     string // The invalid value
+	Message   string
+}
+
+// Error implements interface error
+func (e *ValidationError) Error() string {
+	if e.Statement < 0 {
+		return fmt.Sprintf("policy: %s %q: %s", e.Field, e.Value, e.Message)
+	}
+	return fmt.Sprintf("policy: statement %d: %s %q: %s", e.Statement, e.Field, e.Value, e.Message)
+}
+
+// ValidationErrors is all the problems of the document
+type ValidationErrors []*ValidationError
+
+// Error implements interface error
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+var (
+	accountIDRegexp = regexp.MustCompile(`^[0-9]+$`)
+	principalRegexp = regexp.MustCompile(`^krn:ksc:iam::[0-9]+:(root|user/.+|role/.+)$`)
+)
+
+// conditionOperators is the known operators without the IfExists suffix
+var conditionOperators = map[ConditionOperator]bool{
+	StringEquals: true, StringNotEquals: true, StringEqualsIgnoreCase: true, StringNotEqualsIgnoreCase: true,
+	StringLike: true, StringNotLike: true,
+	NumericEquals: true, NumericNotEquals: true, NumericLessThan: true, NumericLessThanEquals: true,
+	NumericGreaterThan: true, NumericGreaterThanEquals: true,
+	DateEquals: true, DateNotEquals: true, DateLessThan: true, DateLessThanEquals: true,
+	DateGreaterThan: true, DateGreaterThanEquals: true,
+	Bool: true, IpAddress: true, NotIpAddress: true, Null: true,
+}
+
+// baseOperator strips the IfExists suffix, ok is false if the operator is unknown
+func baseOperator(op ConditionOperator) (base ConditionOperator, ifExists bool, ok bool) {
+	base = op
+	if strings.HasSuffix(string(op), IfExistsSuffix) {
+		base, ifExists = ConditionOperator(strings.TrimSuffix(string(op), IfExistsSuffix)), true
+		if base == Null {
+			return base, ifExists, false
+		}
+	}
+	return base, ifExists, conditionOperators[base]
+}
+
+// Validate checks the document as the server does before it's set, the unknown actions, the malformed resources,
+// principals and conditions and the missing fields are reported. It returns ValidationErrors if there's any problem.
+func (d *Document) Validate() error {
+	var errs ValidationErrors
+	add := func(index int, field, value, format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{Statement: index, Field: field, Value: value, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if d.Version != "" && d.Version != DefaultVersion && d.Version != "2012-10-17" {
+		add(-1, "Version", d.Version, "unsupported version")
+	}
+	if len(d.Statement) == 0 {
+		add(-1, "Statement", "", "no statement")
+	}
+
+	sids := map[string]bool{}
+	for i, s := range d.Statement {
+		if s.Sid != "" {
+			if sids[s.Sid] {
+				add(i, "Sid", s.Sid, "duplicate statement ID")
+			}
+			sids[s.Sid] = true
+		}
+		if s.Effect != Allow && s.Effect != Deny {
+			add(i, "Effect", string(s.Effect), "the effect must be Allow or Deny")
+		}
+
+		if s.Principal == nil || len(s.Principal.KSC) == 0 {
+			add(i, "Principal", "", "no principal")
+		} else {
+			for _, p := range s.Principal.KSC {
+				if p != AnyPrincipal && !accountIDRegexp.MatchString(p) && !principalRegexp.MatchString(p) {
+					add(i, "Principal", p, "the principal must be *, the account ID or the KRN such as %s", AccountPrincipal("1234567890"))
+				}
+			}
+		}
+
+		if len(s.Action) == 0 && len(s.NotAction) == 0 {
+			add(i, "Action", "", "either Action or NotAction is required")
+		} else if len(s.Action) > 0 && len(s.NotAction) > 0 {
+			add(i, "Action", "", "Action and NotAction are exclusive")
+		}
+		for _, actions := range []StringList{s.Action, s.NotAction} {
+			for _, a := range actions {
+				if !knownAction(a) {
+					add(i, "Action", a, "unknown action")
+				}
+			}
+		}
+
+		if len(s.Resource) == 0 && len(s.NotResource) == 0 {
+			add(i, "Resource", "", "either Resource or NotResource is required")
+		} else if len(s.Resource) > 0 && len(s.NotResource) > 0 {
+			add(i, "Resource", "", "Resource and NotResource are exclusive")
+		}
+		for _, resources := range []StringList{s.Resource, s.NotResource} {
+			for _, r := range resources {
+				if _, err := ParseResource(r); err != nil {
+					add(i, "Resource", r, "%s", err.Error())
+				}
+			}
+		}
+
+		for _, op := range s.Condition.operators() {
+			base, _, ok := baseOperator(op)
+			if !ok {
+				add(i, "Condition", string(op), "unknown operator")
+				continue
+			}
+			keys := s.Condition[op]
+			for _, key := range sortedKeys(keys) {
+				values := keys[key]
+				if key == "" {
+					add(i, "Condition", string(op), "empty key")
+				}
+				if len(values) == 0 {
+					add(i, "Condition", key, "no value of operator %s", op)
+				}
+				for _, v := range values {
+					if err := checkConditionValue(base, v); err != nil {
+						add(i, "Condition", v, "%s of key %s: %s", op, key, err.Error())
+					}
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// operators returns the operators of the condition in order
+func (c Condition) operators() []ConditionOperator {
+	ops := make([]ConditionOperator, 0, len(c))
+	for op := range c {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	return ops
+}
+
+// sortedKeys returns the keys of the condition operator in order
+func sortedKeys(keys map[string]StringList) []string {
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkConditionValue checks the value is valid for the operator
+func checkConditionValue(op ConditionOperator, value string) error {
+	switch {
+	case strings.HasPrefix(string(op), "Numeric"):
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("not a number")
+		}
+	case strings.HasPrefix(string(op), "Date"):
+		if _, err := parseDate(value); err != nil {
+			return err
+		}
+	case op == Bool || op == Null:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("not a boolean")
+		}
+	case op == IpAddress || op == NotIpAddress:
+		if _, err := parseCIDR(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDate parses the date in ISO 8601 or the unix time in seconds
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("not a date in ISO 8601")
+}
+
+// parseCIDR parses the CIDR or the single IP
+func parseCIDR(value string) (*net.IPNet, error) {
+	if _, ipNet, err := net.ParseCIDR(value); err == nil {
+		return ipNet, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("not an IP or CIDR")
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
@@ -0,0 +1,132 @@
+package ks3
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultPollInterval is the interval of PollPolicy if it's not set
+const DefaultPollInterval = 5 * time.Second
+
+// PollPolicy is how to poll an operation which finishes asynchronously. The zero value polls every
+// DefaultPollInterval until the context is done.
+type PollPolicy struct {
+	Interval    time.Duration // The first interval between the polls
+	Multiplier  float64       // The interval is multiplied by it after each poll, it doesn't grow if Multiplier <= 1
+	MaxInterval time.Duration // The interval grows up to it, no limit if it's 0
+	MaxDuration time.Duration // The max time to wait, no limit but the context if it's 0
+}
+
+// WaitOutcome is the state of a wait
+type WaitOutcome string
+
+const (
+	WaitPending   WaitOutcome = "Pending"   // The operation isn't finished, keep polling
+	WaitSucceeded WaitOutcome = "Succeeded" // The operation reached the desired state
+	WaitFailed    WaitOutcome = "Failed"    // The operation reached a state it won't leave, such as a failed task
+	WaitTimedOut  WaitOutcome = "TimedOut"  // PollPolicy.MaxDuration passed
+	WaitCanceled  WaitOutcome = "Canceled"  // The context was canceled or its deadline passed
+)
+
+// WaitError is the error of a wait which doesn't succeed
+type WaitError struct {
+	Waiter   string      // What's waited for, such as BucketExists
+	Outcome  WaitOutcome // WaitFailed, WaitTimedOut or WaitCanceled
+	Attempts int         // The number of the polls
+	Elapsed  time.Duration
+	Err      error // The reason of the failure, or the error of the context
+}
+
+// Error implements interface error
+func (e *WaitError) Error() string {
+	return fmt.Sprintf("ks3: wait until %s %s after %d attempts in %s: %s",
+		e.Waiter, e.Outcome, e.Attempts, e.Elapsed.Round(time.Millisecond), e.Err.Error())
+}
+
+// Wait polls check by the policy until it returns an outcome other than WaitPending. check returns WaitFailed
+// with the reason as the error for the terminal failures, the other errors end the wait and are returned as is.
+//
+// ctx    the context to cancel the wait, it's passed to check.
+// waiter    what's waited for in the errors, such as BucketExists.
+// policy    how to poll.
+// check    polls the operation once.
+//
+// error    it's nil if the operation succeeded, *WaitError if it failed, timed out or was canceled,
+//          otherwise it's the error of check.
+//
+func Wait(ctx context.Context, waiter string, policy PollPolicy, check func(ctx context.Context) (WaitOutcome, error)) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := time.Now()
+	attempts := 0
+	var failure error
+	err := policy.poll(ctx, func(ctx context.Context) (bool, error) {
+		attempts++
+		outcome, err := check(ctx)
+		if outcome == WaitFailed {
+			failure = err
+			if failure == nil {
+				failure = fmt.Errorf("the operation failed")
+			}
+			return true, nil
+		}
+		return outcome == WaitSucceeded, err
+	})
+
+	waitError := func(outcome WaitOutcome, err error) error {
+		return &WaitError{Waiter: waiter, Outcome: outcome, Attempts: attempts, Elapsed: time.Since(start), Err: err}
+	}
+	switch {
+	case failure != nil:
+		return waitError(WaitFailed, failure)
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return waitError(WaitCanceled, ctx.Err())
+	case err == context.DeadlineExceeded:
+		return waitError(WaitTimedOut, err)
+	}
+	return err
+}
+
+// poll calls fn until it's done or fails, the error is the one of fn, or the one of the context if it's done
+// or MaxDuration passes, which is context.DeadlineExceeded
+func (policy PollPolicy) poll(ctx context.Context, fn func(ctx context.Context) (bool, error)) error {
+	if policy.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.MaxDuration)
+		defer cancel()
+	}
+
+	interval := policy.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	for {
+		done, err := fn(ctx)
+		if err != nil && ctx.Err() != nil {
+			// The request failed since the context is done
+			return ctx.Err()
+		}
+		if err != nil || done {
+			return err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if policy.Multiplier > 1 {
+			interval = time.Duration(float64(interval) * policy.Multiplier)
+			if policy.MaxInterval > 0 && interval > policy.MaxInterval {
+				interval = policy.MaxInterval
+			}
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package ks3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Form fields of the browser-based POST upload
+const (
+	PostFieldKey                   = "key"
+	PostFieldAccessKeyID           = "KSSAccessKeyId"
+	PostFieldPolicy                = "Policy"
+	PostFieldSignature             = "Signature"
+	PostFieldSecurityToken         = "x-kss-security-token"
+	PostFieldACL                   = "acl"
+	PostFieldContentType           = "Content-Type"
+	PostFieldSuccessActionStatus   = "success_action_status"
+	PostFieldSuccessActionRedirect = "success_action_redirect"
+	PostFieldFile                  = "file"
+
+	// PostFileNameVariable is replaced by the name of the uploaded file on the server
+	PostFileNameVariable = "${filename}"
+)
+
+const postPolicyTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// PostPolicy builds the policy of the browser-based POST upload. Every setter adds a condition to the policy, the exact
+// match ones and SetKeyStartsWith add a form field too. The errors of the setters are returned by SignPostPolicy.
+type PostPolicy struct {
+	expiration time.Time
+	conditions []interface{}
+	fields     map[string]string
+	hasKey     bool
+	typePrefix string // The prefix of SetContentTypeStartsWith
+	err        error
+}
+
+// NewPostPolicy creates the policy which expires at the expiration
+func NewPostPolicy(expiration time.Time) *PostPolicy {
+	return &PostPolicy{expiration: expiration, fields: map[string]string{}}
+}
+
+func (p *PostPolicy) setError(err error) *PostPolicy {
+	if p.err == nil {
+		p.err = err
+	}
+	return p
+}
+
+// addEq adds the exact match condition and the form field
+func (p *PostPolicy) addEq(field, value string) *PostPolicy {
+	p.conditions = append(p.conditions, []string{"eq", "$" + field, value})
+	p.fields[field] = value
+	return p
+}
+
+// SetKey requires the object key to be the key
+func (p *PostPolicy) SetKey(key string) *PostPolicy {
+	if key == "" {
+		return p.setError(fmt.Errorf("ks3: post policy key is empty"))
+	}
+	p.hasKey = true
+	return p.addEq(PostFieldKey, key)
+}
+
+// SetKeyStartsWith requires the object key to start with the prefix, the key field is prefix+${filename}
+func (p *PostPolicy) SetKeyStartsWith(prefix string) *PostPolicy {
+	p.hasKey = true
+	p.conditions = append(p.conditions, []string{"starts-with", "$" + PostFieldKey, prefix})
+	p.fields[PostFieldKey] = prefix + PostFileNameVariable
+	return p
+}
+
+// SetContentLengthRange limits the size of the uploaded file in [min, max] bytes
+func (p *PostPolicy) SetContentLengthRange(min, max int64) *PostPolicy {
+	if min < 0 || max < min {
+		return p.setError(fmt.Errorf("ks3: invalid post policy content length range [%d, %d]", min, max))
+	}
+	p.conditions = append(p.conditions, []interface{}{"content-length-range", min, max})
+	return p
+}
+
+// SetContentType requires the Content-Type of the object to be the contentType
+func (p *PostPolicy) SetContentType(contentType string) *PostPolicy {
+	return p.addEq(PostFieldContentType, contentType)
+}
+
+// SetContentTypeStartsWith requires the Content-Type of the object to start with the prefix, such as image/.
+// No form field is added, the uploader sets the Content-Type field of the actual type.
+func (p *PostPolicy) SetContentTypeStartsWith(prefix string) *PostPolicy {
+	p.conditions = append(p.conditions, []string{"starts-with", "$" + PostFieldContentType, prefix})
+	p.typePrefix = prefix
+	return p
+}
+
+// SetACL requires the ACL of the object to be the acl
+func (p *PostPolicy) SetACL(acl ACLType) *PostPolicy {
+	return p.addEq(PostFieldACL, string(acl))
+}
+
+// SetSuccessActionStatus sets the status code returned on success, it's one of 200, 201 and 204
+func (p *PostPolicy) SetSuccessActionStatus(status int) *PostPolicy {
+	if status != http.StatusOK && status != http.StatusCreated && status != http.StatusNoContent {
+		return p.setError(fmt.Errorf("ks3: invalid success_action_status %d, it must be 200, 201 or 204", status))
+	}
+	return p.addEq(PostFieldSuccessActionStatus, strconv.Itoa(status))
+}
+
+// SetSuccessActionRedirect sets the URL which the browser is redirected to on success
+func (p *PostPolicy) SetSuccessActionRedirect(redirect string) *PostPolicy {
+	return p.addEq(PostFieldSuccessActionRedirect, redirect)
+}
+
+// SetMeta requires the user meta of the object, the field is x-kss-meta-key
+func (p *PostPolicy) SetMeta(key, value string) *PostPolicy {
+	return p.addEq(strings.ToLower(HTTPHeaderKs3MetaPrefix+key), value)
+}
+
+// PostPolicyForm is the signed form of the browser-based POST upload
+type PostPolicyForm struct {
+	URL               string            // The action of the form
+	Fields            map[string]string // The fields of the form except the file
+	Policy            string            // The policy document in JSON
+	Expiration        time.Time         // The expiration of the policy
+	ContentTypePrefix string            // The prefix of SetContentTypeStartsWith, the Content-Type field is set by the uploader
+}
+
+// SignPostPolicy signs the policy of the browser-based POST upload.
+// The policy is signed by HMAC-SHA1 whatever the auth version is, and the security token of the STS credentials is added.
+//
+// policy    the policy built by NewPostPolicy.
+//
+// *PostPolicyForm    the action URL and the form fields.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) SignPostPolicy(policy *PostPolicy) (*PostPolicyForm, error) {
+	if policy == nil {
+		return nil, fmt.Errorf("ks3: post policy is nil")
+	}
+	if policy.err != nil {
+		return nil, policy.err
+	}
+	if !policy.hasKey {
+		return nil, fmt.Errorf("ks3: post policy must set the key by SetKey or SetKeyStartsWith")
+	}
+	if !policy.expiration.After(time.Now()) {
+		return nil, fmt.Errorf("ks3: post policy has expired at %s", policy.expiration.UTC().Format(time.RFC3339))
+	}
+
+	akIf, err := bucket.Client.Config.getCredentials()
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	for k, v := range policy.fields {
+		fields[k] = v
+	}
+	conditions := append([]interface{}{map[string]string{"bucket": bucket.BucketName}}, policy.conditions...)
+	if token := akIf.GetSecurityToken(); token != "" {
+		conditions = append(conditions, map[string]string{PostFieldSecurityToken: token})
+		fields[PostFieldSecurityToken] = token
+	}
+
+	doc, err := json.Marshal(struct {
+		Expiration string        `json:"expiration"`
+		Conditions []interface{} `json:"conditions"`
+	}{policy.expiration.UTC().Format(postPolicyTimeFormat), conditions})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(doc)
+	h := hmac.New(func() hash.Hash { return sha1.New() }, []byte(akIf.GetAccessKeySecret()))
+	io.WriteString(h, encoded)
+
+	fields[PostFieldAccessKeyID] = akIf.GetAccessKeyID()
+	fields[PostFieldPolicy] = encoded
+	fields[PostFieldSignature] = base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return &PostPolicyForm{
+		URL:               bucket.Client.Conn.Url.getURL(bucket.BucketName, "", "").String(),
+		Fields:            fields,
+		Policy:            string(doc),
+		Expiration:        policy.expiration,
+		ContentTypePrefix: policy.typePrefix,
+	}, nil
+}
+
+// PostObjectResult is the result of the browser-based POST upload
+type PostObjectResult struct {
+	XMLName    xml.Name    `xml:"PostResponse"`
+	StatusCode int         `xml:"-"`
+	Headers    http.Header `xml:"-"`
+	Location   string      `xml:"Location"` // The URL of the object, or the redirect URL when success_action_redirect is set
+	Bucket     string      `xml:"Bucket"`
+	Key        string      `xml:"Key"`
+	ETag       string      `xml:"ETag"`
+}
+
+// PostObject uploads the object through the signed form, the same as a browser does. If the policy requires
+// the prefix of the Content-Type and the form has no Content-Type field, it's detected by the file name.
+//
+// form    the form signed by SignPostPolicy.
+// key    the object key, it replaces the key field if it's not empty.
+// reader    the data of the file.
+//
+// *PostObjectResult    the result of the upload.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) PostObject(form *PostPolicyForm, key string, reader io.Reader) (*PostObjectResult, error) {
+	return PostObjectWithForm(bucket.Client.Conn.client, form, key, reader)
+}
+
+// PostObjectWithForm uploads the object through the signed form by the HTTP client, it's for the server to server upload
+// without the credentials. The default client is used if httpClient is nil, and the redirect is not followed.
+func PostObjectWithForm(httpClient *http.Client, form *PostPolicyForm, key string, reader io.Reader) (*PostObjectResult, error) {
+	if form == nil {
+		return nil, fmt.Errorf("ks3: post policy form is nil")
+	}
+	fields := map[string]string{}
+	for k, v := range form.Fields {
+		fields[k] = v
+	}
+	if key != "" {
+		fields[PostFieldKey] = key
+	}
+	// The file name replaces ${filename} in the key on the server
+	fileName := PostFieldFile
+	if k := fields[PostFieldKey]; k != "" && !strings.Contains(k, PostFileNameVariable) && !strings.HasSuffix(k, "/") {
+		fileName = path.Base(k)
+	}
+	if _, ok := fields[PostFieldContentType]; !ok && form.ContentTypePrefix != "" {
+		if contentType := TypeByExtension(fileName); contentType != "" {
+			fields[PostFieldContentType] = contentType
+		}
+	}
+
+	body, contentType, contentLength, err := postFormBody(fields, fileName, reader)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, form.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(HTTPHeaderContentType, contentType)
+	req.ContentLength = contentLength
+
+	client := http.Client{}
+	if httpClient != nil {
+		client = *httpClient
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := readResponseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PostObjectResult{StatusCode: resp.StatusCode, Headers: resp.Header}
+	switch {
+	case resp.StatusCode/100 == 3:
+		result.Location = resp.Header.Get(HTTPHeaderLocation)
+		return result, nil
+	case resp.StatusCode/100 != 2:
+		srvErr, errIn := serviceErrFromXML(data, resp.StatusCode, resp.Header.Get(HTTPHeaderKs3RequestID))
+		if errIn != nil || len(data) == 0 {
+			return result, ServiceError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get(HTTPHeaderKs3RequestID)}
+		}
+		return result, srvErr
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err = xml.Unmarshal(data, result); err != nil {
+			return result, err
+		}
+	}
+	if result.ETag == "" {
+		result.ETag = resp.Header.Get(HTTPHeaderEtag)
+	}
+	if result.Location == "" {
+		result.Location = resp.Header.Get(HTTPHeaderLocation)
+	}
+	return result, nil
+}
+
+// postFormBody builds the multipart body whose file is the last part, the file is streamed instead of being buffered.
+// The content length is -1 if the length of the reader is unknown.
+func postFormBody(fields map[string]string, fileName string, reader io.Reader) (io.Reader, string, int64, error) {
+	var head bytes.Buffer
+	writer := multipart.NewWriter(&head)
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := writer.WriteField(k, fields[k]); err != nil {
+			return nil, "", 0, err
+		}
+	}
+
+	partHeader := textproto.MIMEHeader{}
+	partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, PostFieldFile,
+		strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(fileName)))
+	if contentType, ok := fields[PostFieldContentType]; ok {
+		partHeader.Set(HTTPHeaderContentType, contentType)
+	} else {
+		partHeader.Set(HTTPHeaderContentType, "application/octet-stream")
+	}
+	if _, err := writer.CreatePart(partHeader); err != nil {
+		return nil, "", 0, err
+	}
+	contentType := writer.FormDataContentType()
+
+	// The same as multipart.Writer.Close, the closing boundary starts with the line break of the file part
+	closing := fmt.Sprintf("\r\n--%s--\r\n", writer.Boundary())
+
+	var contentLength int64 = -1
+	if size, err := GetReaderLen(reader); err == nil {
+		contentLength = int64(head.Len()) + size + int64(len(closing))
+	}
+	return io.MultiReader(&head, reader, strings.NewReader(closing)), contentType, contentLength, nil
+}
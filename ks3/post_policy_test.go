@@ -0,0 +1,231 @@
+package ks3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3PostPolicySuite struct {
+	server *httptest.Server
+	fields map[string]string
+	file   string
+	name   string
+	typ    string // The Content-Type of the file part
+	length int64
+	status int
+}
+
+var _ = Suite(&Ks3PostPolicySuite{})
+
+func (s *Ks3PostPolicySuite) SetUpSuite(c *C) {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.length = r.ContentLength
+		s.fields = map[string]string{}
+		if err := r.ParseMultipartForm(1 << 20); err == nil {
+			for k := range r.MultipartForm.Value {
+				s.fields[k] = r.MultipartForm.Value[k][0]
+			}
+			if files := r.MultipartForm.File[PostFieldFile]; len(files) > 0 {
+				s.name = files[0].Filename
+				s.typ = files[0].Header.Get(HTTPHeaderContentType)
+				f, _ := files[0].Open()
+				data, _ := ioutil.ReadAll(f)
+				f.Close()
+				s.file = string(data)
+			}
+		}
+
+		switch s.status {
+		case http.StatusSeeOther:
+			w.Header().Set(HTTPHeaderLocation, s.fields[PostFieldSuccessActionRedirect]+"?etag=abc")
+			w.WriteHeader(http.StatusSeeOther)
+		case http.StatusForbidden:
+			w.Header().Set(HTTPHeaderKs3RequestID, "request-id")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "<Error><Code>AccessDenied</Code><Message>Invalid according to Policy</Message></Error>")
+		default:
+			w.Header().Set(HTTPHeaderEtag, `"abc"`)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "<PostResponse><Location>http://post-bucket/%s</Location><Bucket>post-bucket</Bucket>"+
+				"<Key>%s</Key><ETag>\"abc\"</ETag></PostResponse>", s.fields[PostFieldKey], s.fields[PostFieldKey])
+		}
+	}))
+}
+
+func (s *Ks3PostPolicySuite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3PostPolicySuite) SetUpTest(c *C) {
+	s.status = http.StatusCreated
+}
+
+func (s *Ks3PostPolicySuite) newBucket(c *C, options ...ClientOption) *Bucket {
+	addr := s.server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	options = append([]ClientOption{HTTPClient(&http.Client{Transport: transport})}, options...)
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", options...)
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("post-bucket")
+	c.Assert(err, IsNil)
+	return bucket
+}
+
+func (s *Ks3PostPolicySuite) TestSignPostPolicy(c *C) {
+	bucket := s.newBucket(c)
+	expiration := time.Date(2099, 1, 2, 3, 4, 5, 0, time.UTC)
+	policy := NewPostPolicy(expiration).
+		SetKeyStartsWith("uploads/").
+		SetContentLengthRange(1, 1024).
+		SetContentType("text/plain").
+		SetACL(ACLPublicRead).
+		SetSuccessActionStatus(201).
+		SetMeta("Owner", "alice")
+	form, err := bucket.SignPostPolicy(policy)
+	c.Assert(err, IsNil)
+
+	c.Assert(form.URL, Equals, "http://post-bucket.ks3-cn-beijing.ksyuncs.com/")
+	c.Assert(form.Expiration, Equals, expiration)
+	c.Assert(form.Fields, DeepEquals, map[string]string{
+		"key":                   "uploads/${filename}",
+		"Content-Type":          "text/plain",
+		"acl":                   "public-read",
+		"success_action_status": "201",
+		"x-kss-meta-owner":      "alice",
+		"KSSAccessKeyId":        "ak",
+		"Policy":                form.Fields[PostFieldPolicy],
+		"Signature":             form.Fields[PostFieldSignature],
+	})
+	c.Assert(form.Policy, Equals, `{"expiration":"2099-01-02T03:04:05.000Z","conditions":[{"bucket":"post-bucket"},`+
+		`["starts-with","$key","uploads/"],["content-length-range",1,1024],["eq","$Content-Type","text/plain"],`+
+		`["eq","$acl","public-read"],["eq","$success_action_status","201"],["eq","$x-kss-meta-owner","alice"]]}`)
+
+	decoded, err := base64.StdEncoding.DecodeString(form.Fields[PostFieldPolicy])
+	c.Assert(err, IsNil)
+	c.Assert(string(decoded), Equals, form.Policy)
+	h := hmac.New(sha1.New, []byte("sk"))
+	h.Write([]byte(form.Fields[PostFieldPolicy]))
+	c.Assert(form.Fields[PostFieldSignature], Equals, base64.StdEncoding.EncodeToString(h.Sum(nil)))
+}
+
+func (s *Ks3PostPolicySuite) TestSignPostPolicyWithToken(c *C) {
+	bucket := s.newBucket(c, SetCredentialsProvider(NewStaticCredentialsProvider("sts-ak", "sts-sk", "token")))
+	form, err := bucket.SignPostPolicy(NewPostPolicy(time.Now().Add(time.Hour)).SetKey("object"))
+	c.Assert(err, IsNil)
+	c.Assert(form.Fields[PostFieldAccessKeyID], Equals, "sts-ak")
+	c.Assert(form.Fields[PostFieldSecurityToken], Equals, "token")
+
+	var doc struct {
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	c.Assert(json.Unmarshal([]byte(form.Policy), &doc), IsNil)
+	c.Assert(string(doc.Conditions[len(doc.Conditions)-1]), Equals, `{"x-kss-security-token":"token"}`)
+}
+
+func (s *Ks3PostPolicySuite) TestInvalidPostPolicy(c *C) {
+	bucket := s.newBucket(c)
+	future := time.Now().Add(time.Hour)
+
+	_, err := bucket.SignPostPolicy(NewPostPolicy(future))
+	c.Assert(err, NotNil)
+	_, err = bucket.SignPostPolicy(NewPostPolicy(time.Now().Add(-time.Second)).SetKey("object"))
+	c.Assert(err, NotNil)
+	_, err = bucket.SignPostPolicy(NewPostPolicy(future).SetKey("object").SetContentLengthRange(10, 1))
+	c.Assert(err, NotNil)
+	_, err = bucket.SignPostPolicy(NewPostPolicy(future).SetKey("object").SetSuccessActionStatus(302))
+	c.Assert(err, NotNil)
+	_, err = bucket.SignPostPolicy(NewPostPolicy(future).SetKey(""))
+	c.Assert(err, NotNil)
+	_, err = bucket.SignPostPolicy(nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3PostPolicySuite) TestPostObject(c *C) {
+	bucket := s.newBucket(c)
+	form, err := bucket.SignPostPolicy(NewPostPolicy(time.Now().Add(time.Hour)).
+		SetKeyStartsWith("uploads/").SetContentTypeStartsWith("text/").SetSuccessActionStatus(201))
+	c.Assert(err, IsNil)
+
+	content := "hello post"
+	result, err := bucket.PostObject(form, "uploads/dir/hello.txt", strings.NewReader(content))
+	c.Assert(err, IsNil)
+	c.Assert(result.StatusCode, Equals, http.StatusCreated)
+	c.Assert(result.Key, Equals, "uploads/dir/hello.txt")
+	c.Assert(result.Bucket, Equals, "post-bucket")
+	c.Assert(result.ETag, Equals, `"abc"`)
+	c.Assert(s.file, Equals, content)
+	c.Assert(s.name, Equals, "hello.txt")
+	c.Assert(s.fields[PostFieldPolicy], Equals, form.Fields[PostFieldPolicy])
+	c.Assert(s.fields[PostFieldSignature], Equals, form.Fields[PostFieldSignature])
+	// The object is stored with the type of the file, the policy only has the prefix
+	c.Assert(form.ContentTypePrefix, Equals, "text/")
+	_, ok := form.Fields[PostFieldContentType]
+	c.Assert(ok, Equals, false)
+	c.Assert(strings.Contains(form.Policy, `["starts-with","$Content-Type","text/"]`), Equals, true)
+	c.Assert(s.fields[PostFieldContentType], Equals, "text/plain; charset=utf-8")
+	c.Assert(s.typ, Equals, "text/plain; charset=utf-8")
+	_, ok = s.fields[PostFieldFile]
+	c.Assert(ok, Equals, false)
+
+	// The length of the streamed body is known
+	c.Assert(s.length > int64(len(content)), Equals, true)
+
+	// The body with unknown length is chunked, the key field of the form is used
+	result, err = PostObjectWithForm(bucket.Client.Conn.client, form, "", ioutil.NopCloser(strings.NewReader(content)))
+	c.Assert(err, IsNil)
+	c.Assert(s.length, Equals, int64(-1))
+	c.Assert(s.file, Equals, content)
+	c.Assert(s.name, Equals, "file")
+	c.Assert(s.fields[PostFieldKey], Equals, "uploads/${filename}")
+
+	// The Content-Type set by the uploader is used
+	form.Fields[PostFieldContentType] = "text/csv"
+	_, err = bucket.PostObject(form, "uploads/data", strings.NewReader(content))
+	c.Assert(err, IsNil)
+	c.Assert(s.fields[PostFieldContentType], Equals, "text/csv")
+	c.Assert(s.typ, Equals, "text/csv")
+}
+
+func (s *Ks3PostPolicySuite) TestPostObjectRedirect(c *C) {
+	bucket := s.newBucket(c)
+	form, err := bucket.SignPostPolicy(NewPostPolicy(time.Now().Add(time.Hour)).
+		SetKey("object").SetSuccessActionRedirect("http://example.com/done"))
+	c.Assert(err, IsNil)
+
+	s.status = http.StatusSeeOther
+	result, err := bucket.PostObject(form, "", strings.NewReader("data"))
+	c.Assert(err, IsNil)
+	c.Assert(result.StatusCode, Equals, http.StatusSeeOther)
+	c.Assert(result.Location, Equals, "http://example.com/done?etag=abc")
+}
+
+func (s *Ks3PostPolicySuite) TestPostObjectError(c *C) {
+	bucket := s.newBucket(c)
+	form, err := bucket.SignPostPolicy(NewPostPolicy(time.Now().Add(time.Hour)).SetKey("object"))
+	c.Assert(err, IsNil)
+
+	s.status = http.StatusForbidden
+	result, err := bucket.PostObject(form, "", strings.NewReader("data"))
+	c.Assert(err, NotNil)
+	srvErr, ok := err.(ServiceError)
+	c.Assert(ok, Equals, true)
+	c.Assert(srvErr.Code, Equals, "AccessDenied")
+	c.Assert(srvErr.RequestID, Equals, "request-id")
+	c.Assert(result.StatusCode, Equals, http.StatusForbidden)
+}
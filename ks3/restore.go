@@ -0,0 +1,262 @@
+package ks3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRestorePollInterval is the interval of WaitForRestore if the poll policy doesn't set it,
+// since the restores take minutes to hours
+const DefaultRestorePollInterval = time.Minute
+
+// RestoreStatus is the restore status of an archive object, parsed from the X-Kss-Restore header such as
+// ongoing-request="false", expiry-date="Tue, 02 Jan 2024 07:04:05 GMT"
+type RestoreStatus struct {
+	Ongoing    bool      // The restore is in progress
+	ExpiryDate time.Time // When the restored copy expires, zero if it's ongoing
+	Tier       string    // The tier of the restore, such as Standard, empty if it's not returned
+}
+
+// Restored reports whether the restored copy is readable
+func (s *RestoreStatus) Restored() bool {
+	return s != nil && !s.Ongoing
+}
+
+// ParseRestoreStatus parses the X-Kss-Restore header, the status is nil if the header is empty,
+// which means the object isn't restored.
+//
+// value    the header value.
+//
+// *RestoreStatus    the status, it's only valid when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func ParseRestoreStatus(value string) (*RestoreStatus, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	fields, err := parseHeaderFields(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid restore status %q: %s", value, err.Error())
+	}
+
+	status := &RestoreStatus{Tier: fields["tier"]}
+	switch fields["ongoing-request"] {
+	case "true":
+		status.Ongoing = true
+	case "false":
+	default:
+		return nil, fmt.Errorf("invalid restore status %q: ongoing-request must be true or false", value)
+	}
+	if expiry := fields["expiry-date"]; expiry != "" {
+		if status.ExpiryDate, err = http.ParseTime(expiry); err != nil {
+			return nil, fmt.Errorf("invalid restore status %q: invalid expiry-date", value)
+		}
+	}
+	return status, nil
+}
+
+// parseHeaderFields parses the comma separated name="value" fields, the values are quoted if they have commas
+func parseHeaderFields(value string) (map[string]string, error) {
+	fields := map[string]string{}
+	for rest := strings.TrimSpace(value); rest != ""; {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("missing = in %q", rest)
+		}
+		name := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+
+		var field string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed quote of %s", name)
+			}
+			field, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			field, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		}
+		fields[name] = field
+
+		rest = strings.TrimSpace(rest)
+		if rest != "" && !strings.HasPrefix(rest, ",") {
+			return nil, fmt.Errorf("missing , after %s", name)
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	}
+	return fields, nil
+}
+
+// RestoreStatus parses the restore status of the object, it's nil if the object isn't restored
+func (meta *ObjectMeta) RestoreStatus() (*RestoreStatus, error) {
+	return ParseRestoreStatus(meta.Restore)
+}
+
+// IsArchiveStorageClass reports whether the objects of the storage class must be restored to be read
+func IsArchiveStorageClass(storageClass StorageClassType) bool {
+	switch storageClass {
+	case StorageArchive, StorageColdArchive, StorageDeepColdArchive:
+		return true
+	}
+	return false
+}
+
+// isRestoreInProgress reports whether the restore failed since the object is being restored,
+// a 409 is only taken as it if the response has no error code
+func isRestoreInProgress(err error) bool {
+	serviceErr, ok := err.(ServiceError)
+	if !ok {
+		return false
+	}
+	if serviceErr.Code != "" {
+		return serviceErr.Code == "RestoreAlreadyInProgress"
+	}
+	return serviceErr.StatusCode == http.StatusConflict
+}
+
+// WaitForRestore polls the object until it's restored. It returns nil status if the object isn't archived,
+// and an error if it's archived but the restore isn't requested.
+//
+// ctx    the context to cancel the waiting, the requests are sent with it.
+// objectKey    the object key.
+// policy    how to poll, the interval is DefaultRestorePollInterval if it's not set.
+// options    the options of HEAD, such as VersionId.
+//
+// *RestoreStatus    the status of the restored object, it's only valid when error is nil.
+// error    it's nil if no error, *WaitError if the restore isn't requested, or if the waiting timed out or was canceled,
+//          otherwise the error of HEAD.
+//
+func (bucket Bucket) WaitForRestore(ctx context.Context, objectKey string, policy PollPolicy, options ...Option) (*RestoreStatus, error) {
+	if policy.Interval <= 0 {
+		policy.Interval = DefaultRestorePollInterval
+	}
+	var status *RestoreStatus
+	err := Wait(ctx, "ObjectRestored", policy, func(ctx context.Context) (WaitOutcome, error) {
+		meta, err := bucket.HeadObject(objectKey, withOption(options, WithContext(ctx))...)
+		if err != nil {
+			return WaitPending, err
+		}
+		if status, err = meta.RestoreStatus(); err != nil {
+			return WaitPending, err
+		}
+		if status == nil {
+			if IsArchiveStorageClass(meta.StorageClass) {
+				return WaitFailed, fmt.Errorf("the restore of %s isn't requested", objectKey)
+			}
+			return WaitSucceeded, nil
+		}
+		if status.Restored() {
+			return WaitSucceeded, nil
+		}
+		return WaitPending, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// RestoreResult is the result of restoring an object by RestorePrefix
+type RestoreResult struct {
+	Key          string
+	StorageClass StorageClassType
+	InProgress   bool  // The object was being restored already, which isn't an error
+	Err          error // The error of the restore
+}
+
+// RestoreProgress is an option of RestorePrefix to report the progress. fn is called after each restore with
+// the number of the objects done and the total, one call at a time.
+func RestoreProgress(fn func(done, total int, result RestoreResult)) Option {
+	return addArg(restoreProgressArg, fn)
+}
+
+// RestorePrefix restores all the archived objects under the prefix. The objects being restored are successful
+// with InProgress set. The restores go on after the failures, which are in the results.
+//
+// ctx    the context to cancel the restores, the remaining objects fail with its error.
+// prefix    the prefix of the objects.
+// restoreConfig    the configuration of each restore, such as the days and the tier.
+// options    Routines for the number of the concurrent restores, 5 by default, RestoreProgress,
+//            and the options of listing and restoring, such as RequestPayer.
+//
+// []RestoreResult    the results in the order of the keys.
+// error    it's the error of listing, otherwise nil.
+//
+func (bucket Bucket) RestorePrefix(ctx context.Context, prefix string, restoreConfig RestoreConfiguration, options ...Option) ([]RestoreResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	requestOptions := withOption(options, WithContext(ctx))
+
+	var results []RestoreResult
+	it := NewListObjectsIterator(&bucket, withOption(requestOptions, Prefix(prefix))...)
+	for it.HasNext() {
+		page, err := it.NextPage()
+		if err != nil {
+			return results, err
+		}
+		for _, object := range page.Objects {
+			if storageClass := StorageClassType(object.StorageClass); IsArchiveStorageClass(storageClass) {
+				results = append(results, RestoreResult{Key: object.Key, StorageClass: storageClass})
+			}
+		}
+	}
+
+	routines := 5
+	if arg, _ := FindOption(options, routineNum, nil); arg != nil {
+		if n, ok := arg.(int); ok && n > 0 {
+			routines = n
+		}
+	}
+	progressArg, _ := FindOption(options, restoreProgressArg, nil)
+	progress, _ := progressArg.(func(done, total int, result RestoreResult))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	done := 0
+	jobs := make(chan int)
+	for w := 0; w < routines; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result := &results[i]
+				if result.Err = ctx.Err(); result.Err == nil {
+					err := bucket.RestoreObjectDetail(result.Key, restoreConfig, requestOptions...)
+					if isRestoreInProgress(err) {
+						result.InProgress, err = true, nil
+					}
+					result.Err = err
+				}
+
+				mu.Lock()
+				done++
+				if progress != nil {
+					progress(done, len(results), *result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range results {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+// withOption returns the copy of the options with the option added, which is safe to append to concurrently
+func withOption(options []Option, option Option) []Option {
+	out := make([]Option, len(options), len(options)+1)
+	copy(out, options)
+	return append(out, option)
+}
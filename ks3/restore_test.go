@@ -0,0 +1,234 @@
+package ks3
+
+import (
+	"context"
+	"encoding/xml"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3RestoreSuite struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	objects  map[string]StorageClassType
+	restores map[string]int    // The restore requests by the keys
+	heads    map[string]int    // The HEAD requests by the keys
+	statuses map[string]string // The X-Kss-Restore headers by the keys, the ongoing ones are done after 2 HEAD requests
+}
+
+var _ = Suite(&Ks3RestoreSuite{})
+
+func (s *Ks3RestoreSuite) SetUpTest(c *C) {
+	s.objects = map[string]StorageClassType{
+		"archive/a":  StorageArchive,
+		"archive/b":  StorageColdArchive,
+		"archive/c":  StorageStandard,
+		"archive/d":  StorageDeepColdArchive,
+		"busy":       StorageArchive,
+		"conflict":   StorageArchive,
+		"denied":     StorageArchive,
+		"locked":     StorageArchive,
+		"other/e":    StorageArchive,
+		"standard":   StorageStandard,
+		"unrestored": StorageArchive,
+	}
+	s.restores = map[string]int{}
+	s.heads = map[string]int{}
+	s.statuses = map[string]string{
+		"archive/a": `ongoing-request="true"`,
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		switch {
+		case key == "":
+			s.list(w, r)
+		case r.Method == "POST":
+			s.restores[key]++
+			switch key {
+			case "busy":
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte("<Error><Code>RestoreAlreadyInProgress</Code></Error>"))
+			case "conflict":
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte("<Error><Code>OperationAborted</Code></Error>"))
+			case "locked":
+				// No error code
+				w.WriteHeader(http.StatusConflict)
+			case "denied":
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+			default:
+				w.WriteHeader(http.StatusAccepted)
+			}
+		case r.Method == "HEAD":
+			s.heads[key]++
+			storageClass, ok := s.objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set(HTTPHeaderKs3StorageClass, string(storageClass))
+			if status := s.statuses[key]; status != "" {
+				if s.heads[key] > 2 {
+					status = `ongoing-request="false", expiry-date="Tue, 02 Jan 2024 07:04:05 GMT"`
+				}
+				w.Header().Set(HTTPHeaderKs3Restore, status)
+			}
+		}
+	}))
+}
+
+func (s *Ks3RestoreSuite) list(w http.ResponseWriter, r *http.Request) {
+	prefix, marker := r.URL.Query().Get("prefix"), r.URL.Query().Get("marker")
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, prefix) && k > marker {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	// Two keys per page
+	result := ListObjectsResult{Prefix: prefix, IsTruncated: len(keys) > 2}
+	for i, k := range keys {
+		if i == 2 {
+			break
+		}
+		result.Objects = append(result.Objects, ObjectProperties{Key: k, StorageClass: string(s.objects[k])})
+	}
+	data, _ := xml.Marshal(result)
+	w.Write(data)
+}
+
+func (s *Ks3RestoreSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3RestoreSuite) newBucket(c *C) *Bucket {
+	addr := s.server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("restore-bucket")
+	c.Assert(err, IsNil)
+	return bucket
+}
+
+func (s *Ks3RestoreSuite) TestParseRestoreStatus(c *C) {
+	status, err := ParseRestoreStatus("")
+	c.Assert(err, IsNil)
+	c.Assert(status, IsNil)
+	c.Assert(status.Restored(), Equals, false)
+
+	status, err = ParseRestoreStatus(`ongoing-request="true"`)
+	c.Assert(err, IsNil)
+	c.Assert(status, DeepEquals, &RestoreStatus{Ongoing: true})
+	c.Assert(status.Restored(), Equals, false)
+
+	status, err = ParseRestoreStatus(`ongoing-request="false", expiry-date="Tue, 02 Jan 2024 07:04:05 GMT", tier=Bulk`)
+	c.Assert(err, IsNil)
+	c.Assert(status.Restored(), Equals, true)
+	c.Assert(status.ExpiryDate.Equal(time.Date(2024, 1, 2, 7, 4, 5, 0, time.UTC)), Equals, true)
+	c.Assert(status.Tier, Equals, "Bulk")
+
+	for value, message := range map[string]string{
+		`ongoing-request`:                                 `.*missing = in "ongoing-request"`,
+		`ongoing-request="true`:                           `.*unclosed quote of ongoing-request`,
+		`ongoing-request="true" expiry-date=""`:           `.*missing , after ongoing-request`,
+		`ongoing-request="yes"`:                           `.*ongoing-request must be true or false`,
+		`expiry-date="Tue, 02 Jan 2024 07:04:05 GMT"`:     `.*ongoing-request must be true or false`,
+		`ongoing-request="false", expiry-date="tomorrow"`: `.*invalid expiry-date`,
+	} {
+		_, err = ParseRestoreStatus(value)
+		c.Assert(err, ErrorMatches, message)
+	}
+}
+
+func (s *Ks3RestoreSuite) TestWaitForRestore(c *C) {
+	bucket := s.newBucket(c)
+	policy := PollPolicy{Interval: time.Millisecond, Multiplier: 2, MaxInterval: 3 * time.Millisecond}
+
+	status, err := bucket.WaitForRestore(context.Background(), "archive/a", policy)
+	c.Assert(err, IsNil)
+	c.Assert(status.Restored(), Equals, true)
+	c.Assert(s.heads["archive/a"], Equals, 3)
+
+	status, err = bucket.WaitForRestore(context.Background(), "standard", policy)
+	c.Assert(err, IsNil)
+	c.Assert(status, IsNil)
+
+	_, err = bucket.WaitForRestore(context.Background(), "unrestored", policy)
+	c.Assert(err, ErrorMatches, ".*ObjectRestored Failed after 1 attempts.*: the restore of unrestored isn't requested")
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitFailed)
+
+	_, err = bucket.WaitForRestore(context.Background(), "missing", policy)
+	c.Assert(err.(ServiceError).StatusCode, Equals, http.StatusNotFound)
+
+	// The restore never finishes
+	s.statuses["archive/b"] = `ongoing-request="true"`
+	s.heads["archive/b"] = -1000
+	policy.MaxDuration = 20 * time.Millisecond
+	_, err = bucket.WaitForRestore(context.Background(), "archive/b", policy)
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitTimedOut)
+	c.Assert(err.(*WaitError).Err, Equals, context.DeadlineExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = bucket.WaitForRestore(ctx, "archive/b", PollPolicy{})
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitCanceled)
+	c.Assert(err.(*WaitError).Err, Equals, context.Canceled)
+}
+
+func (s *Ks3RestoreSuite) TestRestorePrefix(c *C) {
+	bucket := s.newBucket(c)
+	var dones []int
+	progress := func(done, total int, result RestoreResult) {
+		c.Assert(total, Equals, 3)
+		dones = append(dones, done)
+	}
+	results, err := bucket.RestorePrefix(context.Background(), "archive/", RestoreConfiguration{Days: 2},
+		Routines(2), RestoreProgress(progress))
+	c.Assert(err, IsNil)
+	c.Assert(results, DeepEquals, []RestoreResult{
+		{Key: "archive/a", StorageClass: StorageArchive},
+		{Key: "archive/b", StorageClass: StorageColdArchive},
+		{Key: "archive/d", StorageClass: StorageDeepColdArchive},
+	})
+	c.Assert(dones, DeepEquals, []int{1, 2, 3})
+	c.Assert(s.restores, DeepEquals, map[string]int{"archive/a": 1, "archive/b": 1, "archive/d": 1})
+
+	// The restores in progress are successful, and the failures don't stop the others
+	s.restores = map[string]int{}
+	results, err = bucket.RestorePrefix(context.Background(), "", RestoreConfiguration{})
+	c.Assert(err, IsNil)
+	c.Assert(len(results), Equals, 9)
+	c.Assert(len(s.restores), Equals, 9)
+	for _, result := range results {
+		switch result.Key {
+		case "busy", "locked":
+			c.Assert(result.InProgress, Equals, true)
+			c.Assert(result.Err, IsNil)
+		case "denied":
+			c.Assert(result.Err.(ServiceError).Code, Equals, "AccessDenied")
+		case "conflict":
+			// The other conflicts are failures
+			c.Assert(result.InProgress, Equals, false)
+			c.Assert(result.Err.(ServiceError).Code, Equals, "OperationAborted")
+		default:
+			c.Assert(result.InProgress, Equals, false)
+			c.Assert(result.Err, IsNil)
+		}
+	}
+}
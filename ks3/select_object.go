@@ -0,0 +1,331 @@
+package ks3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// SelectMetaRequest is the request of CreateSelectObjectMeta, it's implemented by CsvMetaRequest and JsonMetaRequest.
+type SelectMetaRequest interface {
+	metaProcess() string
+	marshalMeta() ([]byte, error)
+}
+
+func (meta CsvMetaRequest) metaProcess() string {
+	return "csv/meta"
+}
+
+func (meta CsvMetaRequest) marshalMeta() ([]byte, error) {
+	meta.encodeBase64()
+	return xml.Marshal(meta)
+}
+
+func (meta JsonMetaRequest) metaProcess() string {
+	return "json/meta"
+}
+
+func (meta JsonMetaRequest) marshalMeta() ([]byte, error) {
+	return xml.Marshal(meta)
+}
+
+// CreateSelectObjectMeta creates the meta of a csv or json object, the meta speeds up SelectObject with split range.
+//
+// objectKey    the object key.
+// metaReq    the meta request, CsvMetaRequest for csv objects and JsonMetaRequest for json objects.
+// options    the options for the request.
+//
+// SelectObjectResult    the end frame of the response, MetaEndFrameCSV or MetaEndFrameJSON is set according to the request.
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket Bucket) CreateSelectObjectMeta(objectKey string, metaReq SelectMetaRequest, options ...Option) (SelectObjectResult, error) {
+	var out SelectObjectResult
+	bs, err := metaReq.marshalMeta()
+	if err != nil {
+		return out, err
+	}
+
+	params := map[string]interface{}{}
+	params["x-kss-process"] = metaReq.metaProcess()
+
+	resp, err := bucket.DoPostSelectObject(objectKey, params, bytes.NewBuffer(bs), options...)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Close()
+
+	_, err = io.Copy(io.Discard, resp)
+	return resp.Frame, err
+}
+
+// SelectObject queries the content of a csv or json object with the SQL expression of the request.
+//
+// objectKey    the object key.
+// selectReq    the select request, the json input serialization is used when its type is set, otherwise it's csv.
+// options    the options for the request.
+//
+// io.ReadCloser    the reader of the decoded result data. It must be closed after the usage and only valid when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket Bucket) SelectObject(objectKey string, selectReq SelectRequest, options ...Option) (io.ReadCloser, error) {
+	return bucket.doSelectObject(objectKey, selectReq, options)
+}
+
+// SelectObjectIntoFile queries the content of an object and saves the result data to a local file.
+//
+// objectKey    the object key.
+// filePath    the local file to store the result data.
+// selectReq    the select request, check out SelectObject for the reference.
+// options    the options for the request.
+//
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket Bucket) SelectObjectIntoFile(objectKey, filePath string, selectReq SelectRequest, options ...Option) error {
+	disableTempFile := getDisableTempFile(options)
+	tempFilePath := filePath + TempFileSuffix
+	if disableTempFile {
+		tempFilePath = filePath
+	}
+
+	resp, err := bucket.doSelectObject(objectKey, selectReq, options)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	// If the local file does not exist, create a new one. If it exists, overwrite it.
+	fd, err := os.OpenFile(tempFilePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, FilePermMode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fd, resp)
+	fd.Close()
+	if err != nil {
+		os.Remove(tempFilePath)
+		return err
+	}
+
+	return rename(tempFilePath, filePath, disableTempFile)
+}
+
+func (bucket Bucket) doSelectObject(objectKey string, selectReq SelectRequest, options []Option) (*SelectObjectResponse, error) {
+	params := map[string]interface{}{}
+	if selectReq.InputSerializationSelect.JsonBodyInput.JsonIsEmpty() {
+		params["x-kss-process"] = "csv/select"
+	} else {
+		params["x-kss-process"] = "json/select"
+	}
+
+	selectReq.encodeBase64()
+	bs, err := xml.Marshal(selectReq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := bucket.DoPostSelectObject(objectKey, params, bytes.NewBuffer(bs), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	enableCrc := selectReq.OutputSerializationSelect.EnablePayloadCrc
+	resp.Frame.EnablePayloadCrc = enableCrc != nil && *enableCrc
+	return resp, nil
+}
+
+// DoPostSelectObject is the actual API that sends the SelectObject and CreateSelectObjectMeta requests.
+//
+// objectKey    the object key.
+// params    the request params, x-kss-process specifies the select process.
+// buf    the request body.
+// options    the options for the request.
+//
+// SelectObjectResponse    the response which decodes the frames of the response body.
+// error    it's nil if no error, otherwise it's an error object.
+func (bucket Bucket) DoPostSelectObject(objectKey string, params map[string]interface{}, buf *bytes.Buffer, options ...Option) (*SelectObjectResponse, error) {
+	resp, err := bucket.do("POST", objectKey, params, options, buf, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = CheckRespCode(resp.StatusCode, []int{http.StatusOK, http.StatusPartialContent})
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	// Progress
+	listener := GetProgressListener(options)
+
+	result := &SelectObjectResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Headers,
+		Body:       TeeReader(resp.Body, nil, 0, listener, nil),
+	}
+	result.Frame.OutputRawData = strings.ToUpper(resp.Headers.Get(HTTPHeaderKs3SelectOutputRaw)) == "TRUE"
+	return result, nil
+}
+
+// SelectObjectResponse defines the HTTP response of SelectObject, it decodes the framed response body.
+//
+// Every frame consists of a 12 bytes header and a payload followed by the payload checksum:
+// version (1 byte), frame type (3 bytes), payload length (4 bytes), header checksum (4 bytes),
+// payload (payload length bytes, starting with the 8 bytes scan offset) and the CRC32 of the payload (4 bytes).
+// All the integers are in big endian.
+type SelectObjectResponse struct {
+	StatusCode int
+	Headers    http.Header
+	Body       io.ReadCloser
+	Frame      SelectObjectResult // The last decoded frame
+	Finish     bool               // Flag of the end frame has been read
+	data       []byte             // The data of the current data frame which is not read yet
+}
+
+const (
+	selectFrameHeaderLen   = 12
+	selectFrameOffsetLen   = 8
+	selectFrameChecksumLen = 4
+)
+
+// Read implements io.Reader, it returns the data of the data frames.
+func (sr *SelectObjectResponse) Read(p []byte) (int, error) {
+	if sr.Frame.OutputRawData {
+		return sr.Body.Read(p)
+	}
+
+	for len(sr.data) == 0 {
+		if sr.Finish {
+			return 0, io.EOF
+		}
+		if err := sr.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, sr.data)
+	sr.data = sr.data[n:]
+	sr.Frame.ConsumedBytesLength += int32(n)
+	return n, nil
+}
+
+// Close closes the response body
+func (sr *SelectObjectResponse) Close() error {
+	return sr.Body.Close()
+}
+
+// readFrame reads and decodes the next frame
+func (sr *SelectObjectResponse) readFrame() error {
+	var header [selectFrameHeaderLen]byte
+	if _, err := io.ReadFull(sr.Body, header[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	frame := &sr.Frame
+	frame.Version = header[0]
+	frame.FrameType = int32(header[1])<<16 | int32(header[2])<<8 | int32(header[3])
+	frame.PayloadLength = int32(binary.BigEndian.Uint32(header[4:8]))
+	frame.HeaderCheckSum = binary.BigEndian.Uint32(header[8:12])
+	if frame.HeaderCheckSum != 0 && frame.HeaderCheckSum != crc32.ChecksumIEEE(header[:8]) {
+		return fmt.Errorf("ks3: select object frame header checksum is inconsistent, frame type %d", frame.FrameType)
+	}
+	if frame.PayloadLength < selectFrameOffsetLen {
+		return fmt.Errorf("ks3: invalid select object frame payload length %d", frame.PayloadLength)
+	}
+
+	payload := make([]byte, frame.PayloadLength+selectFrameChecksumLen)
+	if _, err := io.ReadFull(sr.Body, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	checksum := payload[frame.PayloadLength:]
+	payload = payload[:frame.PayloadLength]
+
+	frame.PayloadChecksum = binary.BigEndian.Uint32(checksum)
+	if frame.EnablePayloadCrc && frame.PayloadChecksum != crc32.ChecksumIEEE(payload) {
+		return fmt.Errorf("ks3: select object frame payload crc32 is inconsistent, client %d but server %d",
+			crc32.ChecksumIEEE(payload), frame.PayloadChecksum)
+	}
+
+	frame.Offset = binary.BigEndian.Uint64(payload[:selectFrameOffsetLen])
+	payload = payload[selectFrameOffsetLen:]
+
+	switch frame.FrameType {
+	case DataFrameType:
+		sr.data = payload
+	case ContinuousFrameType:
+		// Keep-alive frame, it only reports the scan offset
+	case EndFrameType:
+		return sr.readEndFrame(payload)
+	case MetaEndFrameCSVType:
+		return sr.readMetaEndFrameCSV(payload)
+	case MetaEndFrameJSONType:
+		return sr.readMetaEndFrameJSON(payload)
+	default:
+		return fmt.Errorf("ks3: unknown select object frame type %d", frame.FrameType)
+	}
+	return nil
+}
+
+func (sr *SelectObjectResponse) readEndFrame(payload []byte) error {
+	if len(payload) < 12 {
+		return fmt.Errorf("ks3: invalid select object end frame length %d", len(payload))
+	}
+	sr.Finish = true
+	sr.Frame.EndFrame = EndFrame{
+		TotalScanned:   int64(binary.BigEndian.Uint64(payload[0:8])),
+		HTTPStatusCode: int32(binary.BigEndian.Uint32(payload[8:12])),
+		ErrorMsg:       string(payload[12:]),
+	}
+	return sr.checkEndStatus(int(sr.Frame.EndFrame.HTTPStatusCode), sr.Frame.EndFrame.ErrorMsg)
+}
+
+func (sr *SelectObjectResponse) readMetaEndFrameCSV(payload []byte) error {
+	if len(payload) < 28 {
+		return fmt.Errorf("ks3: invalid select object meta end frame length %d", len(payload))
+	}
+	sr.Finish = true
+	sr.Frame.MetaEndFrameCSV = MetaEndFrameCSV{
+		TotalScanned: int64(binary.BigEndian.Uint64(payload[0:8])),
+		Status:       int32(binary.BigEndian.Uint32(payload[8:12])),
+		SplitsCount:  int32(binary.BigEndian.Uint32(payload[12:16])),
+		RowsCount:    int64(binary.BigEndian.Uint64(payload[16:24])),
+		ColumnsCount: int32(binary.BigEndian.Uint32(payload[24:28])),
+		ErrorMsg:     string(payload[28:]),
+	}
+	return sr.checkEndStatus(int(sr.Frame.MetaEndFrameCSV.Status), sr.Frame.MetaEndFrameCSV.ErrorMsg)
+}
+
+func (sr *SelectObjectResponse) readMetaEndFrameJSON(payload []byte) error {
+	if len(payload) < 24 {
+		return fmt.Errorf("ks3: invalid select object meta end frame length %d", len(payload))
+	}
+	sr.Finish = true
+	sr.Frame.MetaEndFrameJSON = MetaEndFrameJSON{
+		TotalScanned: int64(binary.BigEndian.Uint64(payload[0:8])),
+		Status:       int32(binary.BigEndian.Uint32(payload[8:12])),
+		SplitsCount:  int32(binary.BigEndian.Uint32(payload[12:16])),
+		RowsCount:    int64(binary.BigEndian.Uint64(payload[16:24])),
+		ErrorMsg:     string(payload[24:]),
+	}
+	return sr.checkEndStatus(int(sr.Frame.MetaEndFrameJSON.Status), sr.Frame.MetaEndFrameJSON.ErrorMsg)
+}
+
+// checkEndStatus converts the failed status of an end frame to ServiceError
+func (sr *SelectObjectResponse) checkEndStatus(statusCode int, errorMsg string) error {
+	if statusCode/100 == 2 {
+		return nil
+	}
+	return ServiceError{
+		Message:    errorMsg,
+		RequestID:  sr.Headers.Get(HTTPHeaderKs3RequestID),
+		StatusCode: statusCode,
+	}
+}
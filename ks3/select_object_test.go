@@ -0,0 +1,282 @@
+package ks3
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3SelectObjectSuite struct {
+	server *httptest.Server
+	bucket *Bucket
+	body   []byte        // The response body of the next request
+	header http.Header   // The response headers of the next request
+	req    *http.Request // The last received request
+	reqXML string
+}
+
+var _ = Suite(&Ks3SelectObjectSuite{})
+
+func (s *Ks3SelectObjectSuite) SetUpSuite(c *C) {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		s.req = r
+		s.reqXML = string(bs)
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(s.body)
+	}))
+
+	// The bucket domain is dialed to the local server
+	addr := s.server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	client, err := New(s.server.URL, "ak", "sk", HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	s.bucket, err = client.Bucket("select-bucket")
+	c.Assert(err, IsNil)
+}
+
+func (s *Ks3SelectObjectSuite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3SelectObjectSuite) SetUpTest(c *C) {
+	s.body = nil
+	s.header = nil
+}
+
+// encodeSelectFrame encodes a frame of the select object response
+func encodeSelectFrame(frameType int32, offset uint64, data []byte) []byte {
+	payload := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(payload, offset)
+	payload = append(payload, data...)
+
+	frame := make([]byte, 12, 12+len(payload)+4)
+	frame[0] = 1
+	frame[1] = byte(frameType >> 16)
+	frame[2] = byte(frameType >> 8)
+	frame[3] = byte(frameType)
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[8:12], crc32.ChecksumIEEE(frame[:8]))
+	frame = append(frame, payload...)
+
+	var checksum [4]byte
+	binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(payload))
+	return append(frame, checksum[:]...)
+}
+
+func encodeEndFrame(totalScanned int64, status int32, errorMsg string) []byte {
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint64(data[0:8], uint64(totalScanned))
+	binary.BigEndian.PutUint32(data[8:12], uint32(status))
+	data = append(data, errorMsg...)
+	return encodeSelectFrame(EndFrameType, uint64(totalScanned), data)
+}
+
+func (s *Ks3SelectObjectSuite) csvSelectRequest() SelectRequest {
+	enable := true
+	req := SelectRequest{Expression: "select * from ks3object"}
+	req.InputSerializationSelect.CsvBodyInput.FileHeaderInfo = "None"
+	req.OutputSerializationSelect.EnablePayloadCrc = &enable
+	return req
+}
+
+func (s *Ks3SelectObjectSuite) TestSelectObjectCsv(c *C) {
+	var body []byte
+	body = append(body, encodeSelectFrame(DataFrameType, 10, []byte("a,1\n"))...)
+	body = append(body, encodeSelectFrame(ContinuousFrameType, 20, nil)...)
+	body = append(body, encodeSelectFrame(DataFrameType, 30, []byte("b,2\n"))...)
+	body = append(body, encodeEndFrame(30, 200, "")...)
+	s.body = body
+
+	rc, err := s.bucket.SelectObject("data.csv", s.csvSelectRequest())
+	c.Assert(err, IsNil)
+	bs, err := ioutil.ReadAll(rc)
+	c.Assert(err, IsNil)
+	c.Assert(rc.Close(), IsNil)
+	c.Assert(string(bs), Equals, "a,1\nb,2\n")
+
+	c.Assert(s.req.Method, Equals, "POST")
+	c.Assert(s.req.URL.Query().Get("x-kss-process"), Equals, "csv/select")
+	c.Assert(strings.Contains(s.reqXML, "<CSV>"), Equals, true)
+	c.Assert(strings.Contains(s.reqXML, "<EnablePayloadCrc>true</EnablePayloadCrc>"), Equals, true)
+
+	sr := rc.(*SelectObjectResponse)
+	c.Assert(sr.Finish, Equals, true)
+	c.Assert(sr.Frame.EndFrame.TotalScanned, Equals, int64(30))
+	c.Assert(sr.Frame.EndFrame.HTTPStatusCode, Equals, int32(200))
+	c.Assert(sr.Frame.ConsumedBytesLength, Equals, int32(8))
+}
+
+func (s *Ks3SelectObjectSuite) TestSelectObjectJson(c *C) {
+	s.body = append(encodeSelectFrame(DataFrameType, 5, []byte(`{"a":1}`)), encodeEndFrame(5, 206, "")...)
+
+	req := SelectRequest{Expression: "select * from ks3object s"}
+	req.InputSerializationSelect.JsonBodyInput.JSONType = "LINES"
+	rc, err := s.bucket.SelectObject("data.json", req)
+	c.Assert(err, IsNil)
+	defer rc.Close()
+	bs, err := ioutil.ReadAll(rc)
+	c.Assert(err, IsNil)
+	c.Assert(string(bs), Equals, `{"a":1}`)
+	c.Assert(s.req.URL.Query().Get("x-kss-process"), Equals, "json/select")
+}
+
+func (s *Ks3SelectObjectSuite) TestSelectObjectCrcError(c *C) {
+	frame := encodeSelectFrame(DataFrameType, 10, []byte("a,1\n"))
+	frame[len(frame)-1] ^= 0xff
+	s.body = append(frame, encodeEndFrame(10, 200, "")...)
+
+	rc, err := s.bucket.SelectObject("data.csv", s.csvSelectRequest())
+	c.Assert(err, IsNil)
+	defer rc.Close()
+	_, err = ioutil.ReadAll(rc)
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "crc32"), Equals, true)
+
+	// The header checksum is always verified
+	frame = encodeSelectFrame(DataFrameType, 10, []byte("a,1\n"))
+	frame[8] ^= 0xff
+	s.body = frame
+	rc, err = s.bucket.SelectObject("data.csv", SelectRequest{Expression: "select * from ks3object"})
+	c.Assert(err, IsNil)
+	defer rc.Close()
+	_, err = ioutil.ReadAll(rc)
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3SelectObjectSuite) TestSelectObjectMalformed(c *C) {
+	// Truncated frame
+	s.body = encodeSelectFrame(DataFrameType, 10, []byte("a,1\n"))[:15]
+	rc, err := s.bucket.SelectObject("data.csv", s.csvSelectRequest())
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(rc)
+	c.Assert(err, NotNil)
+	rc.Close()
+
+	// Unknown frame type
+	s.body = encodeSelectFrame(0x800009, 10, nil)
+	rc, err = s.bucket.SelectObject("data.csv", s.csvSelectRequest())
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(rc)
+	c.Assert(err, NotNil)
+	rc.Close()
+
+	// Payload shorter than the offset
+	frame := encodeSelectFrame(DataFrameType, 0, nil)
+	binary.BigEndian.PutUint32(frame[4:8], 2)
+	binary.BigEndian.PutUint32(frame[8:12], 0)
+	s.body = frame
+	rc, err = s.bucket.SelectObject("data.csv", s.csvSelectRequest())
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(rc)
+	c.Assert(err, NotNil)
+	rc.Close()
+}
+
+func (s *Ks3SelectObjectSuite) TestSelectObjectEndFrameError(c *C) {
+	s.body = append(encodeSelectFrame(DataFrameType, 10, []byte("a,1\n")), encodeEndFrame(10, 400, "InvalidCsvLine")...)
+	s.header = http.Header{HTTPHeaderKs3RequestID: []string{"req-1"}}
+
+	rc, err := s.bucket.SelectObject("data.csv", s.csvSelectRequest())
+	c.Assert(err, IsNil)
+	defer rc.Close()
+	bs, err := ioutil.ReadAll(rc)
+	c.Assert(string(bs), Equals, "a,1\n")
+	srvErr, ok := err.(ServiceError)
+	c.Assert(ok, Equals, true)
+	c.Assert(srvErr.StatusCode, Equals, 400)
+	c.Assert(srvErr.Message, Equals, "InvalidCsvLine")
+	c.Assert(srvErr.RequestID, Equals, "req-1")
+}
+
+func (s *Ks3SelectObjectSuite) TestSelectObjectOutputRaw(c *C) {
+	s.body = []byte("a,1\nb,2\n")
+	s.header = http.Header{HTTPHeaderKs3SelectOutputRaw: []string{"true"}}
+
+	rc, err := s.bucket.SelectObject("data.csv", s.csvSelectRequest())
+	c.Assert(err, IsNil)
+	defer rc.Close()
+	bs, err := ioutil.ReadAll(rc)
+	c.Assert(err, IsNil)
+	c.Assert(string(bs), Equals, "a,1\nb,2\n")
+}
+
+func (s *Ks3SelectObjectSuite) TestSelectObjectIntoFile(c *C) {
+	s.body = append(encodeSelectFrame(DataFrameType, 10, []byte("a,1\n")), encodeEndFrame(10, 200, "")...)
+
+	fileName := "select-object-into-file.csv"
+	defer os.Remove(fileName)
+	err := s.bucket.SelectObjectIntoFile("data.csv", fileName, s.csvSelectRequest())
+	c.Assert(err, IsNil)
+	bs, err := ioutil.ReadFile(fileName)
+	c.Assert(err, IsNil)
+	c.Assert(string(bs), Equals, "a,1\n")
+	_, err = os.Stat(fileName + TempFileSuffix)
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	// The temp file is removed when the select fails
+	s.body = encodeEndFrame(0, 500, "InternalError")
+	err = s.bucket.SelectObjectIntoFile("data.csv", fileName+".bad", s.csvSelectRequest())
+	c.Assert(err, NotNil)
+	_, err = os.Stat(fileName + ".bad" + TempFileSuffix)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *Ks3SelectObjectSuite) TestCreateSelectObjectMetaCsv(c *C) {
+	data := make([]byte, 28)
+	binary.BigEndian.PutUint64(data[0:8], 100)
+	binary.BigEndian.PutUint32(data[8:12], 200)
+	binary.BigEndian.PutUint32(data[12:16], 3)
+	binary.BigEndian.PutUint64(data[16:24], 50)
+	binary.BigEndian.PutUint32(data[24:28], 4)
+	s.body = encodeSelectFrame(MetaEndFrameCSVType, 100, data)
+
+	metaReq := CsvMetaRequest{}
+	metaReq.InputSerialization.CSV.RecordDelimiter = "\n"
+	res, err := s.bucket.CreateSelectObjectMeta("data.csv", metaReq)
+	c.Assert(err, IsNil)
+	c.Assert(s.req.URL.Query().Get("x-kss-process"), Equals, "csv/meta")
+	c.Assert(strings.Contains(s.reqXML, "<RecordDelimiter>Cg==</RecordDelimiter>"), Equals, true)
+	c.Assert(res.MetaEndFrameCSV.TotalScanned, Equals, int64(100))
+	c.Assert(res.MetaEndFrameCSV.SplitsCount, Equals, int32(3))
+	c.Assert(res.MetaEndFrameCSV.RowsCount, Equals, int64(50))
+	c.Assert(res.MetaEndFrameCSV.ColumnsCount, Equals, int32(4))
+	// The request is not modified
+	c.Assert(metaReq.InputSerialization.CSV.RecordDelimiter, Equals, "\n")
+}
+
+func (s *Ks3SelectObjectSuite) TestCreateSelectObjectMetaJson(c *C) {
+	data := make([]byte, 24)
+	binary.BigEndian.PutUint64(data[0:8], 64)
+	binary.BigEndian.PutUint32(data[8:12], 400)
+	binary.BigEndian.PutUint32(data[12:16], 0)
+	binary.BigEndian.PutUint64(data[16:24], 0)
+	data = append(data, "InvalidJsonData"...)
+	s.body = encodeSelectFrame(MetaEndFrameJSONType, 64, data)
+
+	metaReq := JsonMetaRequest{}
+	metaReq.InputSerialization.JSON.JSONType = "LINES"
+	res, err := s.bucket.CreateSelectObjectMeta("data.json", metaReq)
+	c.Assert(err, NotNil)
+	c.Assert(err.(ServiceError).StatusCode, Equals, 400)
+	c.Assert(s.req.URL.Query().Get("x-kss-process"), Equals, "json/meta")
+	c.Assert(res.MetaEndFrameJSON.TotalScanned, Equals, int64(64))
+	c.Assert(res.MetaEndFrameJSON.ErrorMsg, Equals, "InvalidJsonData")
+	c.Assert(bytes.Contains([]byte(s.reqXML), []byte("<Type>LINES</Type>")), Equals, true)
+}
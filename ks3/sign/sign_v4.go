@@ -0,0 +1,512 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// V4 签名的常量
+const (
+	V4Algorithm           = "AWS4-HMAC-SHA256"
+	V4Terminator          = "aws4_request"
+	V4DefaultService      = "s3"
+	V4DefaultHeaderPrefix = "x-kss-"
+
+	// UnsignedPayload 不对请求体签名
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// StreamingPayload 对请求体分块签名
+	StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	// EmptyPayloadHash 空请求体的SHA256
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// DefaultChunkSize 分块签名的默认块大小
+	DefaultChunkSize = 64 * 1024
+
+	v4TimeFormat            = "20060102T150405Z"
+	v4DateFormat            = "20060102"
+	streamingChunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	chunkSignatureLen       = 64
+)
+
+// v4IgnoredHeaders 不参与签名的请求头
+var v4IgnoredHeaders = map[string]bool{
+	"authorization":       true,
+	"user-agent":          true,
+	"proxy-authorization": true,
+	"expect":              true,
+	"connection":          true,
+	"content-length":      true,
+}
+
+// V4Signer V4签名，兼容 AWS Signature Version 4
+type V4Signer struct {
+	AccessKeyID     string // AccessKeyID，必填
+	SecretAccessKey string // SecretAccessKey，必填
+	SecurityToken   string // STS临时凭证的安全令牌，可选
+	Region          string // 区域，如：cn-beijing，必填
+	Service         string // 服务名，默认为 s3
+	HeaderPrefix    string // 签名相关请求头的前缀，默认为 x-kss-
+}
+
+// V4SignResult 签名的中间结果，便于排查签名不一致的问题
+type V4SignResult struct {
+	CanonicalRequest string
+	StringToSign     string
+	SignedHeaders    string
+	Signature        string
+}
+
+func (s *V4Signer) service() string {
+	if s.Service == "" {
+		return V4DefaultService
+	}
+	return s.Service
+}
+
+func (s *V4Signer) header(name string) string {
+	prefix := s.HeaderPrefix
+	if prefix == "" {
+		prefix = V4DefaultHeaderPrefix
+	}
+	return textproto.CanonicalMIMEHeaderKey(prefix + name)
+}
+
+// queryName 预签名URL中的参数名，如：X-Kss-Credential
+func (s *V4Signer) queryName(name string) string {
+	prefix := s.HeaderPrefix
+	if prefix == "" {
+		prefix = V4DefaultHeaderPrefix
+	}
+	return textproto.CanonicalMIMEHeaderKey(strings.TrimSuffix(prefix, "-")) + "-" + name
+}
+
+// HeaderDate 请求时间的请求头，如：X-Kss-Date
+func (s *V4Signer) HeaderDate() string { return s.header("date") }
+
+// HeaderContentSha256 请求体SHA256的请求头，如：X-Kss-Content-Sha256
+func (s *V4Signer) HeaderContentSha256() string { return s.header("content-sha256") }
+
+// HeaderSecurityToken 安全令牌的请求头，如：X-Kss-Security-Token
+func (s *V4Signer) HeaderSecurityToken() string { return s.header("security-token") }
+
+// HeaderDecodedContentLength 分块签名时原始请求体长度的请求头，如：X-Kss-Decoded-Content-Length
+func (s *V4Signer) HeaderDecodedContentLength() string { return s.header("decoded-content-length") }
+
+// scope 签名范围，如：20130524/us-east-1/s3/aws4_request
+func (s *V4Signer) scope(t time.Time) string {
+	return strings.Join([]string{t.UTC().Format(v4DateFormat), s.Region, s.service(), V4Terminator}, "/")
+}
+
+// SignRequest 使用请求头签名，设置时间、请求体SHA256、安全令牌和Authorization请求头
+// req 请求对象，必填
+// payloadHash 请求体的SHA256，可以为 UnsignedPayload，为空时使用请求中已设置的值
+// t 签名时间
+func (s *V4Signer) SignRequest(req *http.Request, payloadHash string, t time.Time) *V4SignResult {
+	return s.signRequest(req, payloadHash, t, false)
+}
+
+func (s *V4Signer) signRequest(req *http.Request, payloadHash string, t time.Time, signContentLength bool) *V4SignResult {
+	t = t.UTC()
+	if payloadHash == "" {
+		payloadHash = req.Header.Get(s.HeaderContentSha256())
+	}
+	if payloadHash == "" {
+		payloadHash = UnsignedPayload
+	}
+	req.Header.Set(s.HeaderDate(), t.Format(v4TimeFormat))
+	req.Header.Set(s.HeaderContentSha256(), payloadHash)
+	if s.SecurityToken != "" {
+		req.Header.Set(s.HeaderSecurityToken(), s.SecurityToken)
+	}
+	req.Header.Del("Authorization")
+
+	result := s.sign(req, req.URL.Query(), payloadHash, t, signContentLength)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		V4Algorithm, s.AccessKeyID, s.scope(t), result.SignedHeaders, result.Signature))
+	return result
+}
+
+// Presign 生成V4预签名URL，签名信息放在查询参数中，请求体不签名
+// req 请求对象，必填，其中的请求头会参与签名，使用URL时需要携带这些请求头
+// expires 有效时长，最长7天
+// t 签名时间
+func (s *V4Signer) Presign(req *http.Request, expires time.Duration, t time.Time) (string, *V4SignResult, error) {
+	p, err := s.NewBatchPresigner(req, expires, t)
+	if err != nil {
+		return "", nil, err
+	}
+	result := p.sign(req.URL)
+	return p.signedURL(req.URL, result.Signature), result, nil
+}
+
+// V4BatchPresigner 批量生成V4预签名URL，各URL的请求方法、主机、请求头、查询参数和签名时间相同，只有路径不同。
+// 规范化的查询参数、请求头和签名密钥只计算一次，适合为大量对象生成预签名URL
+type V4BatchPresigner struct {
+	signer        *V4Signer
+	method        string
+	query         string // 规范化查询参数，不含签名
+	headers       string // 规范化请求头
+	signedHeaders string
+	date          string
+	scope         string
+	key           []byte
+}
+
+// NewBatchPresigner 根据请求模板创建批量预签名
+// req 请求模板，必填，其中的请求方法、主机、请求头和查询参数参与签名，路径不参与签名
+// expires 有效时长，最长7天
+// t 签名时间
+func (s *V4Signer) NewBatchPresigner(req *http.Request, expires time.Duration, t time.Time) (*V4BatchPresigner, error) {
+	if expires <= 0 || expires > 7*24*time.Hour {
+		return nil, fmt.Errorf("invalid expires %v, it must be in (0s, 7 days]", expires)
+	}
+	t = t.UTC()
+	query := req.URL.Query()
+	query.Set(s.queryName("Algorithm"), V4Algorithm)
+	query.Set(s.queryName("Credential"), s.AccessKeyID+"/"+s.scope(t))
+	query.Set(s.queryName("Date"), t.Format(v4TimeFormat))
+	query.Set(s.queryName("Expires"), strconv.FormatInt(int64(expires/time.Second), 10))
+	if s.SecurityToken != "" {
+		query.Set(s.queryName("Security-Token"), s.SecurityToken)
+	}
+	query.Del(s.queryName("Signature"))
+	headers, signedHeaders := s.canonicalHeaders(req, false)
+	query.Set(s.queryName("SignedHeaders"), signedHeaders)
+
+	return &V4BatchPresigner{
+		signer:        s,
+		method:        req.Method,
+		query:         canonicalQuery(query),
+		headers:       headers,
+		signedHeaders: signedHeaders,
+		date:          t.Format(v4TimeFormat),
+		scope:         s.scope(t),
+		key:           DeriveSigningKey(s.SecretAccessKey, t.Format(v4DateFormat), s.Region, s.service()),
+	}, nil
+}
+
+// Presign 生成路径为 u 的预签名URL，u 的协议和主机需与请求模板一致，u 的查询参数会被替换
+func (p *V4BatchPresigner) Presign(u *url.URL) string {
+	return p.signedURL(u, p.sign(u).Signature)
+}
+
+// sign 计算路径为 u 的签名
+func (p *V4BatchPresigner) sign(u *url.URL) *V4SignResult {
+	canonicalRequest := strings.Join([]string{
+		p.method,
+		canonicalURI(u),
+		p.query,
+		p.headers,
+		p.signedHeaders,
+		UnsignedPayload,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		V4Algorithm,
+		p.date,
+		p.scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	return &V4SignResult{
+		CanonicalRequest: canonicalRequest,
+		StringToSign:     stringToSign,
+		SignedHeaders:    p.signedHeaders,
+		Signature:        hex.EncodeToString(hmacSHA256(p.key, []byte(stringToSign))),
+	}
+}
+
+// signedURL 拼接带签名的URL
+func (p *V4BatchPresigner) signedURL(u *url.URL, signature string) string {
+	signed := *u
+	signed.RawQuery = p.query + "&" + p.signer.queryName("Signature") + "=" + signature
+	return signed.String()
+}
+
+// sign 计算签名
+func (s *V4Signer) sign(req *http.Request, query url.Values, payloadHash string, t time.Time, signContentLength bool) *V4SignResult {
+	headers, signedHeaders := s.canonicalHeaders(req, signContentLength)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(query),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		V4Algorithm,
+		t.Format(v4TimeFormat),
+		s.scope(t),
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := DeriveSigningKey(s.SecretAccessKey, t.Format(v4DateFormat), s.Region, s.service())
+	return &V4SignResult{
+		CanonicalRequest: canonicalRequest,
+		StringToSign:     stringToSign,
+		SignedHeaders:    signedHeaders,
+		Signature:        hex.EncodeToString(hmacSHA256(key, []byte(stringToSign))),
+	}
+}
+
+// canonicalHeaders 返回规范化请求头和参与签名的请求头列表
+func (s *V4Signer) canonicalHeaders(req *http.Request, signContentLength bool) (string, string) {
+	values := map[string]string{}
+	for k, vs := range req.Header {
+		name := strings.ToLower(k)
+		if name == "host" || (v4IgnoredHeaders[name] && !(signContentLength && name == "content-length")) {
+			continue
+		}
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values["host"] = host
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.WriteString(name)
+		buf.WriteByte(':')
+		buf.WriteString(values[name])
+		buf.WriteByte('\n')
+	}
+	return buf.String(), strings.Join(names, ";")
+}
+
+// canonicalURI 规范化请求路径，除 / 外按RFC 3986编码
+func canonicalURI(u *url.URL) string {
+	path := u.Path
+	if u.Opaque != "" {
+		path = u.Opaque
+		if strings.HasPrefix(path, "//") {
+			// 形如 //host/path
+			if pos := strings.Index(path[2:], "/"); pos >= 0 {
+				path = path[2+pos:]
+			} else {
+				path = "/"
+			}
+		}
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			path = unescaped
+		}
+	}
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+// canonicalQuery 规范化查询参数，按参数名和参数值排序
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		vs := append([]string(nil), query[k]...)
+		sort.Strings(vs)
+		if len(vs) == 0 {
+			vs = []string{""}
+		}
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode 按RFC 3986编码，保留 A-Z a-z 0-9 - _ . ~，encodeSlash 为 false 时保留 /
+func uriEncode(s string, encodeSlash bool) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// DeriveSigningKey 派生签名密钥，密钥的范围为日期、区域和服务
+// sk 您的SecretAccessKey
+// date 日期，如：20130524
+// region 区域，如：cn-beijing
+// service 服务名，如：s3
+func DeriveSigningKey(sk, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+sk), []byte(date))
+	key = hmacSHA256(key, []byte(region))
+	key = hmacSHA256(key, []byte(service))
+	return hmacSHA256(key, []byte(V4Terminator))
+}
+
+// PayloadHash 计算请求体的SHA256
+func PayloadHash(data []byte) string {
+	return hashHex(data)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// SignStreaming 对请求体分块签名，设置请求头并将请求体替换为带签名的分块
+// req 请求对象，必填，req.ContentLength 为原始请求体长度，不能小于0
+// chunkSize 块大小，不大于0时使用 DefaultChunkSize
+// t 签名时间
+func (s *V4Signer) SignStreaming(req *http.Request, chunkSize int, t time.Time) (*V4SignResult, error) {
+	if req.ContentLength < 0 {
+		return nil, errors.New("the content length is required by streaming signature")
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	t = t.UTC()
+
+	decodedLength := req.ContentLength
+	encodedLength := StreamingContentLength(decodedLength, chunkSize)
+	if encoding := req.Header.Get("Content-Encoding"); encoding != "" && encoding != "aws-chunked" {
+		req.Header.Set("Content-Encoding", "aws-chunked,"+encoding)
+	} else {
+		req.Header.Set("Content-Encoding", "aws-chunked")
+	}
+	req.Header.Set(s.HeaderDecodedContentLength(), strconv.FormatInt(decodedLength, 10))
+	req.Header.Set("Content-Length", strconv.FormatInt(encodedLength, 10))
+	req.ContentLength = encodedLength
+
+	result := s.signRequest(req, StreamingPayload, t, true)
+
+	body := req.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	req.Body = &chunkedReader{
+		body:      body,
+		chunkSize: chunkSize,
+		prevSig:   result.Signature,
+		key:       DeriveSigningKey(s.SecretAccessKey, t.Format(v4DateFormat), s.Region, s.service()),
+		datetime:  t.Format(v4TimeFormat),
+		scope:     s.scope(t),
+	}
+	return result, nil
+}
+
+// StreamingContentLength 计算分块签名后的请求体长度
+func StreamingContentLength(decodedLength int64, chunkSize int) int64 {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	size := int64(chunkSize)
+	full := decodedLength / size
+	length := full * chunkLength(size)
+	if remain := decodedLength % size; remain > 0 {
+		length += chunkLength(remain)
+	}
+	return length + chunkLength(0)
+}
+
+// chunkLength 块的长度：hex(size);chunk-signature=<64>\r\n<data>\r\n
+func chunkLength(size int64) int64 {
+	return int64(len(strconv.FormatInt(size, 16))+len(";chunk-signature=")+chunkSignatureLen+2) + size + 2
+}
+
+// chunkedReader 将请求体编码为带签名的分块
+type chunkedReader struct {
+	body      io.ReadCloser
+	chunkSize int
+	prevSig   string
+	key       []byte
+	datetime  string
+	scope     string
+	buf       bytes.Buffer // 已编码未读取的数据
+	done      bool
+	err       error
+}
+
+// chunkSignature 计算块的签名
+func (r *chunkedReader) chunkSignature(data []byte) string {
+	stringToSign := strings.Join([]string{
+		streamingChunkAlgorithm,
+		r.datetime,
+		r.scope,
+		r.prevSig,
+		EmptyPayloadHash,
+		hashHex(data),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(r.key, []byte(stringToSign)))
+}
+
+func (r *chunkedReader) writeChunk(data []byte) {
+	sig := r.chunkSignature(data)
+	r.prevSig = sig
+	fmt.Fprintf(&r.buf, "%x;chunk-signature=%s\r\n", len(data), sig)
+	r.buf.Write(data)
+	r.buf.WriteString("\r\n")
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+		chunk := make([]byte, r.chunkSize)
+		n, err := io.ReadFull(r.body, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			r.err = err
+			return 0, err
+		}
+		if n > 0 {
+			r.writeChunk(chunk[:n])
+		}
+		if n < r.chunkSize {
+			// 最后一个块
+			r.writeChunk(nil)
+			r.done = true
+		}
+	}
+	return r.buf.Read(p)
+}
+
+func (r *chunkedReader) Close() error {
+	return r.body.Close()
+}
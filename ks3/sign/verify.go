@@ -0,0 +1,771 @@
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxSkew 请求时间与服务端时间允许的最大偏差
+const DefaultMaxSkew = 15 * time.Minute
+
+// subResources V1签名中参与签名的子资源参数
+var subResources = []string{"acl", "uploads", "location", "cors",
+	"logging", "website", "referer", "lifecycle",
+	"retention", "recycle", "recover", "clear",
+	"crr", "mirror", "inventory", "id", "encryption",
+	"delete", "append", "tagging", "objectMeta",
+	"uploadId", "partNumber", "security-token",
+	"position", "img", "style", "styleName",
+	"replication", "replicationProgress",
+	"replicationLocation", "cname", "bucketInfo",
+	"comp", "qos", "live", "status", "vod",
+	"startTime", "endTime", "symlink",
+	"x-ks3-process", "response-content-type", "x-ks3-traffic-limit",
+	"response-content-language", "response-expires",
+	"response-cache-control", "response-content-disposition",
+	"response-content-encoding", "udf", "udfName", "udfImage",
+	"udfId", "udfImageDesc", "udfApplication", "comp",
+	"udfApplicationLog", "restore", "callback", "callback-var", "qosInfo",
+	"policy", "stat", "encryption", "versions", "versioning", "versionId", "requestPayment",
+	"x-ks3-request-payer", "sequential", "asyncFetch",
+	"worm", "wormId", "wormExtend", "withHashContext",
+	"x-ks3-enable-md5", "x-ks3-enable-sha1", "x-ks3-enable-sha256",
+	"x-ks3-hash-ctx", "x-ks3-md5-ctx", "transferAcceleration",
+	"regionList",
+}
+
+// SubResources 返回V1签名中参与签名的子资源参数的副本
+func SubResources() []string {
+	out := make([]string, len(subResources))
+	copy(out, subResources)
+	return out
+}
+
+// 预签名URL中的参数名
+const (
+	paramExpires           = "Expires"
+	paramAccessKeyID       = "KSSAccessKeyId"
+	paramSignature         = "Signature"
+	paramSecurityToken     = "security-token"
+	paramPolicy            = "X-Kss-Policy"
+	paramSignatureVersion  = "X-Kss-signature-version"
+	paramExpiresV2         = "X-Kss-expires"
+	paramAccessKeyIDV2     = "X-Kss-access-key-id"
+	paramSignatureV2       = "X-Kss-signature"
+	paramAdditionalHeaders = "X-Kss-additional-headers"
+)
+
+// VerifyReason 签名校验失败的原因，取值与服务端的错误码一致
+type VerifyReason string
+
+const (
+	// ReasonMissingSignature 请求中没有签名
+	ReasonMissingSignature VerifyReason = "MissingSecurityHeader"
+	// ReasonMalformedSignature 签名格式错误或缺少参与签名的字段
+	ReasonMalformedSignature VerifyReason = "AuthorizationHeaderMalformed"
+	// ReasonUnknownAccessKey AccessKeyID不存在
+	ReasonUnknownAccessKey VerifyReason = "InvalidAccessKeyId"
+	// ReasonExpired 预签名URL已过期
+	ReasonExpired VerifyReason = "RequestExpired"
+	// ReasonRequestTimeTooSkewed 请求时间与服务端时间偏差过大
+	ReasonRequestTimeTooSkewed VerifyReason = "RequestTimeTooSkewed"
+	// ReasonSignatureMismatch 签名不一致
+	ReasonSignatureMismatch VerifyReason = "SignatureDoesNotMatch"
+)
+
+// VerifyError 签名校验失败的错误
+type VerifyError struct {
+	Reason      VerifyReason // 失败原因
+	AccessKeyID string       // 请求中的AccessKeyID，签名格式错误时可能为空
+	Message     string       // 详细信息
+	Err         error        // 查询密钥时的错误
+}
+
+// Error 实现 error 接口
+func (e *VerifyError) Error() string {
+	msg := fmt.Sprintf("sign: verify failed, reason: %s, %s", e.Reason, e.Message)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap 返回查询密钥时的错误
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// SignatureForm 签名的形式
+type SignatureForm string
+
+const (
+	FormHeaderV1 SignatureForm = "HeaderV1" // Authorization: KSS ak:signature
+	FormHeaderV2 SignatureForm = "HeaderV2" // Authorization: KS32 AccessKeyId:ak,Signature:signature
+	FormHeaderV4 SignatureForm = "HeaderV4" // Authorization: AWS4-HMAC-SHA256 Credential=...
+	FormQueryV1  SignatureForm = "QueryV1"  // ?KSSAccessKeyId=ak&Expires=...&Signature=...
+	FormQueryV2  SignatureForm = "QueryV2"  // ?X-Kss-signature-version=KSS2&...
+	FormQueryV4  SignatureForm = "QueryV4"  // ?X-Kss-Algorithm=AWS4-HMAC-SHA256&...
+	FormPolicy   SignatureForm = "Policy"   // ?X-Kss-Policy=...&KSSAccessKeyId=ak&Expires=...&Signature=...
+)
+
+// VerifyResult 签名校验通过后的请求信息
+type VerifyResult struct {
+	AccessKeyID   string        // 签名使用的AccessKeyID
+	SecurityToken string        // STS临时凭证的安全令牌，未使用时为空
+	Form          SignatureForm // 签名的形式
+	Bucket        string        // 签名中的存储空间名称，V4签名不包含存储空间，此时为空
+	Object        string        // 签名中的对象名称
+	Expires       time.Time     // 预签名URL的过期时间，请求头签名时为零值
+}
+
+// Verifier 服务端签名校验，支持V1、V2、V4的请求头签名和预签名URL
+// V4签名只校验 X-Kss-Content-Sha256 请求头的取值，不校验请求体，分块签名的请求只校验首个签名
+type Verifier struct {
+	Lookup  func(ak string) (sk string, err error) // 根据AccessKeyID查询SecretAccessKey，必填
+	MaxSkew time.Duration                          // 请求时间允许的最大偏差，默认为 DefaultMaxSkew
+	Now     func() time.Time                       // 服务端时间，默认为 time.Now
+
+	// Bucket 从请求中解析存储空间名称，为空时按照虚拟主机和路径两种访问方式解析
+	// 使用自定义域名（CNAME）访问时需要指定
+	Bucket func(req *http.Request) string
+}
+
+// Verify 使用默认配置校验请求的签名
+// req 服务端收到的请求
+// lookup 根据AccessKeyID查询SecretAccessKey，AccessKeyID不存在时返回错误
+func Verify(req *http.Request, lookup func(ak string) (sk string, err error)) (*VerifyResult, error) {
+	return (&Verifier{Lookup: lookup}).Verify(req)
+}
+
+// Verify 校验请求的签名，失败时返回 *VerifyError
+func (v *Verifier) Verify(req *http.Request) (*VerifyResult, error) {
+	auth := req.Header.Get("Authorization")
+	query := req.URL.Query()
+	switch {
+	case strings.HasPrefix(auth, "KSS "):
+		return v.verifyHeaderV1(req, strings.TrimPrefix(auth, "KSS "))
+	case strings.HasPrefix(auth, "KS32 "):
+		return v.verifyHeaderV2(req, strings.TrimPrefix(auth, "KS32 "))
+	case strings.HasPrefix(auth, V4Algorithm+" "):
+		return v.verifyHeaderV4(req, strings.TrimPrefix(auth, V4Algorithm+" "))
+	case auth != "":
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, Message: "unsupported authorization " + strings.SplitN(auth, " ", 2)[0]}
+	case query.Get("X-Kss-Algorithm") != "":
+		return v.verifyQueryV4(req, query)
+	case query.Get(paramSignatureV2) != "" || query.Get(paramSignatureVersion) != "":
+		return v.verifyQueryV2(req, query)
+	case query.Get(paramSignature) != "" || query.Get(paramAccessKeyID) != "":
+		return v.verifyQueryV1(req, query)
+	}
+	return nil, &VerifyError{Reason: ReasonMissingSignature, Message: "neither authorization header nor signature parameters found"}
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+func (v *Verifier) maxSkew() time.Duration {
+	if v.MaxSkew > 0 {
+		return v.MaxSkew
+	}
+	return DefaultMaxSkew
+}
+
+// secretKey 查询AccessKeyID对应的SecretAccessKey
+func (v *Verifier) secretKey(ak string) (string, error) {
+	if ak == "" {
+		return "", &VerifyError{Reason: ReasonMalformedSignature, Message: "access key id is empty"}
+	}
+	if v.Lookup == nil {
+		return "", &VerifyError{Reason: ReasonUnknownAccessKey, AccessKeyID: ak, Message: "no secret key lookup"}
+	}
+	sk, err := v.Lookup(ak)
+	if err != nil || sk == "" {
+		return "", &VerifyError{Reason: ReasonUnknownAccessKey, AccessKeyID: ak, Message: "unknown access key id", Err: err}
+	}
+	return sk, nil
+}
+
+// checkSkew 检查请求时间与服务端时间的偏差
+func (v *Verifier) checkSkew(ak string, t time.Time) error {
+	skew := v.now().Sub(t)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > v.maxSkew() {
+		return &VerifyError{Reason: ReasonRequestTimeTooSkewed, AccessKeyID: ak,
+			Message: fmt.Sprintf("request time %s differs from server time by %v", t.UTC().Format(time.RFC3339), skew)}
+	}
+	return nil
+}
+
+// checkExpires 检查预签名URL是否过期
+func (v *Verifier) checkExpires(ak string, expires time.Time) error {
+	if v.now().After(expires) {
+		return &VerifyError{Reason: ReasonExpired, AccessKeyID: ak,
+			Message: "request has expired at " + expires.UTC().Format(time.RFC3339)}
+	}
+	return nil
+}
+
+// requestDate 解析请求头签名的Date请求头
+func requestDate(req *http.Request, ak string) (time.Time, error) {
+	date := req.Header.Get("Date")
+	if date == "" {
+		return time.Time{}, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: ak, Message: "missing Date header"}
+	}
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return time.Time{}, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: ak, Message: "invalid Date header " + date}
+	}
+	return t, nil
+}
+
+// unixExpires 解析预签名URL中以秒为单位的过期时间
+func unixExpires(value, ak string) (time.Time, error) {
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: ak, Message: "invalid expires " + value}
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// mismatch 签名不一致的错误
+func mismatch(ak string) error {
+	return &VerifyError{Reason: ReasonSignatureMismatch, AccessKeyID: ak,
+		Message: "the calculated signature does not match the provided signature"}
+}
+
+// signatureEqual 以固定时间比较签名，避免时序攻击
+func signatureEqual(expected, provided string) bool {
+	return hmac.Equal([]byte(expected), []byte(provided))
+}
+
+// verifyTarget 请求可能对应的存储空间和对象
+type verifyTarget struct {
+	bucket string
+	object string
+}
+
+// targets 解析请求可能对应的存储空间和对象，虚拟主机和路径两种访问方式无法从请求中区分，因此都需要尝试
+func (v *Verifier) targets(req *http.Request) []verifyTarget {
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	var targets []verifyTarget
+	if v.Bucket != nil {
+		bucket := v.Bucket(req)
+		targets = append(targets, verifyTarget{bucket, strings.TrimPrefix(path, "/")})
+		if bucket != "" && strings.HasPrefix(path, "/"+bucket+"/") {
+			targets = append(targets, verifyTarget{bucket, strings.TrimPrefix(path, "/"+bucket+"/")})
+		}
+		return targets
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) == nil && strings.Count(host, ".") >= 2 {
+		targets = append(targets, verifyTarget{strings.SplitN(host, ".", 2)[0], strings.TrimPrefix(path, "/")})
+	}
+	if path == "/" {
+		targets = append(targets, verifyTarget{"", ""})
+	} else {
+		parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+		target := verifyTarget{bucket: parts[0]}
+		if len(parts) == 2 {
+			target.object = parts[1]
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// resourceV1 V1签名的规范化资源
+func resourceV1(target verifyTarget, subResource string) string {
+	if subResource != "" {
+		subResource = "?" + subResource
+	}
+	if target.bucket == "" {
+		return "/" + subResource
+	}
+	return "/" + target.bucket + "/" + encodeResourceKey(target.object) + subResource
+}
+
+// resourceV2 V2签名的规范化资源
+func resourceV2(target verifyTarget, subResource string) string {
+	if subResource != "" {
+		subResource = "?" + subResource
+	}
+	if target.bucket == "" {
+		return url.QueryEscape("/") + subResource
+	}
+	return url.QueryEscape("/"+target.bucket+"/") + strings.Replace(url.QueryEscape(target.object), "+", "%20", -1) + subResource
+}
+
+// encodeResourceKey 编码规范化资源中的对象名称，与 ks3 包的 encodeKS3Str 一致
+func encodeResourceKey(str string) string {
+	objectName := url.QueryEscape(str)
+	objectName = strings.ReplaceAll(objectName, "+", "%20")
+	objectName = strings.ReplaceAll(objectName, "*", "%2A")
+	objectName = strings.ReplaceAll(objectName, "%7E", "~")
+	objectName = strings.ReplaceAll(objectName, "%2F", "/")
+	objectName = strings.ReplaceAll(objectName, "//", "/%2F")
+	if strings.HasPrefix(objectName, "/") {
+		objectName = strings.Replace(objectName, "/", "%2F", 1)
+	}
+	return objectName
+}
+
+// subResourceV1 V1签名的子资源，只包含 keys 中的参数，参数值不编码
+func subResourceV1(query url.Values, keys []string) string {
+	var names []string
+	for _, k := range keys {
+		if _, ok := query[k]; ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	names = uniqueSorted(names)
+
+	var pairs []string
+	for _, k := range names {
+		if value := query.Get(k); value != "" {
+			pairs = append(pairs, k+"="+value)
+		} else {
+			pairs = append(pairs, k)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// subResourceV2 V2签名的子资源，包含除 excluded 外的全部参数，参数名和参数值都需要编码
+func subResourceV2(query url.Values, excluded ...string) string {
+	skip := map[string]bool{}
+	for _, k := range excluded {
+		skip[k] = true
+	}
+	values := map[string]string{}
+	var names []string
+	for k := range query {
+		if skip[k] {
+			continue
+		}
+		name := url.QueryEscape(k)
+		names = append(names, name)
+		if value := query.Get(k); value != "" {
+			values[name] = strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+		}
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, k := range names {
+		if value, ok := values[k]; ok {
+			pairs = append(pairs, k+"="+value)
+		} else {
+			pairs = append(pairs, k)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func uniqueSorted(names []string) []string {
+	result := names[:0]
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// canonicalizedKssHeaders 规范化 x-kss- 请求头和V2签名指定的附加请求头，每个请求头只取第一个值
+func canonicalizedKssHeaders(req *http.Request, additional []string) string {
+	values := map[string]string{}
+	for k, vs := range req.Header {
+		if name := strings.ToLower(k); strings.HasPrefix(name, "x-kss-") && len(vs) > 0 {
+			values[name] = vs[0]
+		}
+	}
+	for _, name := range additional {
+		if vs := req.Header.Values(name); len(vs) > 0 {
+			values[name] = vs[0]
+		}
+	}
+	hs := newHeaderSorter(values)
+	hs.Sort()
+
+	var buf strings.Builder
+	for i := range hs.Keys {
+		buf.WriteString(hs.Keys[i] + ":" + hs.Vals[i] + "\n")
+	}
+	return buf.String()
+}
+
+// stringToSign V1和V2签名的待签名字符串，date 为Date请求头或预签名URL的过期时间
+func stringToSign(req *http.Request, date, kssHeaders string, additional []string, resource string) string {
+	prefix := req.Method + "\n" + req.Header.Get("Content-MD5") + "\n" + req.Header.Get("Content-Type") + "\n" + date + "\n" + kssHeaders
+	if additional == nil {
+		return prefix + resource
+	}
+	return prefix + strings.Join(additional, ";") + "\n" + resource
+}
+
+// hmacBase64 计算HMAC并进行base64编码
+func hmacBase64(h func() hash.Hash, sk, str string) string {
+	mac := hmac.New(h, []byte(sk))
+	io.WriteString(mac, str)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// matchTargets 依次尝试可能的存储空间和对象，返回签名一致的一个，calc 返回该存储空间和对象可能的签名
+func (v *Verifier) matchTargets(req *http.Request, ak, signature string, calc func(target verifyTarget) []string) (verifyTarget, error) {
+	for _, target := range v.targets(req) {
+		for _, expected := range calc(target) {
+			if signatureEqual(expected, signature) {
+				return target, nil
+			}
+		}
+	}
+	return verifyTarget{}, mismatch(ak)
+}
+
+// verifyHeaderV1 校验 Authorization: KSS ak:signature
+func (v *Verifier) verifyHeaderV1(req *http.Request, credential string) (*VerifyResult, error) {
+	pos := strings.LastIndex(credential, ":")
+	if pos < 0 {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, Message: "authorization must be KSS AccessKeyId:Signature"}
+	}
+	ak, signature := credential[:pos], credential[pos+1:]
+	sk, err := v.secretKey(ak)
+	if err != nil {
+		return nil, err
+	}
+	t, err := requestDate(req, ak)
+	if err != nil {
+		return nil, err
+	}
+	if err = v.checkSkew(ak, t); err != nil {
+		return nil, err
+	}
+
+	query := req.URL.Query()
+	kssHeaders := canonicalizedKssHeaders(req, nil)
+	date := req.Header.Get("Date")
+	target, err := v.matchTargets(req, ak, signature, func(target verifyTarget) []string {
+		resource := resourceV1(target, subResourceV1(query, subResources))
+		return []string{hmacBase64(sha1.New, sk, stringToSign(req, date, kssHeaders, nil, resource))}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &VerifyResult{AccessKeyID: ak, SecurityToken: req.Header.Get("X-Kss-Security-Token"), Form: FormHeaderV1,
+		Bucket: target.bucket, Object: target.object}, nil
+}
+
+// verifyHeaderV2 校验 Authorization: KS32 AccessKeyId:ak,AdditionalHeaders:a;b,Signature:signature
+func (v *Verifier) verifyHeaderV2(req *http.Request, credential string) (*VerifyResult, error) {
+	fields := map[string]string{}
+	for _, part := range strings.Split(credential, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		if len(kv) != 2 {
+			return nil, &VerifyError{Reason: ReasonMalformedSignature, Message: "invalid authorization field " + part}
+		}
+		fields[kv[0]] = kv[1]
+	}
+	ak, signature := fields["AccessKeyId"], fields["Signature"]
+	if signature == "" {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: ak, Message: "missing Signature in authorization"}
+	}
+	sk, err := v.secretKey(ak)
+	if err != nil {
+		return nil, err
+	}
+	t, err := requestDate(req, ak)
+	if err != nil {
+		return nil, err
+	}
+	if err = v.checkSkew(ak, t); err != nil {
+		return nil, err
+	}
+
+	additional := additionalHeaders(fields["AdditionalHeaders"])
+	query := req.URL.Query()
+	kssHeaders := canonicalizedKssHeaders(req, additional)
+	date := req.Header.Get("Date")
+	target, err := v.matchTargets(req, ak, signature, func(target verifyTarget) []string {
+		resource := resourceV2(target, subResourceV2(query))
+		return []string{hmacBase64(sha256.New, sk, stringToSign(req, date, kssHeaders, additional, resource))}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &VerifyResult{AccessKeyID: ak, SecurityToken: req.Header.Get("X-Kss-Security-Token"), Form: FormHeaderV2,
+		Bucket: target.bucket, Object: target.object}, nil
+}
+
+// additionalHeaders 解析V2签名的附加请求头列表，返回值不为 nil
+func additionalHeaders(value string) []string {
+	headers := []string{}
+	for _, name := range strings.Split(value, ";") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			headers = append(headers, name)
+		}
+	}
+	return headers
+}
+
+// verifyQueryV1 校验V1预签名URL，包含 X-Kss-Policy 参数时按照策略签名校验
+func (v *Verifier) verifyQueryV1(req *http.Request, query url.Values) (*VerifyResult, error) {
+	ak, signature := query.Get(paramAccessKeyID), query.Get(paramSignature)
+	if signature == "" || query.Get(paramExpires) == "" {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: ak,
+			Message: "presigned url must contain KSSAccessKeyId, Expires and Signature"}
+	}
+	sk, err := v.secretKey(ak)
+	if err != nil {
+		return nil, err
+	}
+	expires, err := unixExpires(query.Get(paramExpires), ak)
+	if err != nil {
+		return nil, err
+	}
+	if err = v.checkExpires(ak, expires); err != nil {
+		return nil, err
+	}
+
+	date := query.Get(paramExpires)
+	form := FormQueryV1
+	var calc func(target verifyTarget) []string
+	if _, ok := query[paramPolicy]; ok {
+		// 策略签名只包含过期时间和规范化资源，V2签名方式生成时子资源包含全部参数
+		form = FormPolicy
+		calc = func(target verifyTarget) []string {
+			target.object = ""
+			return []string{
+				hmacBase64(sha1.New, sk, date+"\n"+resourceV1(target, subResourceV1(query, []string{paramPolicy, paramSecurityToken}))),
+				hmacBase64(sha1.New, sk, date+"\n"+resourceV2(target, subResourceV2(query, paramExpires, paramAccessKeyID, paramSignature))),
+			}
+		}
+	} else {
+		kssHeaders := canonicalizedKssHeaders(req, nil)
+		calc = func(target verifyTarget) []string {
+			resource := resourceV1(target, subResourceV1(query, subResources))
+			return []string{hmacBase64(sha1.New, sk, stringToSign(req, date, kssHeaders, nil, resource))}
+		}
+	}
+	target, err := v.matchTargets(req, ak, signature, calc)
+	if err != nil {
+		return nil, err
+	}
+	result := &VerifyResult{AccessKeyID: ak, SecurityToken: query.Get(paramSecurityToken), Form: form,
+		Bucket: target.bucket, Object: target.object, Expires: expires}
+	if form == FormPolicy {
+		result.Object = ""
+	}
+	return result, nil
+}
+
+// verifyQueryV2 校验V2预签名URL，除签名外的全部参数都参与签名
+func (v *Verifier) verifyQueryV2(req *http.Request, query url.Values) (*VerifyResult, error) {
+	ak, signature := query.Get(paramAccessKeyIDV2), query.Get(paramSignatureV2)
+	if signature == "" || query.Get(paramExpiresV2) == "" || query.Get(paramSignatureVersion) != "KSS2" {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: ak,
+			Message: "presigned url must contain X-Kss-signature-version=KSS2, X-Kss-access-key-id, X-Kss-expires and X-Kss-signature"}
+	}
+	sk, err := v.secretKey(ak)
+	if err != nil {
+		return nil, err
+	}
+	expires, err := unixExpires(query.Get(paramExpiresV2), ak)
+	if err != nil {
+		return nil, err
+	}
+	if err = v.checkExpires(ak, expires); err != nil {
+		return nil, err
+	}
+
+	date := query.Get(paramExpiresV2)
+	additional := additionalHeaders(query.Get(paramAdditionalHeaders))
+	kssHeaders := canonicalizedKssHeaders(req, additional)
+	subResource := subResourceV2(query, paramSignatureV2)
+	target, err := v.matchTargets(req, ak, signature, func(target verifyTarget) []string {
+		return []string{hmacBase64(sha256.New, sk, stringToSign(req, date, kssHeaders, additional, resourceV2(target, subResource)))}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &VerifyResult{AccessKeyID: ak, SecurityToken: query.Get(paramSecurityToken), Form: FormQueryV2,
+		Bucket: target.bucket, Object: target.object, Expires: expires}, nil
+}
+
+// v4Credential V4签名的凭证范围，如：ak/20130524/cn-beijing/s3/aws4_request
+type v4Credential struct {
+	ak      string
+	date    string
+	region  string
+	service string
+}
+
+func parseV4Credential(value string) (*v4Credential, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 5 || parts[len(parts)-1] != V4Terminator {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, Message: "invalid credential " + value}
+	}
+	n := len(parts)
+	return &v4Credential{ak: strings.Join(parts[:n-4], "/"), date: parts[n-4], region: parts[n-3], service: parts[n-2]}, nil
+}
+
+// v4Time 解析V4签名时间，日期需与凭证范围一致
+func v4Time(value string, cred *v4Credential) (time.Time, error) {
+	t, err := time.Parse(v4TimeFormat, value)
+	if err != nil {
+		return time.Time{}, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: cred.ak, Message: "invalid request time " + value}
+	}
+	if t.Format(v4DateFormat) != cred.date {
+		return time.Time{}, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: cred.ak,
+			Message: "credential date " + cred.date + " does not match request time " + value}
+	}
+	return t, nil
+}
+
+// signedRequest 复制只包含参与签名的请求头的请求
+func signedRequest(req *http.Request, signedHeaders string) (*http.Request, bool) {
+	signed := &http.Request{
+		Method: req.Method,
+		URL:    &url.URL{Path: req.URL.Path, RawPath: req.URL.RawPath, Opaque: req.URL.Opaque},
+		Host:   req.Host,
+		Header: http.Header{},
+	}
+	if signed.Host == "" {
+		signed.Host = req.URL.Host
+	}
+	signContentLength := false
+	for _, name := range strings.Split(signedHeaders, ";") {
+		switch name {
+		case "host":
+		case "content-length":
+			signContentLength = true
+			value := req.Header.Get("Content-Length")
+			if value == "" && req.ContentLength >= 0 {
+				value = strconv.FormatInt(req.ContentLength, 10)
+			}
+			signed.Header.Set("Content-Length", value)
+		default:
+			if vs := req.Header.Values(name); len(vs) > 0 {
+				signed.Header[http.CanonicalHeaderKey(name)] = vs
+			}
+		}
+	}
+	return signed, signContentLength
+}
+
+// verifyHeaderV4 校验 Authorization: AWS4-HMAC-SHA256 Credential=...,SignedHeaders=...,Signature=...
+func (v *Verifier) verifyHeaderV4(req *http.Request, value string) (*VerifyResult, error) {
+	fields := map[string]string{}
+	for _, part := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	if fields["Credential"] == "" || fields["SignedHeaders"] == "" || fields["Signature"] == "" {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, Message: "authorization must contain Credential, SignedHeaders and Signature"}
+	}
+	cred, err := parseV4Credential(fields["Credential"])
+	if err != nil {
+		return nil, err
+	}
+	sk, err := v.secretKey(cred.ak)
+	if err != nil {
+		return nil, err
+	}
+	signer := &V4Signer{AccessKeyID: cred.ak, SecretAccessKey: sk, Region: cred.region, Service: cred.service}
+	t, err := v4Time(req.Header.Get(signer.HeaderDate()), cred)
+	if err != nil {
+		return nil, err
+	}
+	if err = v.checkSkew(cred.ak, t); err != nil {
+		return nil, err
+	}
+	payloadHash := req.Header.Get(signer.HeaderContentSha256())
+	if payloadHash == "" {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: cred.ak,
+			Message: "missing " + signer.HeaderContentSha256() + " header"}
+	}
+
+	signed, signContentLength := signedRequest(req, fields["SignedHeaders"])
+	result := signer.sign(signed, req.URL.Query(), payloadHash, t, signContentLength)
+	if result.SignedHeaders != fields["SignedHeaders"] || !signatureEqual(result.Signature, fields["Signature"]) {
+		return nil, mismatch(cred.ak)
+	}
+	return &VerifyResult{AccessKeyID: cred.ak, SecurityToken: req.Header.Get(signer.HeaderSecurityToken()), Form: FormHeaderV4,
+		Object: strings.TrimPrefix(req.URL.Path, "/")}, nil
+}
+
+// verifyQueryV4 校验V4预签名URL，请求体不签名
+func (v *Verifier) verifyQueryV4(req *http.Request, query url.Values) (*VerifyResult, error) {
+	signer := &V4Signer{}
+	if algorithm := query.Get(signer.queryName("Algorithm")); algorithm != V4Algorithm {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, Message: "unsupported algorithm " + algorithm}
+	}
+	signedHeaders, signature := query.Get(signer.queryName("SignedHeaders")), query.Get(signer.queryName("Signature"))
+	if signedHeaders == "" || signature == "" {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, Message: "presigned url must contain SignedHeaders and Signature"}
+	}
+	cred, err := parseV4Credential(query.Get(signer.queryName("Credential")))
+	if err != nil {
+		return nil, err
+	}
+	t, err := v4Time(query.Get(signer.queryName("Date")), cred)
+	if err != nil {
+		return nil, err
+	}
+	sec, err := strconv.ParseInt(query.Get(signer.queryName("Expires")), 10, 64)
+	if err != nil || sec <= 0 {
+		return nil, &VerifyError{Reason: ReasonMalformedSignature, AccessKeyID: cred.ak,
+			Message: "invalid expires " + query.Get(signer.queryName("Expires"))}
+	}
+	sk, err := v.secretKey(cred.ak)
+	if err != nil {
+		return nil, err
+	}
+	if t.Sub(v.now()) > v.maxSkew() {
+		return nil, v.checkSkew(cred.ak, t)
+	}
+	expires := t.Add(time.Duration(sec) * time.Second)
+	if err = v.checkExpires(cred.ak, expires); err != nil {
+		return nil, err
+	}
+
+	signer.AccessKeyID, signer.SecretAccessKey, signer.Region, signer.Service = cred.ak, sk, cred.region, cred.service
+	signed, _ := signedRequest(req, signedHeaders)
+	query.Del(signer.queryName("Signature"))
+	result := signer.sign(signed, query, UnsignedPayload, t, false)
+	if result.SignedHeaders != signedHeaders || !signatureEqual(result.Signature, signature) {
+		return nil, mismatch(cred.ak)
+	}
+	return &VerifyResult{AccessKeyID: cred.ak, SecurityToken: query.Get(signer.queryName("Security-Token")), Form: FormQueryV4,
+		Object: strings.TrimPrefix(req.URL.Path, "/"), Expires: expires}, nil
+}
@@ -0,0 +1,337 @@
+package ks3
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
+	. "gopkg.in/check.v1"
+)
+
+type Ks3SignVerifySuite struct{}
+
+var _ = Suite(&Ks3SignVerifySuite{})
+
+var errUnknownAK = errors.New("no such access key")
+
+func verifyLookup(ak string) (string, error) {
+	if ak == "ak" || ak == "sts-ak" {
+		return "sk", nil
+	}
+	return "", errUnknownAK
+}
+
+func newVerifyConn(c *C, endpoint string, options ...ClientOption) *Conn {
+	client, err := New(endpoint, "ak", "sk", options...)
+	c.Assert(err, IsNil)
+	return client.Conn
+}
+
+// signedRequest builds the request as the server receives it and signs it by Conn.signHeader
+func signedRequest(c *C, conn *Conn, method, bucketName, objectName string, params map[string]interface{},
+	headers map[string]string) *http.Request {
+	uri := conn.Url.getURL(bucketName, encodeKS3Str(objectName), conn.getURLParams(params))
+	req, err := http.NewRequest(method, uri.String(), nil)
+	c.Assert(err, IsNil)
+	req.Header.Set(HTTPHeaderDate, time.Now().UTC().Format(http.TimeFormat))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	akIf, err := conn.config.getCredentials()
+	c.Assert(err, IsNil)
+	if akIf.GetSecurityToken() != "" {
+		req.Header.Set(HTTPHeaderKs3SecurityToken, akIf.GetSecurityToken())
+	}
+	if conn.config.AuthVersion == AuthV4 {
+		req.Header.Set(HTTPHeaderKs3ContentSha256, sign.EmptyPayloadHash)
+	}
+	conn.signHeaderWithCredentials(req, conn.getResource(bucketName, objectName, conn.getSubResource(params)), akIf)
+	return req
+}
+
+// urlRequest builds the request of the signed URL
+func urlRequest(c *C, method, signedURL string, headers map[string]string) *http.Request {
+	req, err := http.NewRequest(method, signedURL, nil)
+	c.Assert(err, IsNil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func assertReason(c *C, err error, reason sign.VerifyReason) {
+	c.Assert(err, NotNil)
+	verifyErr, ok := err.(*sign.VerifyError)
+	c.Assert(ok, Equals, true)
+	c.Assert(verifyErr.Reason, Equals, reason)
+}
+
+func (s *Ks3SignVerifySuite) TestHeaderV1(c *C) {
+	conn := newVerifyConn(c, "http://ks3-cn-beijing.ksyuncs.com")
+	params := map[string]interface{}{"versionId": "v1", "prefix": "ignored"}
+	headers := map[string]string{HTTPHeaderContentType: "text/plain", HTTPHeaderContentMD5: "md5",
+		"X-Kss-Meta-Name": "value", HTTPHeaderKs3ACL: "private"}
+	req := signedRequest(c, conn, "PUT", "verify-bucket", "dir/object name+*~", params, headers)
+	c.Assert(strings.HasPrefix(req.Header.Get(HTTPHeaderAuthorization), "KSS ak:"), Equals, true)
+
+	result, err := sign.Verify(req, verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Form, Equals, sign.FormHeaderV1)
+	c.Assert(result.AccessKeyID, Equals, "ak")
+	c.Assert(result.Bucket, Equals, "verify-bucket")
+	c.Assert(result.Object, Equals, "dir/object name+*~")
+
+	// A signed header is changed
+	req.Header.Set("X-Kss-Meta-Name", "other")
+	_, err = sign.Verify(req, verifyLookup)
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+
+	// Service and bucket requests
+	req = signedRequest(c, conn, "GET", "", "", map[string]interface{}{}, nil)
+	result, err = sign.Verify(req, verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Bucket, Equals, "")
+	req = signedRequest(c, conn, "GET", "verify-bucket", "", map[string]interface{}{"acl": nil}, nil)
+	result, err = sign.Verify(req, verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Bucket, Equals, "verify-bucket")
+	c.Assert(result.Object, Equals, "")
+
+	// The sub-resources are a copy, changing it doesn't change the signatures
+	subResources := sign.SubResources()
+	c.Assert(subResources[0], Equals, "acl")
+	subResources[0] = "prefix"
+	c.Assert(sign.SubResources()[0], Equals, "acl")
+	_, err = sign.Verify(req, verifyLookup)
+	c.Assert(err, IsNil)
+}
+
+func (s *Ks3SignVerifySuite) TestHeaderV2(c *C) {
+	conn := newVerifyConn(c, "http://ks3-cn-beijing.ksyuncs.com", AuthVersion(AuthV2),
+		AdditionalHeaders([]string{"Content-Language", "X-Custom"}))
+	params := map[string]interface{}{"prefix": "a b", "max-keys": "10", "acl": nil}
+	headers := map[string]string{"Content-Language": "zh-CN", "X-Kss-Meta-Name": "value"}
+	req := signedRequest(c, conn, "GET", "verify-bucket", "dir/object", params, headers)
+	c.Assert(strings.HasPrefix(req.Header.Get(HTTPHeaderAuthorization),
+		"KS32 AccessKeyId:ak,AdditionalHeaders:content-language,Signature:"), Equals, true)
+
+	result, err := sign.Verify(req, verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Form, Equals, sign.FormHeaderV2)
+	c.Assert(result.Object, Equals, "dir/object")
+
+	// All parameters are signed in v2
+	req.URL.RawQuery += "&extra=1"
+	_, err = sign.Verify(req, verifyLookup)
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+
+	req = signedRequest(c, conn, "GET", "verify-bucket", "object", map[string]interface{}{}, headers)
+	req.Header.Set("Content-Language", "en")
+	_, err = sign.Verify(req, verifyLookup)
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+}
+
+func (s *Ks3SignVerifySuite) TestHeaderV4(c *C) {
+	conn := newVerifyConn(c, "http://ks3-cn-beijing.ksyuncs.com", AuthVersion(AuthV4),
+		SetCredentialsProvider(NewStaticCredentialsProvider("sts-ak", "sk", "token")))
+	req := signedRequest(c, conn, "GET", "verify-bucket", "dir/object", map[string]interface{}{"versionId": "v1"},
+		map[string]string{"X-Kss-Meta-Name": "value"})
+
+	result, err := sign.Verify(req, verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Form, Equals, sign.FormHeaderV4)
+	c.Assert(result.AccessKeyID, Equals, "sts-ak")
+	c.Assert(result.SecurityToken, Equals, "token")
+	c.Assert(result.Object, Equals, "dir/object")
+
+	req.Header.Set(HTTPHeaderKs3ContentSha256, sign.UnsignedPayload)
+	_, err = sign.Verify(req, verifyLookup)
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+}
+
+func (s *Ks3SignVerifySuite) TestPathStyleAndCname(c *C) {
+	conn := newVerifyConn(c, "http://ks3-cn-beijing.ksyuncs.com", PathStyleAccess(true))
+	req := signedRequest(c, conn, "GET", "verify-bucket", "dir/object", map[string]interface{}{}, nil)
+	c.Assert(req.URL.Path, Equals, "/verify-bucket/dir/object")
+	result, err := sign.Verify(req, verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Bucket, Equals, "verify-bucket")
+	c.Assert(result.Object, Equals, "dir/object")
+
+	conn = newVerifyConn(c, "http://127.0.0.1")
+	req = signedRequest(c, conn, "GET", "verify-bucket", "object", map[string]interface{}{}, nil)
+	c.Assert(req.URL.Path, Equals, "/verify-bucket/object")
+	result, err = sign.Verify(req, verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Bucket, Equals, "verify-bucket")
+
+	// The bucket of the custom domain is resolved by the verifier
+	conn = newVerifyConn(c, "http://static.example.com", UseCname(true))
+	req = signedRequest(c, conn, "GET", "verify-bucket", "dir/object", map[string]interface{}{}, nil)
+	c.Assert(req.Host, Equals, "static.example.com")
+	_, err = sign.Verify(req, verifyLookup)
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+	verifier := &sign.Verifier{Lookup: verifyLookup, Bucket: func(*http.Request) string { return "verify-bucket" }}
+	result, err = verifier.Verify(req)
+	c.Assert(err, IsNil)
+	c.Assert(result.Object, Equals, "dir/object")
+}
+
+func (s *Ks3SignVerifySuite) TestSignURL(c *C) {
+	conn := newVerifyConn(c, "http://ks3-cn-beijing.ksyuncs.com",
+		SetCredentialsProvider(NewStaticCredentialsProvider("sts-ak", "sk", "token")))
+	expiration := time.Now().Unix() + 60
+	headers := map[string]string{HTTPHeaderContentType: "text/plain", "X-Kss-Meta-Name": "value"}
+	signedURL, err := conn.signURL(HTTPPut, "verify-bucket", "dir/object name", expiration,
+		map[string]interface{}{"response-content-type": "text/html"}, headers)
+	c.Assert(err, IsNil)
+
+	result, err := sign.Verify(urlRequest(c, "PUT", signedURL, headers), verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Form, Equals, sign.FormQueryV1)
+	c.Assert(result.AccessKeyID, Equals, "sts-ak")
+	c.Assert(result.SecurityToken, Equals, "token")
+	c.Assert(result.Object, Equals, "dir/object name")
+	c.Assert(result.Expires.Unix(), Equals, expiration)
+
+	// The signed headers must be sent
+	_, err = sign.Verify(urlRequest(c, "PUT", signedURL, nil), verifyLookup)
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+	_, err = sign.Verify(urlRequest(c, "GET", signedURL, headers), verifyLookup)
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+
+	verifier := &sign.Verifier{Lookup: verifyLookup, Now: func() time.Time { return time.Now().Add(2 * time.Minute) }}
+	_, err = verifier.Verify(urlRequest(c, "PUT", signedURL, headers))
+	assertReason(c, err, sign.ReasonExpired)
+}
+
+func (s *Ks3SignVerifySuite) TestSignURLV2(c *C) {
+	conn := newVerifyConn(c, "http://ks3-cn-beijing.ksyuncs.com", AuthVersion(AuthV2),
+		AdditionalHeaders([]string{"Content-Language"}))
+	headers := map[string]string{"Content-Language": "zh-CN"}
+	signedURL, err := conn.signURL(HTTPGet, "verify-bucket", "dir/object", time.Now().Unix()+60,
+		map[string]interface{}{"versionId": "v 1"}, headers)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(signedURL, "X-Kss-additional-headers=content-language"), Equals, true)
+
+	result, err := sign.Verify(urlRequest(c, "GET", signedURL, headers), verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Form, Equals, sign.FormQueryV2)
+	c.Assert(result.Object, Equals, "dir/object")
+
+	tampered := strings.Replace(signedURL, "versionId=v", "versionId=w", 1)
+	_, err = sign.Verify(urlRequest(c, "GET", tampered, headers), verifyLookup)
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+}
+
+func (s *Ks3SignVerifySuite) TestSignURLV4(c *C) {
+	conn := newVerifyConn(c, "http://ks3-cn-beijing.ksyuncs.com", AuthVersion(AuthV4))
+	signedURL, err := conn.signURL(HTTPGet, "verify-bucket", "dir/object", time.Now().Unix()+60,
+		map[string]interface{}{"response-content-type": "text/plain"}, nil)
+	c.Assert(err, IsNil)
+
+	result, err := sign.Verify(urlRequest(c, "GET", signedURL, nil), verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Form, Equals, sign.FormQueryV4)
+	c.Assert(result.Object, Equals, "dir/object")
+
+	verifier := &sign.Verifier{Lookup: verifyLookup, Now: func() time.Time { return time.Now().Add(2 * time.Minute) }}
+	_, err = verifier.Verify(urlRequest(c, "GET", signedURL, nil))
+	assertReason(c, err, sign.ReasonExpired)
+
+	tampered := strings.Replace(signedURL, "text%2Fplain", "text%2Fhtml", 1)
+	_, err = sign.Verify(urlRequest(c, "GET", tampered, nil), verifyLookup)
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+}
+
+func (s *Ks3SignVerifySuite) TestSignPolicyURL(c *C) {
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk")
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("verify-bucket")
+	c.Assert(err, IsNil)
+	signedURL, err := bucket.SignPolicyURL(`{"Statement":[]}`, time.Now().Unix()+60)
+	c.Assert(err, IsNil)
+
+	result, err := sign.Verify(urlRequest(c, "GET", signedURL, nil), verifyLookup)
+	c.Assert(err, IsNil)
+	c.Assert(result.Form, Equals, sign.FormPolicy)
+	c.Assert(result.Bucket, Equals, "verify-bucket")
+}
+
+func (s *Ks3SignVerifySuite) TestFailureReasons(c *C) {
+	conn := newVerifyConn(c, "http://ks3-cn-beijing.ksyuncs.com")
+	req := signedRequest(c, conn, "GET", "verify-bucket", "object", map[string]interface{}{}, nil)
+
+	// Unknown access key
+	_, err := sign.Verify(req, func(string) (string, error) { return "", errUnknownAK })
+	assertReason(c, err, sign.ReasonUnknownAccessKey)
+	c.Assert(errors.Is(err, errUnknownAK), Equals, true)
+	c.Assert(err.(*sign.VerifyError).AccessKeyID, Equals, "ak")
+
+	// Wrong secret key
+	_, err = sign.Verify(req, func(string) (string, error) { return "other", nil })
+	assertReason(c, err, sign.ReasonSignatureMismatch)
+
+	// Clock skew
+	verifier := &sign.Verifier{Lookup: verifyLookup, MaxSkew: time.Minute,
+		Now: func() time.Time { return time.Now().Add(-2 * time.Minute) }}
+	_, err = verifier.Verify(req)
+	assertReason(c, err, sign.ReasonRequestTimeTooSkewed)
+
+	// Missing or malformed signature
+	_, err = sign.Verify(urlRequest(c, "GET", "http://verify-bucket.ks3-cn-beijing.ksyuncs.com/object", nil), verifyLookup)
+	assertReason(c, err, sign.ReasonMissingSignature)
+	req.Header.Set(HTTPHeaderAuthorization, "KSS ak")
+	_, err = sign.Verify(req, verifyLookup)
+	assertReason(c, err, sign.ReasonMalformedSignature)
+	req.Header.Set(HTTPHeaderAuthorization, "Basic YWs6c2s=")
+	_, err = sign.Verify(req, verifyLookup)
+	assertReason(c, err, sign.ReasonMalformedSignature)
+	req.Header.Del(HTTPHeaderDate)
+	req.Header.Set(HTTPHeaderAuthorization, "KSS ak:signature")
+	_, err = sign.Verify(req, verifyLookup)
+	assertReason(c, err, sign.ReasonMalformedSignature)
+}
+
+func (s *Ks3SignVerifySuite) TestServer(c *C) {
+	var results []*sign.VerifyResult
+	var errs []error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := sign.Verify(r, verifyLookup)
+		results, errs = append(results, result), append(errs, err)
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	for _, version := range []AuthVersionType{AuthV1, AuthV2, AuthV4} {
+		client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", AuthVersion(version),
+			HTTPClient(&http.Client{Transport: transport}))
+		c.Assert(err, IsNil)
+		bucket, err := client.Bucket("verify-bucket")
+		c.Assert(err, IsNil)
+
+		err = bucket.PutObject("dir/object", strings.NewReader("data"), Meta("name", "value"))
+		c.Assert(err, IsNil)
+		signedURL, err := bucket.SignURL("dir/object", HTTPGet, 60)
+		c.Assert(err, IsNil)
+		resp, err := (&http.Client{Transport: transport}).Get(signedURL)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+	}
+
+	c.Assert(len(errs), Equals, 6)
+	for i, err := range errs {
+		c.Assert(err, IsNil, Commentf("request %d", i))
+		c.Assert(results[i].Object, Equals, "dir/object")
+	}
+}
@@ -0,0 +1,318 @@
+package ks3
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
+)
+
+// SignedURLType is the type of the signed URL
+type SignedURLType string
+
+const (
+	SignedURLV1     SignedURLType = "v1"     // Signed by signURL with AuthV1
+	SignedURLV2     SignedURLType = "v2"     // Signed by signURL with AuthV2
+	SignedURLV4     SignedURLType = "v4"     // Signed by signURL with AuthV4
+	SignedURLPolicy SignedURLType = "policy" // Signed by signPolicyURL
+	SignedURLRtmp   SignedURLType = "rtmp"   // Signed by signRtmpURL
+)
+
+// signedURLParams are the query parameters of the signature, they're not sub-resources
+var signedURLParams = map[string]bool{
+	HTTPParamExpires: true, HTTPParamAccessKeyID: true, HTTPParamSignature: true, HTTPParamSecurityToken: true,
+	HTTPParamSignatureVersion: true, HTTPParamExpiresV2: true, HTTPParamAccessKeyIDV2: true,
+	HTTPParamSignatureV2: true, HTTPParamAdditionalHeadersV2: true, "X-Kss-Policy": true,
+	"X-Kss-Algorithm": true, "X-Kss-Credential": true, "X-Kss-Date": true, "X-Kss-Expires": true,
+	"X-Kss-SignedHeaders": true, "X-Kss-Signature": true, "X-Kss-Security-Token": true,
+}
+
+// SignedURLInfo is the information of the signed URL parsed by ParseSignedURL
+type SignedURLInfo struct {
+	URL              *url.URL
+	Type             SignedURLType
+	Bucket           string     // The bucket, it's empty for the CNAME endpoint, set it before Verify
+	Key              string     // The object key
+	IsCname          bool       // The host is a custom domain
+	IsPathStyle      bool       // The bucket is in the path
+	MethodHint       HTTPMethod // The method guessed by the sub-resources, the method isn't in the URL except the RTMP URL
+	Expiration       time.Time
+	SignedAt         time.Time // The signing time of the v4 URL
+	AccessKeyID      string
+	HasSecurityToken bool
+	Signature        string
+	Region           string   // The region of the v4 URL
+	SignedHeaders    []string // The headers signed by the v4 URL or the additional headers of the v2 URL
+	Policy           string   // The decoded policy of the policy URL
+	Channel          string   // The live channel of the RTMP URL
+	Playlist         string   // The playlist of the RTMP URL
+
+	ResponseContentType        string
+	ResponseContentLanguage    string
+	ResponseExpires            string
+	ResponseCacheControl       string
+	ResponseContentDisposition string
+	ResponseContentEncoding    string
+
+	SubResources map[string]string // The other parameters, such as versionId, uploadId and partNumber
+}
+
+// ParseSignedURL parses the URL signed by SignURL, SignPolicyURL or SignRtmpURL for troubleshooting,
+// the bucket is parsed from the virtual hosted, path-style or IP endpoint, and it's empty for the CNAME endpoint.
+//
+// signedURL    the signed URL.
+//
+// *SignedURLInfo    the information of the URL.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func ParseSignedURL(signedURL string) (*SignedURLInfo, error) {
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("ks3: %s is not an absolute URL", signedURL)
+	}
+	query := u.Query()
+	info := &SignedURLInfo{URL: u, MethodHint: HTTPGet, SubResources: map[string]string{}}
+
+	switch {
+	case query.Get("X-Kss-Algorithm") != "":
+		err = info.parseV4(query)
+	case query.Get(HTTPParamSignatureV2) != "":
+		err = info.parseV2(query)
+	case query.Get(HTTPParamSignature) != "":
+		err = info.parseV1(query)
+	default:
+		return nil, fmt.Errorf("ks3: %s is not a signed URL, the signature is missing", signedURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.HasSecurityToken = query.Get(HTTPParamSecurityToken) != "" || query.Get("X-Kss-Security-Token") != ""
+
+	info.parseLocation()
+	info.parseParams(query)
+	return info, nil
+}
+
+func (info *SignedURLInfo) parseV1(query url.Values) error {
+	info.Type = SignedURLV1
+	info.AccessKeyID = query.Get(HTTPParamAccessKeyID)
+	info.Signature = query.Get(HTTPParamSignature)
+	if policy, ok := query["X-Kss-Policy"]; ok {
+		info.Type = SignedURLPolicy
+		decoded, err := base64.StdEncoding.DecodeString(policy[0])
+		if err != nil {
+			return fmt.Errorf("ks3: invalid X-Kss-Policy, %s", err.Error())
+		}
+		info.Policy = string(decoded)
+	}
+	if info.URL.Scheme == "rtmp" {
+		info.Type = SignedURLRtmp
+		info.MethodHint = ""
+		info.Playlist = query.Get(HTTPParamPlaylistName)
+	}
+	return info.parseUnixExpiration(query.Get(HTTPParamExpires))
+}
+
+func (info *SignedURLInfo) parseV2(query url.Values) error {
+	info.Type = SignedURLV2
+	info.AccessKeyID = query.Get(HTTPParamAccessKeyIDV2)
+	info.Signature = query.Get(HTTPParamSignatureV2)
+	if headers := query.Get(HTTPParamAdditionalHeadersV2); headers != "" {
+		info.SignedHeaders = strings.Split(headers, ";")
+	}
+	return info.parseUnixExpiration(query.Get(HTTPParamExpiresV2))
+}
+
+func (info *SignedURLInfo) parseV4(query url.Values) error {
+	info.Type = SignedURLV4
+	info.Signature = query.Get("X-Kss-Signature")
+	if headers := query.Get("X-Kss-SignedHeaders"); headers != "" {
+		info.SignedHeaders = strings.Split(headers, ";")
+	}
+	// ak/20130524/cn-beijing/s3/aws4_request
+	scope := strings.Split(query.Get("X-Kss-Credential"), "/")
+	if len(scope) < 5 {
+		return fmt.Errorf("ks3: invalid X-Kss-Credential %s", query.Get("X-Kss-Credential"))
+	}
+	info.AccessKeyID = strings.Join(scope[:len(scope)-4], "/")
+	info.Region = scope[len(scope)-3]
+
+	signedAt, err := time.Parse("20060102T150405Z", query.Get("X-Kss-Date"))
+	if err != nil {
+		return fmt.Errorf("ks3: invalid X-Kss-Date %s", query.Get("X-Kss-Date"))
+	}
+	expires, err := strconv.ParseInt(query.Get("X-Kss-Expires"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("ks3: invalid X-Kss-Expires %s", query.Get("X-Kss-Expires"))
+	}
+	info.SignedAt = signedAt
+	info.Expiration = signedAt.Add(time.Duration(expires) * time.Second)
+	return nil
+}
+
+func (info *SignedURLInfo) parseUnixExpiration(value string) error {
+	expires, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("ks3: invalid expires %s", value)
+	}
+	info.Expiration = time.Unix(expires, 0)
+	return nil
+}
+
+// isKs3Endpoint reports whether the host is a KS3 endpoint, which is ks3-<region>.ksyuncs.com,
+// ks3-<region>-internal.ksyuncs.com or kss.ksyuncs.com
+func isKs3Endpoint(host string) bool {
+	labels := strings.SplitN(strings.ToLower(host), ".", 2)
+	if len(labels) != 2 || labels[1] != "ksyuncs.com" {
+		return false
+	}
+	return labels[0] == "kss" || (strings.HasPrefix(labels[0], "ks3-") && len(labels[0]) > len("ks3-"))
+}
+
+// parseLocation parses the bucket and the key by the host, the KS3 endpoint is like ks3-cn-beijing.ksyuncs.com
+func (info *SignedURLInfo) parseLocation() {
+	host := info.URL.Hostname()
+	labels := strings.SplitN(host, ".", 2)
+
+	path := strings.TrimPrefix(info.URL.Path, "/")
+	switch {
+	case len(labels) == 2 && isKs3Endpoint(labels[1]):
+		info.Bucket = labels[0]
+	case net.ParseIP(host) != nil || isKs3Endpoint(host):
+		info.IsPathStyle = true
+		parts := strings.SplitN(path, "/", 2)
+		info.Bucket = parts[0]
+		if len(parts) == 2 {
+			path = parts[1]
+		} else {
+			path = ""
+		}
+	default:
+		info.IsCname = true
+	}
+
+	if info.Type == SignedURLRtmp {
+		info.Channel = strings.TrimPrefix(path, "live/")
+		return
+	}
+	info.Key = path
+}
+
+// parseParams parses the response override parameters, the sub-resources and the method hint
+func (info *SignedURLInfo) parseParams(query url.Values) {
+	overrides := map[string]*string{
+		"response-content-type":        &info.ResponseContentType,
+		"response-content-language":    &info.ResponseContentLanguage,
+		"response-expires":             &info.ResponseExpires,
+		"response-cache-control":       &info.ResponseCacheControl,
+		"response-content-disposition": &info.ResponseContentDisposition,
+		"response-content-encoding":    &info.ResponseContentEncoding,
+	}
+	for k := range query {
+		if field, ok := overrides[k]; ok {
+			*field = query.Get(k)
+		} else if !signedURLParams[k] && !(info.Type == SignedURLRtmp && k == HTTPParamPlaylistName) {
+			info.SubResources[k] = query.Get(k)
+		}
+	}
+
+	if info.Type == SignedURLRtmp {
+		return
+	}
+	has := func(k string) bool {
+		_, ok := info.SubResources[k]
+		return ok
+	}
+	switch {
+	case has("partNumber"):
+		info.MethodHint = HTTPPut
+	case has("uploads"), has("delete"), has("restore"), has("append"):
+		info.MethodHint = HTTPPost
+	}
+}
+
+// IsExpired tells whether the URL has expired at the time
+func (info *SignedURLInfo) IsExpired(now time.Time) bool {
+	return now.After(info.Expiration)
+}
+
+// Verify verifies the signature by the secret, the methods are tried from the hint.
+// The URL signed with headers, such as Content-Type, can't be verified by Verify, use VerifyMethod instead.
+// The signature is verified as it's not expired, check IsExpired for the expiration.
+func (info *SignedURLInfo) Verify(sk string) error {
+	if info.Type == SignedURLRtmp {
+		return info.VerifyMethod("", sk, nil)
+	}
+	methods := []HTTPMethod{HTTPGet, HTTPPut, HTTPHead, HTTPPost, HTTPDelete}
+	if info.MethodHint != "" && info.MethodHint != HTTPGet {
+		methods = append([]HTTPMethod{info.MethodHint}, methods...)
+	}
+	var err error
+	for _, method := range methods {
+		if err = info.VerifyMethod(method, sk, nil); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// VerifyMethod verifies the signature with the method and the signed headers, it returns *sign.VerifyError if failed.
+func (info *SignedURLInfo) VerifyMethod(method HTTPMethod, sk string, headers map[string]string) error {
+	if info.Type == SignedURLRtmp {
+		return info.verifyRtmp(sk)
+	}
+	u := *info.URL
+	req := &http.Request{Method: string(method), URL: &u, Host: u.Host, Header: make(http.Header)}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	verifier := &sign.Verifier{
+		Lookup: func(string) (string, error) { return sk, nil },
+		// Verify as the URL is used just at the expiration, since the expiration is checked separately
+		Now: func() time.Time { return info.Expiration },
+	}
+	if info.IsCname {
+		verifier.Bucket = func(*http.Request) string { return info.Bucket }
+	}
+	_, err := verifier.Verify(req)
+	return err
+}
+
+// verifyRtmp verifies the signature of the RTMP URL, the same as getRtmpSignedStr
+func (info *SignedURLInfo) verifyRtmp(sk string) error {
+	query := info.URL.Query()
+	var keys []string
+	for k := range query {
+		if k != HTTPParamAccessKeyID && k != HTTPParamSignature && k != HTTPParamExpires && k != HTTPParamSecurityToken {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	signStr := query.Get(HTTPParamExpires) + "\n"
+	for _, k := range keys {
+		signStr += k + ":" + query.Get(k) + "\n"
+	}
+	signStr += fmt.Sprintf("/%s/%s", info.Bucket, info.Channel)
+
+	h := hmac.New(func() hash.Hash { return sha1.New() }, []byte(sk))
+	io.WriteString(h, signStr)
+	if !hmac.Equal([]byte(base64.StdEncoding.EncodeToString(h.Sum(nil))), []byte(info.Signature)) {
+		return &sign.VerifyError{Reason: sign.ReasonSignatureMismatch, AccessKeyID: info.AccessKeyID,
+			Message: "the calculated signature does not match the provided signature"}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package ks3
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
+	. "gopkg.in/check.v1"
+)
+
+type Ks3SignedURLSuite struct{}
+
+var _ = Suite(&Ks3SignedURLSuite{})
+
+func newSignedURLBucket(c *C, endpoint string, options ...ClientOption) *Bucket {
+	client, err := New(endpoint, "ak", "sk", options...)
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("url-bucket")
+	c.Assert(err, IsNil)
+	return bucket
+}
+
+func (s *Ks3SignedURLSuite) TestParseSignURL(c *C) {
+	bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com",
+		SetCredentialsProvider(NewStaticCredentialsProvider("sts-ak", "sk", "token")))
+	before := time.Now()
+	signedURL, err := bucket.SignURL("dir/object name", HTTPGet, 600, VersionId("v1"),
+		ResponseContentType("text/plain"), ResponseContentDisposition("attachment"))
+	c.Assert(err, IsNil)
+
+	info, err := ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.Type, Equals, SignedURLV1)
+	c.Assert(info.Bucket, Equals, "url-bucket")
+	c.Assert(info.Key, Equals, "dir/object name")
+	c.Assert(info.IsCname, Equals, false)
+	c.Assert(info.IsPathStyle, Equals, false)
+	c.Assert(info.MethodHint, Equals, HTTPGet)
+	c.Assert(info.AccessKeyID, Equals, "sts-ak")
+	c.Assert(info.HasSecurityToken, Equals, true)
+	c.Assert(info.ResponseContentType, Equals, "text/plain")
+	c.Assert(info.ResponseContentDisposition, Equals, "attachment")
+	c.Assert(info.SubResources, DeepEquals, map[string]string{"versionId": "v1"})
+	c.Assert(info.Expiration.Unix()-before.Unix() >= 600, Equals, true)
+	c.Assert(info.IsExpired(time.Now()), Equals, false)
+	c.Assert(info.IsExpired(time.Now().Add(time.Hour)), Equals, true)
+
+	c.Assert(info.Verify("sk"), IsNil)
+	c.Assert(info.VerifyMethod(HTTPGet, "sk", nil), IsNil)
+	err = info.VerifyMethod(HTTPPut, "sk", nil)
+	c.Assert(err.(*sign.VerifyError).Reason, Equals, sign.ReasonSignatureMismatch)
+	c.Assert(info.Verify("other"), NotNil)
+}
+
+func (s *Ks3SignedURLSuite) TestParseVersions(c *C) {
+	bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com", AuthVersion(AuthV2),
+		AdditionalHeaders([]string{"User-Agent"}))
+	signedURL, err := bucket.SignURL("object", HTTPPut, 60)
+	c.Assert(err, IsNil)
+	info, err := ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.Type, Equals, SignedURLV2)
+	c.Assert(info.AccessKeyID, Equals, "ak")
+	c.Assert(info.SignedHeaders, DeepEquals, []string{"user-agent"})
+	c.Assert(len(info.SubResources), Equals, 0)
+	c.Assert(info.VerifyMethod(HTTPPut, "sk", map[string]string{"User-Agent": bucket.Client.Config.UserAgent}), IsNil)
+
+	bucket = newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com", AuthVersion(AuthV4))
+	signedURL, err = bucket.SignURL("object", HTTPDelete, 60)
+	c.Assert(err, IsNil)
+	info, err = ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.Type, Equals, SignedURLV4)
+	c.Assert(info.AccessKeyID, Equals, "ak")
+	c.Assert(info.Region, Equals, "cn-beijing")
+	c.Assert(info.SignedHeaders, DeepEquals, []string{"host"})
+	c.Assert(info.Expiration.Sub(info.SignedAt), Equals, 60*time.Second)
+	c.Assert(info.Verify("sk"), IsNil)
+}
+
+func (s *Ks3SignedURLSuite) TestParseMethodHint(c *C) {
+	bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com")
+	imur := InitiateMultipartUploadResult{Key: "object", UploadID: "upload-id"}
+	signedURL, err := bucket.SignUploadPartURL(imur, 2, 60)
+	c.Assert(err, IsNil)
+	info, err := ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.MethodHint, Equals, HTTPPut)
+	c.Assert(info.SubResources, DeepEquals, map[string]string{"uploadId": "upload-id", "partNumber": "2"})
+	c.Assert(info.Verify("sk"), IsNil)
+
+	signedURL, err = bucket.SignInitiateMultipartUploadURL("object", 60)
+	c.Assert(err, IsNil)
+	info, err = ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.MethodHint, Equals, HTTPPost)
+	c.Assert(info.Verify("sk"), IsNil)
+}
+
+func (s *Ks3SignedURLSuite) TestParseEndpoints(c *C) {
+	bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com", PathStyleAccess(true))
+	signedURL, err := bucket.SignURL("dir/object", HTTPGet, 60)
+	c.Assert(err, IsNil)
+	info, err := ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.IsPathStyle, Equals, true)
+	c.Assert(info.Bucket, Equals, "url-bucket")
+	c.Assert(info.Key, Equals, "dir/object")
+	c.Assert(info.Verify("sk"), IsNil)
+
+	bucket = newSignedURLBucket(c, "http://10.0.0.1")
+	signedURL, err = bucket.SignURL("object", HTTPGet, 60)
+	c.Assert(err, IsNil)
+	info, err = ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.IsPathStyle, Equals, true)
+	c.Assert(info.Bucket, Equals, "url-bucket")
+	c.Assert(info.Key, Equals, "object")
+
+	bucket = newSignedURLBucket(c, "https://static.example.com", UseCname(true))
+	signedURL, err = bucket.SignURL("dir/object", HTTPGet, 60)
+	c.Assert(err, IsNil)
+	info, err = ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.IsCname, Equals, true)
+	c.Assert(info.Bucket, Equals, "")
+	c.Assert(info.Key, Equals, "dir/object")
+	c.Assert(info.Verify("sk"), NotNil)
+	info.Bucket = "url-bucket"
+	c.Assert(info.Verify("sk"), IsNil)
+}
+
+func (s *Ks3SignedURLSuite) TestParseKs3PrefixedBucket(c *C) {
+	// The bucket named like an endpoint isn't taken for the endpoint
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk")
+	c.Assert(err, IsNil)
+	bucket, err := client.Bucket("ks3-logs")
+	c.Assert(err, IsNil)
+	signedURL, err := bucket.SignURL("a/b.txt", HTTPGet, 60)
+	c.Assert(err, IsNil)
+	c.Assert(signedURL, Matches, "http://ks3-logs.ks3-cn-beijing.ksyuncs.com/a/b.txt\\?.*")
+	info, err := ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.IsPathStyle, Equals, false)
+	c.Assert(info.IsCname, Equals, false)
+	c.Assert(info.Bucket, Equals, "ks3-logs")
+	c.Assert(info.Key, Equals, "a/b.txt")
+	c.Assert(info.Verify("sk"), IsNil)
+
+	client, err = New("http://ks3-cn-shanghai-internal.ksyuncs.com", "ak", "sk", PathStyleAccess(true))
+	c.Assert(err, IsNil)
+	bucket, err = client.Bucket("ks3-logs")
+	c.Assert(err, IsNil)
+	signedURL, err = bucket.SignURL("a/b.txt", HTTPGet, 60)
+	c.Assert(err, IsNil)
+	info, err = ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.IsPathStyle, Equals, true)
+	c.Assert(info.Bucket, Equals, "ks3-logs")
+	c.Assert(info.Key, Equals, "a/b.txt")
+	c.Assert(info.Verify("sk"), IsNil)
+
+	// Not a KS3 domain, it's a CNAME
+	info, err = ParseSignedURL(strings.Replace(signedURL, "ks3-cn-shanghai-internal.ksyuncs.com", "ks3-logs.example.com", 1))
+	c.Assert(err, IsNil)
+	c.Assert(info.IsCname, Equals, true)
+	c.Assert(info.Bucket, Equals, "")
+	c.Assert(info.Key, Equals, "ks3-logs/a/b.txt")
+}
+
+func (s *Ks3SignedURLSuite) TestParsePolicyURL(c *C) {
+	bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com")
+	signedURL, err := bucket.SignPolicyURL(`{"Statement":[]}`, time.Now().Unix()+60)
+	c.Assert(err, IsNil)
+	info, err := ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.Type, Equals, SignedURLPolicy)
+	c.Assert(info.Policy, Equals, `{"Statement":[]}`)
+	c.Assert(info.Bucket, Equals, "url-bucket")
+	c.Assert(info.Key, Equals, "")
+	c.Assert(len(info.SubResources), Equals, 0)
+	c.Assert(info.Verify("sk"), IsNil)
+}
+
+func (s *Ks3SignedURLSuite) TestParseRtmpURL(c *C) {
+	for _, pathStyle := range []bool{false, true} {
+		bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com", PathStyleAccess(pathStyle))
+		signedURL, err := bucket.SignRtmpURL("channel", "playlist.m3u8", 60)
+		c.Assert(err, IsNil)
+		info, err := ParseSignedURL(signedURL)
+		c.Assert(err, IsNil)
+		c.Assert(info.Type, Equals, SignedURLRtmp)
+		c.Assert(info.MethodHint, Equals, HTTPMethod(""))
+		c.Assert(info.Bucket, Equals, "url-bucket")
+		c.Assert(info.Channel, Equals, "channel")
+		c.Assert(info.Playlist, Equals, "playlist.m3u8")
+		c.Assert(len(info.SubResources), Equals, 0)
+		c.Assert(info.Verify("sk"), IsNil)
+		c.Assert(info.Verify("other"), NotNil)
+	}
+}
+
+func (s *Ks3SignedURLSuite) TestParseInvalid(c *C) {
+	_, err := ParseSignedURL("http://bucket.ks3-cn-beijing.ksyuncs.com/object")
+	c.Assert(err, NotNil)
+	_, err = ParseSignedURL("/object?Signature=abc")
+	c.Assert(err, NotNil)
+	_, err = ParseSignedURL("http://bucket.ks3-cn-beijing.ksyuncs.com/object?Signature=abc&Expires=never")
+	c.Assert(err, NotNil)
+	_, err = ParseSignedURL("http://bucket.ks3-cn-beijing.ksyuncs.com/object?X-Kss-Algorithm=AWS4-HMAC-SHA256")
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,240 @@
+package ks3
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SSECustomerKeySize is the size of the customer provided key of SSE-C, the key is used by AES256.
+const SSECustomerKeySize = 32
+
+// SSECustomerKey is the customer provided key of server side encryption (SSE-C).
+// It derives the algorithm, key and key MD5 headers from the raw key bytes.
+type SSECustomerKey struct {
+	key    string // Base64 of the key
+	keyMd5 string // Base64 of the MD5 of the key
+}
+
+// NewSSECustomerKey creates the customer provided key of SSE-C.
+//
+// key    the raw key of 32 bytes.
+//
+// *SSECustomerKey    the customer provided key.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func NewSSECustomerKey(key []byte) (*SSECustomerKey, error) {
+	if len(key) != SSECustomerKeySize {
+		return nil, fmt.Errorf("ks3: SSE-C key must be %d bytes, but it's %d bytes", SSECustomerKeySize, len(key))
+	}
+	sum := md5.Sum(key)
+	return &SSECustomerKey{
+		key:    base64.StdEncoding.EncodeToString(key),
+		keyMd5: base64.StdEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+// Algorithm returns the value of X-Kss-Server-Side-Encryption-Customer-Algorithm
+func (k *SSECustomerKey) Algorithm() string {
+	return string(AESAlgorithm)
+}
+
+// Key returns the value of X-Kss-Server-Side-Encryption-Customer-Key
+func (k *SSECustomerKey) Key() string {
+	return k.key
+}
+
+// KeyMD5 returns the value of X-Kss-Server-Side-Encryption-Customer-Key-MD5
+func (k *SSECustomerKey) KeyMD5() string {
+	return k.keyMd5
+}
+
+// SSECustomer is an option to set the SSE-C headers of the key
+func SSECustomer(key *SSECustomerKey) Option {
+	return func(params map[string]optionValue) error {
+		if key == nil {
+			return nil
+		}
+		params[HTTPHeaderSSECAlgorithm] = optionValue{key.Algorithm(), optionHTTP}
+		params[HTTPHeaderSSECKey] = optionValue{key.Key(), optionHTTP}
+		params[HTTPHeaderSSECKeyMd5] = optionValue{key.KeyMD5(), optionHTTP}
+		return nil
+	}
+}
+
+// CopySourceSSECustomer is an option to set the SSE-C headers of the copy source object
+func CopySourceSSECustomer(key *SSECustomerKey) Option {
+	return func(params map[string]optionValue) error {
+		if key == nil {
+			return nil
+		}
+		params[HTTPHeaderCopySourceSSECAlgorithm] = optionValue{key.Algorithm(), optionHTTP}
+		params[HTTPHeaderCopySourceSSECKey] = optionValue{key.Key(), optionHTTP}
+		params[HTTPHeaderCopySourceSSECKeyMd5] = optionValue{key.KeyMD5(), optionHTTP}
+		return nil
+	}
+}
+
+// WithSSECustomerKey returns a view of the bucket which sends the SSE-C headers of the key with the object data requests,
+// such as GetObject, GetObjectDetailedMeta, PutObject, InitiateMultipartUpload and UploadPart.
+// The copy source headers are also sent when the copy source is in the same bucket, or when the bucket is the source of CopyFile.
+// The SSE-C options of a single call take precedence over the key of the view.
+//
+// key    the customer provided key, nil removes the key from the view.
+//
+// *Bucket    the bucket view.
+//
+func (bucket Bucket) WithSSECustomerKey(key *SSECustomerKey) *Bucket {
+	bucket.sseCustomerKey = key
+	return &bucket
+}
+
+// SSECustomerKey returns the customer provided key of the bucket view, it's nil if the key is not set.
+func (bucket Bucket) SSECustomerKey() *SSECustomerKey {
+	return bucket.sseCustomerKey
+}
+
+// sseCustomerParams are the params of the object data requests which accept SSE-C headers
+var sseCustomerParams = map[string]bool{
+	"versionId":     true,
+	"partNumber":    true,
+	"uploadId":      true,
+	"uploads":       true,
+	"append":        true,
+	"position":      true,
+	"x-kss-process": true,
+}
+
+// isSSECustomerRequest checks whether the request reads or writes the object data
+func isSSECustomerRequest(method, objectName string, params map[string]interface{}) bool {
+	if objectName == "" {
+		return false
+	}
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "PUT", "POST":
+	default:
+		return false
+	}
+	for k := range params {
+		if !sseCustomerParams[k] && !strings.HasPrefix(k, "response-") {
+			return false
+		}
+	}
+	return true
+}
+
+// sseCustomerOptions adds the SSE-C headers of the bucket view to the options of the object data requests
+func (bucket Bucket) sseCustomerOptions(method, objectName string, params map[string]interface{}, options []Option) []Option {
+	key := bucket.sseCustomerKey
+	if key == nil || !isSSECustomerRequest(method, objectName, params) {
+		return options
+	}
+
+	if set, _, _ := IsOptionSet(options, HTTPHeaderSSECKey); !set {
+		options = append(options, SSECustomer(key))
+	}
+	if set, _, _ := IsOptionSet(options, HTTPHeaderCopySourceSSECKey); !set {
+		source, _ := FindOption(options, HTTPHeaderKs3CopySource, nil)
+		if source != nil && strings.HasPrefix(source.(string), "/"+bucket.BucketName+"/") {
+			options = append(options, CopySourceSSECustomer(key))
+		}
+	}
+	return options
+}
+
+// checkSSECustomerHeaders checks the SSE-C headers are complete and the key matches its MD5
+func checkSSECustomerHeaders(bucketName, objectName string, headers map[string]string) error {
+	groups := []struct {
+		algorithm, key, keyMd5, name string
+	}{
+		{HTTPHeaderSSECAlgorithm, HTTPHeaderSSECKey, HTTPHeaderSSECKeyMd5, "SSE-C"},
+		{HTTPHeaderCopySourceSSECAlgorithm, HTTPHeaderCopySourceSSECKey, HTTPHeaderCopySourceSSECKeyMd5, "copy source SSE-C"},
+	}
+
+	for _, g := range groups {
+		algorithm, key, keyMd5 := headers[g.algorithm], headers[g.key], headers[g.keyMd5]
+		if algorithm == "" && key == "" && keyMd5 == "" {
+			continue
+		}
+		if key == "" {
+			return SSECustomerKeyError{Bucket: bucketName, Object: objectName, Reason: g.name + " key is missing"}
+		}
+		if algorithm == "" {
+			return SSECustomerKeyError{Bucket: bucketName, Object: objectName, Reason: g.name + " algorithm is missing"}
+		}
+		raw, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return SSECustomerKeyError{Bucket: bucketName, Object: objectName, Reason: g.name + " key is not base64 encoded"}
+		}
+		if keyMd5 != "" {
+			sum := md5.Sum(raw)
+			if keyMd5 != base64.StdEncoding.EncodeToString(sum[:]) {
+				return SSECustomerKeyError{Bucket: bucketName, Object: objectName, Reason: g.name + " key MD5 doesn't match the key"}
+			}
+		}
+	}
+	return nil
+}
+
+// sseCustomerError converts the error of a request without SSE-C key on an SSE-C object to SSECustomerKeyError.
+// KS3 rejects such requests with 400, the error message mentions the encryption or the response of HEAD has no body.
+func sseCustomerError(method, bucketName, objectName string, params map[string]interface{}, headers map[string]string, err error) error {
+	srvErr, ok := err.(ServiceError)
+	if !ok || srvErr.StatusCode != http.StatusBadRequest || !isSSECustomerRequest(method, objectName, params) {
+		return err
+	}
+
+	text := strings.ToLower(srvErr.Code + " " + srvErr.Message)
+	mentioned := strings.Contains(text, "encrypt") || strings.Contains(text, "customer")
+	if !mentioned && !(strings.ToUpper(method) == "HEAD" && srvErr.Code == "") {
+		return err
+	}
+
+	// A copy request fails because of the source object if the target key is set or the message mentions the source
+	if headers[HTTPHeaderKs3CopySource] != "" && headers[HTTPHeaderCopySourceSSECKey] == "" && mentioned &&
+		(headers[HTTPHeaderSSECKey] != "" || strings.Contains(text, "source")) {
+		return SSECustomerKeyError{Bucket: bucketName, Object: objectName, Reason: "copy source SSE-C key is missing", Err: srvErr}
+	}
+	if headers[HTTPHeaderSSECKey] == "" {
+		return SSECustomerKeyError{Bucket: bucketName, Object: objectName, Reason: "SSE-C key is missing", Err: srvErr}
+	}
+	return err
+}
+
+// copySourceAsSSECustomer converts the copy source SSE-C options to the SSE-C options of reading the source object
+func copySourceAsSSECustomer(options []Option) []Option {
+	var out []Option
+	pairs := [][2]string{
+		{HTTPHeaderCopySourceSSECAlgorithm, HTTPHeaderSSECAlgorithm},
+		{HTTPHeaderCopySourceSSECKey, HTTPHeaderSSECKey},
+		{HTTPHeaderCopySourceSSECKeyMd5, HTTPHeaderSSECKeyMd5},
+	}
+	for _, pair := range pairs {
+		if value, _ := FindOption(options, pair[0], nil); value != nil {
+			out = append(out, setHeader(pair[1], value))
+		}
+	}
+	return out
+}
+
+// choiceSSECustomerOption chooses the SSE-C and copy source SSE-C options
+func choiceSSECustomerOption(options []Option) []Option {
+	var out []Option
+	for _, header := range []string{HTTPHeaderSSECAlgorithm, HTTPHeaderSSECKey, HTTPHeaderSSECKeyMd5,
+		HTTPHeaderCopySourceSSECAlgorithm, HTTPHeaderCopySourceSSECKey, HTTPHeaderCopySourceSSECKeyMd5} {
+		if value, _ := FindOption(options, header, nil); value != nil {
+			out = append(out, setHeader(header, value))
+		}
+	}
+	return out
+}
+
+// deleteCopySourceSSECustomerOption deletes the copy source SSE-C options from the requests without copy source
+func deleteCopySourceSSECustomerOption(options []Option) []Option {
+	for _, header := range []string{HTTPHeaderCopySourceSSECAlgorithm, HTTPHeaderCopySourceSSECKey, HTTPHeaderCopySourceSSECKeyMd5} {
+		options = DeleteOption(options, header)
+	}
+	return options
+}
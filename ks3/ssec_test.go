@@ -0,0 +1,322 @@
+package ks3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3SSECustomerSuite struct {
+	server    *httptest.Server
+	bucket    *Bucket
+	srcBucket *Bucket
+	key       *SSECustomerKey
+	srcKey    *SSECustomerKey
+	mu        sync.Mutex
+	reqs      []*http.Request // The received requests
+	status    int             // The status code of the object requests
+	errorBody string          // The error response body if the status isn't 200
+}
+
+var _ = Suite(&Ks3SSECustomerSuite{})
+
+func (s *Ks3SSECustomerSuite) SetUpSuite(c *C) {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		s.mu.Lock()
+		s.reqs = append(s.reqs, r)
+		status, errorBody := s.status, s.errorBody
+		s.mu.Unlock()
+
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			if r.Method != "HEAD" {
+				w.Write([]byte(errorBody))
+			}
+			return
+		}
+
+		query := r.URL.Query()
+		_, initiate := query["uploads"]
+		switch {
+		case r.Method == "HEAD":
+			w.Header().Set(HTTPHeaderContentLength, "307200")
+			w.Header().Set(HTTPHeaderEtag, "\"etag\"")
+			w.Header().Set(HTTPHeaderLastModified, "Fri, 24 Feb 2012 06:07:48 GMT")
+		case initiate:
+			w.Write([]byte("<InitiateMultipartUploadResult><Bucket>ssec-bucket</Bucket><Key>dest</Key>" +
+				"<UploadId>upload-id</UploadId></InitiateMultipartUploadResult>"))
+		case r.Method == "PUT" && query.Get("partNumber") != "":
+			w.Write([]byte("<CopyObjectResult><ETag>\"part\"</ETag></CopyObjectResult>"))
+		case r.Method == "PUT" && r.Header.Get(HTTPHeaderKs3CopySource) != "":
+			w.Write([]byte("<CopyObjectResult><ETag>\"copy\"</ETag></CopyObjectResult>"))
+		case r.Method == "POST" && query.Get("uploadId") != "":
+			w.Write([]byte("<CompleteMultipartUploadResult><ETag>\"etag\"</ETag></CompleteMultipartUploadResult>"))
+		}
+	}))
+
+	// The bucket domain is dialed to the local server
+	addr := s.server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	client, err := New(s.server.URL, "ak", "sk", HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	s.bucket, err = client.Bucket("ssec-bucket")
+	c.Assert(err, IsNil)
+	s.srcBucket, err = client.Bucket("ssec-src-bucket")
+	c.Assert(err, IsNil)
+
+	s.key, err = NewSSECustomerKey(bytes.Repeat([]byte("k"), SSECustomerKeySize))
+	c.Assert(err, IsNil)
+	s.srcKey, err = NewSSECustomerKey(bytes.Repeat([]byte("s"), SSECustomerKeySize))
+	c.Assert(err, IsNil)
+}
+
+func (s *Ks3SSECustomerSuite) TearDownSuite(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3SSECustomerSuite) SetUpTest(c *C) {
+	s.reqs = nil
+	s.status = http.StatusOK
+	s.errorBody = ""
+}
+
+// lastRequest returns the last received request
+func (s *Ks3SSECustomerSuite) lastRequest(c *C) *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.Assert(len(s.reqs) > 0, Equals, true)
+	return s.reqs[len(s.reqs)-1]
+}
+
+func assertSSECustomerHeaders(c *C, header http.Header, key *SSECustomerKey) {
+	c.Assert(header.Get(HTTPHeaderSSECAlgorithm), Equals, "AES256")
+	c.Assert(header.Get(HTTPHeaderSSECKey), Equals, key.Key())
+	c.Assert(header.Get(HTTPHeaderSSECKeyMd5), Equals, key.KeyMD5())
+}
+
+func assertCopySourceSSECustomerHeaders(c *C, header http.Header, key *SSECustomerKey) {
+	c.Assert(header.Get(HTTPHeaderCopySourceSSECAlgorithm), Equals, "AES256")
+	c.Assert(header.Get(HTTPHeaderCopySourceSSECKey), Equals, key.Key())
+	c.Assert(header.Get(HTTPHeaderCopySourceSSECKeyMd5), Equals, key.KeyMD5())
+}
+
+func (s *Ks3SSECustomerSuite) TestNewSSECustomerKey(c *C) {
+	raw := bytes.Repeat([]byte("k"), SSECustomerKeySize)
+	sum := md5.Sum(raw)
+	c.Assert(s.key.Algorithm(), Equals, "AES256")
+	c.Assert(s.key.Key(), Equals, base64.StdEncoding.EncodeToString(raw))
+	c.Assert(s.key.KeyMD5(), Equals, base64.StdEncoding.EncodeToString(sum[:]))
+
+	_, err := NewSSECustomerKey([]byte("short"))
+	c.Assert(err, NotNil)
+	_, err = NewSSECustomerKey(nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3SSECustomerSuite) TestBucketView(c *C) {
+	view := s.bucket.WithSSECustomerKey(s.key)
+	c.Assert(view.SSECustomerKey(), Equals, s.key)
+	c.Assert(s.bucket.SSECustomerKey(), IsNil)
+
+	err := view.PutObject("object", strings.NewReader("data"))
+	c.Assert(err, IsNil)
+	assertSSECustomerHeaders(c, s.lastRequest(c).Header, s.key)
+
+	_, err = view.GetObjectDetailedMeta("object")
+	c.Assert(err, IsNil)
+	assertSSECustomerHeaders(c, s.lastRequest(c).Header, s.key)
+
+	// The bucket itself doesn't send the key
+	err = s.bucket.PutObject("object", strings.NewReader("data"))
+	c.Assert(err, IsNil)
+	c.Assert(s.lastRequest(c).Header.Get(HTTPHeaderSSECKey), Equals, "")
+
+	// The key of a single call takes precedence over the view
+	err = view.PutObject("object", strings.NewReader("data"), SSECustomer(s.srcKey))
+	c.Assert(err, IsNil)
+	assertSSECustomerHeaders(c, s.lastRequest(c).Header, s.srcKey)
+}
+
+func (s *Ks3SSECustomerSuite) TestBucketViewNonObjectRequest(c *C) {
+	view := s.bucket.WithSSECustomerKey(s.key)
+
+	view.DeleteObject("object")
+	c.Assert(s.lastRequest(c).Header.Get(HTTPHeaderSSECKey), Equals, "")
+
+	view.GetObjectACL("object")
+	c.Assert(s.lastRequest(c).Header.Get(HTTPHeaderSSECKey), Equals, "")
+}
+
+func (s *Ks3SSECustomerSuite) TestCopyObject(c *C) {
+	view := s.bucket.WithSSECustomerKey(s.key)
+	_, err := view.CopyObject("src", "dest")
+	c.Assert(err, IsNil)
+	req := s.lastRequest(c)
+	assertSSECustomerHeaders(c, req.Header, s.key)
+	assertCopySourceSSECustomerHeaders(c, req.Header, s.key)
+
+	// The source is encrypted with another key
+	_, err = view.CopyObject("src", "dest", CopySourceSSECustomer(s.srcKey))
+	c.Assert(err, IsNil)
+	req = s.lastRequest(c)
+	assertSSECustomerHeaders(c, req.Header, s.key)
+	assertCopySourceSSECustomerHeaders(c, req.Header, s.srcKey)
+
+	// The copy source in another bucket needs the key of a single call
+	_, err = view.CopyObjectFrom(s.srcBucket.BucketName, "src", "dest")
+	c.Assert(err, IsNil)
+	req = s.lastRequest(c)
+	assertSSECustomerHeaders(c, req.Header, s.key)
+	c.Assert(req.Header.Get(HTTPHeaderCopySourceSSECKey), Equals, "")
+
+	_, err = view.CopyObjectTo(s.srcBucket.BucketName, "dest", "src")
+	c.Assert(err, IsNil)
+	req = s.lastRequest(c)
+	c.Assert(req.Header.Get(HTTPHeaderSSECKey), Equals, "")
+	assertCopySourceSSECustomerHeaders(c, req.Header, s.key)
+
+	_, err = view.CopyObjectFrom(s.srcBucket.BucketName, "src", "dest", CopySourceSSECustomer(s.srcKey))
+	c.Assert(err, IsNil)
+	req = s.lastRequest(c)
+	assertSSECustomerHeaders(c, req.Header, s.key)
+	assertCopySourceSSECustomerHeaders(c, req.Header, s.srcKey)
+}
+
+func (s *Ks3SSECustomerSuite) TestUploadPartCopy(c *C) {
+	view := s.bucket.WithSSECustomerKey(s.key)
+	imur := InitiateMultipartUploadResult{Bucket: view.BucketName, Key: "dest", UploadID: "upload-id"}
+
+	_, err := view.UploadPartCopy(imur, view.BucketName, "src", 0, 102400, 1)
+	c.Assert(err, IsNil)
+	req := s.lastRequest(c)
+	assertSSECustomerHeaders(c, req.Header, s.key)
+	assertCopySourceSSECustomerHeaders(c, req.Header, s.key)
+
+	_, err = view.UploadPartCopy(imur, s.srcBucket.BucketName, "src", 0, 102400, 1, CopySourceSSECustomer(s.srcKey))
+	c.Assert(err, IsNil)
+	req = s.lastRequest(c)
+	assertSSECustomerHeaders(c, req.Header, s.key)
+	assertCopySourceSSECustomerHeaders(c, req.Header, s.srcKey)
+}
+
+func (s *Ks3SSECustomerSuite) TestCopyFile(c *C) {
+	view := s.bucket.WithSSECustomerKey(s.key)
+	srcView := s.srcBucket.WithSSECustomerKey(s.srcKey)
+
+	err := view.CopyFile(srcView, "src", "dest", 102400, Routines(2))
+	c.Assert(err, IsNil)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := 0
+	for _, req := range s.reqs {
+		bucketName := strings.Split(req.Host, ".")[0]
+		_, initiate := req.URL.Query()["uploads"]
+		switch {
+		case req.Method == "HEAD":
+			c.Assert(bucketName, Equals, srcView.BucketName)
+			assertSSECustomerHeaders(c, req.Header, s.srcKey)
+		case initiate:
+			assertSSECustomerHeaders(c, req.Header, s.key)
+			c.Assert(req.Header.Get(HTTPHeaderCopySourceSSECKey), Equals, "")
+		case req.Method == "PUT":
+			parts++
+			assertSSECustomerHeaders(c, req.Header, s.key)
+			assertCopySourceSSECustomerHeaders(c, req.Header, s.srcKey)
+		}
+	}
+	c.Assert(parts, Equals, 3)
+}
+
+func (s *Ks3SSECustomerSuite) TestCopyFileSourceOption(c *C) {
+	err := s.bucket.CopyFile(s.srcBucket, "src", "dest", 102400, CopySourceSSECustomer(s.srcKey))
+	c.Assert(err, IsNil)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, req := range s.reqs {
+		switch req.Method {
+		case "HEAD":
+			assertSSECustomerHeaders(c, req.Header, s.srcKey)
+			c.Assert(req.Header.Get(HTTPHeaderCopySourceSSECKey), Equals, "")
+		case "PUT":
+			c.Assert(req.Header.Get(HTTPHeaderSSECKey), Equals, "")
+			assertCopySourceSSECustomerHeaders(c, req.Header, s.srcKey)
+		}
+	}
+}
+
+func (s *Ks3SSECustomerSuite) TestInvalidHeaders(c *C) {
+	var keyErr SSECustomerKeyError
+
+	// The key is missing
+	err := s.bucket.PutObject("object", strings.NewReader("data"), SSECAlgorithm("AES256"))
+	c.Assert(errors.As(err, &keyErr), Equals, true)
+	c.Assert(keyErr.Object, Equals, "object")
+	c.Assert(keyErr.Reason, Equals, "SSE-C key is missing")
+	c.Assert(keyErr.Err, IsNil)
+
+	// The key doesn't match its MD5
+	err = s.bucket.PutObject("object", strings.NewReader("data"), SSECustomer(s.key), SSECKeyMd5(s.srcKey.KeyMD5()))
+	c.Assert(errors.As(err, &keyErr), Equals, true)
+	c.Assert(keyErr.Reason, Equals, "SSE-C key MD5 doesn't match the key")
+
+	// The copy source key isn't base64 encoded
+	_, err = s.bucket.CopyObject("src", "dest", CopySourceSSECustomer(s.srcKey),
+		SetHeader(HTTPHeaderCopySourceSSECKey, "!invalid!"))
+	c.Assert(errors.As(err, &keyErr), Equals, true)
+	c.Assert(keyErr.Reason, Equals, "copy source SSE-C key is not base64 encoded")
+
+	s.mu.Lock()
+	c.Assert(len(s.reqs), Equals, 0)
+	s.mu.Unlock()
+}
+
+func (s *Ks3SSECustomerSuite) TestMissingKeyError(c *C) {
+	s.status = http.StatusBadRequest
+	s.errorBody = "<Error><Code>InvalidRequest</Code><Message>The object was stored using a form of Server Side Encryption. " +
+		"The correct parameters must be provided to retrieve the object.</Message></Error>"
+
+	var keyErr SSECustomerKeyError
+	_, err := s.bucket.GetObject("object")
+	c.Assert(errors.As(err, &keyErr), Equals, true)
+	c.Assert(keyErr.Bucket, Equals, s.bucket.BucketName)
+	c.Assert(keyErr.Reason, Equals, "SSE-C key is missing")
+
+	var srvErr ServiceError
+	c.Assert(errors.As(err, &srvErr), Equals, true)
+	c.Assert(srvErr.Code, Equals, "InvalidRequest")
+
+	// HEAD has no response body
+	_, err = s.bucket.GetObjectDetailedMeta("object")
+	c.Assert(errors.As(err, &keyErr), Equals, true)
+
+	// The target key is set, so the copy source key is missing
+	_, err = s.bucket.CopyObject("src", "dest", SSECustomer(s.key))
+	c.Assert(errors.As(err, &keyErr), Equals, true)
+	c.Assert(keyErr.Reason, Equals, "copy source SSE-C key is missing")
+
+	// The key is set, the error is returned as it is
+	_, err = s.bucket.GetObject("object", SSECustomer(s.key))
+	c.Assert(errors.As(err, &keyErr), Equals, false)
+
+	// Other errors aren't converted
+	s.errorBody = "<Error><Code>InvalidArgument</Code><Message>Invalid argument.</Message></Error>"
+	_, err = s.bucket.GetObject("object")
+	c.Assert(errors.As(err, &keyErr), Equals, false)
+}
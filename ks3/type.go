@@ -59,7 +59,7 @@ type LifecycleConfiguration struct {
 
 // LifecycleRule defines Lifecycle rules
 type LifecycleRule struct {
-	XMLName                        xml.Name                                 `xml:"Rule"`
+	XMLName                        xml.Name                                 `xml:"Rule" json:"-"`
 	ID                             string                                   `xml:"ID,omitempty"`                             // The rule ID
 	Prefix                         string                                   `xml:"Prefix,omitempty"`                         // The object key prefix
 	Filter                         *LifecycleFilter                         `xml:"Filter,omitempty"`                         // the filter property
@@ -67,24 +67,26 @@ type LifecycleRule struct {
 	Expiration                     *LifecycleExpiration                     `xml:"Expiration,omitempty"`                     // The expiration property
 	Transitions                    []LifecycleTransition                    `xml:"Transition,omitempty"`                     // The transition property
 	AbortIncompleteMultipartUpload *LifecycleAbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty"` // The AbortIncompleteMultipartUpload property
+	NoncurrentVersionExpiration    *LifecycleVersionExpiration              `xml:"NoncurrentVersionExpiration,omitempty"`    // The NoncurrentVersionExpiration property
+	NoncurrentVersionTransitions   []LifecycleVersionTransition             `xml:"NoncurrentVersionTransition,omitempty"`    // The NoncurrentVersionTransition property
 }
 
 // LifecycleTransition defines the rule's transition propery
 type LifecycleFilter struct {
-	XMLName xml.Name      `xml:"Filter"`
+	XMLName xml.Name      `xml:"Filter" json:"-"`
 	Prefix  string        `xml:"Prefix,omitempty"` // The object key prefix
 	And     *LifecycleAnd `xml:"And,omitempty"`    // the tags property
 }
 
 type LifecycleAnd struct {
-	XMLName xml.Name `xml:"And"`
+	XMLName xml.Name `xml:"And" json:"-"`
 	Prefix  string   `xml:"Prefix,omitempty"` // The object key prefix
 	Tag     []Tag    `xml:"Tag,omitempty"`    // the tags property
 }
 
 // LifecycleExpiration defines the rule's expiration property
 type LifecycleExpiration struct {
-	XMLName                   xml.Name `xml:"Expiration"`
+	XMLName                   xml.Name `xml:"Expiration" json:"-"`
 	Days                      int      `xml:"Days,omitempty"`                      // Relative expiration time: The expiration time in days after the last modified time
 	Date                      string   `xml:"Date,omitempty"`                      // Absolute expiration time: The expiration time in date, not recommended
 	ExpiredObjectDeleteMarker *bool    `xml:"ExpiredObjectDeleteMarker,omitempty"` // Specifies whether the expired delete tag is automatically deleted
@@ -92,7 +94,7 @@ type LifecycleExpiration struct {
 
 // LifecycleTransition defines the rule's transition propery
 type LifecycleTransition struct {
-	XMLName              xml.Name         `xml:"Transition"`
+	XMLName              xml.Name         `xml:"Transition" json:"-"`
 	Days                 int              `xml:"Days,omitempty"`                 // Relative transition time: The transition time in days after the last modified time
 	Date                 string           `xml:"Date,omitempty"`                 // objects created before the date will be expired
 	StorageClass         StorageClassType `xml:"StorageClass,omitempty"`         // Specifies the target storage type
@@ -102,20 +104,20 @@ type LifecycleTransition struct {
 
 // LifecycleAbortIncompleteMultipartUpload defines the rule's abort multipart upload propery
 type LifecycleAbortIncompleteMultipartUpload struct {
-	XMLName             xml.Name `xml:"AbortIncompleteMultipartUpload"`
+	XMLName             xml.Name `xml:"AbortIncompleteMultipartUpload" json:"-"`
 	DaysAfterInitiation int      `xml:"DaysAfterInitiation,omitempty"` // Relative expiration time: The expiration time in days after the last modified time
 	Date                string   `xml:"Date,omitempty"`                // objects created before the date will be expired
 }
 
 // LifecycleVersionExpiration defines the rule's NoncurrentVersionExpiration propery
 type LifecycleVersionExpiration struct {
-	XMLName        xml.Name `xml:"NoncurrentVersionExpiration"`
+	XMLName        xml.Name `xml:"NoncurrentVersionExpiration" json:"-"`
 	NoncurrentDays int      `xml:"NoncurrentDays,omitempty"` // How many days after the Object becomes a non-current version
 }
 
 // LifecycleVersionTransition defines the rule's NoncurrentVersionTransition propery
 type LifecycleVersionTransition struct {
-	XMLName        xml.Name         `xml:"NoncurrentVersionTransition"`
+	XMLName        xml.Name         `xml:"NoncurrentVersionTransition" json:"-"`
 	NoncurrentDays int              `xml:"NoncurrentDays,omitempty"` // How many days after the Object becomes a non-current version
 	StorageClass   StorageClassType `xml:"StorageClass,omitempty"`
 }
@@ -194,7 +196,7 @@ type GetBucketLifecycleResult LifecycleConfiguration
 
 // RefererXML defines Referer configuration
 type RefererXML struct {
-	XMLName           xml.Name `xml:"RefererConfiguration"`
+	XMLName           xml.Name `xml:"RefererConfiguration" json:"-"`
 	AllowEmptyReferer bool     `xml:"AllowEmptyReferer"`   // Allow empty referrer
 	RefererList       []string `xml:"RefererList>Referer"` // Referer whitelist
 }
@@ -216,7 +218,7 @@ type loggingXMLEmpty struct {
 
 // LoggingEnabled defines the logging configuration information
 type LoggingEnabled struct {
-	XMLName      xml.Name `xml:"LoggingEnabled"`
+	XMLName      xml.Name `xml:"LoggingEnabled" json:"-"`
 	TargetBucket string   `xml:"TargetBucket"` // The bucket name for storing the log files
 	TargetPrefix string   `xml:"TargetPrefix"` // The log file prefix
 }
@@ -226,7 +228,7 @@ type GetBucketLoggingResult LoggingXML
 
 // WebsiteXML defines Website configuration
 type WebsiteXML struct {
-	XMLName       xml.Name      `xml:"WebsiteConfiguration"`
+	XMLName       xml.Name      `xml:"WebsiteConfiguration" json:"-"`
 	IndexDocument IndexDocument `xml:"IndexDocument,omitempty"`            // The index page
 	ErrorDocument ErrorDocument `xml:"ErrorDocument,omitempty"`            // The error page
 	RoutingRules  []RoutingRule `xml:"RoutingRules>RoutingRule,omitempty"` // The routing Rule list
@@ -234,19 +236,19 @@ type WebsiteXML struct {
 
 // IndexDocument defines the index page info
 type IndexDocument struct {
-	XMLName xml.Name `xml:"IndexDocument"`
+	XMLName xml.Name `xml:"IndexDocument" json:"-"`
 	Suffix  string   `xml:"Suffix"` // The file name for the index page
 }
 
 // ErrorDocument defines the 404 error page info
 type ErrorDocument struct {
-	XMLName xml.Name `xml:"ErrorDocument"`
+	XMLName xml.Name `xml:"ErrorDocument" json:"-"`
 	Key     string   `xml:"Key"` // 404 error file name
 }
 
 // RoutingRule defines the routing rules
 type RoutingRule struct {
-	XMLName    xml.Name  `xml:"RoutingRule"`
+	XMLName    xml.Name  `xml:"RoutingRule" json:"-"`
 	RuleNumber int       `xml:"RuleNumber,omitempty"` // The routing number
 	Condition  Condition `xml:"Condition,omitempty"`  // The routing condition
 	Redirect   Redirect  `xml:"Redirect,omitempty"`   // The routing redirect
@@ -255,7 +257,7 @@ type RoutingRule struct {
 
 // Condition defines codition in the RoutingRule
 type Condition struct {
-	XMLName                     xml.Name        `xml:"Condition"`
+	XMLName                     xml.Name        `xml:"Condition" json:"-"`
 	KeyPrefixEquals             string          `xml:"KeyPrefixEquals,omitempty"`             // Matching objcet prefix
 	HTTPErrorCodeReturnedEquals int             `xml:"HttpErrorCodeReturnedEquals,omitempty"` // The rule is for Accessing to the specified object
 	IncludeHeader               []IncludeHeader `xml:"IncludeHeader"`                         // The rule is for request which include header
@@ -263,14 +265,14 @@ type Condition struct {
 
 // IncludeHeader defines includeHeader in the RoutingRule's Condition
 type IncludeHeader struct {
-	XMLName xml.Name `xml:"IncludeHeader"`
+	XMLName xml.Name `xml:"IncludeHeader" json:"-"`
 	Key     string   `xml:"Key,omitempty"`    // The Include header key
 	Equals  string   `xml:"Equals,omitempty"` // The Include header value
 }
 
 // Redirect defines redirect in the RoutingRule
 type Redirect struct {
-	XMLName               xml.Name      `xml:"Redirect"`
+	XMLName               xml.Name      `xml:"Redirect" json:"-"`
 	RedirectType          string        `xml:"RedirectType,omitempty"`         // The redirect type, it have Mirror,External,Internal,AliCDN
 	PassQueryString       *bool         `xml:"PassQueryString"`                // Whether to send the specified request's parameters, true or false
 	MirrorURL             string        `xml:"MirrorURL,omitempty"`            // Mirror of the website address back to the source.
@@ -287,7 +289,7 @@ type Redirect struct {
 
 // MirrorHeaders defines MirrorHeaders in the Redirect
 type MirrorHeaders struct {
-	XMLName xml.Name          `xml:"MirrorHeaders"`
+	XMLName xml.Name          `xml:"MirrorHeaders" json:"-"`
 	PassAll *bool             `xml:"PassAll"` // Penetrating all of headers to source website.
 	Pass    []string          `xml:"Pass"`    // Penetrating some of headers to source website.
 	Remove  []string          `xml:"Remove"`  // Prohibit passthrough some of headers to source website
@@ -296,7 +298,7 @@ type MirrorHeaders struct {
 
 // MirrorHeaderSet defines Set for Redirect's MirrorHeaders
 type MirrorHeaderSet struct {
-	XMLName xml.Name `xml:"Set"`
+	XMLName xml.Name `xml:"Set" json:"-"`
 	Key     string   `xml:"Key,omitempty"`   // The mirror header key
 	Value   string   `xml:"Value,omitempty"` // The mirror header value
 }
@@ -313,7 +315,7 @@ type CORSXML struct {
 
 // CORSRule defines CORS rules
 type CORSRule struct {
-	XMLName       xml.Name `xml:"CORSRule"`
+	XMLName       xml.Name `xml:"CORSRule" json:"-"`
 	AllowedOrigin []string `xml:"AllowedOrigin"` // Allowed origins. By default it's wildcard '*'
 	AllowedMethod []string `xml:"AllowedMethod"` // Allowed methods
 	AllowedHeader []string `xml:"AllowedHeader"` // Allowed headers
@@ -892,7 +894,7 @@ type LiveChannelInfo struct {
 
 // Tag a tag for the object
 type Tag struct {
-	XMLName xml.Name `xml:"Tag"`
+	XMLName xml.Name `xml:"Tag" json:"-"`
 	Key     string   `xml:"Key"`
 	Value   string   `xml:"Value"`
 }
@@ -932,7 +934,7 @@ type RequestPaymentConfiguration struct {
 
 // BucketQoSConfiguration define QoS configuration
 type BucketQoSConfiguration struct {
-	XMLName                   xml.Name `xml:"QoSConfiguration"`
+	XMLName                   xml.Name `xml:"QoSConfiguration" json:"-"`
 	TotalUploadBandwidth      *int     `xml:"TotalUploadBandwidth"`      // Total upload bandwidth
 	IntranetUploadBandwidth   *int     `xml:"IntranetUploadBandwidth"`   // Intranet upload bandwidth
 	ExtranetUploadBandwidth   *int     `xml:"ExtranetUploadBandwidth"`   // Extranet upload bandwidth
@@ -1036,8 +1038,8 @@ func (jsonInput *JSONSelectInput) JsonIsEmpty() bool {
 
 type OutputSerializationSelect struct {
 	XMLName          xml.Name         `xml:"OutputSerialization"`
-	CsvBodyOutput    CSVSelectOutput  `xml:CSV,omitempty`
-	JsonBodyOutput   JSONSelectOutput `xml:JSON,omitempty`
+	CsvBodyOutput    CSVSelectOutput  `xml:"CSV,omitempty"`
+	JsonBodyOutput   JSONSelectOutput `xml:"JSON,omitempty"`
 	OutputRawData    *bool            `xml:"OutputRawData,omitempty"`
 	KeepAllColumns   *bool            `xml:"KeepAllColumns,omitempty"`
 	EnablePayloadCrc *bool            `xml:"EnablePayloadCrc,omitempty"`
@@ -1161,7 +1163,7 @@ type MetaEndFrameJSON struct {
 
 // InventoryConfiguration is Inventory config
 type InventoryConfiguration struct {
-	XMLName        xml.Name        `xml:"InventoryConfiguration"`
+	XMLName        xml.Name        `xml:"InventoryConfiguration" json:"-"`
 	Id             string          `xml:"Id,omitempty"`
 	IsEnabled      *bool           `xml:"IsEnabled,omitempty"`
 	Filter         InventoryFilter `xml:"Filter,omitempty"`
@@ -1171,19 +1173,19 @@ type InventoryConfiguration struct {
 }
 
 type InventoryFilter struct {
-	XMLName                  xml.Name `xml:"Filter"`
+	XMLName                  xml.Name `xml:"Filter" json:"-"`
 	Prefix                   string   `xml:"Prefix,omitempty"`
 	LastModifyBeginTimeStamp string   `xml:"LastModifyBeginTimeStamp,omitempty"`
 	LastModifyEndTimeStamp   string   `xml:"LastModifyEndTimeStamp,omitempty"`
 }
 
 type Destination struct {
-	XMLName              xml.Name             `xml:"Destination"`
+	XMLName              xml.Name             `xml:"Destination" json:"-"`
 	KS3BucketDestination KS3BucketDestination `xml:"KS3BucketDestination,omitempty"`
 }
 
 type KS3BucketDestination struct {
-	XMLName   xml.Name `xml:"KS3BucketDestination"`
+	XMLName   xml.Name `xml:"KS3BucketDestination" json:"-"`
 	Format    string   `xml:"Format,omitempty"`
 	AccountId string   `xml:"AccountId,omitempty"`
 	Bucket    string   `xml:"Bucket,omitempty"`
@@ -1191,12 +1193,12 @@ type KS3BucketDestination struct {
 }
 
 type Schedule struct {
-	XMLName   xml.Name `xml:"Schedule"`
+	XMLName   xml.Name `xml:"Schedule" json:"-"`
 	Frequency string   `xml:"Frequency,omitempty"`
 }
 
 type OptionalFields struct {
-	XMLName xml.Name `xml:"OptionalFields,omitempty"`
+	XMLName xml.Name `xml:"OptionalFields,omitempty" json:"-"`
 	Field   []string `xml:"Field,omitempty"`
 }
 
@@ -1299,7 +1301,7 @@ type RetentionConfiguration struct {
 
 // RetentionRule defines Retention rules
 type RetentionRule struct {
-	XMLName xml.Name `xml:"Rule"`
+	XMLName xml.Name `xml:"Rule" json:"-"`
 	Status  string   `xml:"Status,omitempty"`
 	Days    int      `xml:"Days,omitempty"`
 }
@@ -1336,7 +1338,7 @@ type RetentionObjectProperties struct {
 
 // Replication defines Replication configuration
 type Replication struct {
-	XMLName                     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Replication"`
+	XMLName                     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Replication" json:"-"`
 	Prefix                      []string `xml:"prefix,omitempty"`
 	DeleteMarkerStatus          string   `xml:"DeleteMarkerStatus,omitempty"`
 	TargetBucket                string   `xml:"targetBucket,omitempty"`
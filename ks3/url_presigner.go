@@ -0,0 +1,237 @@
+package ks3
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
+)
+
+// signEndpoint is the host and scheme to sign the URL for, set by SignEndpoint
+type signEndpoint struct {
+	endpoint string
+	isCname  bool
+}
+
+// SignEndpoint is an option to sign the URL for another host than the client endpoint, such as the CDN domain bound to the bucket.
+// It's only used by the URL signing methods, such as SignURL and SignURLs. The canonical resource is still /bucket/object,
+// so the signature is the same as the one for the client endpoint in v1 and v2. In v4 the host is signed.
+//
+// endpoint    the host to sign for, such as https://cdn.example.com. The scheme of the client is used if it's omitted.
+// isCname    true if the host is bound to the bucket (CNAME), otherwise the bucket is prepended to the host (virtual hosted).
+//
+func SignEndpoint(endpoint string, isCname bool) Option {
+	return addArg(signEndpointArg, signEndpoint{endpoint: endpoint, isCname: isCname})
+}
+
+// urlPresigner signs the URLs of the objects with the same method, expiration, parameters and headers.
+// The credentials, the string to sign except the resource and the URL parameters are prepared once,
+// so only the resource is signed for each object.
+type urlPresigner struct {
+	conn       Conn
+	urlMaker   UrlMaker
+	bucketName string
+
+	// v1 and v2
+	keySecret      string
+	signPrefix     string // The string to sign before the canonicalized resource
+	subResource    string
+	paramsBefore   string // The URL parameters before the signature
+	paramsAfter    string // The URL parameters after the signature
+	signatureParam string
+
+	// v4
+	v4 *sign.V4BatchPresigner
+}
+
+// newURLPresigner prepares the presigner, the expiration is the unix time
+func (conn Conn) newURLPresigner(um UrlMaker, method HTTPMethod, bucketName string, expiration int64,
+	params map[string]interface{}, headers map[string]string) (*urlPresigner, error) {
+	akIf, err := conn.config.getCredentials()
+	if err != nil {
+		return nil, err
+	}
+	p := &urlPresigner{conn: conn, urlMaker: um, bucketName: bucketName}
+	m := strings.ToUpper(string(method))
+
+	if conn.config.AuthVersion == AuthV4 {
+		now := time.Now()
+		// The path of the template is not signed, the host is the same for all the objects
+		uri := um.getURL(bucketName, "", conn.getURLParams(params))
+		req := &http.Request{
+			Method: m,
+			URL:    uri,
+			Host:   uri.Host,
+			Header: make(http.Header),
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if p.v4, err = conn.v4Signer(akIf).NewBatchPresigner(req, time.Duration(expiration-now.Unix())*time.Second, now); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	if akIf.GetSecurityToken() != "" {
+		params[HTTPParamSecurityToken] = akIf.GetSecurityToken()
+	}
+
+	req := &http.Request{
+		Method: m,
+		Header: make(http.Header),
+	}
+
+	if conn.config.IsAuthProxy {
+		auth := conn.config.ProxyUser + ":" + conn.config.ProxyPassword
+		basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+		req.Header.Set("Proxy-Authorization", basic)
+	}
+
+	req.Header.Set(HTTPHeaderDate, strconv.FormatInt(expiration, 10))
+	req.Header.Set(HTTPHeaderUserAgent, conn.config.UserAgent)
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	if conn.config.AuthVersion == AuthV2 {
+		params[HTTPParamSignatureVersion] = "KSS2"
+		params[HTTPParamExpiresV2] = strconv.FormatInt(expiration, 10)
+		params[HTTPParamAccessKeyIDV2] = akIf.GetAccessKeyID()
+		additionalList, _ := conn.getAdditionalHeaderKeys(req)
+		if len(additionalList) > 0 {
+			params[HTTPParamAdditionalHeadersV2] = strings.Join(additionalList, ";")
+		}
+		p.signatureParam = HTTPParamSignatureV2
+	} else {
+		p.signatureParam = HTTPParamSignature
+	}
+
+	p.keySecret = akIf.GetAccessKeySecret()
+	p.signPrefix = conn.getStringToSignPrefix(req)
+	p.subResource = conn.getSubResource(params)
+
+	if conn.config.AuthVersion == AuthV1 {
+		params[HTTPParamExpires] = strconv.FormatInt(expiration, 10)
+		params[HTTPParamAccessKeyID] = akIf.GetAccessKeyID()
+	}
+
+	// Split the sorted parameters by the signature, which is the only one that differs between the objects
+	before := map[string]interface{}{}
+	after := map[string]interface{}{}
+	for k, v := range params {
+		if k < p.signatureParam {
+			before[k] = v
+		} else if k > p.signatureParam {
+			after[k] = v
+		}
+	}
+	p.paramsBefore = conn.getURLParams(before)
+	p.paramsAfter = conn.getURLParams(after)
+	return p, nil
+}
+
+// sign signs the URL of the object
+func (p *urlPresigner) sign(objectName string) string {
+	if p.v4 != nil {
+		return p.v4.Presign(p.urlMaker.getURL(p.bucketName, encodeKS3Str(objectName), ""))
+	}
+
+	canonicalResource := p.conn.getResource(p.bucketName, objectName, p.subResource)
+	signStr := p.signPrefix + canonicalResource
+	if p.conn.config.LogLevel >= Debug {
+		p.conn.config.WriteLog(Debug, "[Sign]signStr:%s\n", strings.Replace(signStr, "\n", "\\n", -1))
+	}
+	signedStr := p.conn.signString(signStr, p.keySecret)
+
+	params := make([]string, 0, 3)
+	if p.paramsBefore != "" {
+		params = append(params, p.paramsBefore)
+	}
+	params = append(params, url.QueryEscape(p.signatureParam)+"="+strings.Replace(url.QueryEscape(signedStr), "+", "%20", -1))
+	if p.paramsAfter != "" {
+		params = append(params, p.paramsAfter)
+	}
+	return p.urlMaker.getSignURL(p.bucketName, encodeKS3Str(objectName), strings.Join(params, "&"))
+}
+
+// signURLMaker returns the URL maker of the SignEndpoint option, or the one of the client
+func (bucket Bucket) signURLMaker(options []Option) (UrlMaker, error) {
+	um := bucket.Client.Conn.Url
+	value, err := FindOption(options, signEndpointArg, nil)
+	if err != nil || value == nil {
+		return *um, err
+	}
+	ep := value.(signEndpoint)
+	if ep.endpoint == "" {
+		return *um, fmt.Errorf("the sign endpoint is empty")
+	}
+
+	var signUm UrlMaker
+	if err = signUm.Init(ep.endpoint, ep.isCname, false, bucket.Client.Config.PathStyleAccess); err != nil {
+		return *um, err
+	}
+	if !strings.HasPrefix(ep.endpoint, "http://") && !strings.HasPrefix(ep.endpoint, "https://") {
+		signUm.Scheme = um.Scheme
+	}
+	if signUm.NetLoc == "" {
+		return *um, fmt.Errorf("invalid sign endpoint: %s", ep.endpoint)
+	}
+	return signUm, nil
+}
+
+// newURLPresigner prepares the presigner of the bucket by the options, the extra parameters are added to the ones of the options
+func (bucket Bucket) newURLPresigner(method HTTPMethod, expiration int64, extraParams map[string]interface{},
+	options []Option) (*urlPresigner, error) {
+	params, err := GetRawParams(options)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range extraParams {
+		params[k] = v
+	}
+
+	headers := make(map[string]string)
+	if err = handleOptions(headers, options); err != nil {
+		return nil, err
+	}
+
+	um, err := bucket.signURLMaker(options)
+	if err != nil {
+		return nil, err
+	}
+	return bucket.Client.Conn.newURLPresigner(um, method, bucket.BucketName, expiration, params, headers)
+}
+
+// SignURLs signs the URLs of the objects with the same method, expiration and options. It's much faster than calling SignURL
+// for each object, since the credentials, the headers and the parameters are prepared once and only the object is signed.
+//
+// objectKeys    the objects to sign.
+// method    the HTTP method.
+// expiredInSec    the expiration in seconds.
+// options    the options of the URLs, such as SignEndpoint, ResponseContentType and the headers the client must send.
+//
+// []string    the signed URLs in the same order as objectKeys, when error is nil.
+// error    it's nil if no error, otherwise it's an error object.
+//
+func (bucket Bucket) SignURLs(objectKeys []string, method HTTPMethod, expiredInSec int64, options ...Option) ([]string, error) {
+	if expiredInSec < 0 {
+		return nil, fmt.Errorf("invalid expires: %d, expires must bigger than 0", expiredInSec)
+	}
+	p, err := bucket.newURLPresigner(method, time.Now().Unix()+expiredInSec, nil, options)
+	if err != nil {
+		return nil, err
+	}
+
+	signedURLs := make([]string, len(objectKeys))
+	for i, objectKey := range objectKeys {
+		signedURLs[i] = p.sign(objectKey)
+	}
+	return signedURLs, nil
+}
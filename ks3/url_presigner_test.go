@@ -0,0 +1,165 @@
+package ks3
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3/sign"
+	. "gopkg.in/check.v1"
+)
+
+type Ks3URLPresignerSuite struct{}
+
+var _ = Suite(&Ks3URLPresignerSuite{})
+
+// verifySignedURL verifies the signed URL as the server does, bucket is set for the CNAME host
+func verifySignedURL(c *C, method, signedURL, bucket string, headers map[string]string) *sign.VerifyResult {
+	req, err := http.NewRequest(method, signedURL, nil)
+	c.Assert(err, IsNil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	verifier := &sign.Verifier{Lookup: verifyLookup}
+	if bucket != "" {
+		verifier.Bucket = func(*http.Request) string { return bucket }
+	}
+	result, err := verifier.Verify(req)
+	c.Assert(err, IsNil, Commentf("%s %s", method, signedURL))
+	return result
+}
+
+func (s *Ks3URLPresignerSuite) TestSignURLs(c *C) {
+	keys := []string{"a.txt", "dir/b c.txt", "中文/d+e.txt", "f?g=h"}
+	headers := map[string]string{HTTPHeaderContentType: "text/plain"}
+	for _, version := range []AuthVersionType{AuthV1, AuthV2, AuthV4} {
+		bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com", AuthVersion(version),
+			SetCredentialsProvider(NewStaticCredentialsProvider("sts-ak", "sk", "token")))
+		signedURLs, err := bucket.SignURLs(keys, HTTPPut, 600, ContentType("text/plain"), ResponseContentType("text/html"))
+		c.Assert(err, IsNil)
+		c.Assert(len(signedURLs), Equals, len(keys))
+
+		for i, signedURL := range signedURLs {
+			result := verifySignedURL(c, "PUT", signedURL, "", headers)
+			if version != AuthV4 {
+				c.Assert(result.Bucket, Equals, "url-bucket")
+			}
+			c.Assert(result.Object, Equals, keys[i])
+			c.Assert(result.SecurityToken, Equals, "token")
+
+			info, err := ParseSignedURL(signedURL)
+			c.Assert(err, IsNil)
+			c.Assert(info.Key, Equals, keys[i])
+			c.Assert(info.ResponseContentType, Equals, "text/html")
+		}
+	}
+
+	bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com")
+	signedURLs, err := bucket.SignURLs(nil, HTTPGet, 60)
+	c.Assert(err, IsNil)
+	c.Assert(len(signedURLs), Equals, 0)
+	_, err = bucket.SignURLs(keys, HTTPGet, -1)
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3URLPresignerSuite) TestSameAsSignURL(c *C) {
+	for _, version := range []AuthVersionType{AuthV1, AuthV2} {
+		bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com", AuthVersion(version),
+			AdditionalHeaders([]string{"Content-Type"}))
+		expiration := time.Now().Unix() + 60
+		p, err := bucket.newURLPresigner(HTTPGet, expiration, nil, []Option{ContentType("text/plain"), VersionId("v1")})
+		c.Assert(err, IsNil)
+
+		for _, key := range []string{"a.txt", "dir/b c.txt"} {
+			params := map[string]interface{}{"versionId": "v1"}
+			expected, err := bucket.Client.Conn.signURL(HTTPGet, bucket.BucketName, key, expiration, params,
+				map[string]string{HTTPHeaderContentType: "text/plain"})
+			c.Assert(err, IsNil)
+			c.Assert(p.sign(key), Equals, expected)
+		}
+	}
+}
+
+func (s *Ks3URLPresignerSuite) TestSignEndpointCname(c *C) {
+	bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com")
+	expiration := time.Now().Unix() + 60
+
+	p, err := bucket.newURLPresigner(HTTPGet, expiration, nil, nil)
+	c.Assert(err, IsNil)
+	origin, err := url.Parse(p.sign("dir/object"))
+	c.Assert(err, IsNil)
+
+	p, err = bucket.newURLPresigner(HTTPGet, expiration, nil, []Option{SignEndpoint("https://cdn.example.com", true)})
+	c.Assert(err, IsNil)
+	cdn, err := url.Parse(p.sign("dir/object"))
+	c.Assert(err, IsNil)
+	c.Assert(cdn.Scheme, Equals, "https")
+	c.Assert(cdn.Host, Equals, "cdn.example.com")
+	c.Assert(cdn.Path, Equals, "/dir/object")
+
+	// The canonical resource is the same, so is the signature
+	c.Assert(cdn.RawQuery, Equals, origin.RawQuery)
+	result := verifySignedURL(c, "GET", cdn.String(), "url-bucket", nil)
+	c.Assert(result.Object, Equals, "dir/object")
+
+	// The client is not changed
+	signedURL, err := bucket.SignURL("dir/object", HTTPGet, 60)
+	c.Assert(err, IsNil)
+	info, err := ParseSignedURL(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(info.IsCname, Equals, false)
+}
+
+func (s *Ks3URLPresignerSuite) TestSignEndpointVirtualHosted(c *C) {
+	bucket := newSignedURLBucket(c, "https://ks3-cn-beijing.ksyuncs.com")
+	signedURLs, err := bucket.SignURLs([]string{"object"}, HTTPGet, 60, SignEndpoint("ks3-cn-beijing-internal.ksyuncs.com", false))
+	c.Assert(err, IsNil)
+	u, err := url.Parse(signedURLs[0])
+	c.Assert(err, IsNil)
+	c.Assert(u.Scheme, Equals, "https")
+	c.Assert(u.Host, Equals, "url-bucket.ks3-cn-beijing-internal.ksyuncs.com")
+	c.Assert(u.Path, Equals, "/object")
+	verifySignedURL(c, "GET", signedURLs[0], "", nil)
+
+	// The path style of the client is kept
+	bucket = newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com", PathStyleAccess(true))
+	signedURL, err := bucket.SignURL("object", HTTPGet, 60, SignEndpoint("http://ks3-cn-beijing-internal.ksyuncs.com", false))
+	c.Assert(err, IsNil)
+	u, err = url.Parse(signedURL)
+	c.Assert(err, IsNil)
+	c.Assert(u.Host, Equals, "ks3-cn-beijing-internal.ksyuncs.com")
+	c.Assert(u.Path, Equals, "/url-bucket/object")
+	verifySignedURL(c, "GET", signedURL, "", nil)
+}
+
+func (s *Ks3URLPresignerSuite) TestSignEndpointV4(c *C) {
+	bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com", AuthVersion(AuthV4))
+	keys := []string{"a.txt", "dir/b c.txt"}
+	signedURLs, err := bucket.SignURLs(keys, HTTPGet, 60, SignEndpoint("https://cdn.example.com", true))
+	c.Assert(err, IsNil)
+	for i, signedURL := range signedURLs {
+		u, err := url.Parse(signedURL)
+		c.Assert(err, IsNil)
+		c.Assert(u.Host, Equals, "cdn.example.com")
+		c.Assert(u.Path, Equals, "/"+keys[i])
+		result := verifySignedURL(c, "GET", signedURL, "url-bucket", nil)
+		c.Assert(result.Form, Equals, sign.FormQueryV4)
+		c.Assert(result.Object, Equals, keys[i])
+	}
+
+	// The multipart steps accept the endpoint too
+	imur := InitiateMultipartUploadResult{Key: "object", UploadID: "upload-id"}
+	signedURL, err := bucket.SignUploadPartURL(imur, 1, 60, SignEndpoint("https://cdn.example.com", true))
+	c.Assert(err, IsNil)
+	c.Assert(verifySignedURL(c, "PUT", signedURL, "url-bucket", nil).Object, Equals, "object")
+}
+
+func (s *Ks3URLPresignerSuite) TestSignEndpointInvalid(c *C) {
+	bucket := newSignedURLBucket(c, "http://ks3-cn-beijing.ksyuncs.com")
+	for _, endpoint := range []string{"", "http://", "http://bad host%"} {
+		_, err := bucket.SignURL("object", HTTPGet, 60, SignEndpoint(endpoint, true))
+		c.Assert(err, NotNil, Commentf("%s", endpoint))
+		_, err = bucket.SignURLs([]string{"object"}, HTTPGet, 60, SignEndpoint(endpoint, false))
+		c.Assert(err, NotNil, Commentf("%s", endpoint))
+	}
+}
@@ -330,6 +330,8 @@ func ChoiceTransferPartOption(options []Option) []Option {
 		outOption = append(outOption, GetResponseHeader(respHeader.(*http.Header)))
 	}
 
+	outOption = append(outOption, choiceSSECustomerOption(options)...)
+
 	return outOption
 }
 
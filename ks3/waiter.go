@@ -0,0 +1,215 @@
+package ks3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The terminal states of the async fetch tasks
+const (
+	AsyncTaskStateSuccess = "Success"
+	AsyncTaskStateFailed  = "Failed"
+)
+
+// isNotFound reports whether the request failed with 404, HEAD has no error code in the body
+func isNotFound(err error) bool {
+	serviceErr, ok := err.(ServiceError)
+	return ok && serviceErr.StatusCode == http.StatusNotFound
+}
+
+// WaitUntilBucketExists polls HEAD bucket until the bucket exists, such as after it's created.
+//
+// ctx    the context to cancel the waiting, the requests are sent with it.
+// bucketName    the bucket name.
+// policy    how to poll.
+//
+// error    it's nil if the bucket exists, *WaitError if the waiting timed out or was canceled,
+//          otherwise the error of HEAD.
+//
+func (client Client) WaitUntilBucketExists(ctx context.Context, bucketName string, policy PollPolicy, options ...Option) error {
+	return Wait(ctx, "BucketExists", policy, func(ctx context.Context) (WaitOutcome, error) {
+		_, err := client.HeadBucket(bucketName, withOption(options, WithContext(ctx))...)
+		if isNotFound(err) {
+			return WaitPending, nil
+		}
+		if err != nil {
+			return WaitPending, err
+		}
+		return WaitSucceeded, nil
+	})
+}
+
+// WaitUntilBucketNotExists polls HEAD bucket until the bucket doesn't exist, such as after it's deleted.
+//
+// ctx    the context to cancel the waiting, the requests are sent with it.
+// bucketName    the bucket name.
+// policy    how to poll.
+//
+// error    it's nil if the bucket doesn't exist, *WaitError if the waiting timed out or was canceled,
+//          otherwise the error of HEAD.
+//
+func (client Client) WaitUntilBucketNotExists(ctx context.Context, bucketName string, policy PollPolicy, options ...Option) error {
+	return Wait(ctx, "BucketNotExists", policy, func(ctx context.Context) (WaitOutcome, error) {
+		_, err := client.HeadBucket(bucketName, withOption(options, WithContext(ctx))...)
+		if isNotFound(err) {
+			return WaitSucceeded, nil
+		}
+		return WaitPending, err
+	})
+}
+
+// WaitUntilAsyncTaskDone polls the async fetch task created by SetBucketAsyncTask until it succeeds or fails.
+//
+// ctx    the context to cancel the waiting, the requests are sent with it.
+// bucketName    the bucket name.
+// taskID    the task ID returned by SetBucketAsyncTask.
+// policy    how to poll.
+//
+// AsynFetchTaskInfo    the last task info, which is returned if the task failed too.
+// error    it's nil if the task succeeded, *WaitError with the ErrorMsg of the task if it failed,
+//          or if the waiting timed out or was canceled, otherwise the error of the request.
+//
+func (client Client) WaitUntilAsyncTaskDone(ctx context.Context, bucketName, taskID string, policy PollPolicy, options ...Option) (AsynFetchTaskInfo, error) {
+	var info AsynFetchTaskInfo
+	err := Wait(ctx, "AsyncTaskDone", policy, func(ctx context.Context) (WaitOutcome, error) {
+		current, err := client.GetBucketAsyncTask(bucketName, taskID, withOption(options, WithContext(ctx))...)
+		if err != nil {
+			return WaitPending, err
+		}
+		info = current
+		switch {
+		case strings.EqualFold(info.State, AsyncTaskStateSuccess):
+			return WaitSucceeded, nil
+		case strings.EqualFold(info.State, AsyncTaskStateFailed):
+			return WaitFailed, fmt.Errorf("the async fetch task %s failed: %s", taskID, info.ErrorMsg)
+		}
+		return WaitPending, nil
+	})
+	return info, err
+}
+
+// WaitUntilBucketWormState polls the WORM configuration until it's in the state, such as after InitiateBucketWorm
+// or CompleteBucketWorm. The missing configuration is pending since it may not be visible yet.
+//
+// ctx    the context to cancel the waiting, the requests are sent with it.
+// bucketName    the bucket name.
+// state    the State of GetBucketWorm to wait for, it's compared case-insensitively.
+// policy    how to poll.
+//
+// WormConfiguration    the last configuration.
+// error    it's nil if the configuration is in the state, *WaitError if the waiting timed out or was canceled,
+//          otherwise the error of the request.
+//
+func (client Client) WaitUntilBucketWormState(ctx context.Context, bucketName, state string, policy PollPolicy, options ...Option) (WormConfiguration, error) {
+	var worm WormConfiguration
+	err := Wait(ctx, "BucketWormState", policy, func(ctx context.Context) (WaitOutcome, error) {
+		current, err := client.GetBucketWorm(bucketName, withOption(options, WithContext(ctx))...)
+		if isNotFound(err) {
+			return WaitPending, nil
+		}
+		if err != nil {
+			return WaitPending, err
+		}
+		worm = current
+		if strings.EqualFold(worm.State, state) {
+			return WaitSucceeded, nil
+		}
+		return WaitPending, nil
+	})
+	return worm, err
+}
+
+// WaitUntilReplicationCaughtUp polls GetBucketReplicationProgress until caughtUp reports the replication caught up.
+// The progress is passed to caughtUp as returned, caughtUp parses it, such as to compare the time of the replicated
+// new objects with the time of the last upload. The errors of caughtUp end the waiting as failures, so a progress
+// it doesn't recognize fails instead of being polled until the timeout.
+//
+// ctx    the context to cancel the waiting, the requests are sent with it.
+// bucketName    the bucket name.
+// ruleId    the ID of the replication rule, all the rules if it's empty.
+// caughtUp    reports whether the progress caught up.
+// policy    how to poll.
+//
+// string    the last progress.
+// error    it's nil if the replication caught up, *WaitError if caughtUp failed, or if the waiting timed out
+//          or was canceled, otherwise the error of the request.
+//
+func (client Client) WaitUntilReplicationCaughtUp(ctx context.Context, bucketName, ruleId string, caughtUp func(progress string) (bool, error), policy PollPolicy, options ...Option) (string, error) {
+	var progress string
+	err := Wait(ctx, "ReplicationCaughtUp", policy, func(ctx context.Context) (WaitOutcome, error) {
+		current, err := client.GetBucketReplicationProgress(bucketName, ruleId, withOption(options, WithContext(ctx))...)
+		if err != nil {
+			return WaitPending, err
+		}
+		progress = current
+		done, err := caughtUp(progress)
+		if err != nil {
+			return WaitFailed, err
+		}
+		if done {
+			return WaitSucceeded, nil
+		}
+		return WaitPending, nil
+	})
+	return progress, err
+}
+
+// WaitUntilObjectExists polls HEAD object until the object exists, such as after it's uploaded or replicated.
+//
+// ctx    the context to cancel the waiting, the requests are sent with it.
+// objectKey    the object key.
+// policy    how to poll.
+// options    the options of HEAD, such as VersionId.
+//
+// *ObjectMeta    the metadata of the object, it's only valid when error is nil.
+// error    it's nil if the object exists, *WaitError if the waiting timed out or was canceled,
+//          otherwise the error of HEAD.
+//
+func (bucket Bucket) WaitUntilObjectExists(ctx context.Context, objectKey string, policy PollPolicy, options ...Option) (*ObjectMeta, error) {
+	var meta *ObjectMeta
+	err := Wait(ctx, "ObjectExists", policy, func(ctx context.Context) (WaitOutcome, error) {
+		var err error
+		meta, err = bucket.HeadObject(objectKey, withOption(options, WithContext(ctx))...)
+		if isNotFound(err) {
+			return WaitPending, nil
+		}
+		if err != nil {
+			return WaitPending, err
+		}
+		return WaitSucceeded, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// WaitUntilLiveChannelStatus polls the live-channel until its push status is the status, such as Live after
+// the client starts pushing, or Idle after it stops.
+//
+// ctx    the context to cancel the waiting, the requests are sent with it.
+// channelName    the name of the channel.
+// status    the Status of GetLiveChannelStat to wait for: Disabled, Live or Idle, it's compared case-insensitively.
+// policy    how to poll.
+//
+// LiveChannelStat    the last state of the live-channel.
+// error    it's nil if the live-channel is in the status, *WaitError if the waiting timed out or was canceled,
+//          otherwise the error of the request.
+//
+func (bucket Bucket) WaitUntilLiveChannelStatus(ctx context.Context, channelName, status string, policy PollPolicy, options ...Option) (LiveChannelStat, error) {
+	var stat LiveChannelStat
+	err := Wait(ctx, "LiveChannelStatus", policy, func(ctx context.Context) (WaitOutcome, error) {
+		current, err := bucket.GetLiveChannelStat(channelName, withOption(options, WithContext(ctx))...)
+		if err != nil {
+			return WaitPending, err
+		}
+		stat = current
+		if strings.EqualFold(stat.Status, status) {
+			return WaitSucceeded, nil
+		}
+		return WaitPending, nil
+	})
+	return stat, err
+}
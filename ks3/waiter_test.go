@@ -0,0 +1,264 @@
+package ks3
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type Ks3WaiterSuite struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	requests int // The number of the requests
+	handler  func(w http.ResponseWriter, r *http.Request, n int)
+}
+
+var _ = Suite(&Ks3WaiterSuite{})
+
+func (s *Ks3WaiterSuite) SetUpTest(c *C) {
+	s.requests = 0
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+		s.handler(w, r, s.requests)
+	}))
+}
+
+func (s *Ks3WaiterSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *Ks3WaiterSuite) newClient(c *C) *Client {
+	addr := s.server.Listener.Addr().String()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	client, err := New("http://ks3-cn-beijing.ksyuncs.com", "ak", "sk", HTTPClient(&http.Client{Transport: transport}))
+	c.Assert(err, IsNil)
+	return client
+}
+
+var waiterPolicy = PollPolicy{Interval: time.Millisecond, Multiplier: 2, MaxInterval: 4 * time.Millisecond, MaxDuration: time.Second}
+
+func (s *Ks3WaiterSuite) TestWait(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {}
+	calls := 0
+	err := Wait(context.Background(), "Done", waiterPolicy, func(ctx context.Context) (WaitOutcome, error) {
+		calls++
+		if calls < 3 {
+			return WaitPending, nil
+		}
+		return WaitSucceeded, nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 3)
+
+	err = Wait(context.Background(), "Done", waiterPolicy, func(ctx context.Context) (WaitOutcome, error) {
+		return WaitFailed, nil
+	})
+	c.Assert(err, ErrorMatches, "ks3: wait until Done Failed after 1 attempts in .*: the operation failed")
+
+	// The errors of check aren't wrapped
+	err = Wait(context.Background(), "Done", waiterPolicy, func(ctx context.Context) (WaitOutcome, error) {
+		return WaitPending, fmt.Errorf("boom")
+	})
+	c.Assert(err, ErrorMatches, "boom")
+
+	policy := waiterPolicy
+	policy.MaxDuration = 20 * time.Millisecond
+	err = Wait(context.Background(), "Done", policy, func(ctx context.Context) (WaitOutcome, error) {
+		return WaitPending, nil
+	})
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitTimedOut)
+	c.Assert(err.(*WaitError).Attempts > 1, Equals, true)
+	c.Assert(err.(*WaitError).Elapsed >= policy.MaxDuration, Equals, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = Wait(ctx, "Done", waiterPolicy, func(ctx context.Context) (WaitOutcome, error) {
+		cancel()
+		return WaitPending, nil
+	})
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitCanceled)
+	c.Assert(err.(*WaitError).Err, Equals, context.Canceled)
+}
+
+func (s *Ks3WaiterSuite) TestWaitUntilBucketExists(c *C) {
+	client := s.newClient(c)
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		c.Assert(r.Method, Equals, "HEAD")
+		c.Assert(strings.HasPrefix(r.Host, "new-bucket."), Equals, true)
+		if n < 3 {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	c.Assert(client.WaitUntilBucketExists(context.Background(), "new-bucket", waiterPolicy), IsNil)
+	c.Assert(s.requests, Equals, 3)
+
+	s.requests = 0
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		if n > 1 {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	c.Assert(client.WaitUntilBucketNotExists(context.Background(), "new-bucket", waiterPolicy), IsNil)
+	c.Assert(s.requests, Equals, 2)
+
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		w.WriteHeader(http.StatusForbidden)
+	}
+	err := client.WaitUntilBucketExists(context.Background(), "new-bucket", waiterPolicy)
+	c.Assert(err.(ServiceError).StatusCode, Equals, http.StatusForbidden)
+	err = client.WaitUntilBucketNotExists(context.Background(), "new-bucket", waiterPolicy)
+	c.Assert(err.(ServiceError).StatusCode, Equals, http.StatusForbidden)
+}
+
+func (s *Ks3WaiterSuite) TestWaitUntilObjectExists(c *C) {
+	client := s.newClient(c)
+	bucket, err := client.Bucket("waiter-bucket")
+	c.Assert(err, IsNil)
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		c.Assert(r.URL.Path, Equals, "/object")
+		if n < 2 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set(HTTPHeaderContentLength, "3")
+	}
+	meta, err := bucket.WaitUntilObjectExists(context.Background(), "object", waiterPolicy)
+	c.Assert(err, IsNil)
+	c.Assert(meta.ContentLength, Equals, int64(3))
+
+	// The object never exists
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	policy := waiterPolicy
+	policy.MaxDuration = 20 * time.Millisecond
+	meta, err = bucket.WaitUntilObjectExists(context.Background(), "object", policy)
+	c.Assert(meta, IsNil)
+	c.Assert(err.(*WaitError).Waiter, Equals, "ObjectExists")
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitTimedOut)
+}
+
+func (s *Ks3WaiterSuite) TestWaitUntilAsyncTaskDone(c *C) {
+	client := s.newClient(c)
+	states := []string{"Running", "Retry", "Success"}
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		c.Assert(r.URL.Query()["asyncFetch"], NotNil)
+		c.Assert(r.Header.Get(HTTPHeaderKs3TaskID), Equals, "task")
+		fmt.Fprintf(w, "<AsyncFetchTaskInfo><TaskId>task</TaskId><State>%s</State></AsyncFetchTaskInfo>", states[n-1])
+	}
+	info, err := client.WaitUntilAsyncTaskDone(context.Background(), "waiter-bucket", "task", waiterPolicy)
+	c.Assert(err, IsNil)
+	c.Assert(info.State, Equals, "Success")
+	c.Assert(s.requests, Equals, 3)
+
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Write([]byte("<AsyncFetchTaskInfo><TaskId>task</TaskId><State>failed</State><ErrorMsg>source not found</ErrorMsg></AsyncFetchTaskInfo>"))
+	}
+	info, err = client.WaitUntilAsyncTaskDone(context.Background(), "waiter-bucket", "task", waiterPolicy)
+	c.Assert(info.ErrorMsg, Equals, "source not found")
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitFailed)
+	c.Assert(err, ErrorMatches, ".*: the async fetch task task failed: source not found")
+
+	// The request is canceled with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+	}
+	_, err = client.WaitUntilAsyncTaskDone(ctx, "waiter-bucket", "task", waiterPolicy)
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitCanceled)
+}
+
+func (s *Ks3WaiterSuite) TestWaitUntilReplicationCaughtUp(c *C) {
+	client := s.newClient(c)
+	progresses := []string{"<Progress>50</Progress>", "<Progress>80</Progress>", "<Progress>100</Progress>"}
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		c.Assert(r.URL.Query()["replicationProgress"], NotNil)
+		c.Assert(r.URL.Query().Get("rule-id"), Equals, "rule")
+		w.Write([]byte(progresses[n-1]))
+	}
+	// The caller parses the progress
+	caughtUp := func(progress string) (bool, error) {
+		var percent int
+		if _, err := fmt.Sscanf(progress, "<Progress>%d</Progress>", &percent); err != nil {
+			return false, fmt.Errorf("unrecognized progress %q", progress)
+		}
+		return percent == 100, nil
+	}
+	progress, err := client.WaitUntilReplicationCaughtUp(context.Background(), "waiter-bucket", "rule", caughtUp, waiterPolicy)
+	c.Assert(err, IsNil)
+	c.Assert(progress, Equals, "<Progress>100</Progress>")
+	c.Assert(s.requests, Equals, 3)
+
+	// The unrecognized progress fails at once instead of being polled until the timeout
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Write([]byte("<ReplicationProgress></ReplicationProgress>"))
+	}
+	progress, err = client.WaitUntilReplicationCaughtUp(context.Background(), "waiter-bucket", "rule", caughtUp, waiterPolicy)
+	c.Assert(progress, Equals, "<ReplicationProgress></ReplicationProgress>")
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitFailed)
+	c.Assert(err.(*WaitError).Attempts, Equals, 1)
+	c.Assert(err, ErrorMatches, `.*: unrecognized progress "<ReplicationProgress></ReplicationProgress>"`)
+}
+
+func (s *Ks3WaiterSuite) TestWaitUntilBucketWormState(c *C) {
+	client := s.newClient(c)
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		c.Assert(r.URL.Query()["worm"], NotNil)
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<Error><Code>NoSuchWORMConfiguration</Code></Error>"))
+		case 2:
+			w.Write([]byte("<WormConfiguration><WormId>worm</WormId><State>InProgress</State></WormConfiguration>"))
+		default:
+			w.Write([]byte("<WormConfiguration><WormId>worm</WormId><State>Locked</State></WormConfiguration>"))
+		}
+	}
+	worm, err := client.WaitUntilBucketWormState(context.Background(), "waiter-bucket", "locked", waiterPolicy)
+	c.Assert(err, IsNil)
+	c.Assert(worm.WormId, Equals, "worm")
+	c.Assert(worm.State, Equals, "Locked")
+	c.Assert(s.requests, Equals, 3)
+}
+
+func (s *Ks3WaiterSuite) TestWaitUntilLiveChannelStatus(c *C) {
+	client := s.newClient(c)
+	bucket, err := client.Bucket("waiter-bucket")
+	c.Assert(err, IsNil)
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		c.Assert(r.URL.Path, Equals, "/channel")
+		c.Assert(r.URL.Query().Get("comp"), Equals, "stat")
+		status := "Idle"
+		if n > 1 {
+			status = "Live"
+		}
+		fmt.Fprintf(w, "<LiveChannelStat><Status>%s</Status><RemoteAddr>10.0.0.1:1935</RemoteAddr></LiveChannelStat>", status)
+	}
+	stat, err := bucket.WaitUntilLiveChannelStatus(context.Background(), "channel", "Live", waiterPolicy)
+	c.Assert(err, IsNil)
+	c.Assert(stat.Status, Equals, "Live")
+	c.Assert(stat.RemoteAddr, Equals, "10.0.0.1:1935")
+
+	// The push never starts
+	s.handler = func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Write([]byte("<LiveChannelStat><Status>Idle</Status></LiveChannelStat>"))
+	}
+	policy := waiterPolicy
+	policy.MaxDuration = 20 * time.Millisecond
+	stat, err = bucket.WaitUntilLiveChannelStatus(context.Background(), "channel", "Live", policy)
+	c.Assert(stat.Status, Equals, "Idle")
+	c.Assert(err.(*WaitError).Outcome, Equals, WaitTimedOut)
+}
@@ -0,0 +1,216 @@
+// Package website simulates the static website of a bucket, so the routing rules could be tested before
+// they're set by Client.SetBucketWebsiteDetail:
+//
+//	action, err := website.Simulate(config, website.Request{
+//		URL:        "http://bucket.ks3-cn-beijing.ksyuncs.com/docs/guide.html?lang=en",
+//		Headers:    http.Header{"User-Agent": {"curl"}},
+//		StatusCode: http.StatusNotFound, // the object doesn't exist
+//	})
+//	// action.Type is ServeObject, ServeIndex, ServeError, Redirect or Mirror
+//
+// The rules are evaluated in the order of RuleNumber, and the first rule whose condition matches applies.
+// A rule with HttpErrorCodeReturnedEquals only applies when the object would return the status code.
+package website
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+)
+
+// ActionType is the type of the action the website takes
+type ActionType string
+
+// The actions of the website
+const (
+	ServeObject ActionType = "ServeObject" // Return the object, the key may be rewritten by an Internal rule
+	ServeIndex  ActionType = "ServeIndex"  // Return the index document of the directory
+	ServeError  ActionType = "ServeError"  // Return the error document, or the error itself if there's no error document
+	Redirect    ActionType = "Redirect"    // Redirect the client to Location
+	Mirror      ActionType = "Mirror"      // Fetch the object from the origin at MirrorURL
+)
+
+// The redirect types of the routing rules
+const (
+	RedirectExternal = "External"
+	RedirectInternal = "Internal"
+	RedirectMirror   = "Mirror"
+	RedirectCDN      = "AliCDN"
+)
+
+// Request is the incoming request of the website
+type Request struct {
+	URL        string      // The URL or the path with the query, such as /docs/a.html?lang=en
+	Headers    http.Header // The request headers
+	StatusCode int         // The status the object would return, 404 if it doesn't exist, 0 is 200
+}
+
+// Action is what the website does for the request
+type Action struct {
+	Type          ActionType
+	RuleNumber    int         // The number of the applied routing rule, 0 if no rule applied
+	Key           string      // The key served by ServeObject, ServeIndex and ServeError, or the rewritten key
+	StatusCode    int         // The status of the response, 0 for Mirror, it's decided by the origin
+	Location      string      // The URL of Redirect
+	MirrorURL     string      // The URL fetched by Mirror
+	MirrorHeaders http.Header // The headers sent to the origin by Mirror
+	Reasons       []string    // Why the rules before the applied one (or all the rules) didn't match
+}
+
+// Simulate returns what the website configuration does for the request. It returns an error if the URL of the
+// request is malformed.
+func Simulate(config ks3.WebsiteXML, req Request) (*Action, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URL %q: %s", req.URL, err.Error())
+	}
+	status := req.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+	isIndex := false
+	if suffix := config.IndexDocument.Suffix; suffix != "" && (key == "" || strings.HasSuffix(key, "/")) {
+		key, isIndex = key+suffix, true
+	}
+
+	action := &Action{}
+	for _, rule := range sortedRules(config.RoutingRules) {
+		if reason := mismatch(rule.Condition, key, req.Headers, status); reason != "" {
+			action.Reasons = append(action.Reasons, fmt.Sprintf("rule %d: %s", rule.RuleNumber, reason))
+			continue
+		}
+		action.RuleNumber = rule.RuleNumber
+		redirect(action, rule, u, key, req.Headers)
+		return action, nil
+	}
+
+	switch {
+	case status/100 == 2 && isIndex:
+		action.Type, action.Key, action.StatusCode = ServeIndex, key, status
+	case status/100 == 2:
+		action.Type, action.Key, action.StatusCode = ServeObject, key, status
+	case status == http.StatusNotFound && config.ErrorDocument.Key != "":
+		action.Type, action.Key, action.StatusCode = ServeError, config.ErrorDocument.Key, status
+	default:
+		action.Type, action.Key, action.StatusCode = ServeError, "", status
+	}
+	return action, nil
+}
+
+// sortedRules sorts the rules by the rule numbers
+func sortedRules(rules []ks3.RoutingRule) []ks3.RoutingRule {
+	sorted := append([]ks3.RoutingRule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].RuleNumber < sorted[j].RuleNumber })
+	return sorted
+}
+
+// mismatch returns why the condition doesn't match the request, empty if it matches
+func mismatch(cond ks3.Condition, key string, headers http.Header, status int) string {
+	if !strings.HasPrefix(key, cond.KeyPrefixEquals) {
+		return fmt.Sprintf("the key %q doesn't start with %q", key, cond.KeyPrefixEquals)
+	}
+	if cond.HTTPErrorCodeReturnedEquals != 0 && cond.HTTPErrorCodeReturnedEquals != status {
+		return fmt.Sprintf("the status %d isn't %d", status, cond.HTTPErrorCodeReturnedEquals)
+	}
+	for _, h := range cond.IncludeHeader {
+		if values, ok := headers[http.CanonicalHeaderKey(h.Key)]; !ok || len(values) == 0 || values[0] != h.Equals {
+			return fmt.Sprintf("the header %s isn't %q", h.Key, h.Equals)
+		}
+	}
+	return ""
+}
+
+// redirect fills the action of the rule
+func redirect(action *Action, rule ks3.RoutingRule, u *url.URL, key string, headers http.Header) {
+	r := rule.Redirect
+	newKey := rewriteKey(r, rule.Condition.KeyPrefixEquals, key)
+	action.Key = newKey
+
+	switch r.RedirectType {
+	case RedirectInternal:
+		action.Type, action.StatusCode = ServeObject, http.StatusOK
+
+	case RedirectMirror:
+		action.Type = Mirror
+		action.MirrorURL = strings.TrimSuffix(r.MirrorURL, "/") + "/" + escapeKey(newKey)
+		if isTrue(r.MirrorPassQueryString) && u.RawQuery != "" {
+			action.MirrorURL += "?" + u.RawQuery
+		}
+		action.MirrorHeaders = mirrorHeaders(r.MirrorHeaders, headers)
+
+	default:
+		action.Type = Redirect
+		action.StatusCode = r.HttpRedirectCode
+		if action.StatusCode == 0 {
+			action.StatusCode = http.StatusFound
+		}
+		scheme, host := r.Protocol, r.HostName
+		if scheme == "" {
+			scheme = u.Scheme
+		}
+		if scheme == "" {
+			scheme = "http"
+		}
+		if host == "" {
+			host = u.Host
+		}
+		action.Location = "/" + escapeKey(newKey)
+		if host != "" {
+			action.Location = scheme + "://" + host + action.Location
+		}
+		if isTrue(r.PassQueryString) && u.RawQuery != "" {
+			action.Location += "?" + u.RawQuery
+		}
+	}
+}
+
+// rewriteKey rewrites the key by ReplaceKeyWith, in which ${key} is the original key, or ReplaceKeyPrefixWith,
+// which replaces the KeyPrefixEquals of the condition
+func rewriteKey(r ks3.Redirect, prefix, key string) string {
+	if r.ReplaceKeyWith != "" {
+		return strings.Replace(r.ReplaceKeyWith, "${key}", key, -1)
+	}
+	if r.ReplaceKeyPrefixWith != "" {
+		return r.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+	return key
+}
+
+// mirrorHeaders resolves the headers sent to the origin: all the headers if PassAll, otherwise the ones of Pass,
+// without the ones of Remove, and then the ones of Set
+func mirrorHeaders(m ks3.MirrorHeaders, headers http.Header) http.Header {
+	result := http.Header{}
+	if isTrue(m.PassAll) {
+		for k, v := range headers {
+			result[k] = append([]string(nil), v...)
+		}
+	} else {
+		for _, k := range m.Pass {
+			if v, ok := headers[http.CanonicalHeaderKey(k)]; ok {
+				result[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+			}
+		}
+	}
+	for _, k := range m.Remove {
+		result.Del(k)
+	}
+	for _, s := range m.Set {
+		result.Set(s.Key, s.Value)
+	}
+	return result
+}
+
+// escapeKey escapes the key in the URL path, the slashes are kept
+func escapeKey(key string) string {
+	return (&url.URL{Path: key}).EscapedPath()
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
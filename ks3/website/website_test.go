@@ -0,0 +1,145 @@
+package website
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ks3sdklib/ksyun-ks3-go-sdk/ks3"
+	. "gopkg.in/check.v1"
+)
+
+// Test hooks up gocheck into the "go test" runner.
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type Ks3WebsiteSuite struct{}
+
+var _ = Suite(&Ks3WebsiteSuite{})
+
+func simulate(c *C, config ks3.WebsiteXML, req Request) *Action {
+	action, err := Simulate(config, req)
+	c.Assert(err, IsNil)
+	return action
+}
+
+func (s *Ks3WebsiteSuite) TestDocuments(c *C) {
+	config := ks3.WebsiteXML{
+		IndexDocument: ks3.IndexDocument{Suffix: "index.html"},
+		ErrorDocument: ks3.ErrorDocument{Key: "error.html"},
+	}
+
+	action := simulate(c, config, Request{URL: "/"})
+	c.Assert(*action, DeepEquals, Action{Type: ServeIndex, Key: "index.html", StatusCode: 200})
+	action = simulate(c, config, Request{URL: "http://bucket.ks3-cn-beijing.ksyuncs.com/docs/"})
+	c.Assert(*action, DeepEquals, Action{Type: ServeIndex, Key: "docs/index.html", StatusCode: 200})
+	action = simulate(c, config, Request{URL: "/docs/a%20b.html?x=1"})
+	c.Assert(*action, DeepEquals, Action{Type: ServeObject, Key: "docs/a b.html", StatusCode: 200})
+
+	action = simulate(c, config, Request{URL: "/missing.html", StatusCode: 404})
+	c.Assert(*action, DeepEquals, Action{Type: ServeError, Key: "error.html", StatusCode: 404})
+	action = simulate(c, config, Request{URL: "/private.html", StatusCode: 403})
+	c.Assert(*action, DeepEquals, Action{Type: ServeError, StatusCode: 403})
+
+	_, err := Simulate(config, Request{URL: "http://[::1"})
+	c.Assert(err, NotNil)
+}
+
+func (s *Ks3WebsiteSuite) TestRedirect(c *C) {
+	pass := true
+	config := ks3.WebsiteXML{
+		IndexDocument: ks3.IndexDocument{Suffix: "index.html"},
+		RoutingRules: []ks3.RoutingRule{{
+			RuleNumber: 3,
+			Condition:  ks3.Condition{KeyPrefixEquals: "old/"},
+			Redirect:   ks3.Redirect{RedirectType: RedirectInternal, ReplaceKeyPrefixWith: "new/"},
+		}, {
+			RuleNumber: 1,
+			Condition:  ks3.Condition{KeyPrefixEquals: "old/", IncludeHeader: []ks3.IncludeHeader{{Key: "x-mobile", Equals: "1"}}},
+			Redirect: ks3.Redirect{
+				RedirectType:     RedirectExternal,
+				Protocol:         "https",
+				HostName:         "m.example.com",
+				ReplaceKeyWith:   "mobile/${key}",
+				HttpRedirectCode: 301,
+				PassQueryString:  &pass,
+			},
+		}, {
+			RuleNumber: 2,
+			Condition:  ks3.Condition{KeyPrefixEquals: "docs/", HTTPErrorCodeReturnedEquals: 404},
+			Redirect:   ks3.Redirect{RedirectType: RedirectExternal},
+		}},
+	}
+
+	// The rules are evaluated by the rule numbers
+	action := simulate(c, config, Request{
+		URL:     "http://bucket.example.com/old/a.html?lang=en",
+		Headers: http.Header{"X-Mobile": {"1"}},
+	})
+	c.Assert(*action, DeepEquals, Action{
+		Type:       Redirect,
+		RuleNumber: 1,
+		Key:        "mobile/old/a.html",
+		StatusCode: 301,
+		Location:   "https://m.example.com/mobile/old/a.html?lang=en",
+	})
+
+	action = simulate(c, config, Request{URL: "/old/dir/", Headers: http.Header{"X-Mobile": {"0"}}})
+	c.Assert(*action, DeepEquals, Action{
+		Type:       ServeObject,
+		RuleNumber: 3,
+		Key:        "new/dir/index.html",
+		StatusCode: 200,
+		Reasons: []string{
+			`rule 1: the header x-mobile isn't "1"`,
+			`rule 2: the key "old/dir/index.html" doesn't start with "docs/"`,
+		},
+	})
+
+	// The rule of the error code only applies when the object is missing, the host and the scheme are kept
+	action = simulate(c, config, Request{URL: "http://bucket.example.com/docs/a.html?lang=en"})
+	c.Assert(action.Type, Equals, ServeObject)
+	c.Assert(action.Reasons[1], Equals, "rule 2: the status 200 isn't 404")
+	action = simulate(c, config, Request{URL: "http://bucket.example.com/docs/a b.html?lang=en", StatusCode: 404})
+	c.Assert(action.Type, Equals, Redirect)
+	c.Assert(action.StatusCode, Equals, 302)
+	c.Assert(action.Location, Equals, "http://bucket.example.com/docs/a%20b.html")
+}
+
+func (s *Ks3WebsiteSuite) TestMirror(c *C) {
+	pass, passAll := true, false
+	config := ks3.WebsiteXML{
+		RoutingRules: []ks3.RoutingRule{{
+			RuleNumber: 1,
+			Condition:  ks3.Condition{KeyPrefixEquals: "img/", HTTPErrorCodeReturnedEquals: 404},
+			Redirect: ks3.Redirect{
+				RedirectType:          RedirectMirror,
+				MirrorURL:             "https://origin.example.com/static/",
+				MirrorPassQueryString: &pass,
+				ReplaceKeyPrefixWith:  "images/",
+				MirrorHeaders: ks3.MirrorHeaders{
+					PassAll: &passAll,
+					Pass:    []string{"user-agent", "x-token", "x-missing"},
+					Remove:  []string{"x-token"},
+					Set:     []ks3.MirrorHeaderSet{{Key: "x-from", Value: "ks3"}},
+				},
+			},
+		}},
+	}
+	headers := http.Header{"User-Agent": {"curl"}, "X-Token": {"secret"}, "Cookie": {"a=1"}}
+
+	action := simulate(c, config, Request{URL: "/img/a.png?w=100", Headers: headers, StatusCode: 404})
+	c.Assert(*action, DeepEquals, Action{
+		Type:          Mirror,
+		RuleNumber:    1,
+		Key:           "images/a.png",
+		MirrorURL:     "https://origin.example.com/static/images/a.png?w=100",
+		MirrorHeaders: http.Header{"User-Agent": {"curl"}, "X-From": {"ks3"}},
+	})
+
+	// Pass all the headers except the removed ones
+	passAll = true
+	action = simulate(c, config, Request{URL: "/img/a.png", Headers: headers, StatusCode: 404})
+	c.Assert(action.MirrorURL, Equals, "https://origin.example.com/static/images/a.png")
+	c.Assert(action.MirrorHeaders, DeepEquals, http.Header{"User-Agent": {"curl"}, "Cookie": {"a=1"}, "X-From": {"ks3"}})
+}